@@ -0,0 +1,28 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// setupExcludeStatements - Compiles the exclude_statements patterns once, so they can be
+// reused for every pg_stat_statements entry
+func setupExcludeStatements(config *ServerConfig) error {
+	config.excludeStatementRegexps = nil
+	for _, pattern := range config.ExcludeStatements {
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("Invalid exclude_statements pattern in section %s: %s", config.SectionName, err)
+		}
+		config.excludeStatementRegexps = append(config.excludeStatementRegexps, re)
+	}
+	return nil
+}
+
+// ExcludeStatementRegexps - Compiled exclude_statements patterns
+func (config ServerConfig) ExcludeStatementRegexps() []*regexp.Regexp {
+	return config.excludeStatementRegexps
+}
@@ -0,0 +1,42 @@
+package config
+
+import "testing"
+
+var setupExcludeStatementsTests = []struct {
+	patterns []string
+	expected []string
+	err      bool
+}{
+	{nil, nil, false},
+	{[]string{"^SELECT 1$", "pg_sleep"}, []string{"^SELECT 1$", "pg_sleep"}, false},
+	{[]string{"", "pg_sleep", ""}, []string{"pg_sleep"}, false},
+	{[]string{"pg_sleep", "("}, nil, true},
+}
+
+func TestSetupExcludeStatements(t *testing.T) {
+	for _, test := range setupExcludeStatementsTests {
+		config := ServerConfig{SectionName: "server1", ExcludeStatements: test.patterns}
+		err := setupExcludeStatements(&config)
+		if (err != nil) != test.err {
+			t.Errorf("Patterns %q: expected error %v, actual %v", test.patterns, test.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		var actual []string
+		for _, re := range config.ExcludeStatementRegexps() {
+			actual = append(actual, re.String())
+		}
+		if len(actual) != len(test.expected) {
+			t.Errorf("Patterns %q: expected compiled %q, actual %q", test.patterns, test.expected, actual)
+			continue
+		}
+		for idx := range actual {
+			if actual[idx] != test.expected[idx] {
+				t.Errorf("Patterns %q: expected compiled %q, actual %q", test.patterns, test.expected, actual)
+			}
+		}
+	}
+}
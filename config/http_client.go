@@ -0,0 +1,81 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pganalyze/collector/util"
+)
+
+// HTTPClient - Returns the HTTP client to use for requests to the pganalyze API and S3
+// for this server (e.g. grant requests and snapshot uploads)
+func (config ServerConfig) HTTPClient() *http.Client {
+	if config.httpClient == nil {
+		return http.DefaultClient
+	}
+	return config.httpClient
+}
+
+// setupHTTPClient - Creates the HTTP client for this server, based on its settings
+//
+// Without an explicit http_proxy setting this uses the standard HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY environment variables. HTTPS requests are tunneled through the proxy using CONNECT.
+func setupHTTPClient(config *ServerConfig, logger *util.Logger) error {
+	proxy := http.ProxyFromEnvironment
+
+	if config.HTTPProxy != "" {
+		proxyURL, err := url.Parse(config.HTTPProxy)
+		if err != nil || proxyURL.Host == "" {
+			return fmt.Errorf("Invalid http_proxy setting in section %s: %s", config.SectionName, config.HTTPProxy)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{}
+
+	if config.HTTPCaBundle != "" {
+		pem, err := ioutil.ReadFile(config.HTTPCaBundle)
+		if err != nil {
+			return fmt.Errorf("Could not read http_ca_bundle in section %s: %s", config.SectionName, err)
+		}
+
+		// Add to the system roots, so that regular certificates continue to work
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil || rootCAs == nil {
+			rootCAs = x509.NewCertPool()
+		}
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("No valid PEM certificates found in http_ca_bundle %s (section %s)", config.HTTPCaBundle, config.SectionName)
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	if config.HTTPInsecureSkipVerify {
+		logger.PrintWarning("WARNING: http_insecure_skip_verify is enabled for section %s - TLS certificates for the pganalyze API and S3 are NOT verified. Never use this in production!", config.SectionName)
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	// Same settings as http.DefaultTransport, except for the proxy and TLS configuration
+	transport := &http.Transport{
+		Proxy:           proxy,
+		TLSClientConfig: tlsConfig,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	config.httpClient = &http.Client{Transport: transport}
+
+	return nil
+}
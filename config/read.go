@@ -17,12 +17,20 @@ func getDefaultConfig() *ServerConfig {
 		APIBaseURL:  "https://api.pganalyze.com",
 		AwsRegion:   "us-east-1",
 		SectionName: "default",
-	}
 
-	// The environment variables are the default way to configure when running inside a Docker container.
-	if apiKey := os.Getenv("PGA_API_KEY"); apiKey != "" {
-		config.APIKey = apiKey
+		LockTimeoutMs:            5000,
+		SequenceWarningThreshold: 75,
+		CollectionDeadlineSecs:   540,
+		S3MultipartThresholdMb:   100,
+
+		StatisticsOverridesMinTableSizeMb: 100,
+		LongRunningQueryThresholdSecs:     60,
+		AdvisoryLockThresholdSecs:         300,
+		CircuitBreakerCooldownSecs:        1800,
 	}
+
+	// The environment variables are the default way to configure when running inside a Docker container,
+	// see overrideFromEnvironment for the ones that take precedence over the config file.
 	if apiBaseURL := os.Getenv("PGA_API_BASEURL"); apiBaseURL != "" {
 		config.APIBaseURL = apiBaseURL
 	}
@@ -44,6 +52,146 @@ func getDefaultConfig() *ServerConfig {
 	if enableActivity := os.Getenv("PGA_ENABLE_ACTIVITY"); enableActivity != "" && enableActivity != "0" {
 		config.EnableActivity = true
 	}
+	if collectorInstance := os.Getenv("PGA_COLLECTOR_INSTANCE"); collectorInstance != "" {
+		config.CollectorInstance = collectorInstance
+	}
+	if coordinateCollectors := os.Getenv("PGA_COORDINATE_COLLECTORS"); coordinateCollectors != "" && coordinateCollectors != "0" {
+		config.CoordinateCollectors = true
+	}
+	if collectFromStandby := os.Getenv("PGA_COLLECT_FROM_STANDBY"); collectFromStandby != "" && collectFromStandby != "0" {
+		config.CollectFromStandby = true
+	}
+	if primarySystemID := os.Getenv("PGA_PRIMARY_SYSTEM_ID"); primarySystemID != "" {
+		config.PrimarySystemID = primarySystemID
+	}
+	if disableVersionCheck := os.Getenv("PGA_DISABLE_VERSION_CHECK"); disableVersionCheck != "" && disableVersionCheck != "0" {
+		config.DisableVersionCheck = true
+	}
+	if dbAllowNames := os.Getenv("DB_ALLOW_NAMES"); dbAllowNames != "" {
+		config.DbAllowNames = splitNameList(dbAllowNames)
+	}
+	if dbDenyNames := os.Getenv("DB_DENY_NAMES"); dbDenyNames != "" {
+		config.DbDenyNames = splitNameList(dbDenyNames)
+	}
+	if dbUseIamAuth := os.Getenv("DB_USE_IAM_AUTH"); dbUseIamAuth != "" && dbUseIamAuth != "0" {
+		config.DbUseIamAuth = true
+	}
+	if excludeStatements := os.Getenv("PGA_EXCLUDE_STATEMENTS"); excludeStatements != "" {
+		config.ExcludeStatements = strings.Split(excludeStatements, ";")
+	}
+	if patroniURL := os.Getenv("PGA_PATRONI_URL"); patroniURL != "" {
+		config.PatroniURL = patroniURL
+	}
+	if excludeStatementsUsers := os.Getenv("PGA_EXCLUDE_STATEMENTS_USERS"); excludeStatementsUsers != "" {
+		config.ExcludeStatementsUsers = strings.Split(excludeStatementsUsers, ",")
+	}
+	if maxStatements := os.Getenv("PGA_MAX_STATEMENTS"); maxStatements != "" {
+		config.MaxStatements, _ = strconv.Atoi(maxStatements)
+	}
+	if maxStatementsRankBy := os.Getenv("PGA_MAX_STATEMENTS_RANK_BY"); maxStatementsRankBy != "" {
+		config.MaxStatementsRankBy = maxStatementsRankBy
+	}
+	if waitEventSampleInterval := os.Getenv("PGA_WAIT_EVENT_SAMPLE_INTERVAL"); waitEventSampleInterval != "" {
+		config.WaitEventSampleInterval, _ = strconv.Atoi(waitEventSampleInterval)
+	}
+	if httpProxy := os.Getenv("PGA_HTTP_PROXY"); httpProxy != "" {
+		config.HTTPProxy = httpProxy
+	}
+	if httpCaBundle := os.Getenv("PGA_HTTP_CA_BUNDLE"); httpCaBundle != "" {
+		config.HTTPCaBundle = httpCaBundle
+	}
+	if httpInsecureSkipVerify := os.Getenv("PGA_HTTP_INSECURE_SKIP_VERIFY"); httpInsecureSkipVerify != "" && httpInsecureSkipVerify != "0" {
+		config.HTTPInsecureSkipVerify = true
+	}
+	if pgbouncerURL := os.Getenv("PGA_PGBOUNCER_URL"); pgbouncerURL != "" {
+		config.PgbouncerURL = pgbouncerURL
+	}
+	if logLevel := os.Getenv("PGA_LOG_LEVEL"); logLevel != "" {
+		config.LogLevel = logLevel
+	}
+	if outputType := os.Getenv("PGA_OUTPUT_TYPE"); outputType != "" {
+		config.OutputType = outputType
+	}
+	if outputURL := os.Getenv("PGA_OUTPUT_URL"); outputURL != "" {
+		config.OutputURL = outputURL
+	}
+	if outputDirectory := os.Getenv("PGA_OUTPUT_DIRECTORY"); outputDirectory != "" {
+		config.OutputDirectory = outputDirectory
+	}
+	if maxPayloadSizeMb := os.Getenv("PGA_MAX_PAYLOAD_SIZE_MB"); maxPayloadSizeMb != "" {
+		config.MaxPayloadSizeMb, _ = strconv.Atoi(maxPayloadSizeMb)
+	}
+	if s3MultipartThresholdMb := os.Getenv("PGA_S3_MULTIPART_THRESHOLD_MB"); s3MultipartThresholdMb != "" {
+		config.S3MultipartThresholdMb, _ = strconv.Atoi(s3MultipartThresholdMb)
+	}
+	if collectionDeadlineSecs := os.Getenv("PGA_COLLECTION_DEADLINE_SECS"); collectionDeadlineSecs != "" {
+		config.CollectionDeadlineSecs, _ = strconv.Atoi(collectionDeadlineSecs)
+	}
+	if sequenceWarningThreshold := os.Getenv("PGA_SEQUENCE_WARNING_THRESHOLD"); sequenceWarningThreshold != "" {
+		config.SequenceWarningThreshold, _ = strconv.ParseFloat(sequenceWarningThreshold, 64)
+	}
+	if statisticsOverridesMinTableSizeMb := os.Getenv("PGA_STATISTICS_OVERRIDES_MIN_TABLE_SIZE_MB"); statisticsOverridesMinTableSizeMb != "" {
+		config.StatisticsOverridesMinTableSizeMb, _ = strconv.Atoi(statisticsOverridesMinTableSizeMb)
+	}
+	if circuitBreakerActiveBackendsPercent := os.Getenv("PGA_CIRCUIT_BREAKER_ACTIVE_BACKENDS_PERCENT"); circuitBreakerActiveBackendsPercent != "" {
+		config.CircuitBreakerActiveBackendsPercent, _ = strconv.Atoi(circuitBreakerActiveBackendsPercent)
+	}
+	if circuitBreakerLatencyMs := os.Getenv("PGA_CIRCUIT_BREAKER_LATENCY_MS"); circuitBreakerLatencyMs != "" {
+		config.CircuitBreakerLatencyMs, _ = strconv.Atoi(circuitBreakerLatencyMs)
+	}
+	if circuitBreakerCooldownSecs := os.Getenv("PGA_CIRCUIT_BREAKER_COOLDOWN_SECS"); circuitBreakerCooldownSecs != "" {
+		config.CircuitBreakerCooldownSecs, _ = strconv.Atoi(circuitBreakerCooldownSecs)
+	}
+	if longRunningQueryThresholdSecs := os.Getenv("PGA_LONG_RUNNING_QUERY_THRESHOLD_SECS"); longRunningQueryThresholdSecs != "" {
+		config.LongRunningQueryThresholdSecs, _ = strconv.Atoi(longRunningQueryThresholdSecs)
+	}
+	if advisoryLockThresholdSecs := os.Getenv("PGA_ADVISORY_LOCK_THRESHOLD_SECS"); advisoryLockThresholdSecs != "" {
+		config.AdvisoryLockThresholdSecs, _ = strconv.Atoi(advisoryLockThresholdSecs)
+	}
+	if scheduleSplaySecs := os.Getenv("PGA_SCHEDULE_SPLAY_SECS"); scheduleSplaySecs != "" {
+		config.ScheduleSplaySecs, _ = strconv.Atoi(scheduleSplaySecs)
+	}
+	if lockTimeoutMs := os.Getenv("PGA_LOCK_TIMEOUT_MS"); lockTimeoutMs != "" {
+		config.LockTimeoutMs, _ = strconv.Atoi(lockTimeoutMs)
+	}
+	if awsRegion := os.Getenv("AWS_REGION"); awsRegion != "" {
+		config.AwsRegion = awsRegion
+	}
+	if awsInstanceID := os.Getenv("AWS_INSTANCE_ID"); awsInstanceID != "" {
+		config.AwsDbInstanceID = awsInstanceID
+	}
+	if awsAccessKeyID := os.Getenv("AWS_ACCESS_KEY_ID"); awsAccessKeyID != "" {
+		config.AwsAccessKeyID = awsAccessKeyID
+	}
+	if awsSecretAccessKey := os.Getenv("AWS_SECRET_ACCESS_KEY"); awsSecretAccessKey != "" {
+		config.AwsSecretAccessKey = awsSecretAccessKey
+	}
+
+	overrideFromEnvironment(config)
+
+	return config
+}
+
+// Environment variables for the API key and connection settings, these take precedence over
+// the config file, so that secrets like DB_PASSWORD never need to be written to a file
+var overrideEnvironmentVariables = []string{
+	"PGA_API_KEY", "DB_URL", "DB_NAME", "DB_ALL_NAMES", "DB_USERNAME", "DB_PASSWORD",
+	"DB_HOST", "DB_PORT", "DB_SSLMODE", "DB_SSLROOTCERT", "DB_SSLROOTCERT_CONTENTS",
+}
+
+func hasEnvironmentOverrides() bool {
+	for _, name := range overrideEnvironmentVariables {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func overrideFromEnvironment(config *ServerConfig) {
+	if apiKey := os.Getenv("PGA_API_KEY"); apiKey != "" {
+		config.APIKey = apiKey
+	}
 	if dbURL := os.Getenv("DB_URL"); dbURL != "" {
 		config.DbURL = dbURL
 	}
@@ -74,20 +222,72 @@ func getDefaultConfig() *ServerConfig {
 	if dbSslRootCertContents := os.Getenv("DB_SSLROOTCERT_CONTENTS"); dbSslRootCertContents != "" {
 		config.DbSslRootCertContents = dbSslRootCertContents
 	}
-	if awsRegion := os.Getenv("AWS_REGION"); awsRegion != "" {
-		config.AwsRegion = awsRegion
+	for _, env := range os.Environ() {
+		keyValue := strings.SplitN(env, "=", 2)
+		if len(keyValue) == 2 && strings.HasPrefix(keyValue[0], "DB_PARAM_") && keyValue[1] != "" {
+			if config.DbConnectionParams == nil {
+				config.DbConnectionParams = make(map[string]string)
+			}
+			config.DbConnectionParams[strings.ToLower(strings.TrimPrefix(keyValue[0], "DB_PARAM_"))] = keyValue[1]
+		}
 	}
-	if awsInstanceID := os.Getenv("AWS_INSTANCE_ID"); awsInstanceID != "" {
-		config.AwsDbInstanceID = awsInstanceID
+}
+
+// readConnectionParams - Returns the db_param_<name> settings of the section, merged into (a copy of) the defaults
+func readConnectionParams(section *ini.Section, defaults map[string]string) map[string]string {
+	params := make(map[string]string)
+	for key, value := range defaults {
+		params[key] = value
 	}
-	if awsAccessKeyID := os.Getenv("AWS_ACCESS_KEY_ID"); awsAccessKeyID != "" {
-		config.AwsAccessKeyID = awsAccessKeyID
+	for _, key := range section.Keys() {
+		if strings.HasPrefix(key.Name(), "db_param_") {
+			params[strings.TrimPrefix(key.Name(), "db_param_")] = key.Value()
+		}
 	}
-	if awsSecretAccessKey := os.Getenv("AWS_SECRET_ACCESS_KEY"); awsSecretAccessKey != "" {
-		config.AwsSecretAccessKey = awsSecretAccessKey
+	return params
+}
+
+func splitNameList(list string) []string {
+	names := []string{}
+	for _, s := range strings.Split(list, ",") {
+		names = append(names, strings.TrimSpace(s))
 	}
+	return names
+}
 
-	return config
+// finalizeServerConfig - Expands settings that need to be processed after reading the config
+func finalizeServerConfig(config *ServerConfig) error {
+	dbNameParts := splitNameList(config.DbName)
+	config.DbName = dbNameParts[0]
+	if len(dbNameParts) == 2 && dbNameParts[1] == "*" {
+		config.DbAllNames = true
+	} else {
+		config.DbExtraNames = dbNameParts[1:]
+	}
+
+	if config.DbSslRootCertContents != "" {
+		sslRootTmpFile, err := ioutil.TempFile("", "")
+		if err != nil {
+			return err
+		}
+		_, err = sslRootTmpFile.WriteString(config.DbSslRootCertContents)
+		if err != nil {
+			return err
+		}
+		err = sslRootTmpFile.Close()
+		if err != nil {
+			return err
+		}
+		config.DbSslRootCert = sslRootTmpFile.Name()
+	}
+
+	config.SystemType, config.SystemScope, config.SystemID = identifySystem(*config)
+	if config.CollectFromStandby && config.PrimarySystemID != "" {
+		config.StandbySystemID = config.SystemID
+		config.SystemID = config.PrimarySystemID
+	}
+
+	return nil
 }
 
 // Read - Reads the configuration from the specified filename, or fall back to the default config
@@ -107,8 +307,24 @@ func Read(logger *util.Logger, filename string) (Config, error) {
 		if err != nil {
 			logger.PrintVerbose("Failed to map pganalyze section: %s", err)
 		}
+		defaultConfig.DbConnectionParams = readConnectionParams(configFile.Section("pganalyze"), defaultConfig.DbConnectionParams)
 
 		sections := configFile.Sections()
+
+		// Environment overrides can only refer to a single server, if the file defines
+		// multiple servers they only act as defaults (see getDefaultConfig), and the
+		// settings in the file take precedence
+		serverSectionCount := 0
+		for _, section := range sections {
+			if section.Name() != ini.DEFAULT_SECTION && section.Name() != "pganalyze" {
+				serverSectionCount++
+			}
+		}
+		useEnvironmentOverrides := serverSectionCount <= 1
+		if !useEnvironmentOverrides && hasEnvironmentOverrides() {
+			logger.PrintWarning("%s environment variables don't override the settings in %s, since it defines multiple servers - they are only used as defaults for settings missing from the file", strings.Join(overrideEnvironmentVariables, "/"), filename)
+		}
+
 		for _, section := range sections {
 			config := &ServerConfig{}
 			*config = *defaultConfig
@@ -117,36 +333,17 @@ func Read(logger *util.Logger, filename string) (Config, error) {
 			if err != nil {
 				return conf, err
 			}
+			config.DbConnectionParams = readConnectionParams(section, config.DbConnectionParams)
 
-			dbNameParts := []string{}
-			for _, s := range strings.Split(config.DbName, ",") {
-				dbNameParts = append(dbNameParts, strings.TrimSpace(s))
-			}
-			config.DbName = dbNameParts[0]
-			if len(dbNameParts) == 2 && dbNameParts[1] == "*" {
-				config.DbAllNames = true
-			} else {
-				config.DbExtraNames = dbNameParts[1:]
-			}
-
-			if config.DbSslRootCertContents != "" {
-				sslRootTmpFile, err := ioutil.TempFile("", "")
-				if err != nil {
-					return conf, err
-				}
-				_, err = sslRootTmpFile.WriteString(config.DbSslRootCertContents)
-				if err != nil {
-					return conf, err
-				}
-				err = sslRootTmpFile.Close()
-				if err != nil {
-					return conf, err
-				}
-				config.DbSslRootCert = sslRootTmpFile.Name()
+			if useEnvironmentOverrides {
+				overrideFromEnvironment(config)
 			}
 
 			config.SectionName = section.Name()
-			config.SystemType, config.SystemScope, config.SystemID = identifySystem(*config)
+			err = finalizeServerConfig(config)
+			if err != nil {
+				return conf, err
+			}
 
 			if config.GetDbName() != "" {
 				// Ensure we have no duplicate System Type+Scope+ID within one collector
@@ -174,12 +371,39 @@ func Read(logger *util.Logger, filename string) (Config, error) {
 			conf = handleHeroku()
 		} else if os.Getenv("PGA_API_KEY") != "" {
 			config := getDefaultConfig()
-			config.SystemType, config.SystemScope, config.SystemID = identifySystem(*config)
+			err = finalizeServerConfig(config)
+			if err != nil {
+				return conf, err
+			}
 			conf.Servers = append(conf.Servers, *config)
 		} else {
 			return conf, fmt.Errorf("No configuration file found at %s, and no environment variables set", filename)
 		}
 	}
 
+	for idx := range conf.Servers {
+		if conf.Servers[idx].LogLevel != "" {
+			_, err = util.ParseLogLevel(conf.Servers[idx].LogLevel)
+			if err != nil {
+				return conf, fmt.Errorf("Invalid log_level in section %s: %s", conf.Servers[idx].SectionName, err)
+			}
+		}
+
+		_, err = conf.Servers[idx].parseDbURL()
+		if err != nil {
+			return conf, fmt.Errorf("%s in section %s", err, conf.Servers[idx].SectionName)
+		}
+
+		err = setupHTTPClient(&conf.Servers[idx], logger)
+		if err != nil {
+			return conf, err
+		}
+
+		err = setupExcludeStatements(&conf.Servers[idx])
+		if err != nil {
+			return conf, err
+		}
+	}
+
 	return conf, nil
 }
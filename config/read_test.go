@@ -0,0 +1,105 @@
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pganalyze/collector/util"
+)
+
+var readTests = []struct {
+	file     string
+	env      map[string]string
+	expected []string // SectionName/DbHost/DbName/DbPassword for each server
+}{
+	// The environment takes precedence over the file for a single server
+	{
+		"[pganalyze]\napi_key = filekey\n\n[server1]\ndb_host = filehost\ndb_name = filedb\n",
+		map[string]string{"DB_HOST": "envhost", "DB_PASSWORD": "envpassword"},
+		[]string{"server1/envhost/filedb/envpassword"},
+	},
+	// Multiple servers keep their own settings, the environment only provides defaults
+	{
+		"[pganalyze]\napi_key = filekey\n\n[server1]\ndb_host = host1\ndb_name = db1\n\n[server2]\ndb_host = host2\ndb_name = db2\n",
+		map[string]string{"DB_HOST": "envhost", "DB_PASSWORD": "envpassword"},
+		[]string{"server1/host1/db1/envpassword", "server2/host2/db2/envpassword"},
+	},
+	// Environment variables only, with no config file
+	{
+		"",
+		map[string]string{"PGA_API_KEY": "envkey", "DB_HOST": "envhost", "DB_NAME": "db1, db2", "DB_PASSWORD": "envpassword"},
+		[]string{"default/envhost/db1/envpassword"},
+	},
+}
+
+func TestRead(t *testing.T) {
+	logger := &util.Logger{Destination: log.New(&bytes.Buffer{}, "", 0)}
+
+	for i, test := range readTests {
+		filename := "/nonexistent/pganalyze_collector.conf"
+		if test.file != "" {
+			f, err := ioutil.TempFile("", "pganalyze_collector_test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(f.Name())
+			f.WriteString(test.file)
+			f.Close()
+			filename = f.Name()
+		}
+		for name, value := range test.env {
+			os.Setenv(name, value)
+		}
+
+		conf, err := Read(logger, filename)
+
+		for name := range test.env {
+			os.Unsetenv(name)
+		}
+		if err != nil {
+			t.Errorf("Test %d: unexpected error: %s", i, err)
+			continue
+		}
+
+		var actual []string
+		for _, server := range conf.Servers {
+			actual = append(actual, server.SectionName+"/"+server.DbHost+"/"+server.DbName+"/"+server.DbPassword)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("Test %d:\n\texpected %v\n\tactual %v\n", i, test.expected, actual)
+		}
+	}
+}
+
+func TestReadMultipleServersEnvironmentWarning(t *testing.T) {
+	var logOutput bytes.Buffer
+	logger := &util.Logger{Destination: log.New(&logOutput, "", 0)}
+
+	f, err := ioutil.TempFile("", "pganalyze_collector_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("[pganalyze]\napi_key = filekey\n\n[server1]\ndb_host = host1\ndb_name = db1\n\n[server2]\ndb_name = db2\n")
+	f.Close()
+
+	os.Setenv("DB_HOST", "envhost")
+	conf, err := Read(logger, f.Name())
+	os.Unsetenv("DB_HOST")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The host set in the file is kept, the one missing from the file comes from the environment
+	if len(conf.Servers) != 2 || conf.Servers[0].DbHost != "host1" || conf.Servers[1].DbHost != "envhost" {
+		t.Errorf("Unexpected servers: %+v", conf.Servers)
+	}
+	if !strings.Contains(logOutput.String(), "only used as defaults") {
+		t.Errorf("Expected warning that the environment variables act as defaults, got: %s", logOutput.String())
+	}
+}
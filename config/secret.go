@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const secretFilePrefix = "file://"
+const secretEnvPrefix = "env://"
+
+// resolveSecret - Reads the current value of a secret reference, either file:///path/to/secret
+// or env://VAR_NAME, and returns any other value as-is
+func resolveSecret(value string) (string, error) {
+	if strings.HasPrefix(value, secretFilePrefix) {
+		path := strings.TrimPrefix(value, secretFilePrefix)
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("could not read secret file: %s", err)
+		}
+		// Secret files commonly end with a newline that isn't part of the secret
+		return strings.TrimRight(string(contents), "\r\n"), nil
+	}
+
+	if strings.HasPrefix(value, secretEnvPrefix) {
+		name := strings.TrimPrefix(value, secretEnvPrefix)
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("secret environment variable %s is not set", name)
+		}
+		return secret, nil
+	}
+
+	return value, nil
+}
+
+// GetDbPassword - Gets the database password from the given configuration, resolving
+// file:// and env:// references to their current value
+func (config ServerConfig) GetDbPassword() (string, error) {
+	return resolveSecret(config.DbPassword)
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestResolveSecret(t *testing.T) {
+	f, err := ioutil.TempFile("", "pganalyze_collector_secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("filesecret\n")
+	f.Close()
+
+	os.Setenv("PGA_TEST_SECRET", "envsecret")
+	defer os.Unsetenv("PGA_TEST_SECRET")
+
+	tests := []struct {
+		value       string
+		expected    string
+		expectError bool
+	}{
+		{"literal", "literal", false},
+		{"", "", false},
+		{"file://" + f.Name(), "filesecret", false},
+		{"file:///nonexistent/secret", "", true},
+		{"env://PGA_TEST_SECRET", "envsecret", false},
+		{"env://PGA_TEST_SECRET_MISSING", "", true},
+	}
+
+	for _, test := range tests {
+		actual, err := resolveSecret(test.value)
+		if (err != nil) != test.expectError {
+			t.Errorf("resolveSecret(%q): unexpected error state: %v", test.value, err)
+		}
+		if actual != test.expected {
+			t.Errorf("resolveSecret(%q):\n\texpected %q\n\tactual %q\n", test.value, test.expected, actual)
+		}
+	}
+}
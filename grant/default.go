@@ -11,19 +11,28 @@ import (
 )
 
 func GetDefaultGrant(server state.Server, globalCollectionOpts state.CollectionOpts, logger *util.Logger) (state.Grant, error) {
-	req, err := http.NewRequest("GET", server.Config.APIBaseURL+"/v2/snapshots/grant", nil)
-	if err != nil {
-		return state.Grant{}, err
-	}
-
-	req.Header.Set("Pganalyze-Api-Key", server.Config.APIKey)
-	req.Header.Set("Pganalyze-System-Id", server.Config.SystemID)
-	req.Header.Set("Pganalyze-System-Type", server.Config.SystemType)
-	req.Header.Set("Pganalyze-System-Scope", server.Config.SystemScope)
-	req.Header.Set("User-Agent", util.CollectorNameAndVersion)
-	req.Header.Add("Accept", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := util.DoWithRetry(server.Config.HTTPClient(), util.DefaultHTTPRetryPolicy, logger, func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", server.Config.APIBaseURL+"/v2/snapshots/grant", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Pganalyze-Api-Key", server.Config.APIKey)
+		req.Header.Set("Pganalyze-System-Id", server.Config.SystemID)
+		req.Header.Set("Pganalyze-System-Type", server.Config.SystemType)
+		req.Header.Set("Pganalyze-System-Scope", server.Config.SystemScope)
+		req.Header.Set("User-Agent", util.CollectorNameAndVersion)
+		req.Header.Add("Accept", "application/json")
+
+		// Lets the API include presigned multipart upload requests in the grant (for large snapshots),
+		// and create that upload with the encoding of our snapshot data (zlib-compressed protocol buffers)
+		if server.Config.S3MultipartThresholdMb > 0 {
+			req.Header.Set("Pganalyze-Upload-Capabilities", "multipart")
+			req.Header.Set("Pganalyze-Snapshot-Encoding", "deflate")
+		}
+
+		return req, nil
+	})
 	if err != nil {
 		return state.Grant{}, err
 	}
@@ -33,6 +42,11 @@ func GetDefaultGrant(server state.Server, globalCollectionOpts state.CollectionO
 	if err != nil {
 		return state.Grant{}, err
 	}
+	body = []byte(util.RedactAPIKey(string(body), server.Config.APIKey))
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return state.Grant{}, fmt.Errorf("Error when getting grant: Access denied (%s), your API key is likely invalid - please check the api_key setting: %s", resp.Status, body)
+	}
 
 	if resp.StatusCode != http.StatusOK || len(body) == 0 {
 		return state.Grant{}, fmt.Errorf("Error when getting grant: %s", body)
@@ -11,19 +11,21 @@ import (
 )
 
 func GetLogsGrant(server state.Server, globalCollectionOpts state.CollectionOpts, logger *util.Logger) (state.GrantLogs, error) {
-	req, err := http.NewRequest("GET", server.Config.APIBaseURL+"/v2/snapshots/grant_logs", nil)
-	if err != nil {
-		return state.GrantLogs{}, err
-	}
-
-	req.Header.Set("Pganalyze-Api-Key", server.Config.APIKey)
-	req.Header.Set("Pganalyze-System-Id", server.Config.SystemID)
-	req.Header.Set("Pganalyze-System-Type", server.Config.SystemType)
-	req.Header.Set("Pganalyze-System-Scope", server.Config.SystemScope)
-	req.Header.Set("User-Agent", util.CollectorNameAndVersion)
-	req.Header.Add("Accept", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := util.DoWithRetry(server.Config.HTTPClient(), util.DefaultHTTPRetryPolicy, logger, func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", server.Config.APIBaseURL+"/v2/snapshots/grant_logs", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Pganalyze-Api-Key", server.Config.APIKey)
+		req.Header.Set("Pganalyze-System-Id", server.Config.SystemID)
+		req.Header.Set("Pganalyze-System-Type", server.Config.SystemType)
+		req.Header.Set("Pganalyze-System-Scope", server.Config.SystemScope)
+		req.Header.Set("User-Agent", util.CollectorNameAndVersion)
+		req.Header.Add("Accept", "application/json")
+
+		return req, nil
+	})
 	if err != nil {
 		return state.GrantLogs{}, err
 	}
@@ -33,6 +35,11 @@ func GetLogsGrant(server state.Server, globalCollectionOpts state.CollectionOpts
 	if err != nil {
 		return state.GrantLogs{}, err
 	}
+	body = []byte(util.RedactAPIKey(string(body), server.Config.APIKey))
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return state.GrantLogs{}, fmt.Errorf("Error when getting grant: Access denied (%s), your API key is likely invalid - please check the api_key setting: %s", resp.Status, body)
+	}
 
 	if resp.StatusCode == http.StatusForbidden {
 		return state.GrantLogs{}, nil
@@ -0,0 +1,20 @@
+package input
+
+import "github.com/pganalyze/collector/state"
+
+// trackAdvisoryLocks - Carries over when we first saw each advisory lock that is still held
+func trackAdvisoryLocks(prev []state.PostgresAdvisoryLock, curr []state.PostgresAdvisoryLock) []state.PostgresAdvisoryLock {
+	prevLocks := make(map[state.PostgresAdvisoryLockKey]state.PostgresAdvisoryLock)
+	for _, lock := range prev {
+		prevLocks[lock.Key] = lock
+	}
+
+	for idx, lock := range curr {
+		prevLock, exists := prevLocks[lock.Key]
+		if exists && prevLock.HeldSince.Before(lock.HeldSince) {
+			curr[idx].HeldSince = prevLock.HeldSince
+		}
+	}
+
+	return curr
+}
@@ -24,17 +24,21 @@ func getMemoryRssBytes() uint64 {
 	return mem.RSS
 }
 
-func getCollectorStats() state.CollectorStats {
+func getCollectorStats(timings state.CollectorTimings, collectorInstance string) state.CollectorStats {
 	var memStats runtime.MemStats
 	runtime.ReadMemStats(&memStats)
 
 	return state.CollectorStats{
 		GoVersion:                runtime.Version(),
+		CollectorInstance:        collectorInstance,
 		ActiveGoroutines:         int32(runtime.NumGoroutine()),
 		CgoCalls:                 runtime.NumCgoCall(),
 		MemoryHeapAllocatedBytes: memStats.HeapAlloc,
 		MemoryHeapObjects:        memStats.HeapObjects,
 		MemorySystemBytes:        memStats.Sys,
 		MemoryRssBytes:           getMemoryRssBytes(),
+		GcCount:                  memStats.NumGC,
+		GcPauseTotalNs:           memStats.PauseTotalNs,
+		Timings:                  timings,
 	}
 }
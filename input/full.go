@@ -1,10 +1,13 @@
 package input
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
+	"strings"
 	"time"
 
+	"github.com/guregu/null"
+	"github.com/pganalyze/collector/input/pgbouncer"
 	"github.com/pganalyze/collector/input/postgres"
 	"github.com/pganalyze/collector/input/system"
 	"github.com/pganalyze/collector/state"
@@ -12,9 +15,28 @@ import (
 )
 
 // CollectFull - Collects a "full" snapshot of all data we need on a regular interval
-func CollectFull(server state.Server, connection *sql.DB, collectionOpts state.CollectionOpts, logger *util.Logger) (ps state.PersistedState, ts state.TransientState, err error) {
+//
+// Collectors that can be slow run within the remaining time until the deadline of ctx (if any),
+// and are skipped once it is reached, so that we still submit the rest of the snapshot.
+func CollectFull(ctx context.Context, server state.Server, connection *sql.DB, collectionOpts state.CollectionOpts, logger *util.Logger) (ps state.PersistedState, ts state.TransientState, err error) {
 	isHeroku := server.Config.SystemType == "heroku"
 
+	// Shared with CollectAllSchemas through ps, and re-attached to the final collector stats below
+	timings := make(state.CollectorTimings)
+	ps.CollectorStats.Timings = timings
+	var start time.Time
+
+	withDeadline := func(what string, fn func() error) error {
+		err := postgres.RunWithDeadline(ctx, server, logger, collectionOpts, connection, fn)
+		if err == postgres.ErrDeadlineExceeded {
+			logger.PrintWarning("Skipping collection of %s: %s", what, err)
+			ts.SkippedCollectors = append(ts.SkippedCollectors, what)
+			timings.Skip(what)
+			return nil
+		}
+		return err
+	}
+
 	ps.CollectedAt = time.Now()
 
 	ts.Version, err = postgres.GetPostgresVersion(logger, connection)
@@ -23,79 +45,563 @@ func CollectFull(server state.Server, connection *sql.DB, collectionOpts state.C
 		return
 	}
 
-	if ts.Version.Numeric < state.MinRequiredPostgresVersion {
-		err = fmt.Errorf("Error: Your PostgreSQL server version (%s) is too old, 9.2 or newer is required.", ts.Version.Short)
+	err = ts.Version.CheckSupported()
+	if err != nil {
 		return
 	}
 
+	ts.InRecovery, err = postgres.GetIsInRecovery(connection)
+	if err != nil {
+		logger.PrintError("Error determining recovery state")
+		return
+	}
+	if server.Config.CollectFromStandby && !ts.InRecovery {
+		logger.PrintWarning("collect_from_standby is set, but the server is not a standby (was it promoted?) - statistics get reported as cluster-wide")
+	}
+
+	start = time.Now()
 	ts.Roles, err = postgres.GetRoles(logger, connection, ts.Version)
+	timings.Add("pg_roles", start, len(ts.Roles))
+	err = skipOnTimeout(err, "pg_roles", timings, logger)
 	if err != nil {
 		logger.PrintError("Error collecting pg_roles")
 		return
 	}
 
+	checkRoleConnectionLimits(ts.Roles, logger)
+
+	start = time.Now()
 	ts.Databases, err = postgres.GetDatabases(logger, connection, ts.Version)
+	timings.Add("pg_databases", start, len(ts.Databases))
+	err = skipOnTimeout(err, "pg_databases", timings, logger)
 	if err != nil {
 		logger.PrintError("Error collecting pg_databases")
 		return
 	}
+	ps.DatabaseSizes = state.DatabaseSizes(ts.Databases)
 
-	ps.StatementTextCounter = server.PrevState.StatementTextCounter + 1
-	if ps.StatementTextCounter >= server.Grant.Config.Features.StatementTextFrequency { // Stats and statements
-		ps.StatementTextCounter = 0
-		ts.HasStatementText = true
-		ts.Statements, ps.StatementStats, err = postgres.GetStatements(logger, connection, ts.Version, true, isHeroku)
+	start = time.Now()
+	ps.Tablespaces, err = postgres.GetTablespaces(connection, ts.Version)
+	timings.Add("pg_tablespace", start, len(ps.Tablespaces))
+	if err != nil {
+		logger.PrintWarning("Error collecting pg_tablespace: %s", err)
+		err = nil
+	}
+	if collectionOpts.CollectSystemInformation {
+		system.AddTablespaceDiskUsage(server.Config, ps.Tablespaces, logger)
+		checkTablespaceDiskUsage(ps.Tablespaces, logger)
+	}
+
+	// Statistics and activity, not needed for a schema-only snapshot
+	if !collectionOpts.SchemaOnly {
+		start = time.Now()
+		ps.BackendTypeCounts, err = postgres.GetBackendTypeCounts(connection, ts.Version)
+		timings.Add("backend types", start, len(ps.BackendTypeCounts))
 		if err != nil {
-			logger.PrintError("Error collecting pg_stat_statements")
-			return
+			logger.PrintWarning("Error collecting backend type counts: %s", err)
+			err = nil
+		}
+
+		if ts.Version.Numeric >= state.PostgresVersion10 {
+			start = time.Now()
+			workerUtilization, err := postgres.GetWorkerUtilization(connection)
+			timings.Add("worker utilization", start, 0)
+			if err != nil {
+				logger.PrintWarning("Error collecting worker utilization: %s", err)
+			} else {
+				ps.WorkerUtilization = &workerUtilization
+				checkWorkerUtilization(workerUtilization, logger)
+			}
+		}
+
+		start = time.Now()
+		ps.IdleInTransactionBlockers, err = postgres.GetIdleInTransactionBlockers(connection, ts.Version)
+		timings.Add("idle in transaction blockers", start, len(ps.IdleInTransactionBlockers))
+		if err != nil {
+			logger.PrintWarning("Error collecting idle in transaction sessions: %s", err)
+			err = nil
 		}
-	} else { // Stats only
-		logger.PrintVerbose("Collecting pg_stat_statements without statement text (%d of %d)", ps.StatementTextCounter, server.Grant.Config.Features.StatementTextFrequency)
-		ts.HasStatementText = false
-		_, ps.StatementStats, err = postgres.GetStatements(logger, connection, ts.Version, false, isHeroku)
+		checkIdleInTransactionBlockers(ps.IdleInTransactionBlockers, ps.CollectedAt, logger)
+
+		start = time.Now()
+		ps.AdvisoryLocks, err = postgres.GetAdvisoryLocks(connection, ps.CollectedAt)
+		timings.Add("advisory locks", start, len(ps.AdvisoryLocks))
 		if err != nil {
-			logger.PrintError("Error collecting pg_stat_statements")
-			return
+			logger.PrintWarning("Error collecting advisory locks: %s", err)
+			err = nil
 		}
-	}
+		ps.AdvisoryLocks = trackAdvisoryLocks(server.PrevState.AdvisoryLocks, ps.AdvisoryLocks)
+		checkAdvisoryLocks(ps.AdvisoryLocks, ps.CollectedAt, time.Duration(server.Config.AdvisoryLockThresholdSecs)*time.Second, logger)
 
-	ps.StatementResetCounter = server.PrevState.StatementResetCounter + 1
-	if server.Grant.Config.Features.StatementResetFrequency != 0 && ps.StatementResetCounter >= server.Grant.Config.Features.StatementResetFrequency {
-		ps.StatementResetCounter = 0
-		err = postgres.ResetStatements(logger, connection)
+		statementExcludes := state.StatementExcludes{Patterns: server.Config.ExcludeStatementRegexps(), Keys: server.PrevState.ExcludedStatements}
+		if server.Config.DbAllNames {
+			statementExcludes.DatabaseOids = make(map[state.Oid]bool)
+			for _, database := range ts.Databases {
+				if !server.Config.MonitorsDatabase(database.Name) {
+					statementExcludes.DatabaseOids[database.Oid] = true
+				}
+			}
+		}
+		if len(server.Config.ExcludeStatementsUsers) > 0 {
+			var unknownUsers []string
+			statementExcludes.UserOids, unknownUsers = state.ExcludedUserOids(server.Config.ExcludeStatementsUsers, ts.Roles)
+			for _, user := range unknownUsers {
+				logger.PrintVerbose("Role %s in exclude_statements_users does not exist", user)
+			}
+		}
+
+		ps.StatementTextCounter = server.PrevState.StatementTextCounter + 1
+		if ps.StatementTextCounter >= server.Grant.Config.Features.StatementTextFrequency { // Stats and statements
+			ps.StatementTextCounter = 0
+			ts.HasStatementText = true
+			start = time.Now()
+			err = withDeadline("pg_stat_statements", func() (err error) {
+				ts.Statements, ps.StatementStats, ps.ExcludedStatements, err = postgres.GetStatements(logger, connection, ts.Version, true, isHeroku, ts.InRecovery, statementExcludes)
+				return
+			})
+			timings.Add("pg_stat_statements", start, len(ps.StatementStats))
+			err = skipIfNotPreloaded(err, logger)
+			err = skipOnTimeout(err, "pg_stat_statements", timings, logger)
+			if err != nil {
+				logger.PrintError("Error collecting pg_stat_statements")
+				return
+			}
+		} else { // Stats only
+			logger.PrintVerbose("Collecting pg_stat_statements without statement text (%d of %d)", ps.StatementTextCounter, server.Grant.Config.Features.StatementTextFrequency)
+			ts.HasStatementText = false
+			start = time.Now()
+			err = withDeadline("pg_stat_statements", func() (err error) {
+				_, ps.StatementStats, ps.ExcludedStatements, err = postgres.GetStatements(logger, connection, ts.Version, false, isHeroku, ts.InRecovery, statementExcludes)
+				return
+			})
+			timings.Add("pg_stat_statements", start, len(ps.StatementStats))
+			err = skipIfNotPreloaded(err, logger)
+			err = skipOnTimeout(err, "pg_stat_statements", timings, logger)
+			if err != nil {
+				logger.PrintError("Error collecting pg_stat_statements")
+				return
+			}
+		}
+
+		ts.StatementRoleNames = state.ResolveStatementRoles(ps.StatementStats, ts.Roles)
+		if dropped := ts.StatementRoleNames.DroppedRoles(); len(dropped) > 0 {
+			logger.PrintVerbose("Found pg_stat_statements entries of %d roles that no longer exist", len(dropped))
+		}
+
+		ts.StatementLatencies = state.StatementLatencies(ps.StatementStats)
+		if outliers := ts.StatementLatencies.Outliers(); outliers > 0 {
+			logger.PrintVerbose("Found %d statements that are usually fast, but have occasional executions of more than %dx their mean time", outliers, state.StatementLatencyOutlierFactor)
+		}
+
+		ps.StatementSettings, err = postgres.GetStatementSettings(connection)
 		if err != nil {
-			logger.PrintError("Error calling pg_stat_statements_reset() as requested: %s", err)
-			return
+			logger.PrintWarning("Error collecting pg_stat_statements settings: %s", err)
+			ps.StatementSettings = server.PrevState.StatementSettings
+			err = nil
+		}
+		checkStatementSettings(server.PrevState.StatementSettings, ps.StatementSettings, len(ps.StatementStats), logger)
+
+		if collectionOpts.CollectPostgresFunctions {
+			trackFunctions, err := postgres.GetTrackFunctions(connection)
+			if err != nil {
+				logger.PrintWarning("Error collecting track_functions setting: %s", err)
+			} else {
+				checkTrackFunctions(trackFunctions, logger)
+			}
 		}
-		_, ts.ResetStatementStats, err = postgres.GetStatements(logger, connection, ts.Version, false, isHeroku)
+
+		ps.StatementInfo, err = postgres.GetStatementInfo(logger, connection, ts.Version)
 		if err != nil {
-			logger.PrintError("Error collecting pg_stat_statements")
-			return
+			logger.PrintWarning("Error collecting pg_stat_statements_info: %s", err)
+			err = nil
+		}
+		checkStatementDealloc(server.PrevState.StatementInfo, ps.StatementInfo, logger)
+
+		ps.StatementResetCounter = server.PrevState.StatementResetCounter + 1
+		if server.Grant.Config.Features.StatementResetFrequency != 0 && ps.StatementResetCounter >= server.Grant.Config.Features.StatementResetFrequency {
+			ps.StatementResetCounter = 0
+			if ts.InRecovery {
+				logger.PrintVerbose("Skipping pg_stat_statements_reset() since the server is a standby")
+			} else {
+				err = postgres.ResetStatements(logger, connection)
+				if err != nil {
+					logger.PrintError("Error calling pg_stat_statements_reset() as requested: %s", err)
+					return
+				}
+				err = withDeadline("pg_stat_statements after reset", func() (err error) {
+					statementExcludes.Keys = ps.ExcludedStatements
+					_, ts.ResetStatementStats, _, err = postgres.GetStatements(logger, connection, ts.Version, false, isHeroku, ts.InRecovery, statementExcludes)
+					return
+				})
+				err = skipIfNotPreloaded(err, logger)
+				err = skipOnTimeout(err, "pg_stat_statements", timings, logger)
+				if err != nil {
+					logger.PrintError("Error collecting pg_stat_statements")
+					return
+				}
+			}
 		}
 	}
 
 	if collectionOpts.CollectPostgresSettings {
-		ts.Settings, err = postgres.GetSettings(connection, ts.Version)
+		start = time.Now()
+		err = withDeadline("config settings", func() (err error) {
+			ts.Settings, err = postgres.GetSettings(connection, ts.Version)
+			return
+		})
+		timings.Add("config settings", start, len(ts.Settings))
+		err = skipOnTimeout(err, "config settings", timings, logger)
 		if err != nil {
 			logger.PrintError("Error collecting config settings")
 			return
 		}
+		ts.NonDefaultSettings = state.NonDefaultSettings(ts.Settings)
+		logger.PrintVerbose("Found %d settings changed from their defaults", len(ts.NonDefaultSettings))
+
+		start = time.Now()
+		ps.HbaRules, err = postgres.GetHbaRules(logger, connection, ts.Version)
+		timings.Add("pg_hba_file_rules", start, len(ps.HbaRules))
+		if err != nil {
+			logger.PrintWarning("Error collecting pg_hba_file_rules: %s", err)
+			err = nil
+		}
+		checkHbaRules(ps.HbaRules, logger)
 	}
 
-	ts.Replication, err = postgres.GetReplication(logger, connection, isHeroku, ts.Version)
-	if err != nil {
-		logger.PrintWarning("Error collecting replication statistics: %s", err)
-		// We intentionally accept this as a non-fatal issue (at least for now)
-		err = nil
+	if !collectionOpts.SchemaOnly {
+		start = time.Now()
+		ps.WalPosition, err = postgres.GetWalPosition(connection, ts.Version)
+		timings.Add("wal position", start, 0)
+		if err != nil {
+			logger.PrintWarning("Error collecting WAL position: %s", err)
+			err = nil
+		}
+
+		start = time.Now()
+		ps.ArchiverStats, err = postgres.GetArchiverStats(connection, ts.Version)
+		timings.Add("pg_stat_archiver", start, 0)
+		if err != nil {
+			logger.PrintWarning("Error collecting pg_stat_archiver: %s", err)
+			err = nil
+		}
+		checkArchiverStatus(ps.ArchiverStats, logger)
+
+		start = time.Now()
+		ts.Replication, err = postgres.GetReplication(logger, connection, isHeroku, ts.Version)
+		timings.Add("replication", start, len(ts.Replication.Standbys))
+		if err != nil {
+			logger.PrintWarning("Error collecting replication statistics: %s", err)
+			// We intentionally accept this as a non-fatal issue (at least for now)
+			err = nil
+		}
+
+		ps.SubscriptionWorkers, err = postgres.GetSubscriptionWorkers(connection, ts.Version)
+		if err != nil {
+			logger.PrintWarning("Error collecting pg_stat_subscription: %s", err)
+			err = nil
+		}
+
+		ps.SubscriptionStats, err = postgres.GetSubscriptionStats(connection, ts.Version)
+		if err != nil {
+			logger.PrintWarning("Error collecting pg_stat_subscription_stats: %s", err)
+			err = nil
+		}
+		checkSubscriptionErrors(server.PrevState.SubscriptionStats, ps.SubscriptionStats, logger)
+
+		ts.Subscriptions, err = postgres.GetSubscriptions(connection, ts.Version)
+		if err != nil {
+			logger.PrintWarning("Error collecting pg_subscription: %s", err)
+			err = nil
+		}
+
+		if postgres.WaitSamplingAvailable(connection) {
+			ps.WaitSamplingProfile, err = postgres.GetWaitSamplingProfile(connection)
+			if err != nil {
+				logger.PrintWarning("Error collecting pg_wait_sampling_profile: %s", err)
+				err = nil
+			}
+		}
+
+		if postgres.CitusAvailable(connection) {
+			start = time.Now()
+			err = withDeadline("Citus cluster information", func() (err error) {
+				ps.Citus, err = postgres.GetCitus(connection)
+				return
+			})
+			timings.Add("Citus cluster information", start, len(ps.Citus.Nodes))
+			if err != nil {
+				logger.PrintWarning("Error collecting Citus cluster information: %s", err)
+				err = nil
+			}
+		}
+
+		if server.Config.PgbouncerURL != "" {
+			start = time.Now()
+			ps.Pgbouncer, err = pgbouncer.GetState(server.Config.PgbouncerURL)
+			timings.Add("pgbouncer", start, len(ps.Pgbouncer.Pools))
+			if err != nil {
+				logger.PrintWarning("Error collecting pgbouncer statistics: %s", err)
+				err = nil
+			}
+		}
 	}
 
-	ps, ts = postgres.CollectAllSchemas(server, collectionOpts, logger, ps, ts)
+	ps, ts = postgres.CollectAllSchemas(ctx, server, collectionOpts, logger, ps, ts)
+
+	ps.MatviewRefreshes = trackMatviewRefreshes(server.PrevState.MatviewRefreshes, ps)
+
+	checkSequenceConsumption(ps.Sequences, server.Config.SequenceWarningThreshold, logger)
+
+	ps.BrokenSchemaObjects = state.BrokenSchemaObjects(ps.Relations)
+	if len(ps.BrokenSchemaObjects) > 0 {
+		logger.PrintVerbose("Found %d invalid indices or NOT VALID constraints", len(ps.BrokenSchemaObjects))
+	}
+
+	ps.UnindexedForeignKeys = state.UnindexedForeignKeys(ps.Relations)
+	if len(ps.UnindexedForeignKeys) > 0 {
+		logger.PrintVerbose("Found %d foreign keys without an index on their columns", len(ps.UnindexedForeignKeys))
+		for _, fk := range ps.UnindexedForeignKeys {
+			logger.PrintVerbose("Missing index for foreign key %s on %s.%s (%s), suggested: %s", fk.ConstraintName, fk.SchemaName, fk.RelationName, strings.Join(fk.ColumnNames, ", "), fk.SuggestedIndexDef)
+		}
+	}
+
+	ps.UnanalyzedRelations = state.UnanalyzedRelations(ps.Relations, ps.RelationStats)
+	for _, r := range ps.UnanalyzedRelations {
+		logger.PrintWarning("Table %s.%s (%d rows) has never been analyzed, queries on it are planned without statistics - consider running ANALYZE", r.SchemaName, r.RelationName, r.NLiveTup)
+	}
+
+	checkWraparoundRisk(ps.Relations, ps.RelationVisibility, logger)
+
+	ps.LowHotUpdateRelations = state.LowHotUpdateRelations(ps.Relations, ps.RelationStats)
+	if len(ps.LowHotUpdateRelations) > 0 {
+		logger.PrintVerbose("Found %d heavily updated tables with a low HOT update ratio", len(ps.LowHotUpdateRelations))
+		for _, r := range ps.LowHotUpdateRelations {
+			logger.PrintVerbose("Table %s.%s has %.1f%% HOT updates (%d of %d updates, fillfactor %d) - check for indexes on updated columns, or consider a lower fillfactor", r.SchemaName, r.RelationName, r.HotUpdateRatio*100, r.NTupHotUpd, r.NTupUpd, r.Fillfactor)
+		}
+	}
+
+	ps.LowCorrelationIndices = state.LowCorrelationIndices(ps.Relations, ps.RelationStats, ps.ColumnCorrelations)
+	if len(ps.LowCorrelationIndices) > 0 {
+		logger.PrintVerbose("Found %d indexes on large tables whose leading column has a low correlation", len(ps.LowCorrelationIndices))
+		for _, i := range ps.LowCorrelationIndices {
+			logger.PrintVerbose("Index %s on %s.%s (table size %d MB) has a correlation of %.2f on %s, range scans read rows from many different pages - consider CLUSTER %s.%s USING %s", i.IndexName, i.SchemaName, i.RelationName, i.TableSizeBytes/1024/1024, i.Correlation, i.ColumnName, i.SchemaName, i.RelationName, i.IndexName)
+		}
+	}
+
+	ps.ColumnStatisticsOverrides = state.ColumnStatisticsOverrides(ps.Relations, ps.RelationStats, int64(server.Config.StatisticsOverridesMinTableSizeMb)*1024*1024)
+	if len(ps.ColumnStatisticsOverrides) > 0 {
+		logger.PrintVerbose("Found %d columns with custom statistics settings on large tables", len(ps.ColumnStatisticsOverrides))
+	}
 
 	if collectionOpts.CollectSystemInformation {
+		start = time.Now()
 		ps.System = system.GetSystemState(server.Config, logger)
+		timings.Add("system", start, 0)
+
+		memorySettings, ok := state.MemorySettings(ts.Settings, ps.System.Memory)
+		if ok {
+			ts.MemorySettings = &memorySettings
+			checkMemorySettings(memorySettings, logger)
+		}
 	}
 
-	ps.CollectorStats = getCollectorStats()
+	ps.CollectorStats = getCollectorStats(timings, server.Config.GetCollectorInstance())
 
 	return
 }
+
+// skipOnTimeout - Turns statement/lock timeouts into a warning, so that one slow query only skips
+// its own part of the snapshot, instead of failing the whole snapshot (the skip is recorded in
+// timings, so the circuit breaker takes it into account for the next run)
+func skipOnTimeout(err error, what string, timings state.CollectorTimings, logger *util.Logger) error {
+	if reason := postgres.TimeoutReason(err); reason != "" {
+		logger.PrintWarning("Skipping collection of %s: %s", what, reason)
+		timings.Skip(what)
+		return nil
+	}
+	return err
+}
+
+// skipIfNotPreloaded - pg_stat_statements missing from shared_preload_libraries requires a restart
+// to fix, so we skip query statistics instead of failing the whole snapshot
+func skipIfNotPreloaded(err error, logger *util.Logger) error {
+	if err == postgres.ErrStatementsNotPreloaded {
+		logger.PrintWarning("Skipping collection of pg_stat_statements: %s", err)
+		return nil
+	}
+	return err
+}
+
+// Warn about pg_stat_statements configurations that cause us to see an incomplete picture
+func checkStatementSettings(prev state.PostgresStatementSettings, settings state.PostgresStatementSettings, statementCount int, logger *util.Logger) {
+	// Switching between "top" and "all" changes which calls get counted, so the statistics diffed
+	// against the previous run mix both ways of tracking
+	if prev.Track.Valid && settings.Track.Valid && prev.Track.String != settings.Track.String {
+		logger.PrintInfo("pg_stat_statements.track changed from \"%s\" to \"%s\" since the last run, query statistics of this snapshot are not directly comparable to earlier ones", prev.Track.String, settings.Track.String)
+	}
+
+	if settings.Track.Valid && settings.Track.String == "none" {
+		logger.PrintWarning("pg_stat_statements.track is set to \"none\", no query statistics will be collected")
+	} else if settings.Track.Valid && settings.Track.String == "top" {
+		logger.PrintVerbose("pg_stat_statements.track is set to \"top\", statements nested inside functions are not tracked")
+	}
+
+	// Postgres deallocates the least-executed 5% of entries once the maximum is reached,
+	// so being close to the maximum means we're continuously losing statistics
+	if settings.Max.Valid && settings.Max.Int64 > 0 && int64(statementCount) >= settings.Max.Int64*9/10 {
+		logger.PrintWarning("pg_stat_statements contains %d entries, close to pg_stat_statements.max = %d - statistics for infrequent queries are likely being evicted", statementCount, settings.Max.Int64)
+	}
+}
+
+// Without function tracking pg_stat_user_functions stays empty, which otherwise looks like nothing is being called
+func checkTrackFunctions(setting null.String, logger *util.Logger) {
+	if setting.Valid && setting.String == "none" {
+		logger.PrintWarning("track_functions is set to \"none\", no function statistics will be collected - set it to \"pl\" or \"all\" to track function calls")
+	}
+}
+
+func checkStatementDealloc(prev state.PostgresStatementInfo, curr state.PostgresStatementInfo, logger *util.Logger) {
+	// A changed reset time means the counter was reset, and we have nothing to compare against
+	if prev.StatsReset.Valid != curr.StatsReset.Valid || !prev.StatsReset.Time.Equal(curr.StatsReset.Time) || curr.Dealloc <= prev.Dealloc {
+		return
+	}
+
+	logger.PrintWarning("pg_stat_statements deallocated entries %d times since the last snapshot, consider increasing pg_stat_statements.max", curr.Dealloc-prev.Dealloc)
+}
+
+// Warn about roles that are about to be refused new connections
+func checkRoleConnectionLimits(roles []state.PostgresRole, logger *util.Logger) {
+	for _, r := range roles {
+		if r.Login && r.ConnectionLimit > 0 && r.ConnectionLimitReached(90) {
+			logger.PrintWarning("Role \"%s\" is using %d of its %d allowed connections", r.Name, r.ActiveConnections, r.ConnectionLimit)
+		}
+	}
+}
+
+// Once the worker pools are exhausted, queries silently run without (or with fewer) parallel workers
+func checkWorkerUtilization(u state.PostgresWorkerUtilization, logger *util.Logger) {
+	if u.WorkerProcessesUtilization() >= 0.9 {
+		logger.PrintWarning("Using %d of max_worker_processes = %d background workers, new parallel queries and background workers may not get a worker", u.BackgroundWorkers, u.MaxWorkerProcesses)
+	}
+	if u.ParallelWorkersUtilization() >= 0.9 {
+		logger.PrintWarning("Using %d of max_parallel_workers = %d parallel workers (max_parallel_workers_per_gather = %d), new parallel queries may run without parallelism", u.ParallelWorkers, u.MaxParallelWorkers, u.MaxParallelWorkersPerGather)
+	}
+}
+
+// Memory settings are often copied from another server, and stay unchanged when the instance gets resized
+func checkMemorySettings(m state.PostgresMemorySettings, logger *util.Logger) {
+	totalMb := m.TotalMemoryBytes / 1024 / 1024
+	if m.SharedBuffersMemoryShare() > state.SharedBuffersMaxMemoryShare {
+		logger.PrintWarning("shared_buffers = %d MB is %.0f%% of the %d MB system memory, leaving little for the OS page cache and connections", m.SharedBuffersBytes/1024/1024, m.SharedBuffersMemoryShare()*100, totalMb)
+	}
+	if m.WorkMemMemoryFactor() > state.WorkMemMaxMemoryFactor {
+		logger.PrintWarning("work_mem = %d MB with max_connections = %d allows %.1fx the %d MB system memory to be used for sorts and hashes, busy periods may run out of memory", m.WorkMemBytes/1024/1024, m.MaxConnections, m.WorkMemMemoryFactor(), totalMb)
+	}
+	if m.EffectiveCacheSizeExceedsMemory() {
+		logger.PrintWarning("effective_cache_size = %d MB exceeds the %d MB system memory, the planner overestimates how much data is cached", m.EffectiveCacheSizeBytes/1024/1024, totalMb)
+	}
+}
+
+// Idle in transaction sessions that block others are almost always an application bug, make them stand out
+func checkIdleInTransactionBlockers(blockers []state.PostgresIdleInTransactionBlocker, now time.Time, logger *util.Logger) {
+	for _, b := range blockers {
+		logger.PrintWarning("Backend %d (database \"%s\", role \"%s\") has been %s for %s, holding %d locks that block %d other backend(s) %v; last query: %s",
+			b.Pid, b.DatabaseName.String, b.RoleName.String, b.State, b.IdleDuration(now).Truncate(time.Second), b.HeldLocks, len(b.BlockedPids), b.BlockedPids, b.Query)
+	}
+}
+
+// Advisory locks are managed by the application, and a lock that isn't released blocks whatever it coordinates
+func checkAdvisoryLocks(locks []state.PostgresAdvisoryLock, now time.Time, threshold time.Duration, logger *util.Logger) {
+	if threshold <= 0 {
+		return
+	}
+	for _, l := range locks {
+		if l.HeldDuration(now) < threshold {
+			continue
+		}
+		logger.PrintWarning("Backend %d (database \"%s\", role \"%s\", application \"%s\") has held advisory lock %s (%s) for at least %s, blocking %d other backend(s) %v; last query: %s",
+			l.Key.Pid, l.DatabaseName.String, l.RoleName.String, l.ApplicationName.String, l.Key.LockKey(), l.Mode, l.HeldDuration(now).Truncate(time.Second), len(l.WaitingPids), l.WaitingPids, l.Query)
+	}
+}
+
+// Invalid lines are silently skipped by Postgres on the next reload, and permissive ones are worth a second look
+func checkHbaRules(rules []state.PostgresHbaRule, logger *util.Logger) {
+	for _, r := range rules {
+		if r.Error.Valid {
+			logger.PrintWarning("pg_hba.conf line %d is invalid and will be ignored on the next reload: %s", r.LineNumber, r.Error.String)
+		} else if r.Permissive() {
+			logger.PrintVerbose("pg_hba.conf line %d is permissive: %s %s %s %s/%s %s", r.LineNumber, r.Type.String, strings.Join(r.Databases, ","), strings.Join(r.UserNames, ","), r.Address.String, r.Netmask.String, r.AuthMethod.String)
+		}
+	}
+}
+
+// An aggressive vacuum has to scan every page that isn't all-frozen, which is slow on large tables that are rarely frozen
+func checkWraparoundRisk(relations []state.PostgresRelation, visibility state.PostgresRelationVisibilityMap, logger *util.Logger) {
+	for _, r := range relations {
+		v, exists := visibility[r.Oid]
+		if !exists || !v.WraparoundRisk() {
+			continue
+		}
+		frozen, _ := v.AllFrozenFraction()
+		logger.PrintWarning("Table %s.%s has a relfrozenxid age of %d (autovacuum_freeze_max_age = %d) with only %.1f%% of its %d pages all-frozen, the next aggressive vacuum will have to scan most of it",
+			r.SchemaName, r.RelationName, v.XidAge, v.FreezeMaxAge, frozen*100, v.RelPages)
+	}
+}
+
+// Warn about tablespaces whose filesystem is about to fill up
+func checkTablespaceDiskUsage(tablespaces []state.PostgresTablespace, logger *util.Logger) {
+	for _, t := range tablespaces {
+		if t.DiskUsedPercent() >= 90 {
+			logger.PrintWarning("Filesystem of tablespace %s (%s) is %.1f%% full, %d bytes available", t.Name, t.Location, t.DiskUsedPercent(), t.DiskAvailableBytes.Int64)
+		}
+	}
+}
+
+// Warn about sequences that are close to overflowing, so they can be widened ahead of time
+func checkSequenceConsumption(sequences []state.PostgresSequence, threshold float64, logger *util.Logger) {
+	if threshold <= 0 {
+		return
+	}
+
+	for _, s := range sequences {
+		if s.Cycle || s.PercentConsumed() < threshold {
+			continue
+		}
+
+		if s.OwnerColumnName.Valid {
+			logger.PrintWarning("Sequence %s.%s has used %.1f%% of its range (last value %d of %d, used by %s column \"%s\")",
+				s.SchemaName, s.SequenceName, s.PercentConsumed(), s.LastValue.Int64, s.EffectiveMaxValue(), s.OwnerColumnType.String, s.OwnerColumnName.String)
+		} else {
+			logger.PrintWarning("Sequence %s.%s has used %.1f%% of its range (last value %d of %d)",
+				s.SchemaName, s.SequenceName, s.PercentConsumed(), s.LastValue.Int64, s.EffectiveMaxValue())
+		}
+	}
+}
+
+// Failed archiving goes unnoticed until the disk fills up with WAL, so make it stand out
+func checkArchiverStatus(stats *state.PostgresArchiverStats, logger *util.Logger) {
+	if stats == nil || !stats.Failing() {
+		return
+	}
+
+	logger.PrintWarning("WAL archiving is failing: archiving %s failed at %s (%d failures total), last successfully archived %s at %s",
+		stats.LastFailedWal.String, stats.LastFailedTime.Time, stats.FailedCount, stats.LastArchivedWal.String, stats.LastArchivedTime.Time)
+}
+
+// Apply errors cause the subscription worker to restart continuously without catching up,
+// which is otherwise easy to miss
+func checkSubscriptionErrors(prev state.PostgresSubscriptionStatsMap, curr state.PostgresSubscriptionStatsMap, logger *util.Logger) {
+	for oid, stats := range curr {
+		prevStats, exists := prev[oid]
+		if !exists || prevStats.StatsReset.Valid != stats.StatsReset.Valid || !prevStats.StatsReset.Time.Equal(stats.StatsReset.Time) {
+			continue
+		}
+
+		if stats.ApplyErrorCount > prevStats.ApplyErrorCount {
+			logger.PrintWarning("Subscription \"%s\" had %d apply errors since the last snapshot, logical replication may be stalled", stats.SubscriptionName, stats.ApplyErrorCount-prevStats.ApplyErrorCount)
+		}
+		if stats.SyncErrorCount > prevStats.SyncErrorCount {
+			logger.PrintWarning("Subscription \"%s\" had %d initial table sync errors since the last snapshot", stats.SubscriptionName, stats.SyncErrorCount-prevStats.SyncErrorCount)
+		}
+	}
+}
@@ -6,8 +6,10 @@ import (
 
 	"github.com/pganalyze/collector/input/postgres"
 	"github.com/pganalyze/collector/input/system"
+	"github.com/pganalyze/collector/input/system/logs"
 	"github.com/pganalyze/collector/state"
 	"github.com/pganalyze/collector/util"
+	uuid "github.com/satori/go.uuid"
 )
 
 // CollectLogs - Collects a "logs" snapshot of log data we need on a regular interval
@@ -15,7 +17,14 @@ func CollectLogs(server state.Server, connection *sql.DB, collectionOpts state.C
 	var querySamples []state.PostgresQuerySample
 
 	ls.CollectedAt = time.Now()
-	ls.LogFiles, querySamples = system.GetLogFiles(server.Config, logger)
+	ls.LogFiles, querySamples, ls.LogFilePositions = system.GetLogFiles(server, logger)
+
+	if server.StatementTexts != nil {
+		correlateStatements(ls.LogFiles, server.StatementTexts)
+	}
+	ls.TempFileStats = logs.AggregateTempFiles(ls.LogFiles)
+	ls.CanceledStatements = logs.AggregateCanceledStatements(ls.LogFiles)
+	ls.AuthFailures = logs.AggregateAuthFailures(ls.LogFiles)
 
 	if false && collectionOpts.CollectExplain && server.Grant.Config.Features.Explain {
 		ls.QuerySamples = postgres.RunExplain(connection, querySamples)
@@ -24,3 +33,24 @@ func CollectLogs(server state.Server, connection *sql.DB, collectionOpts state.C
 	}
 	return
 }
+
+// correlateStatements - Associates log lines (e.g. from log_min_duration_statement)
+// with their pg_stat_statements entry
+//
+// Lines are linked to statements through the fingerprint of their query text, which
+// is computed the same way for both when building the snapshot. When the log line
+// carries a queryid (jsonlog on Postgres 15+) we use the statement's normalized text
+// for this instead of relying on the query text in the log, since that may be
+// truncated or differ in ways the fingerprint doesn't ignore.
+func correlateStatements(logFiles []state.LogFile, statementTexts *state.StatementTextCache) {
+	for _, logFile := range logFiles {
+		for idx, logLine := range logFile.LogLines {
+			if logLine.QueryID == 0 || logLine.ParentUUID != uuid.Nil {
+				continue
+			}
+			if text, ok := statementTexts.Lookup(logLine.QueryID); ok {
+				logFile.LogLines[idx].Query = text
+			}
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package input
+
+import "github.com/pganalyze/collector/state"
+
+// trackMatviewRefreshes - Detects materialized view refreshes since the last snapshot, and
+// carries over the last detected refresh for all other materialized views
+func trackMatviewRefreshes(prev state.PostgresMatviewRefreshMap, ps state.PersistedState) state.PostgresMatviewRefreshMap {
+	refreshes := make(state.PostgresMatviewRefreshMap)
+
+	for _, relation := range ps.Relations {
+		if relation.RelationType != "m" {
+			continue
+		}
+
+		stats := ps.RelationStats[relation.Oid]
+		curr := state.PostgresMatviewRefresh{
+			Relfilenode:       relation.Relfilenode,
+			ModificationCount: stats.NTupIns + stats.NTupUpd + stats.NTupDel,
+		}
+
+		key := state.PostgresMatviewRefreshKey{DatabaseOid: relation.DatabaseOid, RelationOid: relation.Oid}
+		prevRefresh, exists := prev[key]
+		if exists {
+			curr.LastRefreshDetectedAt = prevRefresh.LastRefreshDetectedAt
+			// Note that the modification count goes back to zero when statistics get reset
+			if curr.Relfilenode != prevRefresh.Relfilenode || curr.ModificationCount > prevRefresh.ModificationCount {
+				curr.LastRefreshDetectedAt = ps.CollectedAt
+			}
+		}
+
+		refreshes[key] = curr
+	}
+
+	return refreshes
+}
@@ -0,0 +1,40 @@
+package input
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pganalyze/collector/state"
+)
+
+func TestTrackMatviewRefreshes(t *testing.T) {
+	prevAt := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	collectedAt := prevAt.Add(10 * time.Minute)
+
+	// Both materialized views have the same OID, in different databases
+	db1Key := state.PostgresMatviewRefreshKey{DatabaseOid: 1, RelationOid: 100}
+	db2Key := state.PostgresMatviewRefreshKey{DatabaseOid: 2, RelationOid: 100}
+	prev := state.PostgresMatviewRefreshMap{
+		db1Key: {Relfilenode: 500, LastRefreshDetectedAt: prevAt},
+		db2Key: {Relfilenode: 600, LastRefreshDetectedAt: prevAt},
+	}
+	ps := state.PersistedState{
+		CollectedAt: collectedAt,
+		Relations: []state.PostgresRelation{
+			{DatabaseOid: 1, Oid: 100, RelationType: "m", Relfilenode: 501},
+			{DatabaseOid: 2, Oid: 100, RelationType: "m", Relfilenode: 600},
+		},
+	}
+
+	refreshes := trackMatviewRefreshes(prev, ps)
+
+	if len(refreshes) != 2 {
+		t.Fatalf("Expected refresh tracking for both materialized views, got %v", refreshes)
+	}
+	if !refreshes[db1Key].LastRefreshDetectedAt.Equal(collectedAt) {
+		t.Errorf("Expected refresh of the rewritten materialized view to be detected, got %v", refreshes[db1Key])
+	}
+	if !refreshes[db2Key].LastRefreshDetectedAt.Equal(prevAt) {
+		t.Errorf("Expected previous refresh of the unchanged materialized view to be kept, got %v", refreshes[db2Key])
+	}
+}
@@ -0,0 +1,155 @@
+package pgbouncer
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/pganalyze/collector/state"
+)
+
+// GetState - Connects to the pgbouncer admin console and collects pool statistics
+//
+// This uses its own connection (see pgbouncer_url), since pgbouncer has separate
+// authentication from the Postgres server behind it.
+func GetState(dbURL string) (pgbState state.PgbouncerState, err error) {
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	db.SetMaxOpenConns(1)
+
+	stats, err := showCommand(db, "STATS")
+	if err != nil {
+		return
+	}
+	for _, row := range stats {
+		pgbState.Stats = append(pgbState.Stats, state.PgbouncerStats{
+			Database:        row.str("database"),
+			TotalXactCount:  row.int("total_xact_count"),
+			TotalQueryCount: row.int("total_query_count"),
+			TotalReceived:   row.int("total_received"),
+			TotalSent:       row.int("total_sent"),
+			TotalXactTime:   row.int("total_xact_time"),
+			TotalQueryTime:  row.int("total_query_time"),
+			TotalWaitTime:   row.int("total_wait_time"),
+		})
+	}
+
+	pools, err := showCommand(db, "POOLS")
+	if err != nil {
+		return
+	}
+	for _, row := range pools {
+		pgbState.Pools = append(pgbState.Pools, state.PgbouncerPool{
+			Database:  row.str("database"),
+			User:      row.str("user"),
+			PoolMode:  row.str("pool_mode"),
+			ClActive:  row.int("cl_active"),
+			ClWaiting: row.int("cl_waiting"),
+			SvActive:  row.int("sv_active"),
+			SvIdle:    row.int("sv_idle"),
+			SvUsed:    row.int("sv_used"),
+			SvTested:  row.int("sv_tested"),
+			SvLogin:   row.int("sv_login"),
+			MaxWaitUs: row.int("maxwait")*1000000 + row.int("maxwait_us"),
+		})
+	}
+
+	clients, err := showCommand(db, "CLIENTS")
+	if err != nil {
+		return
+	}
+	for _, row := range clients {
+		pgbState.Clients = append(pgbState.Clients, state.PgbouncerClient{
+			User:        row.str("user"),
+			Database:    row.str("database"),
+			State:       row.str("state"),
+			Addr:        row.str("addr"),
+			Port:        row.int("port"),
+			ConnectTime: row.str("connect_time"),
+			RequestTime: row.str("request_time"),
+			WaitUs:      row.int("wait")*1000000 + row.int("wait_us"),
+		})
+	}
+
+	databases, err := showCommand(db, "DATABASES")
+	if err != nil {
+		return
+	}
+	for _, row := range databases {
+		pgbState.Databases = append(pgbState.Databases, state.PgbouncerDatabase{
+			Name:               row.str("name"),
+			Host:               row.str("host"),
+			Port:               row.int("port"),
+			Database:           row.str("database"),
+			PoolSize:           row.int("pool_size"),
+			ReservePool:        row.int("reserve_pool"),
+			PoolMode:           row.str("pool_mode"),
+			MaxConnections:     row.int("max_connections"),
+			CurrentConnections: row.int("current_connections"),
+			Paused:             row.int("paused") == 1,
+			Disabled:           row.int("disabled") == 1,
+		})
+	}
+
+	return
+}
+
+// showRow - One row of a SHOW command, by column name
+//
+// The columns differ between pgbouncer versions, so we read them by name, and treat
+// missing columns as empty.
+type showRow map[string]string
+
+func (row showRow) str(column string) string {
+	return row[column]
+}
+
+func (row showRow) int(column string) int64 {
+	value, _ := strconv.ParseInt(row[column], 10, 64)
+	return value
+}
+
+// Note that the admin console only supports the simple query protocol, so this must not use
+// prepared statements or query parameters
+func showCommand(db *sql.DB, command string) ([]showRow, error) {
+	rows, err := db.Query("SHOW " + command)
+	if err != nil {
+		return nil, fmt.Errorf("SHOW %s: %s", command, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("SHOW %s: %s", command, err)
+	}
+
+	var result []showRow
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+
+		err = rows.Scan(valuePtrs...)
+		if err != nil {
+			return nil, fmt.Errorf("SHOW %s: %s", command, err)
+		}
+
+		row := make(showRow)
+		for i, column := range columns {
+			row[column] = values[i].String
+		}
+		result = append(result, row)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("SHOW %s: %s", command, err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/guregu/null"
+	"github.com/pganalyze/collector/state"
+)
+
+const advisoryLocksSQL string = `
+SELECT l.pid, COALESCE(EXTRACT(epoch FROM a.backend_start)::bigint, 0), COALESCE(l.database, 0),
+			 l.classid, l.objid, l.objsubid, l.mode, d.datname, a.usename, a.application_name,
+			 a.state, a.state_change, a.query,
+			 (SELECT array_agg(w.pid)
+					FROM pg_locks w
+				 WHERE w.locktype = 'advisory' AND NOT w.granted
+							 AND w.database IS NOT DISTINCT FROM l.database AND w.classid = l.classid
+							 AND w.objid = l.objid AND w.objsubid = l.objsubid)
+	FROM pg_locks l
+			 LEFT JOIN %s a ON (a.pid = l.pid)
+			 LEFT JOIN pg_catalog.pg_database d ON (d.oid = l.database)
+ WHERE l.locktype = 'advisory' AND l.granted`
+
+// GetAdvisoryLocks - Returns all granted advisory locks, together with their holding backend
+func GetAdvisoryLocks(db *sql.DB, collectedAt time.Time) ([]state.PostgresAdvisoryLock, error) {
+	sourceTable := "pg_stat_activity"
+	if statsHelperExists(db, "get_stat_activity") {
+		sourceTable = "pganalyze.get_stat_activity()"
+	}
+
+	rows, err := db.Query(QueryMarkerSQL + fmt.Sprintf(advisoryLocksSQL, sourceTable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var locks []state.PostgresAdvisoryLock
+	for rows.Next() {
+		var row state.PostgresAdvisoryLock
+		var stateChange null.Time
+		var query null.String
+		var waiting null.String
+
+		err = rows.Scan(&row.Key.Pid, &row.Key.BackendStart, &row.Key.DatabaseOid, &row.Key.ClassID,
+			&row.Key.ObjID, &row.Key.ObjSubID, &row.Mode, &row.DatabaseName, &row.RoleName,
+			&row.ApplicationName, &row.State, &stateChange, &query, &waiting)
+		if err != nil {
+			return nil, err
+		}
+
+		row.WaitingPids = unpackPostgresInt32Array(waiting)
+		row.Query = query.String
+		if !query.Valid {
+			row.Query = state.InsufficientPrivilegeQueryText
+		}
+
+		// An idle backend must have taken the lock before it went idle
+		row.HeldSince = collectedAt
+		if row.State.String == "idle" && stateChange.Valid && stateChange.Time.Before(collectedAt) {
+			row.HeldSince = stateChange.Time
+		}
+
+		locks = append(locks, row)
+	}
+
+	return locks, rows.Err()
+}
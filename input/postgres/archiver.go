@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/pganalyze/collector/state"
+)
+
+const archiverStatsSQL string = `
+SELECT archived_count, last_archived_wal, last_archived_time,
+			 failed_count, last_failed_wal, last_failed_time, stats_reset
+	FROM pg_catalog.pg_stat_archiver`
+
+// GetArchiverStats - Returns WAL archiver statistics, nil on Postgres versions before 9.4
+//
+// Note that the counters are tracked regardless of whether archive_mode is enabled (and stay zero if its not)
+func GetArchiverStats(db *sql.DB, postgresVersion state.PostgresVersion) (*state.PostgresArchiverStats, error) {
+	if postgresVersion.Numeric < state.PostgresVersion94 {
+		return nil, nil
+	}
+
+	var s state.PostgresArchiverStats
+	err := db.QueryRow(QueryMarkerSQL+archiverStatsSQL).Scan(&s.ArchivedCount, &s.LastArchivedWal, &s.LastArchivedTime,
+		&s.FailedCount, &s.LastFailedWal, &s.LastFailedTime, &s.StatsReset)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
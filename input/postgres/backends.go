@@ -8,9 +8,10 @@ import (
 	"github.com/pganalyze/collector/util"
 )
 
-const activitySQLDefaultOptionalFields = "waiting, NULL, NULL, NULL, NULL, NULL"
-const activitySQLpg94OptionalFields = "waiting, backend_xid, backend_xmin, NULL, NULL, NULL"
-const activitySQLpg96OptionalFields = "wait_event IS NOT NULL, backend_xid, backend_xmin, wait_event_type, wait_event, NULL"
+// Before Postgres 10 backend_type doesn't exist, and pg_stat_activity only contains client backends
+const activitySQLDefaultOptionalFields = "waiting, NULL, NULL, NULL, NULL, 'client backend'"
+const activitySQLpg94OptionalFields = "waiting, backend_xid, backend_xmin, NULL, NULL, 'client backend'"
+const activitySQLpg96OptionalFields = "wait_event IS NOT NULL, backend_xid, backend_xmin, wait_event_type, wait_event, 'client backend'"
 const activitySQLpg10OptionalFields = "wait_event IS NOT NULL, backend_xid, backend_xmin, wait_event_type, wait_event, backend_type"
 
 const activitySQL string = `SELECT (extract(epoch from COALESCE(backend_start, pg_postmaster_start_time()))::int::text || to_char(pid, 'FM000000'))::bigint,
@@ -19,6 +20,42 @@ const activitySQL string = `SELECT (extract(epoch from COALESCE(backend_start, p
 	 FROM %s
 	WHERE pid IS NOT NULL`
 
+const backendTypeCountsSQL string = `SELECT %s, count(*) FROM %s WHERE pid IS NOT NULL GROUP BY 1`
+
+// GetBackendTypeCounts - Counts the current backends by their type (e.g. "client backend" or "autovacuum worker")
+func GetBackendTypeCounts(db *sql.DB, postgresVersion state.PostgresVersion) (state.PostgresBackendTypeCounts, error) {
+	backendTypeField := "'client backend'"
+	if postgresVersion.Numeric >= state.PostgresVersion10 {
+		backendTypeField = "COALESCE(backend_type, 'client backend')"
+	}
+
+	sourceTable := "pg_stat_activity"
+	if statsHelperExists(db, "get_stat_activity") {
+		sourceTable = "pganalyze.get_stat_activity()"
+	}
+
+	rows, err := db.Query(QueryMarkerSQL + fmt.Sprintf(backendTypeCountsSQL, backendTypeField, sourceTable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(state.PostgresBackendTypeCounts)
+	for rows.Next() {
+		var backendType string
+		var count int64
+
+		err = rows.Scan(&backendType, &count)
+		if err != nil {
+			return nil, err
+		}
+
+		counts[backendType] = count
+	}
+
+	return counts, rows.Err()
+}
+
 func GetBackends(logger *util.Logger, db *sql.DB, postgresVersion state.PostgresVersion) ([]state.PostgresBackend, error) {
 	var optionalFields string
 	var sourceTable string
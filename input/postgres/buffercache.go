@@ -100,19 +100,25 @@ func GetBuffercache(logger *util.Logger, db *sql.DB) (report state.PostgresBuffe
 
 	rows, err := db.Query(QueryMarkerSQL + fmt.Sprintf(buffercacheSQL, sourceTable))
 	if err != nil {
-		if err.(*pq.Error).Code == "42P01" { // undefined_table
-			logger.PrintInfo("pg_buffercache relation does not exist, trying to create extension...")
-
-			_, err = db.Exec(QueryMarkerSQL + "CREATE EXTENSION IF NOT EXISTS pg_buffercache")
-			if err != nil {
-				return
-			}
-
-			rows, err = db.Query(QueryMarkerSQL + buffercacheSQL)
-			if err != nil {
-				return
-			}
-		} else {
+		pqErr, ok := err.(*pq.Error)
+		if !ok || pqErr.Code != "42P01" { // undefined_table
+			return
+		}
+
+		if inRecovery, _ := GetIsInRecovery(db); inRecovery {
+			err = fmt.Errorf("pg_buffercache relation does not exist, and can't be created on a standby - please run CREATE EXTENSION pg_buffercache on the primary")
+			return
+		}
+
+		logger.PrintInfo("pg_buffercache relation does not exist, trying to create extension...")
+
+		err = execReadWrite(db, "CREATE EXTENSION IF NOT EXISTS pg_buffercache")
+		if err != nil {
+			return
+		}
+
+		rows, err = db.Query(QueryMarkerSQL + buffercacheSQL)
+		if err != nil {
 			return
 		}
 	}
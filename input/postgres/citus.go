@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/guregu/null"
+	"github.com/lib/pq"
+	"github.com/pganalyze/collector/state"
+)
+
+const citusNodesSQL string = `
+SELECT nodeid, groupid, nodename, nodeport, noderole, nodecluster, isactive, shouldhaveshards
+	FROM pg_catalog.pg_dist_node`
+
+const citusStatementStatsSQL string = `
+SELECT dbid, userid, queryid, executor, partition_key, calls
+	FROM citus_stat_statements`
+
+const citusTableSizesSQL string = `
+SELECT logicalrelid::oid,
+			 pg_catalog.citus_relation_size(logicalrelid),
+			 pg_catalog.citus_total_relation_size(logicalrelid)
+	FROM pg_catalog.pg_dist_partition`
+
+// CitusAvailable - Whether the citus extension is installed in the current database
+func CitusAvailable(db *sql.DB) bool {
+	return extensionExists(db, "citus")
+}
+
+// GetCitus - Collects the cluster membership, distributed query stats and distributed table sizes
+//
+// Note that citus_stat_statements is not available in all Citus releases, in which case the
+// statement stats are left empty.
+func GetCitus(db *sql.DB) (c state.CitusState, err error) {
+	c.Nodes, err = getCitusNodes(db)
+	if err != nil {
+		return
+	}
+
+	c.StatementStats, err = getCitusStatementStats(db)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); !ok || pqErr.Code != "42P01" { // undefined_table
+			return
+		}
+		err = nil
+	}
+
+	c.TableSizes, err = getCitusTableSizes(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func getCitusNodes(db *sql.DB) ([]state.CitusNode, error) {
+	rows, err := db.Query(QueryMarkerSQL + citusNodesSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var nodes []state.CitusNode
+	for rows.Next() {
+		var n state.CitusNode
+
+		err = rows.Scan(&n.NodeID, &n.GroupID, &n.Name, &n.Port, &n.Role, &n.Cluster, &n.IsActive, &n.ShouldHaveShards)
+		if err != nil {
+			return nil, err
+		}
+
+		nodes = append(nodes, n)
+	}
+
+	return nodes, rows.Err()
+}
+
+func getCitusStatementStats(db *sql.DB) (state.CitusStatementStatsMap, error) {
+	rows, err := db.Query(QueryMarkerSQL + citusStatementStatsSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statementStats := make(state.CitusStatementStatsMap)
+	for rows.Next() {
+		var key state.CitusStatementKey
+		var partitionKey null.String
+		var stats state.CitusStatementStats
+
+		err = rows.Scan(&key.DatabaseOid, &key.UserOid, &key.QueryID, &key.Executor, &partitionKey, &stats.Calls)
+		if err != nil {
+			return nil, err
+		}
+
+		key.PartitionKey = partitionKey.String
+		statementStats[key] = stats
+	}
+
+	return statementStats, rows.Err()
+}
+
+func getCitusTableSizes(db *sql.DB) (map[state.Oid]state.CitusTableSize, error) {
+	rows, err := db.Query(QueryMarkerSQL + citusTableSizesSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tableSizes := make(map[state.Oid]state.CitusTableSize)
+	for rows.Next() {
+		var oid state.Oid
+		var size state.CitusTableSize
+
+		err = rows.Scan(&oid, &size.SizeBytes, &size.TotalSizeBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		tableSizes[oid] = size
+	}
+
+	return tableSizes, rows.Err()
+}
@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+
+	"github.com/pganalyze/collector/state"
+	"github.com/pganalyze/collector/util"
+)
+
+const collectorLockApplicationName = "pganalyze_collector_lock"
+
+const tryAdvisoryLockSQL string = `SELECT pg_catalog.pg_try_advisory_lock($1)`
+
+// Advisory locks on a bigint key are shown with the high 32 bits in classid, the low 32 bits in
+// objid, and objsubid = 1
+const advisoryLockHeldSQL string = `
+SELECT pg_catalog.count(*) > 0
+	FROM pg_catalog.pg_locks
+ WHERE locktype = 'advisory'
+			 AND pid = pg_catalog.pg_backend_pid()
+			 AND granted
+			 AND objsubid = 1
+			 AND ((classid::bigint << 32) | objid::bigint) = $1`
+
+// CollectorLock - Session-level advisory lock that marks the collector holding it as the one that
+// collects and submits for a server, when several redundant collectors are configured for it
+//
+// The lock is held by a dedicated connection, and released by Postgres when that connection goes
+// away (e.g. because the collector exited or crashed).
+type CollectorLock struct {
+	db   *sql.DB
+	conn *sql.Conn
+	key  int64
+}
+
+// CollectorLockKey - Advisory lock key for the server, the same for all collectors that use the
+// same API key and system ID for it
+func CollectorLockKey(server state.Server) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("pganalyze-collector\x00" + server.Config.APIKey + "\x00" + server.Config.SystemID))
+	return int64(h.Sum64())
+}
+
+// TryAcquireCollectorLock - Attempts to take the collector lock for the server, returns nil if
+// another collector is currently holding it
+func TryAcquireCollectorLock(server state.Server, logger *util.Logger, globalCollectionOpts state.CollectionOpts) (*CollectorLock, error) {
+	// Use a separate application name, so the long-lived connection stands out (and doesn't count
+	// towards the limit of monitoring connections)
+	lockCollectionOpts := globalCollectionOpts
+	lockCollectionOpts.CollectorApplicationName = collectorLockApplicationName
+
+	db, err := connectToDb(server.Config, logger, lockCollectionOpts, "", "")
+	if err != nil {
+		return nil, err
+	}
+	db.SetConnMaxLifetime(0)
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	key := CollectorLockKey(server)
+	var acquired bool
+	err = conn.QueryRowContext(context.Background(), QueryMarkerSQL+tryAdvisoryLockSQL, key).Scan(&acquired)
+	if err != nil || !acquired {
+		conn.Close()
+		db.Close()
+		return nil, err
+	}
+
+	return &CollectorLock{db: db, conn: conn, key: key}, nil
+}
+
+// Held - Checks the lock is still held by our session
+//
+// This has to run an actual query, since lib/pq doesn't detect a dead connection on Ping. Any
+// error (e.g. the backend got terminated, or the server restarted) means the lock is lost.
+func (l *CollectorLock) Held() bool {
+	var held bool
+	err := l.conn.QueryRowContext(context.Background(), QueryMarkerSQL+advisoryLockHeldSQL, l.key).Scan(&held)
+	return err == nil && held
+}
+
+// Release - Closes the lock connection, which lets another collector take over
+func (l *CollectorLock) Release() {
+	l.conn.Close()
+	l.db.Close()
+}
@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"testing"
+)
+
+// fakeLockBackend - Stands in for the Postgres backend holding the lock, answering the lock check
+type fakeLockBackend struct {
+	held   bool
+	closed bool
+}
+
+var fakeLockBackends = map[string]*fakeLockBackend{}
+
+type fakeLockDriver struct{}
+
+func (fakeLockDriver) Open(name string) (driver.Conn, error) {
+	return &fakeLockConn{backend: fakeLockBackends[name]}, nil
+}
+
+type fakeLockConn struct {
+	backend *fakeLockBackend
+}
+
+func (c *fakeLockConn) Prepare(query string) (driver.Stmt, error) {
+	if c.backend.closed {
+		return nil, driver.ErrBadConn
+	}
+	return fakeLockStmt{c.backend}, nil
+}
+func (c *fakeLockConn) Close() error              { return nil }
+func (c *fakeLockConn) Begin() (driver.Tx, error) { return nil, driver.ErrSkip }
+
+type fakeLockStmt struct {
+	backend *fakeLockBackend
+}
+
+func (s fakeLockStmt) Close() error                                    { return nil }
+func (s fakeLockStmt) NumInput() int                                   { return 1 }
+func (s fakeLockStmt) Exec(args []driver.Value) (driver.Result, error) { return nil, driver.ErrSkip }
+func (s fakeLockStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if s.backend.closed {
+		return nil, driver.ErrBadConn
+	}
+	return &fakeLockRows{held: s.backend.held}, nil
+}
+
+type fakeLockRows struct {
+	held bool
+	done bool
+}
+
+func (r *fakeLockRows) Columns() []string { return []string{"held"} }
+func (r *fakeLockRows) Close() error      { return nil }
+func (r *fakeLockRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.held
+	return nil
+}
+
+func init() {
+	sql.Register("fakelock", fakeLockDriver{})
+}
+
+func TestCollectorLockHeld(t *testing.T) {
+	backend := &fakeLockBackend{held: true}
+	fakeLockBackends["held"] = backend
+
+	db, err := sql.Open("fakelock", "held")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock := &CollectorLock{db: db, conn: conn, key: 42}
+	defer lock.Release()
+
+	if !lock.Held() {
+		t.Errorf("Expected lock to be held")
+	}
+
+	backend.held = false
+	if lock.Held() {
+		t.Errorf("Expected lock to be lost when it no longer shows up for our backend")
+	}
+
+	backend.held = true
+	backend.closed = true
+	if lock.Held() {
+		t.Errorf("Expected lock to be lost after the backend went away")
+	}
+}
@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pganalyze/collector/state"
+)
+
+const columnCorrelationsSQL string = `
+SELECT c.oid, a.attnum, pg_stats.correlation
+	FROM %s
+			 JOIN pg_catalog.pg_namespace n ON (n.nspname = pg_stats.schemaname)
+			 JOIN pg_catalog.pg_class c ON (c.relnamespace = n.oid AND c.relname = pg_stats.tablename)
+			 JOIN pg_catalog.pg_attribute a ON (a.attrelid = c.oid AND a.attname = pg_stats.attname)
+ WHERE NOT pg_stats.inherited
+			 AND pg_stats.correlation IS NOT NULL
+			 AND c.relkind IN ('r','m')
+			 AND n.nspname NOT IN ('pg_catalog','pg_toast','information_schema')
+			 AND EXISTS (SELECT 1 FROM pg_catalog.pg_index i WHERE i.indrelid = c.oid AND a.attnum = ANY(i.indkey))`
+
+// GetColumnCorrelations - Returns the correlation between physical row order and the sort order
+// of each indexed column (as of the last ANALYZE)
+//
+// Like GetRelationsWithColumnStats this requires superuser or the pganalyze.get_column_stats()
+// helper, otherwise known is false.
+func GetColumnCorrelations(db *sql.DB) (correlations state.PostgresColumnCorrelationMap, known bool, err error) {
+	var sourceTable string
+	if columnStatsHelperExists(db) {
+		sourceTable = "(SELECT * FROM pganalyze.get_column_stats()) pg_stats"
+	} else if connectedAsSuperUser(db) {
+		sourceTable = "pg_stats"
+	} else {
+		return nil, false, nil
+	}
+
+	rows, err := db.Query(QueryMarkerSQL + fmt.Sprintf(columnCorrelationsSQL, sourceTable))
+	if err != nil {
+		err = fmt.Errorf("ColumnCorrelations/Query: %s", err)
+		return
+	}
+	defer rows.Close()
+
+	correlations = make(state.PostgresColumnCorrelationMap)
+	for rows.Next() {
+		var oid state.Oid
+		var attnum int32
+		var correlation float64
+		err = rows.Scan(&oid, &attnum, &correlation)
+		if err != nil {
+			err = fmt.Errorf("ColumnCorrelations/Scan: %s", err)
+			return
+		}
+		if correlations[oid] == nil {
+			correlations[oid] = make(map[int32]float64)
+		}
+		correlations[oid][attnum] = correlation
+	}
+
+	return correlations, true, rows.Err()
+}
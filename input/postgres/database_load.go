@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/pganalyze/collector/state"
+)
+
+const databaseLoadSQL string = `
+SELECT (SELECT pg_catalog.count(*)
+					FROM %s
+				 WHERE state = 'active' AND pid <> pg_catalog.pg_backend_pid()),
+			 pg_catalog.current_setting('max_connections')::int
+`
+
+// GetDatabaseLoad - Determines how busy the server is, including how long it took to answer us
+func GetDatabaseLoad(db *sql.DB) (load state.PostgresDatabaseLoad, err error) {
+	sourceTable := "pg_catalog.pg_stat_activity"
+	if statsHelperExists(db, "get_stat_activity") {
+		sourceTable = "pganalyze.get_stat_activity()"
+	}
+
+	start := time.Now()
+	err = db.QueryRow(QueryMarkerSQL+fmt.Sprintf(databaseLoadSQL, sourceTable)).Scan(&load.ActiveBackends, &load.MaxConnections)
+	load.QueryLatency = time.Since(start)
+	return
+}
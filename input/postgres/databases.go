@@ -23,7 +23,8 @@ SELECT oid,
 			 datallowconn,
 			 datconnlimit,
 			 datfrozenxid,
-			 %s
+			 %s,
+			 CASE WHEN has_database_privilege(oid, 'CONNECT') THEN pg_catalog.pg_database_size(oid) END
 	FROM pg_database`
 
 func GetDatabases(logger *util.Logger, db *sql.DB, postgresVersion state.PostgresVersion) ([]state.PostgresDatabase, error) {
@@ -55,7 +56,8 @@ func GetDatabases(logger *util.Logger, db *sql.DB, postgresVersion state.Postgre
 		var d state.PostgresDatabase
 
 		err := rows.Scan(&d.Oid, &d.Name, &d.OwnerRoleOid, &d.Encoding, &d.Collate, &d.CType,
-			&d.IsTemplate, &d.AllowConnections, &d.ConnectionLimit, &d.FrozenXID, &d.MinimumMultixactXID)
+			&d.IsTemplate, &d.AllowConnections, &d.ConnectionLimit, &d.FrozenXID, &d.MinimumMultixactXID,
+			&d.SizeBytes)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/pganalyze/collector/state"
+	"github.com/pganalyze/collector/util"
+)
+
+// ErrDeadlineExceeded - A collector was cancelled (or not started) since the collection deadline was reached
+var ErrDeadlineExceeded = errors.New("exceeded the collection deadline")
+
+const backendPidSQL string = "SELECT pg_catalog.pg_backend_pid()"
+
+const cancelBackendSQL string = "SELECT pg_catalog.pg_cancel_backend($1)"
+
+// RunWithDeadline - Runs fn (which issues queries on db) until it returns, or until ctx is done, in
+// which case the currently running query gets cancelled and ErrDeadlineExceeded is returned (or
+// ctx.Err() when ctx was cancelled for a different reason, e.g. because we're shutting down)
+//
+// Our lib/pq version doesn't support cancelling queries through a context, so this cancels the
+// query using pg_cancel_backend() from a separate connection instead. This relies on db only having
+// a single connection (see connectToDb), which is not recycled while fn runs, since otherwise
+// we'd cancel whatever unrelated backend ends up with the old pid. fn is always finished when
+// this returns.
+func RunWithDeadline(ctx context.Context, server state.Server, logger *util.Logger, collectionOpts state.CollectionOpts, db *sql.DB, fn func() error) error {
+	if ctx.Err() != nil {
+		return deadlineError(ctx)
+	}
+
+	if server.Connection == nil {
+		db.SetConnMaxLifetime(0)
+		defer db.SetConnMaxLifetime(connMaxLifetime)
+	}
+
+	var pid int
+	err := db.QueryRow(QueryMarkerSQL + backendPidSQL).Scan(&pid)
+	if err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+
+	select {
+	case err = <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	reason := "Collection deadline reached"
+	if ctx.Err() != context.DeadlineExceeded {
+		reason = "Collection cancelled"
+	}
+
+	if server.Connection != nil {
+		logger.PrintVerbose("%s, waiting for query on backend %d to finish (can't cancel when using an injected connection)", reason, pid)
+		<-done
+		return deadlineError(ctx)
+	}
+
+	logger.PrintVerbose("%s, cancelling query on backend %d", reason, pid)
+
+	cancelConnection, err := EstablishConnection(server, logger, collectionOpts, "")
+	if err != nil {
+		logger.PrintWarning("Could not connect to cancel query (%s): %s", reason, err)
+		<-done
+		return deadlineError(ctx)
+	}
+	defer CloseConnection(server, cancelConnection)
+
+	// fn might continue with its next query after the current one got cancelled, so keep
+	// cancelling until it returns
+	for {
+		_, err = cancelConnection.Exec(QueryMarkerSQL+cancelBackendSQL, pid)
+		if err != nil {
+			logger.PrintVerbose("Could not cancel query on backend %d: %s", pid, err)
+		}
+
+		select {
+		case <-done:
+			return deadlineError(ctx)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// deadlineError - Returns ErrDeadlineExceeded if the deadline of ctx was reached, and otherwise
+// ctx.Err() as-is, so callers don't mistake a shutdown for a slow collector
+func deadlineError(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return ErrDeadlineExceeded
+	}
+	return ctx.Err()
+}
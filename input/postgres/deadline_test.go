@@ -0,0 +1,141 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pganalyze/collector/state"
+)
+
+func TestRunWithDeadlineAlreadyDone(t *testing.T) {
+	deadlineCtx, cancelDeadline := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelDeadline()
+	shutdownCtx, cancelShutdown := context.WithCancel(context.Background())
+	cancelShutdown()
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		expected error
+	}{
+		{"deadline reached", deadlineCtx, ErrDeadlineExceeded},
+		{"cancelled on shutdown", shutdownCtx, context.Canceled},
+	}
+
+	for _, test := range tests {
+		called := false
+		err := RunWithDeadline(test.ctx, state.Server{}, nil, state.CollectionOpts{}, nil, func() error {
+			called = true
+			return nil
+		})
+		if err != test.expected {
+			t.Errorf("%s: expected %v, actual %v", test.name, test.expected, err)
+		}
+		if called {
+			t.Errorf("%s: expected fn not to be called", test.name)
+		}
+	}
+}
+
+// pidConnector - Fake driver that returns a new backend pid for every connection it opens, and
+// answers every query with the pid of the connection it runs on
+type pidConnector struct {
+	lastPid *int32
+}
+
+type pidConn struct {
+	pid int32
+}
+
+type pidStmt struct {
+	pid int32
+}
+
+type pidRows struct {
+	pid  int32
+	done bool
+}
+
+func (c pidConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return pidConn{pid: atomic.AddInt32(c.lastPid, 1)}, nil
+}
+
+func (c pidConnector) Driver() driver.Driver {
+	return nil
+}
+
+func (c pidConn) Prepare(query string) (driver.Stmt, error) {
+	return pidStmt{pid: c.pid}, nil
+}
+
+func (c pidConn) Close() error {
+	return nil
+}
+
+func (c pidConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+func (s pidStmt) Close() error {
+	return nil
+}
+
+func (s pidStmt) NumInput() int {
+	return -1
+}
+
+func (s pidStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.ResultNoRows, nil
+}
+
+func (s pidStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &pidRows{pid: s.pid}, nil
+}
+
+func (r *pidRows) Columns() []string {
+	return []string{"pg_backend_pid"}
+}
+
+func (r *pidRows) Close() error {
+	return nil
+}
+
+func (r *pidRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(r.pid)
+	return nil
+}
+
+func TestRunWithDeadlineKeepsConnection(t *testing.T) {
+	var lastPid int32
+	db := sql.OpenDB(pidConnector{lastPid: &lastPid})
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxLifetime(10 * time.Millisecond)
+
+	var pidBefore, pidAfter int
+	err := RunWithDeadline(context.Background(), state.Server{}, nil, state.CollectionOpts{}, db, func() error {
+		err := db.QueryRow(backendPidSQL).Scan(&pidBefore)
+		if err != nil {
+			return err
+		}
+		// Long enough for the connection to be recycled, if the lifetime still applied
+		time.Sleep(50 * time.Millisecond)
+		return db.QueryRow(backendPidSQL).Scan(&pidAfter)
+	})
+	if err != nil {
+		t.Fatalf("expected no error, actual %v", err)
+	}
+	if pidBefore != 1 || pidAfter != 1 {
+		t.Errorf("expected all queries to run on backend 1, actual %d and %d", pidBefore, pidAfter)
+	}
+}
@@ -1,49 +1,184 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/pganalyze/collector/config"
 	"github.com/pganalyze/collector/state"
 	"github.com/pganalyze/collector/util"
+	"github.com/pganalyze/collector/util/awsutil"
 )
 
 func EstablishConnection(server state.Server, logger *util.Logger, globalCollectionOpts state.CollectionOpts, databaseName string) (connection *sql.DB, err error) {
-	connection, err = connectToDb(server.Config, logger, globalCollectionOpts, databaseName)
-	if err != nil {
-		if err.Error() == "pq: SSL is not enabled on the server" && (server.Config.DbSslMode == "prefer" || server.Config.DbSslMode == "") {
-			server.Config.DbSslModePreferFailed = true
-			connection, err = connectToDb(server.Config, logger, globalCollectionOpts, databaseName)
+	sessionSettings := statementTimeoutSetting(logger, server.Grant.Config.Features.StatementTimeoutMs) +
+		lockTimeoutSetting(server.Config.LockTimeoutMs) +
+		readOnlySetting
+
+	if server.Connection != nil {
+		return useInjectedConnection(server, databaseName, sessionSettings)
+	}
+
+	connect := func(connConfig config.ServerConfig) (*sql.DB, error) {
+		connection, err := connectToDb(connConfig, logger, globalCollectionOpts, databaseName, sessionSettings)
+		if err != nil {
+			if err.Error() == "pq: SSL is not enabled on the server" && (connConfig.DbSslMode == "prefer" || connConfig.DbSslMode == "") {
+				connConfig.DbSslModePreferFailed = true
+				connection, err = connectToDb(connConfig, logger, globalCollectionOpts, databaseName, sessionSettings)
+			}
+		}
+		return connection, err
+	}
+
+	if server.Config.PatroniURL != "" {
+		// The leader may have changed since we discovered it, in which case we look it up again and retry
+		var leaderConfig config.ServerConfig
+		leaderConfig, err = withPatroniLeader(server.Config, logger, false)
+		if err != nil {
+			return
+		}
+		connection, err = connect(leaderConfig)
+		if err != nil {
+			logger.PrintVerbose("Failed to connect to Patroni leader %s: %s", leaderConfig.DbHost, err)
+			leaderConfig, err = withPatroniLeader(server.Config, logger, true)
+			if err != nil {
+				return
+			}
+			connection, err = connect(leaderConfig)
 		}
+	} else {
+		connection, err = connect(server.Config)
 	}
 
 	if err != nil {
 		return
 	}
 
-	validateConnectionCount(connection, logger, globalCollectionOpts)
-	setStatementTimeout(connection, logger, server.Grant.Config.Features.StatementTimeoutMs)
+	validateConnectionCount(connection, logger, server.Config.GetApplicationName(globalCollectionOpts.CollectorApplicationName))
 
 	return
 }
 
-func connectToDb(config config.ServerConfig, logger *util.Logger, globalCollectionOpts state.CollectionOpts, databaseName string) (*sql.DB, error) {
-	connectString := config.GetPqOpenString(databaseName)
-	connectString += " application_name=" + globalCollectionOpts.CollectorApplicationName
+// useInjectedConnection - Returns the connection that was passed in when embedding the collector
+// (see state.Server.Connection), after applying the session settings (the equivalent of SET)
+//
+// SET only affects the current session, so the connection should be limited to a single open
+// connection (db.SetMaxOpenConns(1)), like the ones we establish ourselves.
+func useInjectedConnection(server state.Server, databaseName string, sessionSettings string) (*sql.DB, error) {
+	if databaseName != "" && databaseName != server.Config.DbName {
+		return nil, fmt.Errorf("can't connect to database %s when using an injected connection", databaseName)
+	}
+
+	for _, setting := range strings.Fields(sessionSettings) {
+		keyValue := strings.SplitN(setting, "=", 2)
+		_, err := server.Connection.Exec(QueryMarkerSQL+setConfigSQL, keyValue[0], keyValue[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return server.Connection, nil
+}
+
+const setConfigSQL = "SELECT pg_catalog.set_config($1, $2, false)"
+
+// applySessionSettings - Runs the equivalent of SET for each of the settings (" key=value" pairs) on a new connection
+func applySessionSettings(conn driver.Conn, sessionSettings string) error {
+	execer, ok := conn.(driver.Execer)
+	if !ok {
+		return fmt.Errorf("database driver does not support Exec")
+	}
+	for _, setting := range strings.Fields(sessionSettings) {
+		keyValue := strings.SplitN(setting, "=", 2)
+		_, err := execer.Exec(QueryMarkerSQL+setConfigSQL, []driver.Value{keyValue[0], keyValue[1]})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CloseConnection - Closes a connection returned by EstablishConnection, unless it's the injected
+// connection of the server, which is owned by the caller that passed it in
+func CloseConnection(server state.Server, db *sql.DB) {
+	if db != server.Connection {
+		db.Close()
+	}
+}
 
-	// logger.PrintVerbose("sql.Open(\"postgres\", \"%s\")", connectString)
+// connector - Determines the connect string separately for each new connection, so that
+// short-lived credentials (RDS IAM auth tokens) are valid even when database/sql reconnects
+//
+// The session settings are applied right after connecting (instead of as run-time parameters
+// in the connect string), since connection poolers like pgbouncer reject unknown startup
+// parameters. Doing it here makes them apply to every connection database/sql re-establishes,
+// e.g. after SetConnMaxLifetime expires.
+type connector struct {
+	getConnectString func() (string, error)
+	sessionSettings  string
+}
 
-	db, err := sql.Open("postgres", connectString)
+func (c connector) Connect(ctx context.Context) (driver.Conn, error) {
+	connectString, err := c.getConnectString()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := pq.Open(connectString)
+	if err != nil {
+		return nil, err
+	}
+	err = applySessionSettings(conn, c.sessionSettings)
 	if err != nil {
+		conn.Close()
 		return nil, err
 	}
+	return conn, nil
+}
+
+func (c connector) Driver() driver.Driver {
+	return pqDriver{}
+}
+
+type pqDriver struct{}
+
+func (d pqDriver) Open(name string) (driver.Conn, error) {
+	return pq.Open(name)
+}
+
+func connectToDb(config config.ServerConfig, logger *util.Logger, globalCollectionOpts state.CollectionOpts, databaseName string, sessionSettings string) (*sql.DB, error) {
+	getConnectString := func() (string, error) {
+		connConfig := config
+		// IAM auth tokens expire after 15 minutes, so we generate a fresh one for each connection
+		if connConfig.DbUseIamAuth {
+			token, err := awsutil.GenerateRdsAuthToken(connConfig)
+			if err != nil {
+				return "", fmt.Errorf("could not generate RDS IAM auth token: %s", err)
+			}
+			connConfig.DbPassword = token
+		}
+
+		connectString, err := connConfig.GetPqOpenString(databaseName)
+		if err != nil {
+			return "", fmt.Errorf("could not determine database password: %s", err)
+		}
+		connectString += withoutExplicitParams(" application_name="+globalCollectionOpts.CollectorApplicationName, connConfig.DbConnectionParams)
+
+		// logger.PrintVerbose("pq.Open(\"%s\")", connectString)
+
+		return connectString, nil
+	}
+
+	db := sql.OpenDB(connector{getConnectString: getConnectString, sessionSettings: withoutExplicitParams(sessionSettings, config.DbConnectionParams)})
 
 	db.SetMaxOpenConns(1)
-	db.SetConnMaxLifetime(30 * time.Second)
+	db.SetConnMaxLifetime(connMaxLifetime)
 
-	err = db.Ping()
+	err := db.Ping()
 	if err != nil {
 		return nil, err
 	}
@@ -51,10 +186,28 @@ func connectToDb(config config.ServerConfig, logger *util.Logger, globalCollecti
 	return db, nil
 }
 
-func validateConnectionCount(connection *sql.DB, logger *util.Logger, globalCollectionOpts state.CollectionOpts) {
+// connMaxLifetime - How long database/sql may reuse a connection established by connectToDb
+const connMaxLifetime = 30 * time.Second
+
+// withoutExplicitParams - Removes the settings (" key=value" pairs) that are overridden by db_param_<name> settings
+func withoutExplicitParams(settings string, explicitParams map[string]string) string {
+	if len(explicitParams) == 0 {
+		return settings
+	}
+	var kept string
+	for _, param := range strings.Fields(settings) {
+		key := strings.SplitN(param, "=", 2)[0]
+		if _, exists := explicitParams[key]; !exists {
+			kept += " " + param
+		}
+	}
+	return kept
+}
+
+func validateConnectionCount(connection *sql.DB, logger *util.Logger, applicationName string) {
 	var connectionCount int
 
-	connection.QueryRow(QueryMarkerSQL + "SELECT COUNT(*) FROM pg_stat_activity WHERE application_name = '" + globalCollectionOpts.CollectorApplicationName + "'").Scan(&connectionCount)
+	connection.QueryRow(QueryMarkerSQL+"SELECT COUNT(*) FROM pg_stat_activity WHERE application_name = $1", applicationName).Scan(&connectionCount)
 
 	if connectionCount > 5 {
 		logger.PrintError("Too many open monitoring connections (current: %d, maximum allowed: 5), exiting", connectionCount)
@@ -64,18 +217,100 @@ func validateConnectionCount(connection *sql.DB, logger *util.Logger, globalColl
 	return
 }
 
-func setStatementTimeout(connection *sql.DB, logger *util.Logger, statementTimeoutMs int32) {
-	if statementTimeoutMs == 0 { // Default value
-		statementTimeoutMs = 30000
+// Statement timeout used when the grant doesn't specify one
+const defaultStatementTimeoutMs = 30000
+
+// statementTimeoutSetting - Returns the statement_timeout to set on each new connection (see connector)
+func statementTimeoutSetting(logger *util.Logger, statementTimeoutMs *int32) string {
+	if statementTimeoutMs == nil { // Not set in the grant
+		return fmt.Sprintf(" statement_timeout=%d", defaultStatementTimeoutMs)
+	}
+
+	if *statementTimeoutMs <= 0 {
+		return " statement_timeout=0"
 	}
 
 	// Assume anything below 100ms to be set in error - its not reasonable to have our queries run faster than that
-	if statementTimeoutMs < 100 {
-		logger.PrintVerbose("Ignoring invalid statement timeout of %dms (set it to at least 100ms)", statementTimeoutMs)
-		return
+	if *statementTimeoutMs < 100 {
+		logger.PrintVerbose("Ignoring invalid statement timeout of %dms (set it to at least 100ms)", *statementTimeoutMs)
+		return ""
+	}
+
+	return fmt.Sprintf(" statement_timeout=%d", *statementTimeoutMs)
+}
+
+// lockTimeoutSetting - Returns the lock_timeout to set on each new connection (see connector)
+//
+// Unlike statement_timeout this also covers the time spent waiting for a lock before a
+// query starts executing, e.g. when a migration holds an ACCESS EXCLUSIVE lock.
+func lockTimeoutSetting(lockTimeoutMs int) string {
+	if lockTimeoutMs <= 0 {
+		return " lock_timeout=0"
 	}
 
-	connection.Exec(fmt.Sprintf("%sSET statement_timeout = %d", QueryMarkerSQL, statementTimeoutMs))
+	return fmt.Sprintf(" lock_timeout=%d", lockTimeoutMs)
+}
 
-	return
+// All our connections are read-only by default (set on each new connection, see connector), so
+// that a buggy query errors out instead of modifying data. The only exceptions are the CREATE
+// EXTENSION calls we do on behalf of the user, which explicitly go through execReadWrite.
+//
+// Note that pg_stat_statements_reset() and EXPLAIN (without ANALYZE) work fine within a
+// read-only transaction, and are therefore not exempted.
+const readOnlySetting = " default_transaction_read_only=on"
+
+// execReadWrite - Runs a single statement that needs to write, in its own read-write transaction
+func execReadWrite(db *sql.DB, query string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(QueryMarkerSQL + "SET TRANSACTION READ WRITE")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(QueryMarkerSQL + query)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// IsStatementTimeout - Whether the error was caused by a query exceeding statement_timeout
+//
+// Some collectors wrap the original error using fmt.Errorf, so this also checks the message.
+func IsStatementTimeout(err error) bool {
+	if pqErr, ok := err.(*pq.Error); ok {
+		// query_canceled is also used for pg_cancel_backend(), which we don't want to match here
+		return pqErr.Code == "57014" && strings.Contains(pqErr.Message, "statement timeout")
+	}
+	return err != nil && strings.Contains(err.Error(), "canceling statement due to statement timeout")
+}
+
+// IsLockTimeout - Whether the error was caused by a query waiting longer than lock_timeout
+func IsLockTimeout(err error) bool {
+	if pqErr, ok := err.(*pq.Error); ok {
+		return pqErr.Code == "55P03" && strings.Contains(pqErr.Message, "lock timeout") // lock_not_available
+	}
+	return err != nil && strings.Contains(err.Error(), "canceling statement due to lock timeout")
+}
+
+// TimeoutReason - Describes why a query was canceled in case it hit statement_timeout or
+// lock_timeout, and returns an empty string for all other errors
+//
+// Timeouts are expected to happen occasionally, and should only skip the affected part of
+// a snapshot, instead of failing the whole snapshot.
+func TimeoutReason(err error) string {
+	if IsStatementTimeout(err) {
+		return "query was canceled due to statement_timeout"
+	}
+	if IsLockTimeout(err) {
+		return "could not acquire lock within lock_timeout (is a migration running?)"
+	}
+	return ""
 }
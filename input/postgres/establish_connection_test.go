@@ -0,0 +1,39 @@
+package postgres
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"testing"
+
+	"github.com/pganalyze/collector/util"
+)
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+var statementTimeoutSettingTests = []struct {
+	statementTimeoutMs *int32
+	expected           string
+}{
+	{nil, " statement_timeout=30000"},
+	{int32Ptr(0), " statement_timeout=0"},
+	{int32Ptr(-1), " statement_timeout=0"},
+	{int32Ptr(50), ""},
+	{int32Ptr(5000), " statement_timeout=5000"},
+}
+
+func TestStatementTimeoutSetting(t *testing.T) {
+	logger := &util.Logger{Destination: log.New(ioutil.Discard, "", 0)}
+	for _, test := range statementTimeoutSettingTests {
+		actual := statementTimeoutSetting(logger, test.statementTimeoutMs)
+		timeout := "not set"
+		if test.statementTimeoutMs != nil {
+			timeout = fmt.Sprintf("%d", *test.statementTimeoutMs)
+		}
+		if actual != test.expected {
+			t.Errorf("\nTimeout: %s\nExpected: %q\n actual: %q", timeout, test.expected, actual)
+		}
+	}
+}
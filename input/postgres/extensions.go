@@ -0,0 +1,44 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/pganalyze/collector/state"
+)
+
+const extensionsSQL string = `
+SELECT e.extname,
+			 e.extversion,
+			 n.nspname,
+			 a.default_version,
+			 COALESCE(a.default_version <> e.extversion AND EXISTS (
+				 SELECT 1
+					 FROM pg_catalog.pg_available_extension_versions v
+					WHERE v.name = e.extname AND v.version = a.default_version
+			 ), false)
+	FROM pg_catalog.pg_extension e
+			 JOIN pg_catalog.pg_namespace n ON (n.oid = e.extnamespace)
+			 LEFT JOIN pg_catalog.pg_available_extensions a ON (a.name = e.extname)`
+
+// GetExtensions - Collects the extensions installed in the current database
+func GetExtensions(db *sql.DB, currentDatabaseOid state.Oid) ([]state.PostgresExtension, error) {
+	rows, err := db.Query(QueryMarkerSQL + extensionsSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var extensions []state.PostgresExtension
+	for rows.Next() {
+		e := state.PostgresExtension{DatabaseOid: currentDatabaseOid}
+
+		err = rows.Scan(&e.ExtensionName, &e.Version, &e.SchemaName, &e.DefaultVersion, &e.UpdateAvailable)
+		if err != nil {
+			return nil, err
+		}
+
+		extensions = append(extensions, e)
+	}
+
+	return extensions, rows.Err()
+}
@@ -36,6 +36,15 @@ const functionStatsSQL string = `
 SELECT funcid, calls, total_time, self_time
 	FROM pg_stat_user_functions`
 
+const trackFunctionsSQL string = `SELECT setting FROM pg_settings WHERE name = 'track_functions'`
+
+// GetTrackFunctions - Reads the track_functions setting, which needs to be "pl" or "all" for
+// pg_stat_user_functions to contain any statistics
+func GetTrackFunctions(db *sql.DB) (setting null.String, err error) {
+	err = db.QueryRow(QueryMarkerSQL + trackFunctionsSQL).Scan(&setting)
+	return
+}
+
 func GetFunctions(db *sql.DB, postgresVersion state.PostgresVersion, currentDatabaseOid state.Oid) ([]state.PostgresFunction, error) {
 	stmt, err := db.Prepare(QueryMarkerSQL + functionsSQL)
 	if err != nil {
@@ -0,0 +1,70 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/guregu/null"
+	"github.com/pganalyze/collector/state"
+	"github.com/pganalyze/collector/util"
+)
+
+// Only superusers can read pg_hba_file_rules by default, unless EXECUTE on the underlying
+// function was granted explicitly (e.g. to the monitoring user)
+const hbaRulesAllowedSQL string = `SELECT pg_catalog.has_function_privilege('pg_catalog.pg_hba_file_rules()', 'EXECUTE')`
+
+// See also https://www.postgresql.org/docs/10/static/view-pg-hba-file-rules.html
+const hbaRulesSQL string = `
+SELECT line_number,
+			 type,
+			 database::text,
+			 user_name::text,
+			 address,
+			 netmask,
+			 auth_method,
+			 options::text,
+			 error
+	FROM pg_catalog.pg_hba_file_rules
+ ORDER BY line_number`
+
+// GetHbaRules - Reads the client authentication rules from pg_hba.conf, when the collector is
+// permitted to (Postgres 10 and newer)
+func GetHbaRules(logger *util.Logger, db *sql.DB, postgresVersion state.PostgresVersion) ([]state.PostgresHbaRule, error) {
+	if postgresVersion.Numeric < state.PostgresVersion10 {
+		logger.PrintVerbose("Skipping pg_hba.conf rules, since pg_hba_file_rules requires Postgres 10 or newer")
+		return nil, nil
+	}
+
+	var allowed bool
+	err := db.QueryRow(QueryMarkerSQL + hbaRulesAllowedSQL).Scan(&allowed)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		logger.PrintVerbose("Skipping pg_hba.conf rules, since the collector user can't read pg_hba_file_rules")
+		return nil, nil
+	}
+
+	rows, err := db.Query(QueryMarkerSQL + hbaRulesSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []state.PostgresHbaRule
+	for rows.Next() {
+		var r state.PostgresHbaRule
+		var databases, userNames, options null.String
+
+		err = rows.Scan(&r.LineNumber, &r.Type, &databases, &userNames, &r.Address, &r.Netmask, &r.AuthMethod, &options, &r.Error)
+		if err != nil {
+			return nil, err
+		}
+		r.Databases = unpackPostgresStringArray(databases)
+		r.UserNames = unpackPostgresStringArray(userNames)
+		r.Options = unpackPostgresStringArray(options)
+
+		rules = append(rules, r)
+	}
+
+	return rules, rows.Err()
+}
@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/guregu/null"
+	"github.com/pganalyze/collector/state"
+)
+
+// Backends waiting for a lock held by a.pid (pg_blocking_pids is only available in 9.6+, before
+// that we match waiting lock requests against the granted locks of the same lockable object)
+const idleInTransactionBlockedPidsDefault = `
+(SELECT array_agg(DISTINCT w.pid)
+	 FROM pg_locks h
+	 JOIN pg_locks w ON (w.pid <> h.pid AND NOT w.granted
+	                     AND w.locktype = h.locktype
+	                     AND w.database IS NOT DISTINCT FROM h.database
+	                     AND w.relation IS NOT DISTINCT FROM h.relation
+	                     AND w.page IS NOT DISTINCT FROM h.page
+	                     AND w.tuple IS NOT DISTINCT FROM h.tuple
+	                     AND w.virtualxid IS NOT DISTINCT FROM h.virtualxid
+	                     AND w.transactionid IS NOT DISTINCT FROM h.transactionid
+	                     AND w.classid IS NOT DISTINCT FROM h.classid
+	                     AND w.objid IS NOT DISTINCT FROM h.objid
+	                     AND w.objsubid IS NOT DISTINCT FROM h.objsubid)
+	WHERE h.pid = a.pid AND h.granted)`
+const idleInTransactionBlockedPidsPg96 = `
+(SELECT array_agg(b.pid) FROM pg_stat_activity b WHERE a.pid = ANY(pg_catalog.pg_blocking_pids(b.pid)))`
+
+const idleInTransactionSQL string = `
+SELECT a.pid, a.datname, a.usename, a.application_name, a.client_addr::text,
+			 a.xact_start, a.state_change, a.state, a.query,
+			 (SELECT count(*) FROM pg_locks l WHERE l.pid = a.pid AND l.granted),
+			 %s
+	FROM %s a
+ WHERE a.state IN ('idle in transaction', 'idle in transaction (aborted)')`
+
+// GetIdleInTransactionBlockers - Finds idle-in-transaction sessions that hold locks other backends are waiting for
+func GetIdleInTransactionBlockers(db *sql.DB, postgresVersion state.PostgresVersion) ([]state.PostgresIdleInTransactionBlocker, error) {
+	blockedPids := idleInTransactionBlockedPidsDefault
+	if postgresVersion.Numeric >= state.PostgresVersion96 {
+		blockedPids = idleInTransactionBlockedPidsPg96
+	}
+
+	sourceTable := "pg_stat_activity"
+	if statsHelperExists(db, "get_stat_activity") {
+		sourceTable = "pganalyze.get_stat_activity()"
+	}
+
+	rows, err := db.Query(QueryMarkerSQL + fmt.Sprintf(idleInTransactionSQL, blockedPids, sourceTable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blockers []state.PostgresIdleInTransactionBlocker
+	for rows.Next() {
+		var row state.PostgresIdleInTransactionBlocker
+		var query null.String
+		var blocked null.String
+
+		err = rows.Scan(&row.Pid, &row.DatabaseName, &row.RoleName, &row.ApplicationName, &row.ClientAddr,
+			&row.XactStart, &row.StateChange, &row.State, &query, &row.HeldLocks, &blocked)
+		if err != nil {
+			return nil, err
+		}
+
+		row.BlockedPids = unpackPostgresInt32Array(blocked)
+		if len(row.BlockedPids) == 0 {
+			continue
+		}
+
+		row.Query = query.String
+		if !query.Valid {
+			row.Query = state.InsufficientPrivilegeQueryText
+		}
+
+		blockers = append(blockers, row)
+	}
+
+	return blockers, rows.Err()
+}
@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pganalyze/collector/config"
+	"github.com/pganalyze/collector/util"
+)
+
+type patroniCluster struct {
+	Members []patroniMember `json:"members"`
+}
+
+type patroniMember struct {
+	Name  string `json:"name"`
+	Role  string `json:"role"`
+	State string `json:"state"`
+	Host  string `json:"host"`
+	Port  int    `json:"port"`
+}
+
+var patroniHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// Last discovered leader for each patroni_url, shared by all connections (and kept across reloads)
+// until connecting to it fails
+var patroniLeaders = make(map[string]patroniMember)
+var patroniLeadersMutex sync.Mutex
+
+// discoverPatroniLeader - Asks the Patroni REST API which cluster member is the current leader
+func discoverPatroniLeader(patroniURL string) (patroniMember, error) {
+	resp, err := patroniHTTPClient.Get(strings.TrimSuffix(patroniURL, "/") + "/cluster")
+	if err != nil {
+		return patroniMember{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return patroniMember{}, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var cluster patroniCluster
+	err = json.NewDecoder(resp.Body).Decode(&cluster)
+	if err != nil {
+		return patroniMember{}, err
+	}
+
+	for _, member := range cluster.Members {
+		// Patroni versions before 2.0 called the leader "master"
+		if (member.Role == "leader" || member.Role == "master") && member.Host != "" {
+			return member, nil
+		}
+	}
+
+	return patroniMember{}, fmt.Errorf("no leader in cluster (%d members)", len(cluster.Members))
+}
+
+// withPatroniLeader - Returns the config pointed at the current Patroni leader, using the
+// previously discovered leader unless refresh is set
+func withPatroniLeader(serverConfig config.ServerConfig, logger *util.Logger, refresh bool) (config.ServerConfig, error) {
+	patroniLeadersMutex.Lock()
+	defer patroniLeadersMutex.Unlock()
+
+	leader, known := patroniLeaders[serverConfig.PatroniURL]
+	if !known || refresh {
+		discovered, err := discoverPatroniLeader(serverConfig.PatroniURL)
+		if err != nil {
+			return serverConfig, fmt.Errorf("could not discover Patroni leader: %s", err)
+		}
+		if !known || discovered.Host != leader.Host || discovered.Port != leader.Port {
+			logger.PrintInfo("Patroni leader is %s (%s:%d)", discovered.Name, discovered.Host, discovered.Port)
+		}
+		leader = discovered
+		patroniLeaders[serverConfig.PatroniURL] = leader
+	}
+
+	serverConfig.DbHost = leader.Host
+	if leader.Port != 0 {
+		serverConfig.DbPort = leader.Port
+	}
+	return serverConfig, nil
+}
@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var discoverPatroniLeaderTests = []struct {
+	response     string
+	expectedHost string
+	expectedErr  bool
+}{
+	{`{"members": [{"name": "pg1", "role": "replica", "host": "10.0.0.1", "port": 5432}, {"name": "pg2", "role": "leader", "host": "10.0.0.2", "port": 5433}]}`, "10.0.0.2:5433", false},
+	{`{"members": [{"name": "pg1", "role": "master", "host": "10.0.0.1", "port": 5432}]}`, "10.0.0.1:5432", false},
+	{`{"members": [{"name": "pg1", "role": "replica", "host": "10.0.0.1", "port": 5432}]}`, "", true},
+	{`not json`, "", true},
+}
+
+func TestDiscoverPatroniLeader(t *testing.T) {
+	for _, test := range discoverPatroniLeaderTests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/cluster" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprint(w, test.response)
+		}))
+
+		leader, err := discoverPatroniLeader(server.URL + "/")
+		server.Close()
+
+		if test.expectedErr {
+			if err == nil {
+				t.Errorf("\nResponse: %s\nExpected an error, actual leader: %+v", test.response, leader)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("\nResponse: %s\nUnexpected error: %s", test.response, err)
+			continue
+		}
+		actual := fmt.Sprintf("%s:%d", leader.Host, leader.Port)
+		if actual != test.expectedHost {
+			t.Errorf("\nResponse: %s\nExpected: %s\n actual: %s", test.response, test.expectedHost, actual)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package postgres
+
+import (
+	"database/sql"
+)
+
+const inRecoverySQL string = `SELECT pg_catalog.pg_is_in_recovery()`
+
+// GetIsInRecovery - Determines whether the connected server is a standby (i.e. in recovery),
+// which means we can only run read-only statements against it
+func GetIsInRecovery(db *sql.DB) (inRecovery bool, err error) {
+	err = db.QueryRow(QueryMarkerSQL + inRecoverySQL).Scan(&inRecovery)
+	return
+}
@@ -9,7 +9,29 @@ import (
 
 const relationStatsSQLDefaultOptionalFields = "NULL"
 const relationStatsSQLpg94OptionalFields = "s.n_mod_since_analyze"
-
+const relationStatsSQLNewpageUpdDefaultField = "0"
+const relationStatsSQLpg16NewpageUpdField = "COALESCE(s.n_tup_newpage_upd, 0)"
+
+// Before Postgres 16 pg_statio_all_tables joined the table's indexes and the TOAST indexes in one
+// GROUP BY, so tidx_blks_read/tidx_blks_hit got multiplied by the number of indexes on the table.
+// We keep reporting the same values as the view did on each release.
+const relationStatsSQLDefaultTidxFields = `
+			 (COALESCE((SELECT pg_catalog.sum(pg_catalog.pg_stat_get_blocks_fetched(i.indexrelid) - pg_catalog.pg_stat_get_blocks_hit(i.indexrelid))
+									 FROM pg_catalog.pg_index i WHERE i.indrelid = NULLIF(c.reltoastrelid, 0)), 0)
+				* GREATEST((SELECT pg_catalog.count(*) FROM pg_catalog.pg_index i WHERE i.indrelid = s.relid), 1))::bigint AS tidx_blks_read,
+			 (COALESCE((SELECT pg_catalog.sum(pg_catalog.pg_stat_get_blocks_hit(i.indexrelid))
+									 FROM pg_catalog.pg_index i WHERE i.indrelid = NULLIF(c.reltoastrelid, 0)), 0)
+				* GREATEST((SELECT pg_catalog.count(*) FROM pg_catalog.pg_index i WHERE i.indrelid = s.relid), 1))::bigint AS tidx_blks_hit`
+const relationStatsSQLpg16TidxFields = `
+			 COALESCE((SELECT pg_catalog.sum(pg_catalog.pg_stat_get_blocks_fetched(i.indexrelid) - pg_catalog.pg_stat_get_blocks_hit(i.indexrelid))
+									 FROM pg_catalog.pg_index i WHERE i.indrelid = NULLIF(c.reltoastrelid, 0)), 0)::bigint AS tidx_blks_read,
+			 COALESCE((SELECT pg_catalog.sum(pg_catalog.pg_stat_get_blocks_hit(i.indexrelid))
+									 FROM pg_catalog.pg_index i WHERE i.indrelid = NULLIF(c.reltoastrelid, 0)), 0)::bigint AS tidx_blks_hit`
+
+// Note: The block I/O and TOAST statistics use the pg_stat_get_* functions directly, instead
+// of joining pg_statio_user_tables and pg_stat_all_tables, since both views aggregate over all
+// relations and would be evaluated in full, which gets slow on databases with many tables.
+// The expressions match the view definitions (see TestRelationStatsMatchLegacy).
 const relationStatsSQL = `
 SELECT s.relid,
 			 COALESCE(pg_catalog.pg_table_size(s.relid), 0) AS size_bytes,
@@ -21,6 +43,7 @@ SELECT s.relid,
 			 COALESCE(s.n_tup_upd, 0),
 			 COALESCE(s.n_tup_del, 0),
 			 COALESCE(s.n_tup_hot_upd, 0),
+			 %s,
 			 COALESCE(s.n_live_tup, 0),
 			 COALESCE(s.n_dead_tup, 0),
 			 %s,
@@ -32,16 +55,21 @@ SELECT s.relid,
 			 COALESCE(s.autovacuum_count, 0),
 			 COALESCE(s.analyze_count, 0),
 			 COALESCE(s.autoanalyze_count, 0),
-			 COALESCE(sio.heap_blks_read, 0),
-			 COALESCE(sio.heap_blks_hit, 0),
-			 COALESCE(sio.idx_blks_read, 0),
-			 COALESCE(sio.idx_blks_hit, 0),
-			 COALESCE(sio.toast_blks_read, 0),
-			 COALESCE(sio.toast_blks_hit, 0),
-			 COALESCE(sio.tidx_blks_read, 0),
-			 COALESCE(sio.tidx_blks_hit, 0)
+			 pg_catalog.pg_stat_get_blocks_fetched(s.relid) - pg_catalog.pg_stat_get_blocks_hit(s.relid) AS heap_blks_read,
+			 pg_catalog.pg_stat_get_blocks_hit(s.relid) AS heap_blks_hit,
+			 COALESCE((SELECT pg_catalog.sum(pg_catalog.pg_stat_get_blocks_fetched(i.indexrelid) - pg_catalog.pg_stat_get_blocks_hit(i.indexrelid))
+									 FROM pg_catalog.pg_index i WHERE i.indrelid = s.relid), 0)::bigint AS idx_blks_read,
+			 COALESCE((SELECT pg_catalog.sum(pg_catalog.pg_stat_get_blocks_hit(i.indexrelid))
+									 FROM pg_catalog.pg_index i WHERE i.indrelid = s.relid), 0)::bigint AS idx_blks_hit,
+			 CASE WHEN c.reltoastrelid <> 0 THEN pg_catalog.pg_stat_get_blocks_fetched(c.reltoastrelid) - pg_catalog.pg_stat_get_blocks_hit(c.reltoastrelid) ELSE 0 END AS toast_blks_read,
+			 CASE WHEN c.reltoastrelid <> 0 THEN pg_catalog.pg_stat_get_blocks_hit(c.reltoastrelid) ELSE 0 END AS toast_blks_hit,
+			 %s,
+			 COALESCE(pg_catalog.pg_relation_size(NULLIF(c.reltoastrelid, 0)), 0) AS toast_size_bytes,
+			 CASE WHEN c.reltoastrelid <> 0 THEN pg_catalog.pg_stat_get_live_tuples(c.reltoastrelid) ELSE 0 END AS toast_n_live_tup,
+			 CASE WHEN c.reltoastrelid <> 0 THEN pg_catalog.pg_stat_get_dead_tuples(c.reltoastrelid) ELSE 0 END AS toast_n_dead_tup,
+			 CASE WHEN c.reltoastrelid <> 0 THEN pg_catalog.pg_stat_get_last_autovacuum_time(c.reltoastrelid) END AS toast_last_autovacuum
 	FROM pg_stat_user_tables s
-			 LEFT JOIN pg_statio_user_tables sio USING (relid);
+			 LEFT JOIN pg_catalog.pg_class c ON (c.oid = s.relid);
 `
 
 const indexStatsSQL = `
@@ -50,10 +78,9 @@ SELECT s.indexrelid,
 			 COALESCE(s.idx_scan, 0),
 			 COALESCE(s.idx_tup_read, 0),
 			 COALESCE(s.idx_tup_fetch, 0),
-			 COALESCE(sio.idx_blks_read, 0),
-			 COALESCE(sio.idx_blks_hit, 0)
-	FROM pg_stat_user_indexes s
-			 LEFT JOIN pg_statio_user_indexes sio USING (indexrelid);
+			 pg_catalog.pg_stat_get_blocks_fetched(s.indexrelid) - pg_catalog.pg_stat_get_blocks_hit(s.indexrelid) AS idx_blks_read,
+			 pg_catalog.pg_stat_get_blocks_hit(s.indexrelid) AS idx_blks_hit
+	FROM pg_stat_user_indexes s;
 `
 
 func GetRelationStats(db *sql.DB, postgresVersion state.PostgresVersion) (relStats state.PostgresRelationStatsMap, err error) {
@@ -65,7 +92,14 @@ func GetRelationStats(db *sql.DB, postgresVersion state.PostgresVersion) (relSta
 		optionalFields = relationStatsSQLDefaultOptionalFields
 	}
 
-	stmt, err := db.Prepare(QueryMarkerSQL + fmt.Sprintf(relationStatsSQL, optionalFields))
+	newpageUpdField := relationStatsSQLNewpageUpdDefaultField
+	tidxFields := relationStatsSQLDefaultTidxFields
+	if postgresVersion.Numeric >= state.PostgresVersion16 {
+		newpageUpdField = relationStatsSQLpg16NewpageUpdField
+		tidxFields = relationStatsSQLpg16TidxFields
+	}
+
+	stmt, err := db.Prepare(QueryMarkerSQL + fmt.Sprintf(relationStatsSQL, newpageUpdField, optionalFields, tidxFields))
 	if err != nil {
 		err = fmt.Errorf("RelationStats/Prepare: %s", err)
 		return
@@ -87,13 +121,14 @@ func GetRelationStats(db *sql.DB, postgresVersion state.PostgresVersion) (relSta
 		err = rows.Scan(&oid, &stats.SizeBytes, &stats.SeqScan, &stats.SeqTupRead,
 			&stats.IdxScan, &stats.IdxTupFetch, &stats.NTupIns,
 			&stats.NTupUpd, &stats.NTupDel, &stats.NTupHotUpd,
-			&stats.NLiveTup, &stats.NDeadTup, &stats.NModSinceAnalyze,
+			&stats.NTupNewpageUpd, &stats.NLiveTup, &stats.NDeadTup, &stats.NModSinceAnalyze,
 			&stats.LastVacuum, &stats.LastAutovacuum, &stats.LastAnalyze,
 			&stats.LastAutoanalyze, &stats.VacuumCount, &stats.AutovacuumCount,
 			&stats.AnalyzeCount, &stats.AutoanalyzeCount, &stats.HeapBlksRead,
 			&stats.HeapBlksHit, &stats.IdxBlksRead, &stats.IdxBlksHit,
 			&stats.ToastBlksRead, &stats.ToastBlksHit, &stats.TidxBlksRead,
-			&stats.TidxBlksHit)
+			&stats.TidxBlksHit, &stats.ToastSizeBytes, &stats.ToastNLiveTup,
+			&stats.ToastNDeadTup, &stats.ToastLastAutovacuum)
 		if err != nil {
 			err = fmt.Errorf("RelationStats/Scan: %s", err)
 			return
@@ -105,6 +140,48 @@ func GetRelationStats(db *sql.DB, postgresVersion state.PostgresVersion) (relSta
 	return
 }
 
+const relationsWithColumnStatsSQL = `
+SELECT DISTINCT c.oid
+	FROM %s
+			 JOIN pg_catalog.pg_namespace n ON (n.nspname = pg_stats.schemaname)
+			 JOIN pg_catalog.pg_class c ON (c.relnamespace = n.oid AND c.relname = pg_stats.tablename)
+`
+
+// GetRelationsWithColumnStats - Returns the tables that have planner statistics (rows in pg_statistic)
+//
+// pg_stats only shows columns the connecting user can read, so unless we are superuser or
+// the pganalyze.get_column_stats() helper is set up, we can't tell and known is false.
+func GetRelationsWithColumnStats(db *sql.DB) (oids map[state.Oid]bool, known bool, err error) {
+	var sourceTable string
+	if columnStatsHelperExists(db) {
+		sourceTable = "(SELECT * FROM pganalyze.get_column_stats()) pg_stats"
+	} else if connectedAsSuperUser(db) {
+		sourceTable = "pg_stats"
+	} else {
+		return nil, false, nil
+	}
+
+	rows, err := db.Query(QueryMarkerSQL + fmt.Sprintf(relationsWithColumnStatsSQL, sourceTable))
+	if err != nil {
+		err = fmt.Errorf("RelationsWithColumnStats/Query: %s", err)
+		return
+	}
+	defer rows.Close()
+
+	oids = make(map[state.Oid]bool)
+	for rows.Next() {
+		var oid state.Oid
+		err = rows.Scan(&oid)
+		if err != nil {
+			err = fmt.Errorf("RelationsWithColumnStats/Scan: %s", err)
+			return
+		}
+		oids[oid] = true
+	}
+
+	return oids, true, nil
+}
+
 func GetIndexStats(db *sql.DB, postgresVersion state.PostgresVersion) (indexStats state.PostgresIndexStatsMap, err error) {
 	stmt, err := db.Prepare(QueryMarkerSQL + indexStatsSQL)
 	if err != nil {
@@ -0,0 +1,180 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	_ "github.com/lib/pq" // Enable database package to use Postgres
+	"github.com/pganalyze/collector/state"
+)
+
+// Previous version of relationStatsSQL, which joined pg_statio_user_tables and pg_stat_all_tables
+const legacyRelationStatsSQL = `
+SELECT s.relid,
+			 COALESCE(pg_catalog.pg_table_size(s.relid), 0) AS size_bytes,
+			 COALESCE(s.seq_scan, 0),
+			 COALESCE(s.seq_tup_read, 0),
+			 COALESCE(s.idx_scan, 0),
+			 COALESCE(s.idx_tup_fetch, 0),
+			 COALESCE(s.n_tup_ins, 0),
+			 COALESCE(s.n_tup_upd, 0),
+			 COALESCE(s.n_tup_del, 0),
+			 COALESCE(s.n_tup_hot_upd, 0),
+			 COALESCE(s.n_live_tup, 0),
+			 COALESCE(s.n_dead_tup, 0),
+			 %s,
+			 s.last_vacuum,
+			 s.last_autovacuum,
+			 s.last_analyze,
+			 s.last_autoanalyze,
+			 COALESCE(s.vacuum_count, 0),
+			 COALESCE(s.autovacuum_count, 0),
+			 COALESCE(s.analyze_count, 0),
+			 COALESCE(s.autoanalyze_count, 0),
+			 COALESCE(sio.heap_blks_read, 0),
+			 COALESCE(sio.heap_blks_hit, 0),
+			 COALESCE(sio.idx_blks_read, 0),
+			 COALESCE(sio.idx_blks_hit, 0),
+			 COALESCE(sio.toast_blks_read, 0),
+			 COALESCE(sio.toast_blks_hit, 0),
+			 COALESCE(sio.tidx_blks_read, 0),
+			 COALESCE(sio.tidx_blks_hit, 0),
+			 COALESCE(pg_catalog.pg_relation_size(NULLIF(c.reltoastrelid, 0)), 0) AS toast_size_bytes,
+			 COALESCE(ts.n_live_tup, 0),
+			 COALESCE(ts.n_dead_tup, 0),
+			 ts.last_autovacuum
+	FROM pg_stat_user_tables s
+			 LEFT JOIN pg_statio_user_tables sio USING (relid)
+			 LEFT JOIN pg_catalog.pg_class c ON (c.oid = s.relid)
+			 LEFT JOIN pg_stat_all_tables ts ON (ts.relid = c.reltoastrelid);
+`
+
+// Previous version of indexStatsSQL, which joined pg_statio_user_indexes
+const legacyIndexStatsSQL = `
+SELECT s.indexrelid,
+			 COALESCE(pg_catalog.pg_relation_size(s.indexrelid), 0) AS size_bytes,
+			 COALESCE(s.idx_scan, 0),
+			 COALESCE(s.idx_tup_read, 0),
+			 COALESCE(s.idx_tup_fetch, 0),
+			 COALESCE(sio.idx_blks_read, 0),
+			 COALESCE(sio.idx_blks_hit, 0)
+	FROM pg_stat_user_indexes s
+			 LEFT JOIN pg_statio_user_indexes sio USING (indexrelid);
+`
+
+// These benchmarks (and TestRelationStatsMatchLegacy) need a database to run against, ideally
+// one with a large schema, e.g.:
+//
+//	PGA_BENCHMARK_DB_URL=postgres://localhost/bench go test -run - -bench . ./input/postgres/
+func benchmarkDb(tb testing.TB) *sql.DB {
+	dbURL := os.Getenv("PGA_BENCHMARK_DB_URL")
+	if dbURL == "" {
+		tb.Skip("PGA_BENCHMARK_DB_URL not set")
+	}
+
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return db
+}
+
+// Runs the query and returns each row's columns as text, keyed by the first column (the OID)
+func queryRowsByOid(t *testing.T, db *sql.DB, query string, skipColumn int) map[string][]string {
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(map[string][]string)
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			t.Fatal(err)
+		}
+
+		var row []string
+		for i, v := range values {
+			if i != skipColumn {
+				row = append(row, fmt.Sprintf("%v", v))
+			}
+		}
+		result[values[0].String] = row
+	}
+
+	return result
+}
+
+// The statistics must not change while the test runs, so this should run against an idle database
+func TestRelationStatsMatchLegacy(t *testing.T) {
+	db := benchmarkDb(t)
+	defer db.Close()
+
+	var versionNum int
+	if err := db.QueryRow("SELECT current_setting('server_version_num')::int").Scan(&versionNum); err != nil {
+		t.Fatal(err)
+	}
+	tidxFields := relationStatsSQLDefaultTidxFields
+	if versionNum >= state.PostgresVersion16 {
+		tidxFields = relationStatsSQLpg16TidxFields
+	}
+
+	// The legacy query doesn't have n_tup_newpage_upd (the 11th column of the current query)
+	expected := queryRowsByOid(t, db, fmt.Sprintf(legacyRelationStatsSQL, relationStatsSQLpg94OptionalFields), -1)
+	actual := queryRowsByOid(t, db, fmt.Sprintf(relationStatsSQL, relationStatsSQLNewpageUpdDefaultField, relationStatsSQLpg94OptionalFields, tidxFields), 10)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Relation stats differ from the legacy query\nexpected: %v\nactual:   %v", expected, actual)
+	}
+
+	expected = queryRowsByOid(t, db, legacyIndexStatsSQL, -1)
+	actual = queryRowsByOid(t, db, indexStatsSQL, -1)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Index stats differ from the legacy query\nexpected: %v\nactual:   %v", expected, actual)
+	}
+}
+
+func benchmarkQuery(b *testing.B, query string) {
+	db := benchmarkDb(b)
+	defer db.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rows, err := db.Query(query)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for rows.Next() {
+		}
+		rows.Close()
+	}
+}
+
+func BenchmarkRelationStatsLegacy(b *testing.B) {
+	benchmarkQuery(b, fmt.Sprintf(legacyRelationStatsSQL, relationStatsSQLpg94OptionalFields))
+}
+
+func BenchmarkRelationStats(b *testing.B) {
+	benchmarkQuery(b, fmt.Sprintf(relationStatsSQL, relationStatsSQLNewpageUpdDefaultField, relationStatsSQLpg94OptionalFields, relationStatsSQLDefaultTidxFields))
+}
+
+func BenchmarkIndexStatsLegacy(b *testing.B) {
+	benchmarkQuery(b, legacyIndexStatsSQL)
+}
+
+func BenchmarkIndexStats(b *testing.B) {
+	benchmarkQuery(b, indexStatsSQL)
+}
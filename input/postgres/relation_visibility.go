@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pganalyze/collector/state"
+	"github.com/pganalyze/collector/util"
+)
+
+const relationVisibilitySQLDefaultFields = "NULL::bigint, NULL::bigint"
+const relationVisibilitySQLSummaryFields = "v.all_visible, v.all_frozen"
+
+// Reading the visibility map takes an AccessShareLock and reads the whole VM fork, so we only
+// do it for tables that could be flagged by state.PostgresRelationVisibility.WraparoundRisk()
+const relationVisibilitySQLSummaryJoin = `
+	LEFT JOIN LATERAL (
+		SELECT * FROM %s.pg_visibility_map_summary(c.oid)
+		 WHERE c.relpages >= %d
+					 AND pg_catalog.age(c.relfrozenxid) >= pg_catalog.current_setting('autovacuum_freeze_max_age')::bigint * 3 / 4
+	) v ON (true)`
+
+const relationVisibilitySQL string = `
+	WITH locked_relids AS (SELECT DISTINCT relation relid FROM pg_locks WHERE mode = 'AccessExclusiveLock')
+SELECT c.oid, c.relpages, c.relallvisible, %s, pg_catalog.age(c.relfrozenxid),
+			 pg_catalog.current_setting('autovacuum_freeze_max_age')::bigint
+	FROM pg_catalog.pg_class c
+			 LEFT JOIN pg_catalog.pg_namespace n ON (n.oid = c.relnamespace)
+			 %s
+ WHERE c.relkind IN ('r','m')
+			 AND c.relpersistence <> 't'
+			 AND n.nspname NOT IN ('pg_catalog','pg_toast','information_schema')
+			 AND c.oid NOT IN (SELECT relid FROM locked_relids)`
+
+// Only returns a schema if we can use the function (superusers always can, everyone else needs
+// an explicit GRANT EXECUTE)
+const visibilitySummarySchemaSQL string = `
+SELECT pg_catalog.quote_ident(n.nspname)
+	FROM pg_catalog.pg_extension e
+			 JOIN pg_catalog.pg_namespace n ON (n.oid = e.extnamespace)
+ WHERE e.extname = 'pg_visibility'
+			 AND pg_catalog.has_function_privilege(pg_catalog.quote_ident(n.nspname) || '.pg_visibility_map_summary(regclass)', 'EXECUTE')`
+
+// GetRelationVisibility - Returns the all-visible and all-frozen pages of each table in the current
+// database, the latter only when the pg_visibility extension is installed and usable, and only for
+// large tables with an old relfrozenxid (tables locked by e.g. ALTER TABLE are skipped)
+func GetRelationVisibility(logger *util.Logger, db *sql.DB) (state.PostgresRelationVisibilityMap, error) {
+	fields := relationVisibilitySQLDefaultFields
+	join := ""
+
+	var schema string
+	err := db.QueryRow(QueryMarkerSQL + visibilitySummarySchemaSQL).Scan(&schema)
+	if err == nil {
+		fields = relationVisibilitySQLSummaryFields
+		join = fmt.Sprintf(relationVisibilitySQLSummaryJoin, schema, state.VisibilityWraparoundRiskMinPages)
+	} else if err == sql.ErrNoRows {
+		logger.PrintVerbose("Skipping all-frozen page counts, since the pg_visibility extension is not installed or not usable by the collector user")
+	} else {
+		return nil, err
+	}
+
+	rows, err := db.Query(QueryMarkerSQL + fmt.Sprintf(relationVisibilitySQL, fields, join))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	visibility := make(state.PostgresRelationVisibilityMap)
+	for rows.Next() {
+		var oid state.Oid
+		var v state.PostgresRelationVisibility
+
+		err = rows.Scan(&oid, &v.RelPages, &v.RelAllVisible, &v.AllVisible, &v.AllFrozen, &v.XidAge, &v.FreezeMaxAge)
+		if err != nil {
+			return nil, err
+		}
+
+		visibility[oid] = v
+	}
+
+	return visibility, rows.Err()
+}
@@ -10,8 +10,8 @@ import (
 	"github.com/pganalyze/collector/state"
 )
 
-const relationsSQLDefaultOptionalFields = "0"
-const relationsSQLpg93OptionalFields = "c.relminmxid"
+const relationsSQLDefaultOptionalFields = "0, true"
+const relationsSQLpg93OptionalFields = "c.relminmxid, c.relispopulated"
 
 const relationsSQL string = `
 	 WITH locked_relids AS (SELECT DISTINCT relation relid FROM pg_locks WHERE mode = 'AccessExclusiveLock')
@@ -23,12 +23,15 @@ const relationsSQL string = `
 				c.relhasoids AS relation_has_oids,
 				c.relpersistence AS relation_persistence,
 				c.relhassubclass AS relation_has_inheritance_children,
-				c.reltoastrelid IS NULL AS relation_has_toast,
+				c.reltoastrelid <> 0 AS relation_has_toast,
 				c.relfrozenxid AS relation_frozen_xid,
 				%s,
+				c.relfilenode,
+				am.amname AS access_method,
 				locked_relids.relid IS NOT NULL
 	 FROM pg_catalog.pg_class c
 	 LEFT JOIN pg_catalog.pg_namespace n ON (n.oid = c.relnamespace)
+	 LEFT JOIN pg_catalog.pg_am am ON (am.oid = c.relam)
 	 LEFT JOIN locked_relids ON (c.oid = locked_relids.relid)
 	WHERE c.relkind IN ('r','v','m')
 				AND c.relpersistence <> 't'
@@ -46,7 +49,9 @@ const columnsSQL string = `
 			AND d.adnum = a.attnum
 			AND a.atthasdef) AS default_value,
 				a.attnotnull AS not_null,
-				a.attnum AS position
+				a.attnum AS position,
+				COALESCE(a.attstattarget, -1) AS statistics_target,
+				a.attoptions AS options
  FROM pg_catalog.pg_class c
  LEFT JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
  LEFT JOIN pg_catalog.pg_attribute a ON c.oid = a.attrelid
@@ -68,10 +73,11 @@ SELECT c.oid,
 			 i.indisprimary,
 			 i.indisunique,
 			 i.indisvalid,
+			 i.indisready,
 			 pg_catalog.pg_get_indexdef(i.indexrelid, 0, TRUE),
 			 pg_catalog.pg_get_constraintdef(con.oid, TRUE),
 			 c2.reloptions,
-			 (SELECT pg_am.amname FROM pg_am JOIN pg_opclass ON (pg_am.oid = pg_opclass.opcmethod) WHERE pg_opclass.oid = i.indclass[0])
+			 (SELECT pg_am.amname FROM pg_catalog.pg_am WHERE pg_am.oid = c2.relam)
 	FROM pg_catalog.pg_class c
 	JOIN pg_catalog.pg_namespace n ON (n.oid = c.relnamespace)
 	JOIN pg_catalog.pg_index i ON (c.oid = i.indrelid)
@@ -96,11 +102,16 @@ SELECT c.oid,
 			 confkey,
 			 confupdtype,
 			 confdeltype,
-			 confmatchtype
+			 confmatchtype,
+			 convalidated,
+			 condeferrable,
+			 condeferred
 	FROM pg_catalog.pg_constraint r
 			 JOIN pg_catalog.pg_class c ON r.conrelid = c.oid
 			 JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
-WHERE n.nspname NOT IN ('pg_catalog','pg_toast','information_schema')
+WHERE c.relkind IN ('r','v','m')
+			AND c.relpersistence <> 't'
+			AND n.nspname NOT IN ('pg_catalog','pg_toast','information_schema')
 			AND c.oid NOT IN (SELECT relid FROM locked_relids)`
 
 const viewDefinitionSQL string = `
@@ -141,7 +152,8 @@ func GetRelations(db *sql.DB, postgresVersion state.PostgresVersion, currentData
 
 		err = rows.Scan(&row.Oid, &row.SchemaName, &row.RelationName, &row.RelationType,
 			&options, &row.HasOids, &row.PersistenceType, &row.HasInheritanceChildren,
-			&row.HasToast, &row.FrozenXID, &row.MinimumMultixactXID, &row.ExclusivelyLocked)
+			&row.HasToast, &row.FrozenXID, &row.MinimumMultixactXID, &row.IsPopulated,
+			&row.Relfilenode, &row.AccessMethod, &row.ExclusivelyLocked)
 		if err != nil {
 			err = fmt.Errorf("Relations/Scan: %s", err)
 			return nil, err
@@ -171,14 +183,34 @@ func GetRelations(db *sql.DB, postgresVersion state.PostgresVersion, currentData
 
 	for rows.Next() {
 		var row state.PostgresColumn
+		var options null.String
 
 		err = rows.Scan(&row.RelationOid, &row.Name, &row.DataType, &row.DefaultValue,
-			&row.NotNull, &row.Position)
+			&row.NotNull, &row.Position, &row.StatisticsTarget, &options)
 		if err != nil {
 			err = fmt.Errorf("Columns/Scan: %s", err)
 			return nil, err
 		}
 
+		if options.Valid {
+			for _, cstr := range strings.Split(strings.Trim(options.String, "{}"), ",") {
+				parts := strings.SplitN(cstr, "=", 2)
+				if len(parts) != 2 {
+					continue
+				}
+				value, err := strconv.ParseFloat(parts[1], 64)
+				if err != nil {
+					continue
+				}
+				switch parts[0] {
+				case "n_distinct":
+					row.NDistinctOverride = null.FloatFrom(value)
+				case "n_distinct_inherited":
+					row.NDistinctInheritedOverride = null.FloatFrom(value)
+				}
+			}
+		}
+
 		relation := relations[row.RelationOid]
 		relation.Columns = append(relation.Columns, row)
 		relations[row.RelationOid] = relation
@@ -199,7 +231,7 @@ func GetRelations(db *sql.DB, postgresVersion state.PostgresVersion, currentData
 		var options null.String
 
 		err = rows.Scan(&row.RelationOid, &row.IndexOid, &columns, &row.Name, &row.IsPrimary,
-			&row.IsUnique, &row.IsValid, &row.IndexDef, &row.ConstraintDef, &options, &row.IndexType)
+			&row.IsUnique, &row.IsValid, &row.IsReady, &row.IndexDef, &row.ConstraintDef, &options, &row.IndexType)
 		if err != nil {
 			err = fmt.Errorf("Indices/Scan: %s", err)
 			return nil, err
@@ -239,7 +271,7 @@ func GetRelations(db *sql.DB, postgresVersion state.PostgresVersion, currentData
 
 		err = rows.Scan(&row.RelationOid, &row.Name, &row.Type, &row.ConstraintDef,
 			&columns, &row.ForeignOid, &foreignColumns, &foreignUpdateType,
-			&foreignDeleteType, &foreignMatchType)
+			&foreignDeleteType, &foreignMatchType, &row.IsValidated, &row.IsDeferrable, &row.IsDeferred)
 		if err != nil {
 			err = fmt.Errorf("Constraints/Scan: %s", err)
 			return nil, err
@@ -10,7 +10,7 @@ import (
 
 const replicationSQLPg10 string = `
 SELECT in_recovery,
-			 CASE WHEN in_recovery THEN NULL ELSE pg_current_wal_lsn() END AS current_xlog_location,
+			 CASE WHEN in_recovery THEN replay_location ELSE pg_current_wal_lsn() END AS current_xlog_location,
 			 COALESCE(receive_location, '0/0') >= replay_location AS is_streaming,
 			 receive_location,
 			 replay_location,
@@ -24,7 +24,7 @@ SELECT in_recovery,
 
 const replicationSQLPg9 string = `
 SELECT in_recovery,
-			 CASE WHEN in_recovery THEN NULL ELSE pg_current_xlog_location() END AS current_xlog_location,
+			 CASE WHEN in_recovery THEN replay_location ELSE pg_current_xlog_location() END AS current_xlog_location,
 			 COALESCE(receive_location, '0/0') >= replay_location AS is_streaming,
 			 receive_location,
 			 replay_location,
@@ -81,6 +81,13 @@ func GetReplication(logger *util.Logger, db *sql.DB, isHeroku bool, postgresVers
 	var replicationStandbySQL string
 	var replicationSQL string
 
+	// Aurora replicas don't use WAL streaming, and error out on pg_last_wal_receive_lsn()
+	if postgresVersion.Distribution == state.PostgresDistributionAmazonAurora {
+		logger.PrintVerbose("Skipping replication statistics, since they are not meaningful on Amazon Aurora")
+		repl.InRecovery, err = GetIsInRecovery(db)
+		return repl, err
+	}
+
 	if statsHelperExists(db, "get_stat_replication") {
 		logger.PrintVerbose("Found pganalyze.get_stat_replication() stats helper")
 		sourceTable = "pganalyze.get_stat_replication()"
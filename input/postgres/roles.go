@@ -13,6 +13,8 @@ const rolesSQLDefaultOptionalFields = "rolsuper"
 const rolesSQLpg95OptionalFields = "rolbypassrls"
 
 // See also https://www.postgresql.org/docs/9.5/static/catalog-pg-database.html
+//
+// Note that we intentionally don't read rolpassword (its masked in pg_roles anyway).
 const rolesSQL string = `
 SELECT oid,
 			 rolname,
@@ -26,6 +28,7 @@ SELECT oid,
 			 CASE WHEN rolvaliduntil = 'infinity' THEN NULL ELSE rolvaliduntil END,
 			 rolconfig,
 			 (SELECT array_agg(roleid) FROM pg_auth_members WHERE pg_roles.oid = pg_auth_members.member) AS member_of,
+			 (SELECT pg_catalog.count(*) FROM pg_catalog.pg_stat_activity WHERE pg_stat_activity.usesysid = pg_roles.oid) AS connections,
 			 %s
 	FROM pg_roles
 	 `
@@ -60,7 +63,7 @@ func GetRoles(logger *util.Logger, db *sql.DB, postgresVersion state.PostgresVer
 		var config, memberOf null.String
 
 		err := rows.Scan(&r.Oid, &r.Name, &r.Inherit, &r.Login, &r.CreateRole, &r.CreateDb, &r.SuperUser,
-			&r.Replication, &r.ConnectionLimit, &r.PasswordValidUntil, &config, &memberOf, &r.BypassRLS)
+			&r.Replication, &r.ConnectionLimit, &r.PasswordValidUntil, &config, &memberOf, &r.ActiveConnections, &r.BypassRLS)
 		if err != nil {
 			return nil, err
 		}
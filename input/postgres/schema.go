@@ -1,18 +1,21 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
+	"time"
 
+	"github.com/guregu/null"
 	"github.com/pganalyze/collector/state"
 	"github.com/pganalyze/collector/util"
 )
 
-func CollectAllSchemas(server state.Server, collectionOpts state.CollectionOpts, logger *util.Logger, ps state.PersistedState, ts state.TransientState) (state.PersistedState, state.TransientState) {
+func CollectAllSchemas(ctx context.Context, server state.Server, collectionOpts state.CollectionOpts, logger *util.Logger, ps state.PersistedState, ts state.TransientState) (state.PersistedState, state.TransientState) {
 	schemaDbNames := []string{}
 
 	if server.Config.DbAllNames {
 		for _, database := range ts.Databases {
-			if !database.IsTemplate && database.AllowConnections {
+			if !database.IsTemplate && database.AllowConnections && server.Config.MonitorsDatabase(database.Name) {
 				schemaDbNames = append(schemaDbNames, database.Name)
 			}
 		}
@@ -25,6 +28,12 @@ func CollectAllSchemas(server state.Server, collectionOpts state.CollectionOpts,
 	ps.RelationStats = make(state.PostgresRelationStatsMap)
 	ps.IndexStats = make(state.PostgresIndexStatsMap)
 	ps.Functions = []state.PostgresFunction{}
+	ps.FunctionStats = make(state.PostgresFunctionStatsMap)
+	ps.RelationVisibility = make(state.PostgresRelationVisibilityMap)
+	ps.ColumnCorrelations = make(state.PostgresColumnCorrelationMap)
+	ps.Hypertables = []state.PostgresHypertable{}
+	ps.Extensions = []state.PostgresExtension{}
+	ps.Sequences = []state.PostgresSequence{}
 
 	for _, dbName := range schemaDbNames {
 		schemaConnection, err := EstablishConnection(server, logger, collectionOpts, dbName)
@@ -35,15 +44,47 @@ func CollectAllSchemas(server state.Server, collectionOpts state.CollectionOpts,
 
 		databaseOid, err := CurrentDatabaseOid(schemaConnection)
 		if err != nil {
-			logger.PrintError("Error getting OID of database %s")
-			schemaConnection.Close()
+			logger.PrintError("Error getting OID of database %s", dbName)
+			CloseConnection(server, schemaConnection)
 			continue
 		}
 
-		ps = collectSchemaData(collectionOpts, logger, schemaConnection, ps, databaseOid, ts.Version)
+		err = RunWithDeadline(ctx, server, logger, collectionOpts, schemaConnection, func() error {
+			ps = collectSchemaData(collectionOpts, logger, schemaConnection, ps, databaseOid, ts.Version)
+
+			start := time.Now()
+			newExtensions, err := GetExtensions(schemaConnection, databaseOid)
+			ps.CollectorStats.Timings.Add("extensions", start, len(newExtensions))
+			if err != nil {
+				logger.PrintWarning("Error collecting extensions for database %s: %s", dbName, err)
+			} else {
+				ps.Extensions = append(ps.Extensions, newExtensions...)
+			}
+
+			start = time.Now()
+			newPublications, err := GetPublications(schemaConnection, ts.Version, databaseOid)
+			ps.CollectorStats.Timings.Add("publications", start, len(newPublications))
+			if err != nil {
+				logger.PrintWarning("Error collecting publications for database %s: %s", dbName, err)
+			} else {
+				ts.Publications = append(ts.Publications, newPublications...)
+			}
+			return nil
+		})
+		if err == ErrDeadlineExceeded {
+			logger.PrintWarning("Skipping collection of schema information for database %s: %s", dbName, err)
+			ts.SkippedCollectors = append(ts.SkippedCollectors, "schema information for database "+dbName)
+			ps.CollectorStats.Timings.Skip("schema information")
+		} else if err == context.Canceled {
+			// Shutting down, the snapshot won't be submitted
+			CloseConnection(server, schemaConnection)
+			break
+		} else if err != nil {
+			logger.PrintWarning("Error collecting schema information for database %s: %s", dbName, err)
+		}
 		ts.DatabaseOidsWithLocalCatalog = append(ts.DatabaseOidsWithLocalCatalog, databaseOid)
 
-		schemaConnection.Close()
+		CloseConnection(server, schemaConnection)
 	}
 
 	return ps, ts
@@ -51,24 +92,55 @@ func CollectAllSchemas(server state.Server, collectionOpts state.CollectionOpts,
 
 func collectSchemaData(collectionOpts state.CollectionOpts, logger *util.Logger, db *sql.DB, ps state.PersistedState, databaseOid state.Oid, postgresVersion state.PostgresVersion) state.PersistedState {
 	if collectionOpts.CollectPostgresRelations {
+		start := time.Now()
 		newRelations, err := GetRelations(db, postgresVersion, databaseOid)
-		if err != nil {
+		ps.CollectorStats.Timings.Add("relations", start, len(newRelations))
+		if reason := TimeoutReason(err); reason != "" {
+			logger.PrintWarning("Skipping collection of relation/index information: %s", reason)
+			ps.CollectorStats.Timings.Skip("relations")
+		} else if err != nil {
 			logger.PrintError("Error collecting relation/index information: %s", err)
 			return ps
 		}
 		ps.Relations = append(ps.Relations, newRelations...)
+	}
 
+	// These get the size of every table and index, which is too expensive while the server is overloaded
+	if collectionOpts.CollectPostgresRelations && !collectionOpts.ReducedCollection && !collectionOpts.SchemaOnly {
+		start := time.Now()
 		newRelationStats, err := GetRelationStats(db, postgresVersion)
-		if err != nil {
+		ps.CollectorStats.Timings.Add("relation stats", start, len(newRelationStats))
+		if reason := TimeoutReason(err); reason != "" {
+			logger.PrintWarning("Skipping collection of relation stats: %s", reason)
+			ps.CollectorStats.Timings.Skip("relation stats")
+		} else if err != nil {
 			logger.PrintError("Error collecting relation stats: %s", err)
 			return ps
 		}
+
+		start = time.Now()
+		relationsWithColumnStats, columnStatsKnown, err := GetRelationsWithColumnStats(db)
+		ps.CollectorStats.Timings.Add("relations with column stats", start, len(relationsWithColumnStats))
+		if reason := TimeoutReason(err); reason != "" {
+			logger.PrintWarning("Skipping collection of column statistics presence: %s", reason)
+			ps.CollectorStats.Timings.Skip("relations with column stats")
+		} else if err != nil {
+			logger.PrintWarning("Error collecting column statistics presence: %s", err)
+		}
 		for k, v := range newRelationStats {
+			if columnStatsKnown && err == nil {
+				v.HasColumnStats = null.BoolFrom(relationsWithColumnStats[k])
+			}
 			ps.RelationStats[k] = v
 		}
 
+		start = time.Now()
 		newIndexStats, err := GetIndexStats(db, postgresVersion)
-		if err != nil {
+		ps.CollectorStats.Timings.Add("index stats", start, len(newIndexStats))
+		if reason := TimeoutReason(err); reason != "" {
+			logger.PrintWarning("Skipping collection of index stats: %s", reason)
+			ps.CollectorStats.Timings.Skip("index stats")
+		} else if err != nil {
 			logger.PrintError("Error collecting index stats: %s", err)
 			return ps
 		}
@@ -77,13 +149,89 @@ func collectSchemaData(collectionOpts state.CollectionOpts, logger *util.Logger,
 		}
 	}
 
+	if collectionOpts.CollectPostgresRelations && !collectionOpts.ReducedCollection && !collectionOpts.SchemaOnly {
+		start := time.Now()
+		newVisibility, err := GetRelationVisibility(logger, db)
+		ps.CollectorStats.Timings.Add("relation visibility", start, len(newVisibility))
+		if reason := TimeoutReason(err); reason != "" {
+			logger.PrintWarning("Skipping collection of relation visibility: %s", reason)
+			ps.CollectorStats.Timings.Skip("relation visibility")
+		} else if err != nil {
+			logger.PrintWarning("Error collecting relation visibility: %s", err)
+		}
+		for k, v := range newVisibility {
+			ps.RelationVisibility[k] = v
+		}
+
+		start = time.Now()
+		newCorrelations, correlationsKnown, err := GetColumnCorrelations(db)
+		ps.CollectorStats.Timings.Add("column correlations", start, len(newCorrelations))
+		if reason := TimeoutReason(err); reason != "" {
+			logger.PrintWarning("Skipping collection of column correlations: %s", reason)
+			ps.CollectorStats.Timings.Skip("column correlations")
+		} else if err != nil {
+			logger.PrintWarning("Error collecting column correlations: %s", err)
+		} else if !correlationsKnown {
+			logger.PrintVerbose("Skipping collection of column correlations, since the collector user can't read pg_stats (set up the pganalyze.get_column_stats() helper)")
+		}
+		for k, v := range newCorrelations {
+			ps.ColumnCorrelations[k] = v
+		}
+	}
+
+	if collectionOpts.CollectPostgresRelations && TimescaleAvailable(db) {
+		start := time.Now()
+		newHypertables, err := GetHypertables(db, databaseOid)
+		ps.CollectorStats.Timings.Add("TimescaleDB hypertables", start, len(newHypertables))
+		if reason := TimeoutReason(err); reason != "" {
+			logger.PrintWarning("Skipping collection of TimescaleDB hypertables: %s", reason)
+			ps.CollectorStats.Timings.Skip("TimescaleDB hypertables")
+		} else if err != nil {
+			logger.PrintWarning("Error collecting TimescaleDB hypertables: %s", err)
+		}
+		ps.Hypertables = append(ps.Hypertables, newHypertables...)
+	}
+
+	if collectionOpts.CollectPostgresRelations {
+		start := time.Now()
+		newSequences, err := GetSequences(db, postgresVersion, databaseOid)
+		ps.CollectorStats.Timings.Add("sequences", start, len(newSequences))
+		if reason := TimeoutReason(err); reason != "" {
+			logger.PrintWarning("Skipping collection of sequences: %s", reason)
+			ps.CollectorStats.Timings.Skip("sequences")
+		} else if err != nil {
+			logger.PrintWarning("Error collecting sequences: %s", err)
+		}
+		ps.Sequences = append(ps.Sequences, newSequences...)
+	}
+
 	if collectionOpts.CollectPostgresFunctions {
+		start := time.Now()
 		newFunctions, err := GetFunctions(db, postgresVersion, databaseOid)
-		if err != nil {
+		ps.CollectorStats.Timings.Add("stored procedures", start, len(newFunctions))
+		if reason := TimeoutReason(err); reason != "" {
+			logger.PrintWarning("Skipping collection of stored procedures: %s", reason)
+			ps.CollectorStats.Timings.Skip("stored procedures")
+		} else if err != nil {
 			logger.PrintError("Error collecting stored procedures")
 			return ps
 		}
 		ps.Functions = append(ps.Functions, newFunctions...)
+
+		if !collectionOpts.SchemaOnly {
+			start = time.Now()
+			newFunctionStats, err := GetFunctionStats(db, postgresVersion)
+			ps.CollectorStats.Timings.Add("function stats", start, len(newFunctionStats))
+			if reason := TimeoutReason(err); reason != "" {
+				logger.PrintWarning("Skipping collection of function stats: %s", reason)
+				ps.CollectorStats.Timings.Skip("function stats")
+			} else if err != nil {
+				logger.PrintWarning("Error collecting function stats: %s", err)
+			}
+			for k, v := range newFunctionStats {
+				ps.FunctionStats[k] = v
+			}
+		}
 	}
 
 	return ps
@@ -0,0 +1,129 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+
+	"github.com/pganalyze/collector/config"
+	"github.com/pganalyze/collector/state"
+)
+
+// SelfTestCheck - Result of a single prerequisite check run as part of the self-test
+type SelfTestCheck struct {
+	Name   string
+	Passed bool
+	Detail string
+}
+
+// Helpers that are only required when not connecting as superuser / pg_monitor member
+var selfTestStatsHelpers = []string{"get_stat_activity", "get_stat_replication", "get_stat_progress_vacuum", "get_column_stats", "get_buffercache", "reset_stat_statements"}
+
+// RunSelfTestChecks - Verifies that the connection has the permissions and extensions we need
+// for collecting data, and whether log/explain collection is possible with this configuration
+func RunSelfTestChecks(db *sql.DB, serverConfig config.ServerConfig, postgresVersion state.PostgresVersion) (checks []SelfTestCheck) {
+	checks = append(checks, SelfTestCheck{
+		Name:   "Postgres version",
+		Passed: postgresVersion.CheckSupported() == nil,
+		Detail: fmt.Sprintf("%s (%d), minimum is %s", postgresVersion.Short, postgresVersion.Numeric, state.MinRequiredPostgresVersionShort),
+	})
+
+	isSuperUser := connectedAsSuperUser(db)
+	isMonitoringRole := connectedAsMonitoringRole(db)
+	privilegedCheck := SelfTestCheck{Name: "Superuser or pg_monitor", Passed: true}
+	if isSuperUser {
+		privilegedCheck.Detail = "connected as superuser"
+	} else if isMonitoringRole {
+		privilegedCheck.Detail = "connected as member of pg_monitor"
+	} else {
+		privilegedCheck.Detail = "not privileged, relying on pganalyze stats helpers"
+	}
+	checks = append(checks, privilegedCheck)
+
+	statementHelperExists := statementStatsHelperExists(db, false)
+	checks = append(checks, SelfTestCheck{
+		Name:   "Stats helper pganalyze.get_stat_statements",
+		Passed: statementHelperExists || isSuperUser || isMonitoringRole,
+		Detail: describeHelper(statementHelperExists),
+	})
+	if statementHelperExists {
+		helperVersion := statsHelperVersion(db)
+		detail := fmt.Sprintf("found version %d, expected %d", helperVersion, StatsHelperVersion)
+		if helperVersion >= StatsHelperMinCompatibleVersion && helperVersion < StatsHelperVersion {
+			detail += " (still usable, re-run the setup SQL to update)"
+		}
+		checks = append(checks, SelfTestCheck{
+			Name:   "Stats helper version",
+			Passed: helperVersion >= StatsHelperMinCompatibleVersion,
+			Detail: detail,
+		})
+	}
+	for _, helper := range selfTestStatsHelpers {
+		exists := statsHelperExists(db, helper)
+		checks = append(checks, SelfTestCheck{
+			Name:   "Stats helper pganalyze." + helper,
+			Passed: exists || isSuperUser || isMonitoringRole,
+			Detail: describeHelper(exists),
+		})
+	}
+
+	installed := extensionExists(db, "pg_stat_statements")
+	pgssCheck := SelfTestCheck{Name: "pg_stat_statements extension", Passed: installed || statementHelperExists}
+	if installed {
+		pgssCheck.Detail = "installed"
+	} else if statementHelperExists {
+		pgssCheck.Detail = "not installed in this database, but accessible through stats helper"
+	} else {
+		pgssCheck.Detail = "not installed, run CREATE EXTENSION pg_stat_statements"
+	}
+	checks = append(checks, pgssCheck)
+
+	logsCheck := checkLogPrerequisites(serverConfig)
+	checks = append(checks, logsCheck)
+
+	explainCheck := SelfTestCheck{Name: "EXPLAIN of slow queries", Passed: logsCheck.Passed}
+	if logsCheck.Passed {
+		explainCheck.Detail = "log collection is available"
+	} else {
+		explainCheck.Detail = "requires log collection"
+	}
+	checks = append(checks, explainCheck)
+
+	return
+}
+
+func describeHelper(exists bool) string {
+	if exists {
+		return "exists"
+	}
+	return "missing"
+}
+
+func checkLogPrerequisites(serverConfig config.ServerConfig) SelfTestCheck {
+	check := SelfTestCheck{Name: "Log collection"}
+
+	if serverConfig.SystemType == "amazon_rds" {
+		check.Passed = serverConfig.AwsDbInstanceID != ""
+		if check.Passed {
+			check.Detail = "using RDS log files for instance " + serverConfig.AwsDbInstanceID
+		} else {
+			check.Detail = "aws_db_instance_id is not set"
+		}
+	} else if serverConfig.SystemType == "heroku" {
+		check.Passed = true
+		check.Detail = "using Heroku log drain"
+	} else if serverConfig.LogLocation != "" {
+		f, err := os.Open(serverConfig.LogLocation)
+		if err != nil {
+			check.Detail = fmt.Sprintf("can't read db_log_location: %s", err)
+		} else {
+			f.Close()
+			check.Passed = true
+			check.Detail = "reading from " + serverConfig.LogLocation
+		}
+	} else {
+		check.Detail = "no log source configured (set db_log_location)"
+	}
+
+	return check
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/gedex/inflector"
+	"github.com/guregu/null"
 	"github.com/lib/pq"
 	"github.com/pganalyze/collector/state"
 	"github.com/pganalyze/collector/util"
@@ -149,3 +150,77 @@ func GetSequenceReport(logger *util.Logger, db *sql.DB) (report state.PostgresSe
 
 	return
 }
+
+const sequencesSQL string = `
+SELECT c.oid,
+			 n.nspname,
+			 c.relname,
+			 %s,
+			 d.refobjid,
+			 a.attname,
+			 pg_catalog.format_type(a.atttypid, NULL)
+	FROM pg_catalog.pg_class c
+			 JOIN pg_catalog.pg_namespace n ON (n.oid = c.relnamespace)
+			 LEFT JOIN pg_catalog.pg_depend d ON (d.classid = 'pg_catalog.pg_class'::regclass AND d.objid = c.oid
+																						 AND d.refclassid = 'pg_catalog.pg_class'::regclass
+																						 AND d.refobjsubid > 0 AND d.deptype IN ('a', 'i'))
+			 LEFT JOIN pg_catalog.pg_attribute a ON (a.attrelid = d.refobjid AND a.attnum = d.refobjsubid)
+			 %s
+ WHERE c.relkind = 'S'
+			 AND n.nspname NOT IN ('pg_catalog', 'pg_toast', 'information_schema')`
+
+// pg_sequences already handles missing permissions (last_value is NULL in that case)
+const sequencesSQLpg10Fields = "s.data_type::text, s.last_value, s.min_value, s.max_value, s.increment_by, s.cycle"
+const sequencesSQLpg10Join = "JOIN pg_catalog.pg_sequences s ON (s.schemaname = n.nspname AND s.sequencename = c.relname)"
+
+// Before Postgres 10 the values are only available by reading each sequence relation itself
+const sequencesSQLDefaultFields = "'bigint', NULL::bigint, 0::bigint, 0::bigint, 0::bigint, false"
+
+const sequenceValuesSQLDefault string = "SELECT CASE WHEN is_called THEN last_value END, min_value, max_value, increment_by, is_cycled FROM %s.%s"
+
+// GetSequences - Collects the sequences of the current database with their current values
+func GetSequences(db *sql.DB, postgresVersion state.PostgresVersion, currentDatabaseOid state.Oid) ([]state.PostgresSequence, error) {
+	var fields, join string
+
+	if postgresVersion.Numeric >= state.PostgresVersion10 {
+		fields = sequencesSQLpg10Fields
+		join = sequencesSQLpg10Join
+	} else {
+		fields = sequencesSQLDefaultFields
+	}
+
+	rows, err := db.Query(QueryMarkerSQL + fmt.Sprintf(sequencesSQL, fields, join))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sequences []state.PostgresSequence
+	for rows.Next() {
+		s := state.PostgresSequence{DatabaseOid: currentDatabaseOid}
+
+		err = rows.Scan(&s.Oid, &s.SchemaName, &s.SequenceName, &s.DataType, &s.LastValue, &s.MinValue,
+			&s.MaxValue, &s.IncrementBy, &s.Cycle, &s.OwnerRelationOid, &s.OwnerColumnName, &s.OwnerColumnType)
+		if err != nil {
+			return nil, err
+		}
+
+		sequences = append(sequences, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if postgresVersion.Numeric < state.PostgresVersion10 {
+		for idx, s := range sequences {
+			err = db.QueryRow(QueryMarkerSQL+fmt.Sprintf(sequenceValuesSQLDefault, pq.QuoteIdentifier(s.SchemaName), pq.QuoteIdentifier(s.SequenceName))).Scan(
+				&sequences[idx].LastValue, &sequences[idx].MinValue, &sequences[idx].MaxValue, &sequences[idx].IncrementBy, &sequences[idx].Cycle)
+			if err != nil {
+				// Most likely missing permissions on this sequence, keep the values unknown
+				sequences[idx].LastValue = null.Int{}
+			}
+		}
+	}
+
+	return sequences, nil
+}
@@ -2,9 +2,13 @@ package postgres
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"hash/fnv"
+	"path"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/guregu/null"
 	"github.com/lib/pq"
@@ -12,15 +16,25 @@ import (
 	"github.com/pganalyze/collector/util"
 )
 
+// Columns that were renamed in newer pg_stat_statements versions (total_exec_time in 13, shared_blk_read_time in 17)
+const statementSQLDefaultTimeFields = "total_time, blk_read_time, blk_write_time"
+const statementSQLpg13TimeFields = "total_exec_time, blk_read_time, blk_write_time"
+const statementSQLpg17TimeFields = "total_exec_time, shared_blk_read_time, shared_blk_write_time"
+
 const statementSQLDefaultOptionalFields = "NULL, NULL, NULL, NULL, NULL"
 const statementSQLpg94OptionalFields = "queryid, NULL, NULL, NULL, NULL"
 const statementSQLpg95OptionalFields = "queryid, min_time, max_time, mean_time, stddev_time"
+const statementSQLpg13OptionalFields = "queryid, min_exec_time, max_exec_time, mean_exec_time, stddev_exec_time"
+
+const statementSQLDefaultJitFields = "0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0"
+const statementSQLpg15JitFields = "jit_functions, jit_generation_time, jit_inlining_count, jit_inlining_time, jit_optimization_count, jit_optimization_time, jit_emission_count, jit_emission_time, 0, 0, temp_blk_read_time, temp_blk_write_time"
+const statementSQLpg17JitFields = "jit_functions, jit_generation_time, jit_inlining_count, jit_inlining_time, jit_optimization_count, jit_optimization_time, jit_emission_count, jit_emission_time, jit_deform_count, jit_deform_time, temp_blk_read_time, temp_blk_write_time"
 
 const statementSQL string = `
-SELECT dbid, userid, query, calls, total_time, rows, shared_blks_hit, shared_blks_read,
+SELECT dbid, userid, query, calls, rows, shared_blks_hit, shared_blks_read,
 			 shared_blks_dirtied, shared_blks_written, local_blks_hit, local_blks_read,
 			 local_blks_dirtied, local_blks_written, temp_blks_read, temp_blks_written,
-			 blk_read_time, blk_write_time, %s
+			 %s, %s, %s
 	FROM %s
  WHERE query IS NULL OR (query !~* '^%s' AND query <> '<insufficient privilege>'
 			 AND query NOT LIKE 'DEALLOCATE %%')`
@@ -33,6 +47,164 @@ SELECT 1 AS enabled
 			 %s
 `
 
+const statementSettingsSQL string = `
+SELECT name, setting
+	FROM pg_settings
+ WHERE name IN ('pg_stat_statements.track', 'pg_stat_statements.max')`
+
+// GetStatementSettings - Reads the pg_stat_statements settings that determine which statements are tracked
+func GetStatementSettings(db *sql.DB) (settings state.PostgresStatementSettings, err error) {
+	rows, err := db.Query(QueryMarkerSQL + statementSettingsSQL)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var setting null.String
+
+		err = rows.Scan(&name, &setting)
+		if err != nil {
+			return
+		}
+
+		switch name {
+		case "pg_stat_statements.track":
+			settings.Track = setting
+		case "pg_stat_statements.max":
+			if setting.Valid {
+				max, _ := strconv.ParseInt(setting.String, 10, 64)
+				settings.Max = null.IntFrom(max)
+			}
+		}
+	}
+
+	return
+}
+
+const statementExtensionSchemaSQL string = `
+SELECT nspname
+	FROM pg_catalog.pg_extension
+	JOIN pg_catalog.pg_namespace ON (extnamespace = pg_namespace.oid)
+ WHERE extname = 'pg_stat_statements'`
+
+const statementInfoSQL string = `SELECT dealloc, stats_reset FROM %s.pg_stat_statements_info`
+
+var statementInfoPermissionWarned sync.Once
+
+// GetStatementInfo - Reads how many entries were evicted from pg_stat_statements (Postgres 14+, no-op on older versions)
+func GetStatementInfo(logger *util.Logger, db *sql.DB, postgresVersion state.PostgresVersion) (info state.PostgresStatementInfo, err error) {
+	if postgresVersion.Numeric < state.PostgresVersion14 {
+		return
+	}
+
+	// The view lives in whichever schema the extension was created in
+	var schema string
+	err = db.QueryRow(QueryMarkerSQL + statementExtensionSchemaSQL).Scan(&schema)
+	if err == sql.ErrNoRows {
+		err = nil
+		return
+	} else if err != nil {
+		return
+	}
+
+	err = db.QueryRow(QueryMarkerSQL+fmt.Sprintf(statementInfoSQL, pq.QuoteIdentifier(schema))).Scan(&info.Dealloc, &info.StatsReset)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code {
+			case "42P01", "42883": // undefined_table / undefined_function
+				// Older pg_stat_statements extension version that doesn't have the view yet
+				err = nil
+			case "42501": // insufficient_privilege
+				statementInfoPermissionWarned.Do(func() {
+					logger.PrintWarning("Skipping pg_stat_statements_info, since the monitoring user lacks permission to read it: %s", err)
+				})
+				err = nil
+			}
+		}
+	}
+
+	return
+}
+
+// pg_stat_statements extension versions that changed the available columns, encoded as major * 100 + minor
+const (
+	statementExtensionVersion12  = 102 // Postgres 9.4: queryid, pg_stat_statements(showtext)
+	statementExtensionVersion13  = 103 // Postgres 9.5: min_time, max_time, mean_time, stddev_time
+	statementExtensionVersion18  = 108 // Postgres 13: *_time renamed to *_exec_time
+	statementExtensionVersion110 = 110 // Postgres 15: jit_*, temp_blk_read_time, temp_blk_write_time
+	statementExtensionVersion111 = 111 // Postgres 17: blk_*_time renamed to shared_blk_*_time, jit_deform_*
+)
+
+const statementExtensionVersionSQL string = `SELECT extversion FROM pg_extension WHERE extname = 'pg_stat_statements'`
+
+// statementExtensionVersion - Determines the installed pg_stat_statements extension version, which can be older
+// than the server (e.g. after a major version upgrade without ALTER EXTENSION ... UPDATE)
+//
+// In case the extension is not installed (yet), this assumes the version that ships with the server.
+func statementExtensionVersion(logger *util.Logger, db *sql.DB, postgresVersion state.PostgresVersion) int {
+	var extVersion string
+
+	err := db.QueryRow(QueryMarkerSQL + statementExtensionVersionSQL).Scan(&extVersion)
+	if err == nil {
+		parts := strings.SplitN(extVersion, ".", 2)
+		major, majorErr := strconv.Atoi(parts[0])
+		minor := 0
+		if len(parts) == 2 {
+			minor, _ = strconv.Atoi(parts[1])
+		}
+		if majorErr == nil {
+			logger.PrintVerbose("Found pg_stat_statements extension version %s", extVersion)
+			return major*100 + minor
+		}
+	}
+
+	if postgresVersion.Numeric >= state.PostgresVersion17 {
+		return statementExtensionVersion111
+	} else if postgresVersion.Numeric >= state.PostgresVersion15 {
+		return statementExtensionVersion110
+	} else if postgresVersion.Numeric >= state.PostgresVersion13 {
+		return statementExtensionVersion18
+	} else if postgresVersion.Numeric >= state.PostgresVersion95 {
+		return statementExtensionVersion13
+	} else if postgresVersion.Numeric >= state.PostgresVersion94 {
+		return statementExtensionVersion12
+	}
+	return 100
+}
+
+const sharedPreloadLibrariesSQL string = `SELECT setting FROM pg_catalog.pg_settings WHERE name = 'shared_preload_libraries'`
+
+// ErrStatementsNotPreloaded - pg_stat_statements can't be used (or created) since it is not preloaded
+var ErrStatementsNotPreloaded = errors.New("pg_stat_statements is not loaded - add pg_stat_statements to shared_preload_libraries and restart Postgres")
+
+// statementsPreloaded - Whether pg_stat_statements is included in shared_preload_libraries
+//
+// The setting is only visible to superusers and members of pg_read_all_settings, in which case
+// known is false and we can't tell either way.
+func statementsPreloaded(db *sql.DB) (preloaded bool, known bool) {
+	var setting string
+
+	err := db.QueryRow(QueryMarkerSQL + sharedPreloadLibrariesSQL).Scan(&setting)
+	if err != nil {
+		return false, false
+	}
+
+	return preloadLibrariesInclude(setting, "pg_stat_statements"), true
+}
+
+// preloadLibrariesInclude - Checks a shared_preload_libraries value, e.g. "auto_explain, '$libdir/pg_stat_statements'"
+func preloadLibrariesInclude(setting string, library string) bool {
+	for _, entry := range strings.Split(setting, ",") {
+		entry = strings.Trim(strings.TrimSpace(entry), "'\"")
+		if strings.TrimSuffix(path.Base(entry), ".so") == library {
+			return true
+		}
+	}
+	return false
+}
+
 func statementStatsHelperExists(db *sql.DB, showtext bool) bool {
 	var enabled bool
 	var additionalWhere string
@@ -68,23 +240,90 @@ func ResetStatements(logger *util.Logger, db *sql.DB) error {
 	return nil
 }
 
-func GetStatements(logger *util.Logger, db *sql.DB, postgresVersion state.PostgresVersion, showtext bool, isHeroku bool) (state.PostgresStatementMap, state.PostgresStatementStatsMap, error) {
+// GetStatements - Reads all pg_stat_statements entries, except for those matched by excludes
+//
+// Also returns the keys of excluded statements, which are determined anew when showtext is true,
+// and otherwise carried over from excludes.
+func GetStatements(logger *util.Logger, db *sql.DB, postgresVersion state.PostgresVersion, showtext bool, isHeroku bool, inRecovery bool, excludes state.StatementExcludes) (state.PostgresStatementMap, state.PostgresStatementStatsMap, state.PostgresStatementKeySet, error) {
+	statements := make(state.PostgresStatementMap)
+	statementStats := make(state.PostgresStatementStatsMap)
+
+	excludedKeys := excludes.NextKeys(showtext)
+
+	err := GetStatementsFunc(logger, db, postgresVersion, showtext, isHeroku, inRecovery, func(key state.PostgresStatementKey, statement state.PostgresStatement, stats state.PostgresStatementStats) error {
+		if excludes.Excluded(key, statement.NormalizedQuery, showtext) {
+			excludedKeys[key] = true
+			return nil
+		}
+		if showtext {
+			statements[key] = statement
+		}
+		statementStats[key] = stats
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return statements, statementStats, excludedKeys, nil
+}
+
+// GetStatementsFunc - Reads pg_stat_statements like GetStatements, but calls fn for each entry
+// instead of returning maps of all entries, to avoid holding everything in memory at once
+//
+// The statement text is only set when showtext is true. Returning an error from fn stops
+// reading further rows, and is returned as-is.
+func GetStatementsFunc(logger *util.Logger, db *sql.DB, postgresVersion state.PostgresVersion, showtext bool, isHeroku bool, inRecovery bool, fn func(key state.PostgresStatementKey, statement state.PostgresStatement, stats state.PostgresStatementStats) error) error {
 	var err error
-	var optionalFields string
+	var timeFields, optionalFields, jitFields string
 	var sourceTable string
 
-	if postgresVersion.Numeric >= state.PostgresVersion95 {
+	extVersion := statementExtensionVersion(logger, db, postgresVersion)
+
+	if extVersion >= statementExtensionVersion111 {
+		timeFields = statementSQLpg17TimeFields
+	} else if extVersion >= statementExtensionVersion18 {
+		timeFields = statementSQLpg13TimeFields
+	} else {
+		timeFields = statementSQLDefaultTimeFields
+	}
+
+	if extVersion >= statementExtensionVersion18 {
+		optionalFields = statementSQLpg13OptionalFields
+	} else if extVersion >= statementExtensionVersion13 {
 		optionalFields = statementSQLpg95OptionalFields
-	} else if postgresVersion.Numeric >= state.PostgresVersion94 {
+	} else if extVersion >= statementExtensionVersion12 {
 		optionalFields = statementSQLpg94OptionalFields
 	} else {
 		optionalFields = statementSQLDefaultOptionalFields
 	}
 
+	if extVersion >= statementExtensionVersion111 {
+		jitFields = statementSQLpg17JitFields
+	} else if extVersion >= statementExtensionVersion110 {
+		jitFields = statementSQLpg15JitFields
+	} else {
+		jitFields = statementSQLDefaultJitFields
+	}
+
 	usingStatsHelper := false
+	preloaded, preloadKnown := false, false
 
 	if statementStatsHelperExists(db, showtext) {
-		usingStatsHelper = true
+		helperVersion := statsHelperVersion(db)
+		if helperVersion < StatsHelperMinCompatibleVersion {
+			logger.PrintWarning(statsHelperOutdatedMessage+" - falling back to direct pg_stat_statements access", helperVersion, StatsHelperVersion)
+		} else {
+			if helperVersion < StatsHelperVersion {
+				statsHelperOutdatedWarned.Do(func() {
+					logger.PrintWarning(statsHelperOutdatedMessage, helperVersion, StatsHelperVersion)
+				})
+			}
+			usingStatsHelper = true
+		}
+	}
+
+	if usingStatsHelper {
 		if !showtext {
 			logger.PrintVerbose("Found pganalyze.get_stat_statements(false) stats helper")
 			sourceTable = "pganalyze.get_stat_statements(false)"
@@ -98,7 +337,11 @@ func GetStatements(logger *util.Logger, db *sql.DB, postgresVersion state.Postgr
 				" the monitoring helper functions (https://github.com/pganalyze/collector#setting-up-a-restricted-monitoring-user)" +
 				" or connect as superuser, to get query statistics for all roles.")
 		}
-		if !showtext {
+		preloaded, preloadKnown = statementsPreloaded(db)
+		if preloadKnown && !preloaded {
+			return ErrStatementsNotPreloaded
+		}
+		if !showtext && extVersion >= statementExtensionVersion12 {
 			sourceTable = "public.pg_stat_statements(false)"
 		} else {
 			sourceTable = "public.pg_stat_statements"
@@ -109,25 +352,29 @@ func GetStatements(logger *util.Logger, db *sql.DB, postgresVersion state.Postgr
 	queryMarkerRegex = strings.Replace(queryMarkerRegex, "*", "\\*", -1)
 	queryMarkerRegex = strings.Replace(queryMarkerRegex, "/", "\\/", -1)
 
-	sql := QueryMarkerSQL + fmt.Sprintf(statementSQL, optionalFields, sourceTable, queryMarkerRegex)
+	sql := QueryMarkerSQL + fmt.Sprintf(statementSQL, timeFields, optionalFields, jitFields, sourceTable, queryMarkerRegex)
 
 	stmt, err := db.Prepare(sql)
 	if err != nil {
 		errCode := err.(*pq.Error).Code
-		if !usingStatsHelper && (errCode == "42P01" || errCode == "42883") { // undefined_table / undefined_function
+		if !usingStatsHelper && inRecovery && (errCode == "42P01" || errCode == "42883") {
+			return fmt.Errorf("pg_stat_statements does not exist, and can't be created on a standby - please run CREATE EXTENSION pg_stat_statements on the primary")
+		} else if !usingStatsHelper && !preloaded && (errCode == "42P01" || errCode == "42883") {
+			return fmt.Errorf("pg_stat_statements does not exist - please make sure it is included in shared_preload_libraries, and run CREATE EXTENSION pg_stat_statements")
+		} else if !usingStatsHelper && (errCode == "42P01" || errCode == "42883") { // undefined_table / undefined_function
 			logger.PrintInfo("pg_stat_statements does not exist, trying to create extension...")
 
-			_, err = db.Exec(QueryMarkerSQL + "CREATE EXTENSION IF NOT EXISTS pg_stat_statements")
+			err = execReadWrite(db, "CREATE EXTENSION IF NOT EXISTS pg_stat_statements")
 			if err != nil {
-				return nil, nil, err
+				return err
 			}
 
 			stmt, err = db.Prepare(sql)
 			if err != nil {
-				return nil, nil, err
+				return err
 			}
 		} else {
-			return nil, nil, err
+			return err
 		}
 	}
 
@@ -135,27 +382,28 @@ func GetStatements(logger *util.Logger, db *sql.DB, postgresVersion state.Postgr
 
 	rows, err := stmt.Query()
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
 	defer rows.Close()
 
-	statements := make(state.PostgresStatementMap)
-	statementStats := make(state.PostgresStatementStatsMap)
-
 	for rows.Next() {
 		var key state.PostgresStatementKey
 		var queryID null.Int
 		var normalizedQuery null.String
 		var stats state.PostgresStatementStats
 
-		err = rows.Scan(&key.DatabaseOid, &key.UserOid, &normalizedQuery, &stats.Calls, &stats.TotalTime, &stats.Rows,
+		err = rows.Scan(&key.DatabaseOid, &key.UserOid, &normalizedQuery, &stats.Calls, &stats.Rows,
 			&stats.SharedBlksHit, &stats.SharedBlksRead, &stats.SharedBlksDirtied, &stats.SharedBlksWritten,
 			&stats.LocalBlksHit, &stats.LocalBlksRead, &stats.LocalBlksDirtied, &stats.LocalBlksWritten,
-			&stats.TempBlksRead, &stats.TempBlksWritten, &stats.BlkReadTime, &stats.BlkWriteTime,
-			&queryID, &stats.MinTime, &stats.MaxTime, &stats.MeanTime, &stats.StddevTime)
+			&stats.TempBlksRead, &stats.TempBlksWritten, &stats.TotalTime, &stats.BlkReadTime, &stats.BlkWriteTime,
+			&queryID, &stats.MinTime, &stats.MaxTime, &stats.MeanTime, &stats.StddevTime,
+			&stats.JitFunctions, &stats.JitGenerationTime, &stats.JitInliningCount, &stats.JitInliningTime,
+			&stats.JitOptimizationCount, &stats.JitOptimizationTime, &stats.JitEmissionCount, &stats.JitEmissionTime,
+			&stats.JitDeformCount, &stats.JitDeformTime, &stats.TempBlkReadTime, &stats.TempBlkWriteTime)
 		if err != nil {
-			return nil, nil, err
+			return err
 		}
+		stats.MeanTime = meanTime(stats)
 
 		if queryID.Valid {
 			key.QueryID = queryID.Int64
@@ -169,11 +417,25 @@ func GetStatements(logger *util.Logger, db *sql.DB, postgresVersion state.Postgr
 			continue
 		}
 
+		var statement state.PostgresStatement
 		if showtext {
-			statements[key] = state.PostgresStatement{NormalizedQuery: normalizedQuery.String}
+			statement.NormalizedQuery = normalizedQuery.String
+		}
+
+		err = fn(key, statement, stats)
+		if err != nil {
+			return err
 		}
-		statementStats[key] = stats
 	}
 
-	return statements, statementStats, nil
+	return rows.Err()
+}
+
+// meanTime - Returns the mean_time column, or for Postgres before 9.5 (which doesn't have it) the
+// average computed from total_time and calls - min/max/stddev can't be derived and stay null
+func meanTime(stats state.PostgresStatementStats) null.Float {
+	if stats.MeanTime.Valid || stats.Calls <= 0 {
+		return stats.MeanTime
+	}
+	return null.FloatFrom(stats.TotalTime / float64(stats.Calls))
 }
@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/guregu/null"
+	"github.com/pganalyze/collector/state"
+)
+
+var preloadLibrariesTests = []struct {
+	setting  string
+	expected bool
+}{
+	{"", false},
+	{"pg_stat_statements", true},
+	{"auto_explain, pg_stat_statements", true},
+	{"'$libdir/pg_stat_statements'", true},
+	{"\"pg_stat_statements.so\",auto_explain", true},
+	{"auto_explain,pg_stat_kcache", false},
+	{"pg_stat_statements_extra", false},
+}
+
+func TestPreloadLibrariesInclude(t *testing.T) {
+	for _, test := range preloadLibrariesTests {
+		actual := preloadLibrariesInclude(test.setting, "pg_stat_statements")
+		if actual != test.expected {
+			t.Errorf("\nSetting: %q\nExpected: %v\n actual: %v", test.setting, test.expected, actual)
+		}
+	}
+}
+
+var meanTimeTests = []struct {
+	stats    state.PostgresStatementStats
+	expected null.Float
+}{
+	{state.PostgresStatementStats{Calls: 4, TotalTime: 10, MeanTime: null.FloatFrom(2.0)}, null.FloatFrom(2.0)},
+	{state.PostgresStatementStats{Calls: 4, TotalTime: 10}, null.FloatFrom(2.5)},
+	{state.PostgresStatementStats{Calls: 0, TotalTime: 0}, null.Float{}},
+}
+
+func TestMeanTime(t *testing.T) {
+	for _, test := range meanTimeTests {
+		actual := meanTime(test.stats)
+		if actual != test.expected {
+			t.Errorf("\nStats: %+v\nExpected: %v\n actual: %v", test.stats, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/guregu/null"
+	"github.com/pganalyze/collector/state"
+)
+
+const subscriptionWorkersSQL string = `
+SELECT subid, subname, pid, relid, received_lsn::text, last_msg_send_time, last_msg_receipt_time,
+			 latest_end_lsn::text, latest_end_time
+	FROM pg_catalog.pg_stat_subscription`
+
+const subscriptionStatsSQL string = `
+SELECT subid, subname, apply_error_count, sync_error_count, stats_reset
+	FROM pg_catalog.pg_stat_subscription_stats`
+
+// GetSubscriptionWorkers - Collects the state of logical replication workers (Postgres 10+)
+func GetSubscriptionWorkers(db *sql.DB, postgresVersion state.PostgresVersion) ([]state.PostgresSubscriptionWorker, error) {
+	if postgresVersion.Numeric < state.PostgresVersion10 {
+		return nil, nil
+	}
+
+	rows, err := db.Query(QueryMarkerSQL + subscriptionWorkersSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var workers []state.PostgresSubscriptionWorker
+
+	for rows.Next() {
+		var w state.PostgresSubscriptionWorker
+
+		err = rows.Scan(&w.SubscriptionOid, &w.SubscriptionName, &w.Pid, &w.RelationOid, &w.ReceivedLsn,
+			&w.LastMsgSendTime, &w.LastMsgReceiptTime, &w.LatestEndLsn, &w.LatestEndTime)
+		if err != nil {
+			return nil, err
+		}
+
+		workers = append(workers, w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return workers, nil
+}
+
+// GetSubscriptionStats - Collects the error counters of subscriptions (Postgres 15+)
+func GetSubscriptionStats(db *sql.DB, postgresVersion state.PostgresVersion) (state.PostgresSubscriptionStatsMap, error) {
+	if postgresVersion.Numeric < state.PostgresVersion15 {
+		return nil, nil
+	}
+
+	rows, err := db.Query(QueryMarkerSQL + subscriptionStatsSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make(state.PostgresSubscriptionStatsMap)
+
+	for rows.Next() {
+		var oid state.Oid
+		var s state.PostgresSubscriptionStats
+
+		err = rows.Scan(&oid, &s.SubscriptionName, &s.ApplyErrorCount, &s.SyncErrorCount, &s.StatsReset)
+		if err != nil {
+			return nil, err
+		}
+
+		stats[oid] = s
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+const publicationsSQL string = `
+SELECT oid, pubname, puballtables, pubinsert, pubupdate, pubdelete, %s
+	FROM pg_catalog.pg_publication`
+
+const publicationTablesSQL string = `
+SELECT pubname, schemaname, tablename
+	FROM pg_catalog.pg_publication_tables`
+
+// Note: subconninfo is intentionally not selected, it can contain credentials
+const subscriptionsSQL string = `
+SELECT oid, subdbid, subname, subowner, subenabled, subslotname, subsynccommit, subpublications
+	FROM pg_catalog.pg_subscription`
+
+const subscriptionTablesSQL string = `
+SELECT srsubid, srrelid, srsubstate
+	FROM pg_catalog.pg_subscription_rel`
+
+// GetPublications - Collects the publications of the current database (Postgres 10+)
+func GetPublications(db *sql.DB, postgresVersion state.PostgresVersion, currentDatabaseOid state.Oid) ([]state.PostgresPublication, error) {
+	var truncateField string
+
+	if postgresVersion.Numeric < state.PostgresVersion10 {
+		return nil, nil
+	}
+
+	if postgresVersion.Numeric >= state.PostgresVersion11 {
+		truncateField = "pubtruncate"
+	} else {
+		truncateField = "false"
+	}
+
+	rows, err := db.Query(QueryMarkerSQL + fmt.Sprintf(publicationsSQL, truncateField))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var publications []state.PostgresPublication
+	publicationIdxByName := make(map[string]int)
+
+	for rows.Next() {
+		p := state.PostgresPublication{DatabaseOid: currentDatabaseOid}
+
+		err = rows.Scan(&p.Oid, &p.Name, &p.AllTables, &p.Insert, &p.Update, &p.Delete, &p.Truncate)
+		if err != nil {
+			return nil, err
+		}
+
+		publicationIdxByName[p.Name] = len(publications)
+		publications = append(publications, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(QueryMarkerSQL + publicationTablesSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pubName string
+		var t state.PostgresPublicationTable
+
+		err = rows.Scan(&pubName, &t.SchemaName, &t.TableName)
+		if err != nil {
+			return nil, err
+		}
+
+		idx, exists := publicationIdxByName[pubName]
+		if exists {
+			publications[idx].Tables = append(publications[idx].Tables, t)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return publications, nil
+}
+
+// GetSubscriptions - Collects all subscriptions on the server, and the tables they cover (Postgres 10+)
+func GetSubscriptions(db *sql.DB, postgresVersion state.PostgresVersion) ([]state.PostgresSubscription, error) {
+	if postgresVersion.Numeric < state.PostgresVersion10 {
+		return nil, nil
+	}
+
+	rows, err := db.Query(QueryMarkerSQL + subscriptionsSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscriptions []state.PostgresSubscription
+	subscriptionIdxByOid := make(map[state.Oid]int)
+
+	for rows.Next() {
+		var s state.PostgresSubscription
+		var publications null.String
+
+		err = rows.Scan(&s.Oid, &s.DatabaseOid, &s.Name, &s.OwnerOid, &s.Enabled, &s.SlotName,
+			&s.SyncCommit, &publications)
+		if err != nil {
+			return nil, err
+		}
+
+		s.Publications = unpackPostgresStringArray(publications)
+
+		subscriptionIdxByOid[s.Oid] = len(subscriptions)
+		subscriptions = append(subscriptions, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(QueryMarkerSQL + subscriptionTablesSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var subOid state.Oid
+		var t state.PostgresSubscriptionTable
+
+		err = rows.Scan(&subOid, &t.RelationOid, &t.State)
+		if err != nil {
+			return nil, err
+		}
+
+		idx, exists := subscriptionIdxByOid[subOid]
+		if exists {
+			subscriptions[idx].Tables = append(subscriptions[idx].Tables, t)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
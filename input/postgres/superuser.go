@@ -3,6 +3,7 @@ package postgres
 import (
 	"database/sql"
 	"fmt"
+	"sync"
 )
 
 const connectedAsSuperUserSQL string = `SELECT current_setting('is_superuser') = 'on'`
@@ -52,3 +53,52 @@ func statsHelperExists(db *sql.DB, statsHelper string) bool {
 
 	return enabled
 }
+
+const extensionSQL string = `SELECT 1 AS enabled FROM pg_extension WHERE extname = '%s'`
+
+func extensionExists(db *sql.DB, extensionName string) bool {
+	var enabled bool
+
+	err := db.QueryRow(QueryMarkerSQL + fmt.Sprintf(extensionSQL, extensionName)).Scan(&enabled)
+	if err != nil {
+		return false
+	}
+
+	return enabled
+}
+
+// StatsHelperVersion - Version of the helper functions in the setup SQL (see README) that this collector expects
+//
+// Increase this whenever the signature of one of the helper functions changes, and update the
+// pganalyze.collector_version() function in the setup SQL accordingly.
+const StatsHelperVersion = 1
+
+// StatsHelperMinCompatibleVersion - Oldest version of the helper functions this collector can still use
+//
+// Helpers installed before the pganalyze.collector_version() marker existed report version 0, and
+// are still compatible. Raise this only once a helper signature changes incompatibly.
+const StatsHelperMinCompatibleVersion = 0
+
+const statsHelperVersionSQL string = `SELECT pganalyze.collector_version()`
+
+// statsHelperVersion - Returns the version of the installed helper functions, or 0 in case
+// they predate the pganalyze.collector_version() marker function
+func statsHelperVersion(db *sql.DB) int {
+	var version int
+
+	if !statsHelperExists(db, "collector_version") {
+		return 0
+	}
+
+	err := db.QueryRow(QueryMarkerSQL + statsHelperVersionSQL).Scan(&version)
+	if err != nil {
+		return 0
+	}
+
+	return version
+}
+
+const statsHelperOutdatedMessage string = "Your stats helper functions in the pganalyze schema are out of date" +
+	" (found version %d, expected %d), please re-run the setup SQL (https://github.com/pganalyze/collector#setting-up-a-restricted-monitoring-user)"
+
+var statsHelperOutdatedWarned sync.Once
@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pganalyze/collector/state"
+)
+
+// Before Postgres 10, determining the size requires CREATE privilege on the tablespace (or superuser)
+const tablespaceSizeAllowedDefault = "has_tablespace_privilege(oid, 'CREATE')"
+const tablespaceSizeAllowedPg10 = "(has_tablespace_privilege(oid, 'CREATE') OR pg_has_role('pg_read_all_stats', 'USAGE'))"
+
+// See also https://www.postgresql.org/docs/10/static/catalog-pg-tablespace.html
+const tablespacesSQL string = `
+SELECT oid,
+			 spcname,
+			 pg_catalog.pg_get_userbyid(spcowner),
+			 pg_catalog.pg_tablespace_location(oid),
+			 CASE WHEN %s THEN pg_catalog.pg_tablespace_size(oid) END
+	FROM pg_catalog.pg_tablespace`
+
+// GetTablespaces - Lists all tablespaces, together with their location and size
+func GetTablespaces(db *sql.DB, postgresVersion state.PostgresVersion) ([]state.PostgresTablespace, error) {
+	sizeAllowed := tablespaceSizeAllowedDefault
+	if postgresVersion.Numeric >= state.PostgresVersion10 {
+		sizeAllowed = tablespaceSizeAllowedPg10
+	}
+
+	rows, err := db.Query(QueryMarkerSQL + fmt.Sprintf(tablespacesSQL, sizeAllowed))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tablespaces []state.PostgresTablespace
+	for rows.Next() {
+		var t state.PostgresTablespace
+
+		err = rows.Scan(&t.Oid, &t.Name, &t.Owner, &t.Location, &t.SizeBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		tablespaces = append(tablespaces, t)
+	}
+
+	return tablespaces, rows.Err()
+}
@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/guregu/null"
+	"github.com/pganalyze/collector/state"
+)
+
+// Note: This requires TimescaleDB 2.0 or newer, older releases had a different information schema
+const hypertablesSQL string = `
+SELECT ht.oid,
+			 h.hypertable_schema,
+			 h.hypertable_name,
+			 h.num_dimensions,
+			 h.compression_enabled,
+			 pg_catalog.array_agg(c.chunk_oid) FILTER (WHERE c.chunk_oid IS NOT NULL),
+			 pg_catalog.count(c.chunk_oid),
+			 pg_catalog.count(c.chunk_oid) FILTER (WHERE c.is_compressed),
+			 COALESCE(public.hypertable_size(ht.oid), 0)
+	FROM timescaledb_information.hypertables h
+			 JOIN pg_catalog.pg_namespace n ON (n.nspname = h.hypertable_schema)
+			 JOIN pg_catalog.pg_class ht ON (ht.relnamespace = n.oid AND ht.relname = h.hypertable_name)
+			 LEFT JOIN (SELECT ch.hypertable_schema, ch.hypertable_name, ch.is_compressed, cc.oid AS chunk_oid
+										FROM timescaledb_information.chunks ch
+												 JOIN pg_catalog.pg_namespace cn ON (cn.nspname = ch.chunk_schema)
+												 JOIN pg_catalog.pg_class cc ON (cc.relnamespace = cn.oid AND cc.relname = ch.chunk_name)
+								 ) c USING (hypertable_schema, hypertable_name)
+ GROUP BY 1, 2, 3, 4, 5`
+
+// TimescaleAvailable - Whether the timescaledb extension is installed in the current database
+func TimescaleAvailable(db *sql.DB) bool {
+	return extensionExists(db, "timescaledb")
+}
+
+// GetHypertables - Collects TimescaleDB hypertables of the current database, with their chunks aggregated
+func GetHypertables(db *sql.DB, currentDatabaseOid state.Oid) ([]state.PostgresHypertable, error) {
+	rows, err := db.Query(QueryMarkerSQL + hypertablesSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hypertables []state.PostgresHypertable
+	for rows.Next() {
+		var chunkOids null.String
+		h := state.PostgresHypertable{DatabaseOid: currentDatabaseOid}
+
+		err = rows.Scan(&h.Oid, &h.SchemaName, &h.TableName, &h.NumDimensions, &h.CompressionEnabled,
+			&chunkOids, &h.NumChunks, &h.CompressedChunks, &h.TotalSizeBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		h.ChunkOids = unpackPostgresOidArray(chunkOids)
+		hypertables = append(hypertables, h)
+	}
+
+	return hypertables, rows.Err()
+}
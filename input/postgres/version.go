@@ -2,11 +2,23 @@ package postgres
 
 import (
 	"database/sql"
+	"strings"
 
 	"github.com/pganalyze/collector/state"
 	"github.com/pganalyze/collector/util"
 )
 
+// Managed services report the same version() as community Postgres, but can be
+// recognized by functions and settings that only exist on them
+const distributionMarkerSQL string = `
+SELECT CASE
+			 WHEN EXISTS(SELECT 1 FROM pg_proc WHERE proname = 'aurora_version') THEN 'amazon_aurora'
+			 WHEN EXISTS(SELECT 1 FROM pg_settings WHERE name = 'rds.extensions') THEN 'amazon_rds'
+			 WHEN EXISTS(SELECT 1 FROM pg_settings WHERE name LIKE 'cloudsql.%') THEN 'google_cloudsql'
+			 WHEN EXISTS(SELECT 1 FROM pg_settings WHERE name LIKE 'azure.%') THEN 'azure'
+			 ELSE ''
+			 END`
+
 // GetPostgresVersion - Reads the version of the connected PostgreSQL server
 func GetPostgresVersion(logger *util.Logger, db *sql.DB) (version state.PostgresVersion, err error) {
 	err = db.QueryRow(QueryMarkerSQL + "SELECT version()").Scan(&version.Full)
@@ -24,7 +36,32 @@ func GetPostgresVersion(logger *util.Logger, db *sql.DB) (version state.Postgres
 		return
 	}
 
-	logger.PrintVerbose("Detected PostgreSQL Version %d (%s)", version.Numeric, version.Full)
+	var distributionMarker string
+	err = db.QueryRow(QueryMarkerSQL + distributionMarkerSQL).Scan(&distributionMarker)
+	if err != nil {
+		logger.PrintVerbose("Could not determine Postgres distribution: %s", err)
+		err = nil
+	}
+	version.Distribution = parseDistribution(version.Full, distributionMarker)
+
+	if version.Distribution != state.PostgresDistributionCommunity {
+		logger.PrintVerbose("Detected PostgreSQL Version %d (%s, %s)", version.Numeric, version.Full, version.Distribution)
+	} else {
+		logger.PrintVerbose("Detected PostgreSQL Version %d (%s)", version.Numeric, version.Full)
+	}
 
 	return
 }
+
+// parseDistribution - Determines the distribution from the version() string, unless
+// we already recognized a managed service based on its marker functions/settings
+func parseDistribution(fullVersion string, distributionMarker string) string {
+	if distributionMarker != "" {
+		return distributionMarker
+	}
+
+	if strings.Contains(fullVersion, "EnterpriseDB") {
+		return state.PostgresDistributionEnterpriseDB
+	}
+	return state.PostgresDistributionCommunity
+}
@@ -0,0 +1,27 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/pganalyze/collector/state"
+)
+
+var parseDistributionTests = []struct {
+	fullVersion        string
+	distributionMarker string
+	expected           string
+}{
+	{"PostgreSQL 12.5 on x86_64-pc-linux-gnu, compiled by gcc (GCC) 4.8.5 20150623 (Red Hat 4.8.5-11), 64-bit", "", state.PostgresDistributionCommunity},
+	{"PostgreSQL 12.5 on x86_64-pc-linux-gnu, compiled by gcc (GCC) 4.8.5 20150623 (Red Hat 4.8.5-11), 64-bit", "amazon_rds", state.PostgresDistributionAmazonRds},
+	{"PostgreSQL 11.9 on x86_64-pc-linux-gnu, compiled by x86_64-pc-linux-gnu-gcc (GCC) 7.4.0, 64-bit", "amazon_aurora", state.PostgresDistributionAmazonAurora},
+	{"PostgreSQL 11.5 (EnterpriseDB Advanced Server 11.5.12) on x86_64-pc-linux-gnu, compiled by gcc (GCC) 4.8.5 20150623 (Red Hat 4.8.5-36), 64-bit", "", state.PostgresDistributionEnterpriseDB},
+}
+
+func TestParseDistribution(t *testing.T) {
+	for _, test := range parseDistributionTests {
+		actual := parseDistribution(test.fullVersion, test.distributionMarker)
+		if actual != test.expected {
+			t.Errorf("parseDistribution(%q, %q): expected %q, got %q", test.fullVersion, test.distributionMarker, test.expected, actual)
+		}
+	}
+}
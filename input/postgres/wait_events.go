@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pganalyze/collector/state"
+)
+
+const waitEventSQLDefaultFields = "CASE WHEN waiting THEN 'Lock' ELSE '' END, ''"
+const waitEventSQLpg96Fields = "COALESCE(wait_event_type, ''), COALESCE(wait_event, '')"
+
+const waitEventSQLDefaultQueryIDField = "0"
+const waitEventSQLpg14QueryIDField = "COALESCE(query_id, 0)"
+
+// Only a few narrow columns, so that sampling every few seconds stays cheap
+const waitEventSQL string = `SELECT state, %s, %s, pg_catalog.count(*)
+	 FROM %s
+	WHERE state <> 'idle' AND pid <> pg_catalog.pg_backend_pid()
+	GROUP BY 1, 2, 3, 4`
+
+// GetActiveSessionCounts - Counts the non-idle backends by state, query and wait event, as a
+// single sample of pg_stat_activity (see runner.SetupWaitEventSampling)
+func GetActiveSessionCounts(ctx context.Context, db *sql.DB, postgresVersion state.PostgresVersion) (state.PostgresActiveSessionHistogram, error) {
+	var fields string
+	var sourceTable string
+
+	if postgresVersion.Numeric >= state.PostgresVersion96 {
+		fields = waitEventSQLpg96Fields
+	} else {
+		fields = waitEventSQLDefaultFields
+	}
+
+	queryIDField := waitEventSQLDefaultQueryIDField
+	if postgresVersion.Numeric >= state.PostgresVersion14 {
+		queryIDField = waitEventSQLpg14QueryIDField
+	}
+
+	if statsHelperExists(db, "get_stat_activity") {
+		sourceTable = "pganalyze.get_stat_activity()"
+	} else {
+		sourceTable = "pg_stat_activity"
+	}
+
+	rows, err := db.QueryContext(ctx, QueryMarkerSQL+fmt.Sprintf(waitEventSQL, queryIDField, fields, sourceTable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	histogram := make(state.PostgresActiveSessionHistogram)
+
+	for rows.Next() {
+		var key state.PostgresActiveSessionKey
+		var count int64
+
+		err = rows.Scan(&key.State, &key.QueryID, &key.WaitEventType, &key.WaitEvent, &count)
+		if err != nil {
+			return nil, err
+		}
+
+		histogram[key] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return histogram, nil
+}
@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/pganalyze/collector/state"
+)
+
+const waitSamplingProfileSQL string = `
+SELECT COALESCE(queryid, 0), COALESCE(event_type, ''), COALESCE(event, ''), pg_catalog.sum(count)::bigint
+	FROM public.pg_wait_sampling_profile
+ GROUP BY 1, 2, 3`
+
+// WaitSamplingAvailable - Whether the pg_wait_sampling extension is installed, in which
+// case we use its profile instead of sampling pg_stat_activity ourselves
+func WaitSamplingAvailable(db *sql.DB) bool {
+	return extensionExists(db, "pg_wait_sampling")
+}
+
+// GetWaitSamplingProfile - Reads the cumulative wait event counts per query from pg_wait_sampling_profile
+func GetWaitSamplingProfile(db *sql.DB) (state.PostgresWaitSamplingProfile, error) {
+	stmt, err := db.Prepare(QueryMarkerSQL + waitSamplingProfileSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	profile := make(state.PostgresWaitSamplingProfile)
+
+	for rows.Next() {
+		var key state.PostgresWaitSamplingKey
+		var count int64
+
+		err = rows.Scan(&key.QueryID, &key.WaitEventType, &key.WaitEvent, &count)
+		if err != nil {
+			return nil, err
+		}
+
+		profile[key] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
@@ -0,0 +1,32 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/pganalyze/collector/state"
+)
+
+// WAL position in bytes since 0/0, on standbys we use the replay position since no WAL is inserted
+const walPositionSQLPg10 string = `
+SELECT pg_catalog.pg_is_in_recovery(),
+			 pg_catalog.pg_wal_lsn_diff(CASE WHEN pg_catalog.pg_is_in_recovery() THEN pg_catalog.pg_last_wal_replay_lsn() ELSE pg_catalog.pg_current_wal_lsn() END, '0/0')::bigint`
+
+const walPositionSQLPg9 string = `
+SELECT pg_catalog.pg_is_in_recovery(),
+			 pg_catalog.pg_xlog_location_diff(CASE WHEN pg_catalog.pg_is_in_recovery() THEN pg_catalog.pg_last_xlog_replay_location() ELSE pg_catalog.pg_current_xlog_location() END, '0/0')::bigint`
+
+// GetWalPosition - Returns the current WAL location, used to determine the WAL generation rate
+func GetWalPosition(db *sql.DB, postgresVersion state.PostgresVersion) (position state.PostgresWalPosition, err error) {
+	// Aurora doesn't use Postgres WAL for replication or storage, so its positions are not meaningful
+	if postgresVersion.Distribution == state.PostgresDistributionAmazonAurora {
+		return
+	}
+
+	walPositionSQL := walPositionSQLPg9
+	if postgresVersion.Numeric >= state.PostgresVersion10 {
+		walPositionSQL = walPositionSQLPg10
+	}
+
+	err = db.QueryRow(QueryMarkerSQL+walPositionSQL).Scan(&position.Replay, &position.Bytes)
+	return
+}
@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pganalyze/collector/state"
+)
+
+// Background workers report their own backend_type (bgw_type) starting with Postgres 13, so we
+// count everything except the built-in backend types as a background worker
+const workerUtilizationSQL string = `
+SELECT pg_catalog.current_setting('max_worker_processes')::int,
+			 pg_catalog.current_setting('max_parallel_workers')::int,
+			 pg_catalog.current_setting('max_parallel_workers_per_gather')::int,
+			 (SELECT pg_catalog.count(*) FROM %s
+				 WHERE backend_type NOT IN ('client backend', 'autovacuum launcher', 'autovacuum worker',
+																		'background writer', 'checkpointer', 'walwriter', 'startup',
+																		'walreceiver', 'walsender', 'archiver', 'stats collector', 'logger',
+																		'standalone backend', 'walsummarizer', 'slotsync worker', 'io worker')),
+			 (SELECT pg_catalog.count(*) FROM %s WHERE backend_type = 'parallel worker')
+`
+
+// GetWorkerUtilization - Compares the current background and parallel workers to their limits (Postgres 10+)
+func GetWorkerUtilization(db *sql.DB) (utilization state.PostgresWorkerUtilization, err error) {
+	sourceTable := "pg_stat_activity"
+	if statsHelperExists(db, "get_stat_activity") {
+		sourceTable = "pganalyze.get_stat_activity()"
+	}
+
+	err = db.QueryRow(QueryMarkerSQL+fmt.Sprintf(workerUtilizationSQL, sourceTable, sourceTable)).Scan(
+		&utilization.MaxWorkerProcesses, &utilization.MaxParallelWorkers, &utilization.MaxParallelWorkersPerGather,
+		&utilization.BackgroundWorkers, &utilization.ParallelWorkers)
+	return
+}
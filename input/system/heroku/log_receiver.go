@@ -75,7 +75,7 @@ func processSystemMetrics(timestamp time.Time, content []byte, nameToServer map[
 		return
 	}
 
-	prefixedLogger := logger.WithPrefix(server.Config.SectionName)
+	prefixedLogger := server.Config.Logger(logger, false)
 
 	grant, err := grant.GetDefaultGrant(server, globalCollectionOpts, prefixedLogger)
 	if err != nil {
@@ -217,7 +217,7 @@ func logReceiver(servers []state.Server, in <-chan config.HerokuLogStreamItem, g
 				logLines[idx] = logLine
 			}
 
-			prefixedLogger := logger.WithPrefix(server.Config.SectionName)
+			prefixedLogger := server.Config.Logger(logger, false)
 			logLinesByName[sourceName] = logs.AnalyzeInGroupsAndSend(server, logLines, globalCollectionOpts, prefixedLogger)
 		}
 	}
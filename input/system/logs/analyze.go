@@ -28,7 +28,7 @@ var ContentCheckpointCompleteRegexp = regexp.MustCompile(`^(checkpoint|restartpo
 	`sync files=(\d+), longest=([\d\.]+) s, average=([\d\.]+) s` +
 	`(; distance=(\d+) kB, estimate=(\d+) kB)?`)
 var ContentDisconnectionRegexp = regexp.MustCompile(`^disconnection: session time: (\d+):(\d+):([\d\.]+)`)
-var ContentRoleNotAllowedLoginRegexp = regexp.MustCompile(`^role ".+?" is not permitted to log in`)
+var ContentRoleNotAllowedLoginRegexp = regexp.MustCompile(`^role "(.+?)" is not permitted to log in`)
 var ContentDatabaseNotAcceptingConnectionsRegexp = regexp.MustCompile(`^database ".+?" is not currently accepting connections`)
 var ContentCheckpointsTooFrequentRegexp = regexp.MustCompile(`^checkpoints are occurring too frequently \((\d+) seconds? apart\)`)
 var ContentRedoLastTxRegexp = regexp.MustCompile(`^last completed transaction was at log time (.+)`)
@@ -110,6 +110,14 @@ func classifyAndSetDetails(logLine state.LogLine, detailLine state.LogLine, samp
 		logLine.Classification = pganalyze_collector.LogLineInformation_CONNECTION_AUTHORIZED
 		return logLine, samples
 	}
+	if reason, username, clientAddr := authFailure(logLine); reason != "" {
+		logLine.Classification = pganalyze_collector.LogLineInformation_CONNECTION_REJECTED
+		logLine.Details = map[string]interface{}{"auth_failure": reason, "username": username}
+		if clientAddr != "" {
+			logLine.Details["client_addr"] = clientAddr
+		}
+		return logLine, samples
+	}
 	if strings.HasPrefix(logLine.Content, "pg_hba.conf rejects connection ") || strings.HasPrefix(logLine.Content, "password authentication failed for user") || strings.HasPrefix(logLine.Content, "no pg_hba.conf entry for") {
 		logLine.Classification = pganalyze_collector.LogLineInformation_CONNECTION_REJECTED
 		return logLine, samples
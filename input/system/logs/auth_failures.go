@@ -0,0 +1,87 @@
+package logs
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pganalyze/collector/output/pganalyze_collector"
+	"github.com/pganalyze/collector/state"
+)
+
+// Postgres reports the method that failed, e.g. "password" (also used for md5 and scram-sha-256),
+// "Ident", "Peer", "LDAP", "RADIUS", "certificate" or "GSSAPI"
+var ContentAuthenticationFailedRegexp = regexp.MustCompile(`^(\w+) authentication failed for user "(.*?)"`)
+
+// Postgres 14+ end these with "no encryption" or "SSL encryption" instead of "SSL off" or "SSL on",
+// replication connections are worded slightly differently
+var ContentNoHbaEntryRegexp = regexp.MustCompile(`^no pg_hba\.conf entry for (?:replication connection from )?host "(.+?)", user "(.*?)"`)
+var ContentHbaRejectsRegexp = regexp.MustCompile(`^pg_hba\.conf rejects (?:replication )?connection for host "(.+?)", user "(.*?)"`)
+
+var ContentRoleDoesNotExistRegexp = regexp.MustCompile(`^role "(.+?)" does not exist`)
+
+// authFailure - Determines whether the log line reports a failed authentication, and if so, why and
+// for which user (as well as the client address, if the message includes it)
+func authFailure(logLine state.LogLine) (reason string, username string, clientAddr string) {
+	if logLine.LogLevel != pganalyze_collector.LogLineInformation_FATAL {
+		return
+	}
+
+	if parts := ContentAuthenticationFailedRegexp.FindStringSubmatch(logLine.Content); parts != nil {
+		return strings.ToLower(parts[1]) + " authentication failed", parts[2], ""
+	}
+	if parts := ContentNoHbaEntryRegexp.FindStringSubmatch(logLine.Content); parts != nil {
+		return "no pg_hba.conf entry", parts[2], parts[1]
+	}
+	if parts := ContentHbaRejectsRegexp.FindStringSubmatch(logLine.Content); parts != nil {
+		return "rejected by pg_hba.conf", parts[2], parts[1]
+	}
+	// Only FATAL while connecting (e.g. SET ROLE reports this as an ERROR)
+	if parts := ContentRoleDoesNotExistRegexp.FindStringSubmatch(logLine.Content); parts != nil {
+		return "role does not exist", parts[1], ""
+	}
+	if parts := ContentRoleNotAllowedLoginRegexp.FindStringSubmatch(logLine.Content); parts != nil {
+		return "role not permitted to log in", parts[1], ""
+	}
+	return
+}
+
+// AggregateAuthFailures - Counts failed connection attempts by user, client address and reason,
+// to detect brute-force attempts or misconfigured clients
+//
+// Run this after the log lines were analyzed, since that determines the reason of the failure.
+func AggregateAuthFailures(logFiles []state.LogFile) state.AuthFailuresMap {
+	failures := make(state.AuthFailuresMap)
+
+	for _, logFile := range logFiles {
+		for _, logLine := range logFile.LogLines {
+			if logLine.Classification != pganalyze_collector.LogLineInformation_CONNECTION_REJECTED {
+				continue
+			}
+			reason, ok := logLine.Details["auth_failure"].(string)
+			if !ok {
+				continue
+			}
+
+			// The user and host named in the message take precedence, since the log_line_prefix
+			// doesn't always include them for connections that didn't get authenticated
+			key := state.AuthFailureKey{Username: logLine.Username, ClientAddr: logLine.ClientAddr, Reason: reason}
+			if username, _ := logLine.Details["username"].(string); username != "" {
+				key.Username = username
+			}
+			if clientAddr, _ := logLine.Details["client_addr"].(string); clientAddr != "" {
+				key.ClientAddr = clientAddr
+			}
+			f := failures[key]
+			f.Count++
+			if f.FirstOccurredAt.IsZero() || logLine.OccurredAt.Before(f.FirstOccurredAt) {
+				f.FirstOccurredAt = logLine.OccurredAt
+			}
+			if logLine.OccurredAt.After(f.LastOccurredAt) {
+				f.LastOccurredAt = logLine.OccurredAt
+			}
+			failures[key] = f
+		}
+	}
+
+	return failures
+}
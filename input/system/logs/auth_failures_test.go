@@ -0,0 +1,62 @@
+package logs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pganalyze/collector/state"
+)
+
+var authFailuresTests = []struct {
+	buffer   string
+	expected state.AuthFailureKey
+}{
+	{
+		"2018-03-11 20:00:02 UTC:1.2.3.4(1234):bob@mydb:[123]:FATAL:  password authentication failed for user \"bob\"\n",
+		state.AuthFailureKey{Username: "bob", ClientAddr: "1.2.3.4", Reason: "password authentication failed"},
+	},
+	{
+		"2018-03-11 20:00:02 UTC::bob@mydb:[123]:FATAL:  Peer authentication failed for user \"bob\"\n",
+		state.AuthFailureKey{Username: "bob", Reason: "peer authentication failed"},
+	},
+	{
+		"2018-03-11 20:00:02 UTC:1.2.3.4(1234):bob@mydb:[123]:FATAL:  no pg_hba.conf entry for host \"1.2.3.4\", user \"bob\", database \"mydb\", SSL off\n",
+		state.AuthFailureKey{Username: "bob", ClientAddr: "1.2.3.4", Reason: "no pg_hba.conf entry"},
+	},
+	// Postgres 14+ wording, with the client address only known from the message
+	{
+		"2018-03-11 20:00:02 UTC::bob@mydb:[123]:FATAL:  no pg_hba.conf entry for host \"10.0.0.1\", user \"bob\", database \"mydb\", no encryption\n",
+		state.AuthFailureKey{Username: "bob", ClientAddr: "10.0.0.1", Reason: "no pg_hba.conf entry"},
+	},
+	{
+		"2018-03-11 20:00:02 UTC:1.2.3.4(1234):[unknown]@[unknown]:[123]:FATAL:  pg_hba.conf rejects replication connection for host \"1.2.3.4\", user \"repl\", SSL off\n",
+		state.AuthFailureKey{Username: "repl", ClientAddr: "1.2.3.4", Reason: "rejected by pg_hba.conf"},
+	},
+	{
+		"2018-03-11 20:00:02 UTC:1.2.3.4(1234):admin@mydb:[123]:FATAL:  role \"admin\" does not exist\n",
+		state.AuthFailureKey{Username: "admin", ClientAddr: "1.2.3.4", Reason: "role does not exist"},
+	},
+}
+
+func TestAggregateAuthFailures(t *testing.T) {
+	for _, test := range authFailuresTests {
+		logLines, _, _ := ParseAndAnalyzeBuffer(test.buffer+test.buffer, 0, time.Time{})
+		failures := AggregateAuthFailures([]state.LogFile{{LogLines: logLines}})
+
+		if len(failures) != 1 || failures[test.expected].Count != 2 {
+			t.Errorf("For %q: expected 2 failures for %+v, got %+v", test.buffer, test.expected, failures)
+		}
+	}
+}
+
+func TestAggregateAuthFailuresIgnoresErrors(t *testing.T) {
+	buffer := "2018-03-11 20:00:02 UTC:1.2.3.4(1234):app@mydb:[123]:ERROR:  role \"admin\" does not exist\n" +
+		"2018-03-11 20:00:02 UTC:1.2.3.4(1234):app@mydb:[123]:STATEMENT:  SET ROLE admin\n"
+
+	logLines, _, _ := ParseAndAnalyzeBuffer(buffer, 0, time.Time{})
+	failures := AggregateAuthFailures([]state.LogFile{{LogLines: logLines}})
+
+	if len(failures) != 0 {
+		t.Errorf("Expected no authentication failures, got %+v", failures)
+	}
+}
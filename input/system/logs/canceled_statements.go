@@ -0,0 +1,45 @@
+package logs
+
+import (
+	"github.com/pganalyze/collector/output/pganalyze_collector"
+	"github.com/pganalyze/collector/state"
+)
+
+// AggregateCanceledStatements - Counts the statements cancelled due to statement_timeout or a user
+// request by the statement (taken from the STATEMENT line that follows each cancellation message)
+//
+// Run this after the log lines were analyzed, since that attaches the statement to the log line.
+func AggregateCanceledStatements(logFiles []state.LogFile) state.CanceledStatementsMap {
+	stats := make(state.CanceledStatementsMap)
+
+	for _, logFile := range logFiles {
+		for _, logLine := range logFile.LogLines {
+			var reason string
+			switch logLine.Classification {
+			case pganalyze_collector.LogLineInformation_STATEMENT_CANCELED_TIMEOUT:
+				reason = "statement timeout"
+			case pganalyze_collector.LogLineInformation_STATEMENT_CANCELED_USER:
+				reason = "user request"
+			default:
+				continue
+			}
+
+			key := state.CanceledStatementsKey{Database: logLine.Database, Username: logLine.Username, Reason: reason}
+			var normalizedQuery string
+			key.Fingerprint, normalizedQuery = identifyStatement(logLine.Query)
+
+			s := stats[key]
+			s.NormalizedQuery = normalizedQuery
+			if logLine.QueryID != 0 {
+				s.QueryID = logLine.QueryID
+			}
+			s.Count++
+			if len(s.Samples) < state.MaxCanceledStatementSamples {
+				s.Samples = append(s.Samples, state.CanceledStatementSample{OccurredAt: logLine.OccurredAt, BackendPid: logLine.BackendPid})
+			}
+			stats[key] = s
+		}
+	}
+
+	return stats
+}
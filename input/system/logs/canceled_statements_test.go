@@ -0,0 +1,45 @@
+package logs
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/pganalyze/collector/state"
+)
+
+func TestAggregateCanceledStatements(t *testing.T) {
+	buffer := "2018-03-11 20:00:02 UTC:1.2.3.4(1234):app@mydb:[123]:ERROR:  canceling statement due to statement timeout\n" +
+		"2018-03-11 20:00:02 UTC:1.2.3.4(1234):app@mydb:[123]:STATEMENT:  SELECT * FROM orders WHERE id = 1\n" +
+		"2018-03-11 20:00:03 UTC:1.2.3.4(1234):app@mydb:[124]:ERROR:  canceling statement due to statement timeout\n" +
+		"2018-03-11 20:00:03 UTC:1.2.3.4(1234):app@mydb:[124]:STATEMENT:  SELECT * FROM orders WHERE id = 2\n" +
+		"2018-03-11 20:00:04 UTC:1.2.3.4(1234):app@mydb:[125]:ERROR:  canceling statement due to user request\n" +
+		"2018-03-11 20:00:04 UTC:1.2.3.4(1234):app@mydb:[125]:STATEMENT:  SELECT * FROM orders WHERE id = 3\n"
+
+	logLines, _, _ := ParseAndAnalyzeBuffer(buffer, 0, time.Time{})
+	stats := AggregateCanceledStatements([]state.LogFile{{LogLines: logLines}})
+
+	if len(stats) != 2 {
+		t.Fatalf("Expected cancellations of one statement for 2 reasons, got %d: %+v", len(stats), stats)
+	}
+
+	for key, s := range stats {
+		if s.NormalizedQuery != "SELECT * FROM orders WHERE id = $1" {
+			t.Errorf("Unexpected statement for cancellations due to %s: %s", key.Reason, s.NormalizedQuery)
+		}
+		switch key.Reason {
+		case "statement timeout":
+			// Log lines of different backends aren't necessarily analyzed in order
+			sort.Slice(s.Samples, func(i, j int) bool { return s.Samples[i].OccurredAt.Before(s.Samples[j].OccurredAt) })
+			if s.Count != 2 || len(s.Samples) != 2 || s.Samples[0].BackendPid != 123 || s.Samples[1].BackendPid != 124 {
+				t.Errorf("Unexpected aggregation for statement timeouts: %+v", s)
+			}
+		case "user request":
+			if s.Count != 1 {
+				t.Errorf("Unexpected aggregation for user cancellations: %+v", s)
+			}
+		default:
+			t.Errorf("Unexpected reason %q", key.Reason)
+		}
+	}
+}
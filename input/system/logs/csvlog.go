@@ -0,0 +1,216 @@
+package logs
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pganalyze/collector/output/pganalyze_collector"
+	"github.com/pganalyze/collector/state"
+	uuid "github.com/satori/go.uuid"
+)
+
+// LogFormatCsv - Value of db_log_format for logs written with log_destination = csvlog
+const LogFormatCsv string = "csvlog"
+
+// Column positions of the csvlog format, see "Using CSV-Format Log Output" in the
+// Postgres documentation - columns added in later versions (e.g. backend_type)
+// are appended at the end and therefore don't affect these positions
+const (
+	csvLogTime            = 0
+	csvUserName           = 1
+	csvDatabaseName       = 2
+	csvProcessID          = 3
+	csvConnectionFrom     = 4
+	csvSessionID          = 5
+	csvCommandTag         = 7
+	csvErrorSeverity      = 11
+	csvSQLStateCode       = 12
+	csvMessage            = 13
+	csvDetail             = 14
+	csvHint               = 15
+	csvInternalQuery      = 16
+	csvContext            = 18
+	csvQuery              = 19
+	csvApplicationName    = 22
+	csvMinimumColumnCount = 23
+)
+
+type csvField struct {
+	value string
+	start int // Byte offset of the field content (inside the quotes, if quoted)
+	end   int // Byte offset directly after the field content
+}
+
+// parseCsvRecord - Parses one CSV record starting at pos, returning its fields
+// and the position of the next record
+//
+// Quoted fields may contain newlines, so a record can span multiple lines. When
+// the buffer ends before the record is complete, ok is false.
+func parseCsvRecord(buffer string, pos int) (fields []csvField, next int, ok bool) {
+	for {
+		var field csvField
+		if pos < len(buffer) && buffer[pos] == '"' {
+			pos++
+			field.start = pos
+			var value strings.Builder
+			for {
+				quote := strings.IndexByte(buffer[pos:], '"')
+				if quote == -1 {
+					return nil, 0, false
+				}
+				value.WriteString(buffer[pos : pos+quote])
+				pos += quote + 1
+				if pos < len(buffer) && buffer[pos] == '"' {
+					// Escaped quote
+					value.WriteByte('"')
+					pos++
+					continue
+				}
+				break
+			}
+			field.value = value.String()
+			field.end = pos - 1
+		} else {
+			field.start = pos
+			for pos < len(buffer) && buffer[pos] != ',' && buffer[pos] != '\n' {
+				pos++
+			}
+			field.value = strings.TrimSuffix(buffer[field.start:pos], "\r")
+			field.end = field.start + len(field.value)
+		}
+		fields = append(fields, field)
+
+		if pos >= len(buffer) {
+			return nil, 0, false
+		}
+		if buffer[pos] == '\n' {
+			return fields, pos + 1, true
+		}
+		if buffer[pos] == '\r' && pos+1 < len(buffer) && buffer[pos+1] == '\n' {
+			return fields, pos + 2, true
+		}
+		if buffer[pos] != ',' {
+			// Garbage after a quoted field, skip the remainder of the line
+			newline := strings.IndexByte(buffer[pos:], '\n')
+			if newline == -1 {
+				return nil, 0, false
+			}
+			return nil, pos + newline + 1, true
+		}
+		pos++
+	}
+}
+
+// csvLogLines - Turns one csvlog record into log lines, in the same shape that the
+// text format parser produces (i.e. DETAIL/HINT/etc as separate follow-on lines)
+func csvLogLines(fields []csvField, byteOffset int64, recordStart int64) (logLines []state.LogLine, ok bool) {
+	if len(fields) < csvMinimumColumnCount {
+		return
+	}
+
+	var logLine state.LogLine
+	var err error
+	logLine.OccurredAt, err = time.Parse(structuredLogTimeFormat, fields[csvLogTime].value)
+	if err != nil {
+		return
+	}
+
+	levelValue, exists := pganalyze_collector.LogLineInformation_LogLevel_value[fields[csvErrorSeverity].value]
+	if !exists {
+		return
+	}
+
+	logLine.Username = fields[csvUserName].value
+	logLine.Database = fields[csvDatabaseName].value
+	logLine.Application = fields[csvApplicationName].value
+	if idx := strings.LastIndex(fields[csvConnectionFrom].value, ":"); idx != -1 { // "host:port", or "[local]" for Unix sockets
+		logLine.ClientAddr = fields[csvConnectionFrom].value[:idx]
+	}
+	backendPid, _ := strconv.Atoi(fields[csvProcessID].value)
+	logLine.BackendPid = int32(backendPid)
+	logLine.LogLevel = pganalyze_collector.LogLineInformation_LogLevel(levelValue)
+	logLine.SQLState = fields[csvSQLStateCode].value
+	logLine.SessionID = fields[csvSessionID].value
+	logLine.CommandTag = fields[csvCommandTag].value
+	logLine.Query = fields[csvQuery].value
+
+	message := fields[csvMessage]
+	logLine.Content = message.value
+	logLine.UUID = uuid.NewV4()
+	logLine.ByteStart = recordStart
+	logLine.ByteContentStart = byteOffset + int64(message.start)
+	logLine.ByteEnd = byteOffset + int64(message.end) - 1
+	logLines = append(logLines, logLine)
+
+	// Same order as Postgres uses when writing these to a text log
+	for _, additional := range []struct {
+		column int
+		level  pganalyze_collector.LogLineInformation_LogLevel
+	}{
+		{csvDetail, pganalyze_collector.LogLineInformation_DETAIL},
+		{csvHint, pganalyze_collector.LogLineInformation_HINT},
+		{csvInternalQuery, pganalyze_collector.LogLineInformation_QUERY},
+		{csvContext, pganalyze_collector.LogLineInformation_CONTEXT},
+		{csvQuery, pganalyze_collector.LogLineInformation_STATEMENT},
+	} {
+		field := fields[additional.column]
+		if field.value == "" {
+			continue
+		}
+		logLines = append(logLines, additionalLogLine(logLine, additional.level, field.value, byteOffset+int64(field.start), byteOffset+int64(field.end)))
+	}
+
+	ok = true
+	return
+}
+
+// additionalLogLine - Creates a follow-on line (e.g. DETAIL) for a structured log record,
+// whose content is located at [start, end) in the log file
+func additionalLogLine(logLine state.LogLine, level pganalyze_collector.LogLineInformation_LogLevel, content string, start int64, end int64) state.LogLine {
+	return state.LogLine{
+		UUID:             uuid.NewV4(),
+		OccurredAt:       logLine.OccurredAt,
+		Username:         logLine.Username,
+		Database:         logLine.Database,
+		Application:      logLine.Application,
+		BackendPid:       logLine.BackendPid,
+		LogLevel:         level,
+		Content:          content,
+		ByteStart:        start,
+		ByteContentStart: start,
+		ByteEnd:          end - 1,
+	}
+}
+
+// ParseAndAnalyzeCsvBuffer - Same as ParseAndAnalyzeBuffer, but for logs written with
+// log_destination = csvlog, which don't require any guessing of the log_line_prefix
+func ParseAndAnalyzeCsvBuffer(buffer string, initialByteStart int64, linesNewerThan time.Time) ([]state.LogLine, []state.PostgresQuerySample, int64) {
+	var logLines []state.LogLine
+	pos := 0
+
+	for pos < len(buffer) {
+		fields, next, ok := parseCsvRecord(buffer, pos)
+		if !ok {
+			break
+		}
+		recordStart := initialByteStart + int64(pos)
+		pos = next
+
+		newLines, ok := csvLogLines(fields, initialByteStart, recordStart)
+		if !ok {
+			continue
+		}
+
+		// Ignore loglines which are outside our time window
+		if newLines[0].OccurredAt.Before(linesNewerThan) {
+			continue
+		}
+
+		logLines = append(logLines, newLines...)
+	}
+
+	// Like for text logs we consume the whole buffer, even if it ends in an incomplete record
+	newLogLines, newSamples := AnalyzeLogLines(logLines)
+	return newLogLines, newSamples, initialByteStart + int64(len(buffer))
+}
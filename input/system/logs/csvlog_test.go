@@ -0,0 +1,107 @@
+package logs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kylelemons/godebug/pretty"
+	"github.com/pganalyze/collector/input/system/logs"
+	"github.com/pganalyze/collector/output/pganalyze_collector"
+	"github.com/pganalyze/collector/state"
+)
+
+type csvParseTestpair struct {
+	bufferIn     string
+	logLinesOut  []state.LogLine
+	byteStartOut int64
+}
+
+var csvParseTests = []csvParseTestpair{
+	{
+		"2018-09-27 06:57:01.030 UTC,\"postgres\",\"mydb\",4983,\"[local]\",5bac7ead.1377,1,\"SELECT\",2018-09-27 06:56:45 UTC,3/0,0,ERROR,42P01,\"relation \"\"foo\"\" does not exist\",,,,,,\"SELECT * FROM foo;\",15,,\"psql\"\n",
+		[]state.LogLine{
+			{
+				OccurredAt:       time.Date(2018, time.September, 27, 6, 57, 1, 30*1000*1000, time.UTC),
+				Username:         "postgres",
+				Database:         "mydb",
+				Application:      "psql",
+				BackendPid:       4983,
+				LogLevel:         pganalyze_collector.LogLineInformation_ERROR,
+				SQLState:         "42P01",
+				SessionID:        "5bac7ead.1377",
+				CommandTag:       "SELECT",
+				Query:            "SELECT * FROM foo;",
+				ByteStart:        0,
+				ByteContentStart: 129,
+				ByteEnd:          159,
+				Classification:   pganalyze_collector.LogLineInformation_RELATION_DOES_NOT_EXIST,
+			},
+			{
+				OccurredAt:       time.Date(2018, time.September, 27, 6, 57, 1, 30*1000*1000, time.UTC),
+				Username:         "postgres",
+				Database:         "mydb",
+				Application:      "psql",
+				BackendPid:       4983,
+				LogLevel:         pganalyze_collector.LogLineInformation_STATEMENT,
+				ByteStart:        168,
+				ByteContentStart: 168,
+				ByteEnd:          185,
+			},
+		},
+		199,
+	},
+	{
+		// Multi-line message with a detail, followed by an incomplete record
+		"2018-09-27 06:57:02.000 UTC,,,4984,,5bac7ead.1378,1,,2018-09-27 06:56:45 UTC,,0,LOG,00000,\"checkpoint starting:\ntime\",\"some detail\",,,,,,,,\"\"\n2018-09-27 06:57:03.000 UTC,,,4984,,\"partial",
+		[]state.LogLine{
+			{
+				OccurredAt:       time.Date(2018, time.September, 27, 6, 57, 2, 0, time.UTC),
+				BackendPid:       4984,
+				LogLevel:         pganalyze_collector.LogLineInformation_LOG,
+				SQLState:         "00000",
+				SessionID:        "5bac7ead.1378",
+				ByteStart:        0,
+				ByteContentStart: 91,
+				ByteEnd:          115,
+			},
+			{
+				OccurredAt:       time.Date(2018, time.September, 27, 6, 57, 2, 0, time.UTC),
+				BackendPid:       4984,
+				LogLevel:         pganalyze_collector.LogLineInformation_DETAIL,
+				ByteStart:        119,
+				ByteContentStart: 119,
+				ByteEnd:          129,
+			},
+		},
+		186,
+	},
+}
+
+func TestParseAndAnalyzeCsvBuffer(t *testing.T) {
+	for _, pair := range csvParseTests {
+		l, _, byteStart := logs.ParseAndAnalyzeCsvBuffer(pair.bufferIn, 0, time.Time{})
+
+		cfg := pretty.CompareConfig
+		cfg.SkipZeroFields = true
+
+		// UUIDs are random, only compare whether lines are connected as expected
+		var parentUUID [16]byte
+		if len(l) > 0 {
+			parentUUID = l[0].UUID
+		}
+		for idx := range l {
+			if idx > 0 && l[idx].ParentUUID != parentUUID {
+				t.Errorf("For %q: expected line %d to have the first line as its parent", pair.bufferIn, idx)
+			}
+			l[idx].UUID = [16]byte{}
+			l[idx].ParentUUID = [16]byte{}
+		}
+
+		if diff := cfg.Compare(l, pair.logLinesOut); diff != "" {
+			t.Errorf("For %q: log lines diff: (-got +want)\n%s", pair.bufferIn, diff)
+		}
+		if byteStart != pair.byteStartOut {
+			t.Errorf("For %q: expected byte start %d, but was %d", pair.bufferIn, pair.byteStartOut, byteStart)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package logs
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pganalyze/collector/state"
+)
+
+// LogFormatText - Value of db_log_format for regular text logs (the default), whose
+// log_line_prefix we detect automatically
+const LogFormatText string = "stderr"
+
+// Timestamp format used by both csvlog and jsonlog output
+const structuredLogTimeFormat string = "2006-01-02 15:04:05.999 MST"
+
+// DetectLogFormat - Determines the format of a log file, either based on the
+// configured format, or the file extension Postgres uses for csvlog/jsonlog output
+func DetectLogFormat(fileName string, logFormat string) string {
+	if logFormat == LogFormatCsv || logFormat == LogFormatJSON {
+		return logFormat
+	}
+	if strings.HasSuffix(fileName, ".csv") {
+		return LogFormatCsv
+	}
+	if strings.HasSuffix(fileName, ".json") {
+		return LogFormatJSON
+	}
+	return LogFormatText
+}
+
+// ParseAndAnalyzeBufferWithFormat - Parses a buffer of log data in the given format,
+// see ParseAndAnalyzeBuffer
+func ParseAndAnalyzeBufferWithFormat(logFormat string, buffer string, initialByteStart int64, linesNewerThan time.Time) ([]state.LogLine, []state.PostgresQuerySample, int64) {
+	switch logFormat {
+	case LogFormatCsv:
+		return ParseAndAnalyzeCsvBuffer(buffer, initialByteStart, linesNewerThan)
+	case LogFormatJSON:
+		return ParseAndAnalyzeJSONBuffer(buffer, initialByteStart, linesNewerThan)
+	default:
+		return ParseAndAnalyzeBuffer(buffer, initialByteStart, linesNewerThan)
+	}
+}
+
+// CompleteRecordsLength - Returns the length of the leading part of the buffer that only
+// contains complete log records, so a reader can resume after it once the rest is written
+//
+// csvlog records may contain newlines inside quoted fields, so for those we need to parse
+// the records, for the other formats each record ends with a newline.
+func CompleteRecordsLength(logFormat string, buffer string) int {
+	if logFormat != LogFormatCsv {
+		return strings.LastIndexByte(buffer, '\n') + 1
+	}
+
+	pos := 0
+	for pos < len(buffer) {
+		_, next, ok := parseCsvRecord(buffer, pos)
+		if !ok {
+			break
+		}
+		pos = next
+	}
+	return pos
+}
@@ -0,0 +1,172 @@
+package logs
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pganalyze/collector/output/pganalyze_collector"
+	"github.com/pganalyze/collector/state"
+	uuid "github.com/satori/go.uuid"
+)
+
+// LogFormatJSON - Value of db_log_format for logs written with log_destination = jsonlog (Postgres 15+)
+const LogFormatJSON string = "jsonlog"
+
+// jsonLogRecord - One line of jsonlog output
+//
+// Postgres omits keys whose value is not set, so all of these are optional
+// except for the timestamp, error_severity and message.
+type jsonLogRecord struct {
+	Timestamp       string `json:"timestamp"`
+	User            string `json:"user"`
+	Dbname          string `json:"dbname"`
+	Pid             int32  `json:"pid"`
+	SessionID       string `json:"session_id"`
+	Ps              string `json:"ps"`
+	ErrorSeverity   string `json:"error_severity"`
+	StateCode       string `json:"state_code"`
+	Message         string `json:"message"`
+	Detail          string `json:"detail"`
+	Hint            string `json:"hint"`
+	InternalQuery   string `json:"internal_query"`
+	Context         string `json:"context"`
+	Statement       string `json:"statement"`
+	ApplicationName string `json:"application_name"`
+	RemoteHost      string `json:"remote_host"`
+	BackendType     string `json:"backend_type"`
+	QueryID         int64  `json:"query_id"`
+}
+
+// jsonStringRange - Finds the raw (still escaped) string value of the given key,
+// returning its byte range within the line
+//
+// Quotes inside string values are always escaped, so searching for the quoted
+// key followed by a colon can't accidentally match inside of a value.
+func jsonStringRange(line string, key string) (start int, end int, ok bool) {
+	keyPos := strings.Index(line, `"`+key+`":`)
+	if keyPos == -1 {
+		return
+	}
+	pos := keyPos + len(key) + 3
+	for pos < len(line) && line[pos] == ' ' {
+		pos++
+	}
+	if pos >= len(line) || line[pos] != '"' {
+		return
+	}
+	start = pos + 1
+	for pos = start; pos < len(line); pos++ {
+		if line[pos] == '\\' {
+			pos++
+		} else if line[pos] == '"' {
+			return start, pos, true
+		}
+	}
+	return 0, 0, false
+}
+
+// jsonLogLines - Turns one jsonlog line into log lines, in the same shape that the
+// text format parser produces (i.e. DETAIL/HINT/etc as separate follow-on lines)
+func jsonLogLines(line string, byteStart int64) (logLines []state.LogLine, ok bool) {
+	var record jsonLogRecord
+	err := json.Unmarshal([]byte(line), &record)
+	if err != nil {
+		return
+	}
+
+	var logLine state.LogLine
+	logLine.OccurredAt, err = time.Parse(structuredLogTimeFormat, record.Timestamp)
+	if err != nil {
+		return
+	}
+
+	levelValue, exists := pganalyze_collector.LogLineInformation_LogLevel_value[record.ErrorSeverity]
+	if !exists {
+		return
+	}
+
+	logLine.Username = record.User
+	logLine.Database = record.Dbname
+	logLine.Application = record.ApplicationName
+	if record.RemoteHost != "[local]" {
+		logLine.ClientAddr = record.RemoteHost
+	}
+	logLine.BackendPid = record.Pid
+	logLine.LogLevel = pganalyze_collector.LogLineInformation_LogLevel(levelValue)
+	logLine.SQLState = record.StateCode
+	logLine.SessionID = record.SessionID
+	logLine.CommandTag = record.Ps
+	logLine.BackendType = record.BackendType
+	logLine.QueryID = record.QueryID
+	logLine.Query = record.Statement
+	logLine.Content = record.Message
+	logLine.UUID = uuid.NewV4()
+	logLine.ByteStart = byteStart
+	logLine.ByteContentStart = byteStart
+	logLine.ByteEnd = byteStart + int64(len(line)) - 1
+	if start, end, found := jsonStringRange(line, "message"); found {
+		logLine.ByteContentStart = byteStart + int64(start)
+		logLine.ByteEnd = byteStart + int64(end) - 1
+	}
+	logLines = append(logLines, logLine)
+
+	// Same order as Postgres uses when writing these to a text log
+	for _, additional := range []struct {
+		key     string
+		content string
+		level   pganalyze_collector.LogLineInformation_LogLevel
+	}{
+		{"detail", record.Detail, pganalyze_collector.LogLineInformation_DETAIL},
+		{"hint", record.Hint, pganalyze_collector.LogLineInformation_HINT},
+		{"internal_query", record.InternalQuery, pganalyze_collector.LogLineInformation_QUERY},
+		{"context", record.Context, pganalyze_collector.LogLineInformation_CONTEXT},
+		{"statement", record.Statement, pganalyze_collector.LogLineInformation_STATEMENT},
+	} {
+		if additional.content == "" {
+			continue
+		}
+		start, end, found := jsonStringRange(line, additional.key)
+		if !found {
+			continue
+		}
+		logLines = append(logLines, additionalLogLine(logLine, additional.level, additional.content, byteStart+int64(start), byteStart+int64(end)))
+	}
+
+	ok = true
+	return
+}
+
+// ParseAndAnalyzeJSONBuffer - Same as ParseAndAnalyzeBuffer, but for logs written with
+// log_destination = jsonlog, which contain one JSON object per line
+func ParseAndAnalyzeJSONBuffer(buffer string, initialByteStart int64, linesNewerThan time.Time) ([]state.LogLine, []state.PostgresQuerySample, int64) {
+	var logLines []state.LogLine
+	pos := 0
+
+	for pos < len(buffer) {
+		newline := strings.IndexByte(buffer[pos:], '\n')
+		if newline == -1 {
+			// Incomplete line at the end of the buffer
+			break
+		}
+		line := strings.TrimSuffix(buffer[pos:pos+newline], "\r")
+		byteStart := initialByteStart + int64(pos)
+		pos += newline + 1
+
+		newLines, ok := jsonLogLines(line, byteStart)
+		if !ok {
+			continue
+		}
+
+		// Ignore loglines which are outside our time window
+		if newLines[0].OccurredAt.Before(linesNewerThan) {
+			continue
+		}
+
+		logLines = append(logLines, newLines...)
+	}
+
+	// Like for text logs we consume the whole buffer, even if it ends in an incomplete line
+	newLogLines, newSamples := AnalyzeLogLines(logLines)
+	return newLogLines, newSamples, initialByteStart + int64(len(buffer))
+}
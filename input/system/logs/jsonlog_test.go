@@ -0,0 +1,102 @@
+package logs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kylelemons/godebug/pretty"
+	"github.com/pganalyze/collector/input/system/logs"
+	"github.com/pganalyze/collector/output/pganalyze_collector"
+	"github.com/pganalyze/collector/state"
+)
+
+type jsonParseTestpair struct {
+	bufferIn     string
+	logLinesOut  []state.LogLine
+	byteStartOut int64
+}
+
+var jsonParseTests = []jsonParseTestpair{
+	{
+		`{"timestamp":"2022-10-13 09:36:03.127 UTC","user":"postgres","dbname":"mydb","pid":24348,"session_id":"6347db1b.5f1c","ps":"SELECT","error_severity":"ERROR","state_code":"42P01","message":"relation \"foo\" does not exist","statement":"SELECT * FROM foo;","application_name":"psql","backend_type":"client backend","query_id":-6915677900366396612}` + "\n",
+		[]state.LogLine{
+			{
+				OccurredAt:       time.Date(2022, time.October, 13, 9, 36, 3, 127*1000*1000, time.UTC),
+				Username:         "postgres",
+				Database:         "mydb",
+				Application:      "psql",
+				BackendPid:       24348,
+				LogLevel:         pganalyze_collector.LogLineInformation_ERROR,
+				SQLState:         "42P01",
+				SessionID:        "6347db1b.5f1c",
+				CommandTag:       "SELECT",
+				BackendType:      "client backend",
+				QueryID:          -6915677900366396612,
+				Query:            "SELECT * FROM foo;",
+				ByteStart:        0,
+				ByteContentStart: 189,
+				ByteEnd:          219,
+				Classification:   pganalyze_collector.LogLineInformation_RELATION_DOES_NOT_EXIST,
+			},
+			{
+				OccurredAt:       time.Date(2022, time.October, 13, 9, 36, 3, 127*1000*1000, time.UTC),
+				Username:         "postgres",
+				Database:         "mydb",
+				Application:      "psql",
+				BackendPid:       24348,
+				LogLevel:         pganalyze_collector.LogLineInformation_STATEMENT,
+				ByteStart:        235,
+				ByteContentStart: 235,
+				ByteEnd:          252,
+			},
+		},
+		346,
+	},
+	{
+		// Optional fields missing, followed by invalid JSON and an incomplete line
+		`{"timestamp":"2022-10-13 09:36:04.000 UTC","pid":24349,"error_severity":"LOG","message":"checkpoint starting: time","backend_type":"checkpointer"}` + "\nnot json\n" + `{"timestamp":"2022-10-13`,
+		[]state.LogLine{
+			{
+				OccurredAt:       time.Date(2022, time.October, 13, 9, 36, 4, 0, time.UTC),
+				BackendPid:       24349,
+				LogLevel:         pganalyze_collector.LogLineInformation_LOG,
+				BackendType:      "checkpointer",
+				ByteStart:        0,
+				ByteContentStart: 89,
+				ByteEnd:          113,
+				Classification:   pganalyze_collector.LogLineInformation_CHECKPOINT_STARTING,
+				Details:          map[string]interface{}{"reason": "time"},
+			},
+		},
+		180,
+	},
+}
+
+func TestParseAndAnalyzeJSONBuffer(t *testing.T) {
+	for _, pair := range jsonParseTests {
+		l, _, byteStart := logs.ParseAndAnalyzeJSONBuffer(pair.bufferIn, 0, time.Time{})
+
+		cfg := pretty.CompareConfig
+		cfg.SkipZeroFields = true
+
+		// UUIDs are random, only compare whether lines are connected as expected
+		var parentUUID [16]byte
+		if len(l) > 0 {
+			parentUUID = l[0].UUID
+		}
+		for idx := range l {
+			if idx > 0 && l[idx].ParentUUID != parentUUID {
+				t.Errorf("For %q: expected line %d to have the first line as its parent", pair.bufferIn, idx)
+			}
+			l[idx].UUID = [16]byte{}
+			l[idx].ParentUUID = [16]byte{}
+		}
+
+		if diff := cfg.Compare(l, pair.logLinesOut); diff != "" {
+			t.Errorf("For %q: log lines diff: (-got +want)\n%s", pair.bufferIn, diff)
+		}
+		if byteStart != pair.byteStartOut {
+			t.Errorf("For %q: expected byte start %d, but was %d", pair.bufferIn, pair.byteStartOut, byteStart)
+		}
+	}
+}
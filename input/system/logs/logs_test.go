@@ -146,10 +146,12 @@ var tests = []testpair{
 		}, {
 			Classification: pganalyze_collector.LogLineInformation_CONNECTION_REJECTED,
 			LogLevel:       pganalyze_collector.LogLineInformation_FATAL,
+			Details:        map[string]interface{}{"auth_failure": "no pg_hba.conf entry", "username": "postgres", "client_addr": "8.8.8.8"},
 		}, {
 			Classification: pganalyze_collector.LogLineInformation_CONNECTION_REJECTED,
 			LogLevel:       pganalyze_collector.LogLineInformation_FATAL,
 			UUID:           uuid.UUID{1},
+			Details:        map[string]interface{}{"auth_failure": "password authentication failed", "username": "postgres"},
 		}, {
 			LogLevel:   pganalyze_collector.LogLineInformation_DETAIL,
 			ParentUUID: uuid.UUID{1},
@@ -159,11 +161,13 @@ var tests = []testpair{
 		}, {
 			Classification: pganalyze_collector.LogLineInformation_CONNECTION_REJECTED,
 			LogLevel:       pganalyze_collector.LogLineInformation_FATAL,
+			Details:        map[string]interface{}{"auth_failure": "role not permitted to log in", "username": "abc"},
 		}, {
 			Classification: pganalyze_collector.LogLineInformation_CONNECTION_REJECTED,
 		}, {
 			Classification: pganalyze_collector.LogLineInformation_CONNECTION_REJECTED,
 			LogLevel:       pganalyze_collector.LogLineInformation_FATAL,
+			Details:        map[string]interface{}{"auth_failure": "ident authentication failed", "username": "postgres"},
 		}, {
 			Classification: pganalyze_collector.LogLineInformation_CONNECTION_DISCONNECTED,
 			Details:        map[string]interface{}{"session_time_secs": 6781.198},
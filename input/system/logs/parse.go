@@ -51,7 +51,7 @@ var RsyslogProcessNameRegexp = `(\w+)`
 var RsyslogRegexp = regexp.MustCompile(`^` + RsyslogTimeRegexp + ` ` + RsyslogHostnameRegxp + ` ` + RsyslogProcessNameRegexp + `\[` + PidRegexp + `\]: ` + SyslogSequenceAndSplitRegexp + ` ` + RsyslogLevelAndContentRegexp)
 
 func ParseLogLineWithPrefix(prefix string, line string) (logLine state.LogLine, ok bool) {
-	var timePart, userPart, dbPart, appPart, pidPart, levelPart, contentPart string
+	var timePart, userPart, dbPart, appPart, pidPart, clientPart, levelPart, contentPart string
 
 	// Assume Postgres time format unless overriden by the prefix (e.g. syslog)
 	timeFormat := "2006-01-02 15:04:05 MST"
@@ -101,7 +101,7 @@ func ParseLogLineWithPrefix(prefix string, line string) (logLine state.LogLine,
 			}
 
 			timePart = parts[1]
-			// skip %r (ip+port)
+			clientPart = parts[2]
 			userPart = parts[3]
 			dbPart = parts[4]
 			pidPart = parts[5]
@@ -153,6 +153,9 @@ func ParseLogLineWithPrefix(prefix string, line string) (logLine state.LogLine,
 	if appPart != "[unknown]" {
 		logLine.Application = appPart
 	}
+	if idx := strings.Index(clientPart, "("); idx != -1 { // %r includes the port, e.g. "1.2.3.4(1234)"
+		logLine.ClientAddr = clientPart[:idx]
+	}
 
 	backendPid, _ := strconv.Atoi(pidPart)
 	logLine.BackendPid = int32(backendPid)
@@ -107,6 +107,9 @@ func AnalyzeInGroupsAndSend(server state.Server, logLines []state.LogLine, globa
 	}
 
 	logState.LogFiles = []state.LogFile{logFile}
+	logState.TempFileStats = AggregateTempFiles(logState.LogFiles)
+	logState.CanceledStatements = AggregateCanceledStatements(logState.LogFiles)
+	logState.AuthFailures = AggregateAuthFailures(logState.LogFiles)
 	defer logState.Cleanup()
 
 	if globalCollectionOpts.DebugLogs {
@@ -0,0 +1,62 @@
+package logs
+
+import (
+	"strings"
+
+	pg_query "github.com/lfittl/pg_query_go"
+	"github.com/pganalyze/collector/output/pganalyze_collector"
+	"github.com/pganalyze/collector/state"
+	"github.com/pganalyze/collector/util"
+)
+
+// identifyStatement - Fingerprints and normalizes the statement attached to a log line (if any)
+func identifyStatement(query string) (fingerprint [21]byte, normalizedQuery string) {
+	// Multi-line statements keep their line breaks and indentation in the log, which
+	// we don't care about for identifying the statement
+	query = strings.Join(strings.Fields(query), " ")
+	if query == "" {
+		return
+	}
+
+	fingerprint = util.FingerprintQuery(query)
+	normalizedQuery, err := pg_query.Normalize(query)
+	if err != nil {
+		normalizedQuery = "<truncated query>"
+	}
+	return
+}
+
+// AggregateTempFiles - Sums up the temp files logged due to log_temp_files by the statement that
+// created them (taken from the STATEMENT line that follows each temp file message)
+//
+// Run this after the log lines were analyzed, since that attaches the statement to the log line.
+func AggregateTempFiles(logFiles []state.LogFile) state.TempFileStatsMap {
+	stats := make(state.TempFileStatsMap)
+
+	for _, logFile := range logFiles {
+		for _, logLine := range logFile.LogLines {
+			if logLine.Classification != pganalyze_collector.LogLineInformation_SERVER_TEMP_FILE_CREATED {
+				continue
+			}
+			size, _ := logLine.Details["size"].(int64)
+
+			key := state.TempFileStatsKey{Database: logLine.Database, Username: logLine.Username}
+			var normalizedQuery string
+			key.Fingerprint, normalizedQuery = identifyStatement(logLine.Query)
+
+			s := stats[key]
+			s.NormalizedQuery = normalizedQuery
+			if logLine.QueryID != 0 {
+				s.QueryID = logLine.QueryID
+			}
+			s.Count++
+			s.TotalBytes += size
+			if size > s.MaxBytes {
+				s.MaxBytes = size
+			}
+			stats[key] = s
+		}
+	}
+
+	return stats
+}
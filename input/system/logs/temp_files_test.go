@@ -0,0 +1,39 @@
+package logs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pganalyze/collector/state"
+)
+
+func TestAggregateTempFiles(t *testing.T) {
+	buffer := "2018-03-11 20:00:02 UTC:1.2.3.4(1234):app@mydb:[123]:LOG:  temporary file: path \"base/pgsql_tmp/pgsql_tmp123.0\", size 1048576\n" +
+		"2018-03-11 20:00:02 UTC:1.2.3.4(1234):app@mydb:[123]:STATEMENT:  SELECT *\n" +
+		"\t  FROM orders\n" +
+		"\t ORDER BY created_at\n" +
+		"2018-03-11 20:00:03 UTC:1.2.3.4(1234):app@mydb:[123]:LOG:  temporary file: path \"base/pgsql_tmp/pgsql_tmp123.1\", size 3145728\n" +
+		"2018-03-11 20:00:03 UTC:1.2.3.4(1234):app@mydb:[123]:STATEMENT:  SELECT * FROM orders ORDER BY created_at\n" +
+		"2018-03-11 20:00:04 UTC:1.2.3.4(1234):app@mydb:[124]:LOG:  temporary file: path \"base/pgsql_tmp/pgsql_tmp124.0\", size 2048\n"
+
+	logLines, _, _ := ParseAndAnalyzeBuffer(buffer, 0, time.Time{})
+	stats := AggregateTempFiles([]state.LogFile{{LogLines: logLines}})
+
+	if len(stats) != 2 {
+		t.Fatalf("Expected temp files of 2 statements (including one without statement text), got %d: %+v", len(stats), stats)
+	}
+
+	var orders state.TempFileStats
+	for key, s := range stats {
+		if s.NormalizedQuery != "" {
+			orders = s
+			if key.Database != "mydb" || key.Username != "app" {
+				t.Errorf("Expected temp files to be attributed to app@mydb, got %s@%s", key.Username, key.Database)
+			}
+		}
+	}
+
+	if orders.NormalizedQuery != "SELECT * FROM orders ORDER BY created_at" || orders.Count != 2 || orders.TotalBytes != 4194304 || orders.MaxBytes != 3145728 {
+		t.Errorf("Unexpected aggregation for multi-line statement: %+v", orders)
+	}
+}
@@ -88,7 +88,8 @@ func GetLogFiles(config config.ServerConfig, logger *util.Logger) (result []stat
 
 			var newLogLines []state.LogLine
 			var newSamples []state.PostgresQuerySample
-			newLogLines, newSamples, currentByteStart = logs.ParseAndAnalyzeBuffer(*resp.LogFileData, currentByteStart, linesNewerThan)
+			logFormat := logs.DetectLogFormat(logFile.OriginalName, config.LogFormat)
+			newLogLines, newSamples, currentByteStart = logs.ParseAndAnalyzeBufferWithFormat(logFormat, *resp.LogFileData, currentByteStart, linesNewerThan)
 			logFile.LogLines = append(logFile.LogLines, newLogLines...)
 			samples = append(samples, newSamples...)
 
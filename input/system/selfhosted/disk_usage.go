@@ -0,0 +1,13 @@
+package selfhosted
+
+import "github.com/shirou/gopsutil/disk"
+
+// GetDiskUsage - Returns the total and available bytes of the filesystem that contains path
+// (available being the space usable by unprivileged processes, like the Postgres server)
+func GetDiskUsage(path string) (total uint64, available uint64, err error) {
+	usage, err := disk.Usage(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	return usage.Total, usage.Free, nil
+}
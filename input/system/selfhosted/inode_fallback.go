@@ -0,0 +1,10 @@
+// +build !darwin,!linux,!freebsd
+
+package selfhosted
+
+import "os"
+
+// Without inodes we can't detect rotation, and only rely on truncation detection
+func getInode(info os.FileInfo) uint64 {
+	return 0
+}
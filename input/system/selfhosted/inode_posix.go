@@ -0,0 +1,16 @@
+// +build linux freebsd darwin
+
+package selfhosted
+
+import (
+	"os"
+	"syscall"
+)
+
+func getInode(info os.FileInfo) uint64 {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0
+	}
+	return uint64(stat.Ino)
+}
@@ -20,7 +20,7 @@ func SetupLogTails(servers []state.Server, globalCollectionOpts state.Collection
 			continue
 		}
 
-		prefixedLogger := logger.WithPrefix(server.Config.SectionName)
+		prefixedLogger := server.Config.Logger(logger, false)
 
 		if globalCollectionOpts.DebugLogs {
 			prefixedLogger.PrintInfo("Setting up log tail for %s", server.Config.LogLocation)
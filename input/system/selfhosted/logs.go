@@ -0,0 +1,225 @@
+package selfhosted
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pganalyze/collector/config"
+	"github.com/pganalyze/collector/input/system/logs"
+	"github.com/pganalyze/collector/state"
+	"github.com/pganalyze/collector/util"
+	uuid "github.com/satori/go.uuid"
+)
+
+// GetLogFiles - Reads all log data that was written since the previous run
+//
+// The log location can either be a single file, or a directory of log files. For each
+// file we remember the inode and the offset up to which we've read (only ever advancing
+// over complete log records), and resume from there on the next run. When a file was rotated
+// (a different inode took over the path) we read the remainder of the old file before
+// starting on the new one, and when a file was truncated we start over from the beginning.
+//
+// On the very first run (no known positions) we start at the end of each file, to avoid
+// sending historic log data.
+func GetLogFiles(config config.ServerConfig, prevPositions state.LogFilePositionMap, logger *util.Logger) (result []state.LogFile, samples []state.PostgresQuerySample, positions state.LogFilePositionMap) {
+	logLocation := config.LogLocation
+	statInfo, err := os.Stat(logLocation)
+	if err != nil {
+		logger.PrintError("Could not read log location: %s", err)
+		return nil, nil, prevPositions
+	}
+
+	var paths []string
+	if statInfo.IsDir() {
+		files, err := ioutil.ReadDir(logLocation)
+		if err != nil {
+			logger.PrintError("Could not read log directory: %s", err)
+			return nil, nil, prevPositions
+		}
+		for _, f := range files {
+			if f.Mode().IsRegular() {
+				paths = append(paths, filepath.Join(logLocation, f.Name()))
+			}
+		}
+	} else {
+		paths = []string{logLocation}
+	}
+
+	firstRun := prevPositions == nil
+	positions = make(state.LogFilePositionMap)
+
+	for _, path := range paths {
+		logFormat := logs.DetectLogFormat(path, config.LogFormat)
+		content, position, err := readLogFile(path, logFormat, prevPositions, firstRun, !statInfo.IsDir())
+		if err != nil {
+			logger.PrintWarning("Could not read log file %s: %s", path, err)
+			if prevPosition, exist := prevPositions[path]; exist {
+				positions[path] = prevPosition
+			}
+			continue
+		}
+		positions[path] = position
+
+		if len(content) == 0 {
+			continue
+		}
+
+		var logFile state.LogFile
+		logFile.UUID = uuid.NewV4()
+		logFile.TmpFile, err = ioutil.TempFile("", "")
+		if err != nil {
+			logger.PrintError("Could not allocate tempfile for logs: %s", err)
+			return result, samples, prevPositions
+		}
+		logFile.OriginalName = path
+
+		_, err = logFile.TmpFile.Write(content)
+		if err != nil {
+			logger.PrintError("%s", err)
+			logFile.Cleanup()
+			return result, samples, prevPositions
+		}
+
+		var newSamples []state.PostgresQuerySample
+		logFile.LogLines, newSamples, _ = logs.ParseAndAnalyzeBufferWithFormat(logFormat, string(content), 0, time.Time{})
+		samples = append(samples, newSamples...)
+
+		result = append(result, logFile)
+	}
+
+	return
+}
+
+// readLogFile - Returns the new content of a log file since its previously known position,
+// as well as the position to resume from next time
+func readLogFile(path string, logFormat string, prevPositions state.LogFilePositionMap, firstRun bool, followRotation bool) (content []byte, position state.LogFilePosition, err error) {
+	statInfo, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	position.Inode = getInode(statInfo)
+
+	prevPosition, known := prevPositions[path]
+	if known && prevPosition.Inode != position.Inode {
+		// The path now refers to a different file - when following a single file, read
+		// whatever was written to the old one before it got rotated away
+		if followRotation {
+			oldPath := findFileByInode(filepath.Dir(path), prevPosition.Inode, path)
+			if oldPath != "" {
+				content, _, err = readFrom(oldPath, logFormat, prevPosition.Offset, false)
+				if err != nil {
+					return
+				}
+			}
+		}
+		known = false
+	}
+	if !known {
+		// Files that have been renamed within a log directory keep their inode
+		if renamedPosition, exist := findPositionByInode(prevPositions, position.Inode); exist {
+			prevPosition = renamedPosition
+			known = true
+		}
+	}
+
+	startOffset := int64(0)
+	if known {
+		startOffset = prevPosition.Offset
+		if startOffset > statInfo.Size() {
+			// The file was truncated, e.g. by logrotate's copytruncate
+			startOffset = 0
+		}
+	} else if firstRun {
+		position.Offset = statInfo.Size()
+		return
+	}
+
+	newContent, newOffset, err := readFrom(path, logFormat, startOffset, true)
+	if err != nil {
+		return
+	}
+	content = append(content, newContent...)
+	position.Offset = newOffset
+
+	return
+}
+
+// Maximum amount of log data read from a single file in one run, anything beyond that
+// gets read on the next run
+var maxLogReadBytes int64 = 10 * 1024 * 1024
+
+// readFrom - Reads a file starting at the given offset, returning the data and the new offset
+//
+// With completeRecordsOnly set, a partially written log record at the end is left for the
+// next read, and the offset only advances over the bytes of the complete records.
+func readFrom(path string, logFormat string, offset int64, completeRecordsOnly bool) ([]byte, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, offset, err
+	}
+	defer file.Close()
+
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return nil, offset, err
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(file, maxLogReadBytes))
+	if err != nil {
+		return nil, offset, err
+	}
+
+	if completeRecordsOnly {
+		consumed := logs.CompleteRecordsLength(logFormat, string(data))
+		if consumed == 0 && int64(len(data)) == maxLogReadBytes {
+			// A single record larger than what we read at once, skip over it instead of
+			// getting stuck at this offset
+			consumed = len(data)
+		}
+		data = data[:consumed]
+	}
+
+	return data, offset + int64(len(data)), nil
+}
+
+func findPositionByInode(positions state.LogFilePositionMap, inode uint64) (state.LogFilePosition, bool) {
+	if inode == 0 {
+		return state.LogFilePosition{}, false
+	}
+
+	// Iterate in a stable order, in case of (unlikely) inode reuse
+	var paths []string
+	for path := range positions {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if positions[path].Inode == inode {
+			return positions[path], true
+		}
+	}
+	return state.LogFilePosition{}, false
+}
+
+func findFileByInode(dir string, inode uint64, excludePath string) string {
+	if inode == 0 {
+		return ""
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.Name())
+		if path != excludePath && f.Mode().IsRegular() && getInode(f) == inode {
+			return path
+		}
+	}
+	return ""
+}
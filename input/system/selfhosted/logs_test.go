@@ -0,0 +1,150 @@
+package selfhosted
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pganalyze/collector/input/system/logs"
+	"github.com/pganalyze/collector/state"
+)
+
+type readLogFileStep struct {
+	// Applied to the log directory before reading
+	setup func(t *testing.T, path string)
+	// Expected content returned by the read
+	content string
+}
+
+func appendToFile(data string) func(t *testing.T, path string) {
+	return func(t *testing.T, path string) {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err = f.WriteString(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func truncateFile(data string) func(t *testing.T, path string) {
+	return func(t *testing.T, path string) {
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func rotateFile(oldData string, newData string) func(t *testing.T, path string) {
+	return func(t *testing.T, path string) {
+		appendToFile(oldData)(t, path)
+		if err := os.Rename(path, path+".1"); err != nil {
+			t.Fatal(err)
+		}
+		appendToFile(newData)(t, path)
+	}
+}
+
+var readLogFileTests = []struct {
+	name  string
+	steps []readLogFileStep
+}{
+	{
+		"first run starts at the end, then resumes",
+		[]readLogFileStep{
+			{appendToFile("old line\n"), ""},
+			{appendToFile("line 1\nline 2\n"), "line 1\nline 2\n"},
+			{appendToFile("line 3\n"), "line 3\n"},
+		},
+	},
+	{
+		"partial lines are read once complete",
+		[]readLogFileStep{
+			{appendToFile(""), ""},
+			{appendToFile("line 1\nline"), "line 1\n"},
+			{appendToFile(" 2\n"), "line 2\n"},
+		},
+	},
+	{
+		"truncated file is read from the start",
+		[]readLogFileStep{
+			{appendToFile("line 1\nline 2\n"), ""},
+			{truncateFile("new\n"), "new\n"},
+		},
+	},
+	{
+		"rotated file is read to the end before the new file",
+		[]readLogFileStep{
+			{appendToFile("line 1\n"), ""},
+			{rotateFile("line 2\n", "line 3\n"), "line 2\nline 3\n"},
+			{appendToFile("line 4\n"), "line 4\n"},
+		},
+	},
+}
+
+var readCsvLogFileTests = []struct {
+	name  string
+	steps []readLogFileStep
+}{
+	{
+		"records with newlines in quoted fields are read once complete",
+		[]readLogFileStep{
+			{appendToFile(""), ""},
+			{appendToFile("2018-09-27 06:57:01.030 UTC,,,1,\"line 1\"\n2018-09-27 06:57:02.030 UTC,,,2,\"line 2\n"), "2018-09-27 06:57:01.030 UTC,,,1,\"line 1\"\n"},
+			{appendToFile("continued\"\n"), "2018-09-27 06:57:02.030 UTC,,,2,\"line 2\ncontinued\"\n"},
+		},
+	},
+}
+
+func runReadLogFileSteps(t *testing.T, name string, logFormat string, steps []readLogFileStep) {
+	dir, err := ioutil.TempDir("", "pganalyze-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "postgresql.log")
+
+	var positions state.LogFilePositionMap
+	for idx, step := range steps {
+		step.setup(t, path)
+
+		content, position, err := readLogFile(path, logFormat, positions, positions == nil, true)
+		if err != nil {
+			t.Fatalf("%s, step %d: %s", name, idx, err)
+		}
+		if string(content) != step.content {
+			t.Errorf("%s, step %d:\n got: %q\n expected: %q", name, idx, content, step.content)
+		}
+		positions = state.LogFilePositionMap{path: position}
+	}
+}
+
+func TestReadLogFile(t *testing.T) {
+	for _, test := range readLogFileTests {
+		runReadLogFileSteps(t, test.name, logs.LogFormatText, test.steps)
+	}
+}
+
+func TestReadCsvLogFile(t *testing.T) {
+	for _, test := range readCsvLogFileTests {
+		runReadLogFileSteps(t, test.name, logs.LogFormatCsv, test.steps)
+	}
+}
+
+func TestReadLogFileBounded(t *testing.T) {
+	prevMaxLogReadBytes := maxLogReadBytes
+	maxLogReadBytes = 10
+	defer func() { maxLogReadBytes = prevMaxLogReadBytes }()
+
+	runReadLogFileSteps(t, "bounded reads", logs.LogFormatText, []readLogFileStep{
+		{appendToFile(""), ""},
+		{appendToFile("line 1\nline 2\nline 3\n"), "line 1\n"},
+		{appendToFile(""), "line 2\n"},
+		{appendToFile("a much longer line\n"), "line 3\n"},
+		{appendToFile(""), "a much lon"},
+		{appendToFile(""), "ger line\n"},
+	})
+}
@@ -3,6 +3,7 @@ package system
 import (
 	"os"
 
+	"github.com/guregu/null"
 	"github.com/pganalyze/collector/config"
 	"github.com/pganalyze/collector/input/system/rds"
 	"github.com/pganalyze/collector/input/system/selfhosted"
@@ -11,9 +12,11 @@ import (
 )
 
 // GetLogFiles - Retrieves all new log files for this system and returns them
-func GetLogFiles(config config.ServerConfig, logger *util.Logger) (files []state.LogFile, querySamples []state.PostgresQuerySample) {
-	if config.SystemType == "amazon_rds" {
-		files, querySamples = rds.GetLogFiles(config, logger)
+func GetLogFiles(server state.Server, logger *util.Logger) (files []state.LogFile, querySamples []state.PostgresQuerySample, positions state.LogFilePositionMap) {
+	if server.Config.SystemType == "amazon_rds" {
+		files, querySamples = rds.GetLogFiles(server.Config, logger)
+	} else if server.Config.LogLocation != "" && server.LogFilePositions != nil {
+		files, querySamples, positions = selfhosted.GetLogFiles(server.Config, server.LogFilePositions.Get(), logger)
 	}
 
 	return
@@ -21,10 +24,9 @@ func GetLogFiles(config config.ServerConfig, logger *util.Logger) (files []state
 
 // GetSystemState - Retrieves a system snapshot for this system and returns it
 func GetSystemState(config config.ServerConfig, logger *util.Logger) (system state.SystemState) {
-	dbHost := config.GetDbHost()
 	if config.SystemType == "amazon_rds" {
 		system = rds.GetSystemState(config, logger)
-	} else if dbHost == "" || dbHost == "localhost" || dbHost == "127.0.0.1" || os.Getenv("PGA_ALWAYS_COLLECT_SYSTEM_DATA") != "" {
+	} else if isLocalSystem(config) {
 		system = selfhosted.GetSystemState(config, logger)
 	}
 
@@ -33,3 +35,30 @@ func GetSystemState(config config.ServerConfig, logger *util.Logger) (system sta
 
 	return
 }
+
+// AddTablespaceDiskUsage - Determines the free space on the filesystem of each tablespace, if the
+// database server runs on this host
+func AddTablespaceDiskUsage(config config.ServerConfig, tablespaces []state.PostgresTablespace, logger *util.Logger) {
+	if config.SystemType == "amazon_rds" || config.SystemType == "heroku" || !isLocalSystem(config) {
+		return
+	}
+
+	for idx, tablespace := range tablespaces {
+		if tablespace.Location == "" {
+			continue
+		}
+
+		total, available, err := selfhosted.GetDiskUsage(tablespace.Location)
+		if err != nil {
+			logger.PrintVerbose("Failed to get disk usage for tablespace %s: %s", tablespace.Name, err)
+			continue
+		}
+		tablespaces[idx].DiskTotalBytes = null.IntFrom(int64(total))
+		tablespaces[idx].DiskAvailableBytes = null.IntFrom(int64(available))
+	}
+}
+
+func isLocalSystem(config config.ServerConfig) bool {
+	dbHost := config.GetDbHost()
+	return dbHost == "" || dbHost == "localhost" || dbHost == "127.0.0.1" || os.Getenv("PGA_ALWAYS_COLLECT_SYSTEM_DATA") != ""
+}
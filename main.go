@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -30,19 +31,27 @@ import (
 	_ "github.com/lib/pq" // Enable database package to use Postgres
 )
 
-func run(wg *sync.WaitGroup, globalCollectionOpts state.CollectionOpts, logger *util.Logger, configFilename string) (bool, chan<- bool, chan<- bool, chan<- bool, chan<- bool) {
+// run - Reads the config and sets up the scheduled runs, ctx is cancelled on reload or shutdown, and
+// collectionCtx only on shutdown (to stop an in-progress full snapshot)
+func run(ctx context.Context, collectionCtx context.Context, wg *sync.WaitGroup, globalCollectionOpts state.CollectionOpts, logger *util.Logger, configFilename string) (bool, []state.Server, chan<- bool, chan<- bool, chan<- bool, chan<- bool) {
 	var servers []state.Server
 
 	schedulerGroups, err := scheduler.GetSchedulerGroups()
 	if err != nil {
 		logger.PrintError("Error: Could not get scheduler groups")
-		return false, nil, nil, nil, nil
+		if globalCollectionOpts.RunOnce || globalCollectionOpts.UploadSnapshotsDirectory != "" {
+			os.Exit(runner.ExitCodeError)
+		}
+		return false, nil, nil, nil, nil, nil
 	}
 
 	conf, err := config.Read(logger, configFilename)
 	if err != nil {
 		logger.PrintError("Config Error: %s", err)
-		return !globalCollectionOpts.TestRun, nil, nil, nil, nil
+		if globalCollectionOpts.RunOnce || globalCollectionOpts.UploadSnapshotsDirectory != "" {
+			os.Exit(runner.ExitCodeError)
+		}
+		return !globalCollectionOpts.TestRun, nil, nil, nil, nil, nil
 	}
 
 	serverConfigs := conf.Servers
@@ -53,29 +62,63 @@ func run(wg *sync.WaitGroup, globalCollectionOpts state.CollectionOpts, logger *
 
 	runner.ReadStateFile(servers, globalCollectionOpts, logger)
 
+	for idx, server := range servers {
+		if server.Config.LogLocation != "" {
+			servers[idx].LogFilePositions = state.NewLogFilePositionTracker(server.PrevState.LogFilePositions)
+		}
+		if server.Config.EnableLogs || server.Config.LogLocation != "" {
+			servers[idx].StatementTexts = &state.StatementTextCache{}
+		}
+	}
+
 	// We intentionally don't do a test-run in the normal mode, since we're fine with
 	// a later SIGHUP that fixes the config (or a temporarily unreachable server at start)
 	if globalCollectionOpts.TestRun {
-		if globalCollectionOpts.TestReport != "" {
+		if globalCollectionOpts.SelfTest {
+			if !runner.RunSelfTest(servers, globalCollectionOpts, logger) {
+				os.Exit(runner.ExitCodeError)
+			}
+		} else if globalCollectionOpts.TestReport != "" {
 			runner.RunTestReport(servers, globalCollectionOpts, logger)
 		} else if globalCollectionOpts.TestRunLogs {
 			runner.CollectLogsFromAllServers(servers, globalCollectionOpts, logger)
 		} else {
-			runner.CollectAllServers(servers, globalCollectionOpts, logger)
+			runner.CollectAllServers(context.Background(), servers, globalCollectionOpts, logger)
+		}
+		return false, nil, nil, nil, nil, nil
+	}
+
+	if globalCollectionOpts.RunOnce {
+		results := runner.CollectAllServersOnce(servers, globalCollectionOpts, logger)
+		os.Exit(runner.OnceExitCode(results))
+	}
+
+	if globalCollectionOpts.UploadSnapshotsDirectory != "" {
+		if !runner.UploadSpooledSnapshots(servers, globalCollectionOpts.UploadSnapshotsDirectory, globalCollectionOpts, logger) {
+			os.Exit(runner.ExitCodeError)
 		}
-		return false, nil, nil, nil, nil
+		return false, nil, nil, nil, nil, nil
 	}
 
 	if globalCollectionOpts.DebugLogs {
 		selfhosted.SetupLogTails(servers, globalCollectionOpts, logger)
 
 		// Keep running but only running log processing
-		return true, nil, nil, nil, nil
+		return true, servers, nil, nil, nil, nil
+	}
+
+	runner.SetupWaitEventSampling(ctx, wg, servers, globalCollectionOpts, logger)
+
+	if splay := scheduler.GetSplay(serverConfigs); splay > 0 {
+		logger.PrintVerbose("Applying schedule splay of %s", splay)
+		for name, group := range schedulerGroups {
+			schedulerGroups[name] = group.WithSplay(splay)
+		}
 	}
 
 	statsStop := schedulerGroups["stats"].Schedule(func() {
 		wg.Add(1)
-		runner.CollectAllServers(servers, globalCollectionOpts, logger)
+		runner.CollectAllServers(collectionCtx, servers, globalCollectionOpts, logger)
 		wg.Done()
 	}, logger, "full snapshot of all servers")
 
@@ -106,8 +149,6 @@ func run(wg *sync.WaitGroup, globalCollectionOpts state.CollectionOpts, logger *
 
 	var logsStop chan<- bool
 	if hasAnyLogsEnabled {
-		selfhosted.SetupLogTails(servers, globalCollectionOpts, logger)
-
 		if conf.HerokuLogStream != nil {
 			heroku.SetupLogReceiver(conf, servers, globalCollectionOpts, logger)
 		} else {
@@ -128,7 +169,7 @@ func run(wg *sync.WaitGroup, globalCollectionOpts state.CollectionOpts, logger *
 		}, logger, "activity snapshot of all servers")
 	}
 
-	return true, statsStop, reportsStop, logsStop, activityStop
+	return true, servers, statsStop, reportsStop, logsStop, activityStop
 }
 
 const defaultConfigFile = "/etc/pganalyze-collector.conf"
@@ -142,6 +183,7 @@ func main() {
 	var debugLogs bool
 	var testRun bool
 	var testReport string
+	var selfTest bool
 	var forceStateUpdate bool
 	var configFilename string
 	var stateFilename string
@@ -152,7 +194,15 @@ func main() {
 	var testRunAndTrace bool
 	var logToSyslog bool
 	var logNoTimestamps bool
+	var logFormat string
+	var logLevel string
+	var verbose bool
 	var reloadRun bool
+	var runOnce bool
+	var uploadSnapshots string
+	var shutdownGracePeriod int
+	var finalSnapshotOnShutdown bool
+	var schemaOnly bool
 
 	logFlags := log.LstdFlags
 	logger := &util.Logger{}
@@ -160,10 +210,18 @@ func main() {
 	flag.BoolVarP(&showVersion, "version", "", false, "Shows current version of the collector and exits")
 	flag.BoolVarP(&testRun, "test", "t", false, "Tests whether we can successfully collect data, submits it to the server, and exits afterwards")
 	flag.StringVar(&testReport, "test-report", "", "Tests a particular report and returns its output as JSON")
+	flag.BoolVar(&selfTest, "self-test", false, "Checks whether the collector can connect and has the permissions it needs, outputs a checklist and exits (with a non-zero exit code if any check fails)")
+	flag.BoolVar(&runOnce, "once", false, "Collects and submits a single full snapshot, updates the state file, and exits - the exit code is 0 on success, 2 if the collector could not connect, 3 for partial collection, 4 if submission failed, and 1 for other errors")
+	flag.StringVar(&uploadSnapshots, "upload-snapshots", "", "Uploads snapshots written to the given directory by a collector with output_type = file (e.g. on another host, across an air gap), removes them once submitted, and exits")
+	flag.IntVar(&shutdownGracePeriod, "shutdown-grace-period", 5, "Seconds to wait on SIGTERM/SIGINT for an in-progress snapshot to stop, and for the final snapshot (if enabled), before exiting")
+	flag.BoolVar(&finalSnapshotOnShutdown, "final-snapshot-on-shutdown", false, "Collect and submit one last full snapshot when receiving SIGTERM/SIGINT (limited by --shutdown-grace-period)")
+	flag.BoolVar(&schemaOnly, "schema-only", false, "Collects and submits a single snapshot with only schema information and settings (no statistics, system metrics or logs) and exits, without updating the state file")
 	flag.BoolVar(&reloadRun, "reload", false, "Reloads the collector daemon thats running on the host")
-	flag.BoolVarP(&logger.Verbose, "verbose", "v", false, "Outputs additional debugging information, use this if you're encoutering errors or other problems")
+	flag.BoolVarP(&verbose, "verbose", "v", false, "Outputs additional debugging information, use this if you're encoutering errors or other problems (same as --log-level=verbose)")
+	flag.StringVar(&logLevel, "log-level", "info", "Minimum level of log messages to output: error, warn, info or verbose/debug (can be overridden per server using the log_level setting)")
 	flag.BoolVar(&logToSyslog, "syslog", false, "Write all log output to syslog instead of stderr (disabled by default)")
 	flag.BoolVar(&logNoTimestamps, "no-log-timestamps", false, "Disable timestamps in the log output (automatically done when syslog is enabled)")
+	flag.StringVar(&logFormat, "log-format", "text", "Format of the log output, either \"text\" or \"json\" (one JSON object per line, intended for structured logging pipelines)")
 	flag.BoolVar(&dryRun, "dry-run", false, "Print JSON data that would get sent to web service (without actually sending) and exit afterwards")
 	flag.BoolVar(&dryRunLogs, "dry-run-logs", false, "Print JSON data for log snapshot (without actually sending) and exit afterwards")
 	flag.StringVar(&analyzeLogfile, "analyze-logfile", "", "Analyzes the content of the given log file and returns debug output about it")
@@ -195,6 +253,27 @@ func main() {
 		logFlags = 0
 	}
 
+	if verbose {
+		logLevel = "verbose"
+	}
+	var err error
+	logger.MinLevel, err = util.ParseLogLevel(logLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	switch logFormat {
+	case "text":
+	case "json":
+		// Timestamps are included in each JSON object instead
+		logger.JSON = true
+		logFlags = 0
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown log format \"%s\", supported formats are \"text\" and \"json\"\n", logFormat)
+		os.Exit(1)
+	}
+
 	if logToSyslog {
 		var err error
 		logger.Destination, err = syslog.NewLogger(syslog.LOG_NOTICE|syslog.LOG_DAEMON, logFlags)
@@ -224,12 +303,19 @@ func main() {
 		testRun = true
 	}
 
+	if selfTest {
+		testRun = true
+	}
+
 	globalCollectionOpts := state.CollectionOpts{
 		SubmitCollectedData:      true,
 		TestRun:                  testRun,
 		TestReport:               testReport,
+		SelfTest:                 selfTest,
 		TestRunLogs:              dryRunLogs,
 		DebugLogs:                debugLogs,
+		RunOnce:                  runOnce,
+		UploadSnapshotsDirectory: uploadSnapshots,
 		CollectPostgresRelations: !noPostgresRelations,
 		CollectPostgresSettings:  !noPostgresSettings,
 		CollectPostgresLocks:     !noPostgresLocks,
@@ -245,6 +331,15 @@ func main() {
 		ForceEmptyGrant:          dryRun || dryRunLogs,
 	}
 
+	if schemaOnly {
+		globalCollectionOpts.SchemaOnly = true
+		globalCollectionOpts.RunOnce = true
+		globalCollectionOpts.WriteStateUpdate = false
+		globalCollectionOpts.CollectLogs = false
+		globalCollectionOpts.CollectExplain = false
+		globalCollectionOpts.CollectSystemInformation = false
+	}
+
 	if reloadRun {
 		util.Reload()
 		return
@@ -283,7 +378,7 @@ func main() {
 			panic(err)
 		}
 		trace.Start(f)
-		run(&sync.WaitGroup{}, globalCollectionOpts, logger, configFilename)
+		run(context.Background(), context.Background(), &sync.WaitGroup{}, globalCollectionOpts, logger, configFilename)
 		trace.Stop()
 		f.Close()
 		return
@@ -303,15 +398,26 @@ func main() {
 
 	wg := sync.WaitGroup{}
 
+	collectionCtx, cancelCollection := context.WithCancel(context.Background())
+	defer cancelCollection()
+
 ReadConfigAndRun:
-	keepRunning, statsStop, reportsStop, logsStop, activityStop := run(&wg, globalCollectionOpts, logger, configFilename)
+	ctx, cancel := context.WithCancel(context.Background())
+	keepRunning, servers, statsStop, reportsStop, logsStop, activityStop := run(ctx, collectionCtx, &wg, globalCollectionOpts, logger, configFilename)
 	if !keepRunning {
+		cancel()
 		return
 	}
 
 	// Block here until we get any of the registered signals
 	s := <-sigs
 
+	// When shutting down, stop an in-progress snapshot right away (otherwise stopping the
+	// scheduled runs below would wait for it to finish)
+	if s != syscall.SIGHUP {
+		cancelCollection()
+	}
+
 	// Stop the scheduled runs
 	if statsStop != nil {
 		statsStop <- true
@@ -326,6 +432,9 @@ ReadConfigAndRun:
 		activityStop <- true
 	}
 
+	// Stop any background goroutines tied to this configuration (e.g. wait event sampling)
+	cancel()
+
 	if s == syscall.SIGHUP {
 		if writeHeapProfile {
 			usr, err := user.Current()
@@ -347,5 +456,40 @@ ReadConfigAndRun:
 	signal.Stop(sigs)
 
 	logger.PrintInfo("Exiting...")
-	wg.Wait()
+	gracePeriod := time.Duration(shutdownGracePeriod) * time.Second
+	if !waitWithTimeout(&wg, gracePeriod) {
+		// Writing the state file here would race with the snapshot that's still running - the file
+		// already has the state of the last completed snapshot though (see CollectAllServers)
+		logger.PrintError("Background work did not finish within %s, exiting without saving the state of the in-progress snapshot (the state file keeps that of the last completed snapshot)", gracePeriod)
+		runner.ReleaseCollectorLocks()
+		return
+	}
+
+	if finalSnapshotOnShutdown {
+		logger.PrintInfo("Submitting final snapshot before exiting...")
+		finalCtx, cancelFinal := context.WithTimeout(context.Background(), gracePeriod)
+		runner.CollectAllServers(finalCtx, servers, globalCollectionOpts, logger)
+		cancelFinal()
+	} else if globalCollectionOpts.WriteStateUpdate {
+		runner.WriteStateFile(servers, globalCollectionOpts, logger)
+	}
+
+	// Let a standby collector take over on its next run, instead of waiting for the connection to time out
+	runner.ReleaseCollectorLocks()
+}
+
+// waitWithTimeout - Waits for wg, and returns false if this takes longer than timeout
+func waitWithTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
 }
@@ -9,7 +9,7 @@ import (
 
 func UploadAndSendLogs(server state.Server, grant state.GrantLogs, collectionOpts state.CollectionOpts, logger *util.Logger, logState state.LogState) error {
 	if collectionOpts.SubmitCollectedData && grant.EncryptionKey.CiphertextBlob != "" {
-		logState.LogFiles = EncryptAndUploadLogfiles(grant.Logdata, grant.EncryptionKey, logger, logState.LogFiles)
+		logState.LogFiles = EncryptAndUploadLogfiles(server.Config.HTTPClient(), grant.Logdata, grant.EncryptionKey, logger, logState.LogFiles)
 	}
 
 	ls, r := transform.LogStateToLogSnapshot(logState)
@@ -3,6 +3,7 @@ package output
 import (
 	"bytes"
 	"compress/zlib"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -27,19 +28,24 @@ func SendFull(server state.Server, collectionOpts state.CollectionOpts, logger *
 	s := transform.StateToSnapshot(newState, diffState, transientState)
 	s.CollectedIntervalSecs = collectedIntervalSecs
 	s.CollectorErrors = logger.ErrorMessages
+	for _, skipped := range transientState.SkippedCollectors {
+		s.CollectorErrors = append(s.CollectorErrors, fmt.Sprintf("Skipped collection of %s: exceeded the collection deadline", skipped))
+	}
+
+	var standbyLocal []string
+	if server.Config.CollectFromStandby && transientState.InRecovery {
+		standbyLocal = state.StandbyLocalStatistics
+	}
 
-	return submitFull(s, server, collectionOpts, logger, newState.CollectedAt, false)
+	return submitFull(s, server, collectionOpts, logger, newState.CollectedAt, false, diffState.Baseline, standbyLocal)
 }
 
 func SendFailedFull(server state.Server, collectionOpts state.CollectionOpts, logger *util.Logger) error {
 	s := snapshot.FullSnapshot{FailedRun: true, CollectorErrors: logger.ErrorMessages}
-	return submitFull(s, server, collectionOpts, logger, time.Now(), true)
+	return submitFull(s, server, collectionOpts, logger, time.Now(), true, false, nil)
 }
 
-func submitFull(s snapshot.FullSnapshot, server state.Server, collectionOpts state.CollectionOpts, logger *util.Logger, collectedAt time.Time, quiet bool) error {
-	var err error
-	var data []byte
-
+func submitFull(s snapshot.FullSnapshot, server state.Server, collectionOpts state.CollectionOpts, logger *util.Logger, collectedAt time.Time, quiet bool, baseline bool, standbyLocal []string) error {
 	snapshotUUID := uuid.NewV4()
 
 	s.SnapshotVersionMajor = 1
@@ -48,29 +54,23 @@ func submitFull(s snapshot.FullSnapshot, server state.Server, collectionOpts sta
 	s.SnapshotUuid = snapshotUUID.String()
 	s.CollectedAt, _ = ptypes.TimestampProto(collectedAt)
 
-	data, err = proto.Marshal(&s)
+	compressedData, err := compressFullSnapshotWithLimit(&s, server.Config.MaxPayloadSizeMb*1024*1024, logger)
 	if err != nil {
 		logger.PrintError("Error marshaling protocol buffers")
 		return err
 	}
 
-	var compressedData bytes.Buffer
-	w := zlib.NewWriter(&compressedData)
-	w.Write(data)
-	w.Close()
-
 	if !collectionOpts.SubmitCollectedData {
 		debugOutputAsJSON(logger, compressedData)
 		return nil
 	}
 
-	s3Location, err := uploadSnapshot(server.Grant, logger, compressedData, snapshotUUID.String())
+	out, err := NewOutput(server, collectionOpts, logger)
 	if err != nil {
-		logger.PrintError("Error uploading to S3: %s", err)
 		return err
 	}
 
-	return submitSnapshot(server, collectionOpts, logger, s3Location, collectedAt, quiet)
+	return out.Submit(context.Background(), Snapshot{UUID: snapshotUUID.String(), CollectedAt: collectedAt, Data: compressedData, SchemaVersion: SnapshotSchemaVersion, Quiet: quiet, Baseline: baseline, CollectorInstance: server.Config.GetCollectorInstance(), StandbyLocal: standbyLocal, SchemaOnly: collectionOpts.SchemaOnly})
 }
 
 func debugOutputAsJSON(logger *util.Logger, compressedData bytes.Buffer) {
@@ -104,7 +104,7 @@ func debugOutputAsJSON(logger *util.Logger, compressedData bytes.Buffer) {
 	fmt.Printf("%s\n", out.String())
 }
 
-func submitSnapshot(server state.Server, collectionOpts state.CollectionOpts, logger *util.Logger, s3Location string, collectedAt time.Time, quiet bool) error {
+func submitSnapshot(server state.Server, collectionOpts state.CollectionOpts, logger *util.Logger, s3Location string, snapshot Snapshot) error {
 	requestURL := server.Config.APIBaseURL + "/v2/snapshots"
 
 	if collectionOpts.TestRun {
@@ -112,8 +112,21 @@ func submitSnapshot(server state.Server, collectionOpts state.CollectionOpts, lo
 	}
 
 	data := url.Values{
-		"s3_location":  {s3Location},
-		"collected_at": {fmt.Sprintf("%d", collectedAt.Unix())},
+		"s3_location":    {s3Location},
+		"collected_at":   {fmt.Sprintf("%d", snapshot.CollectedAt.Unix())},
+		"schema_version": {fmt.Sprintf("%d", snapshot.SchemaVersion)},
+	}
+	if snapshot.Baseline {
+		data.Set("baseline", "true")
+	}
+	if len(snapshot.StandbyLocal) > 0 {
+		data.Set("standby_local", strings.Join(snapshot.StandbyLocal, ","))
+	}
+	if snapshot.SchemaOnly {
+		data.Set("schema_only", "true")
+	}
+	if snapshot.CollectorInstance != "" {
+		data.Set("collector_instance", snapshot.CollectorInstance)
 	}
 
 	req, err := http.NewRequest("POST", requestURL, strings.NewReader(data.Encode()))
@@ -129,7 +142,7 @@ func submitSnapshot(server state.Server, collectionOpts state.CollectionOpts, lo
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Add("Accept", "application/json,text/plain")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := server.Config.HTTPClient().Do(req)
 	// TODO: We could consider re-running on error (e.g. if it was a temporary server issue)
 	if err != nil {
 		return err
@@ -140,6 +153,7 @@ func submitSnapshot(server state.Server, collectionOpts state.CollectionOpts, lo
 	if err != nil {
 		return err
 	}
+	body = []byte(util.RedactAPIKey(string(body), server.Config.APIKey))
 
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("Error when submitting: %s\n", body)
@@ -147,7 +161,7 @@ func submitSnapshot(server state.Server, collectionOpts state.CollectionOpts, lo
 
 	if len(body) > 0 {
 		logger.PrintInfo("%s", body)
-	} else if !quiet {
+	} else if !snapshot.Quiet {
 		logger.PrintInfo("Submitted snapshot successfully")
 	}
 
@@ -0,0 +1,179 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pganalyze/collector/config"
+	"github.com/pganalyze/collector/state"
+	"github.com/pganalyze/collector/util"
+)
+
+// SnapshotSchemaVersion - Version of the snapshot contents, submitted alongside each snapshot
+//
+// The contract with the backend: Adding fields to the protocol buffers (with new field numbers)
+// does not change the version, since decoders on both sides skip fields they don't know, and
+// treat missing fields as unset. Increment this only when the meaning of existing data changes
+// (e.g. a field changes its unit, or switches from diffed to cumulative values), so a backend
+// that hasn't been upgraded yet can reject or convert the snapshot instead of misreading it.
+// Field numbers must never be reused, removed fields are marked as reserved instead.
+//
+// This is independent of state.StateOnDiskFormatVersion (local state file) and
+// SpoolFormatVersion (layout of spooled snapshot metadata).
+const SnapshotSchemaVersion = 1
+
+// Snapshot - A full snapshot that is ready to be submitted (zlib-compressed protocol buffers)
+type Snapshot struct {
+	UUID        string
+	CollectedAt time.Time
+	Data        bytes.Buffer
+
+	// Schema version the data was written with, see SnapshotSchemaVersion
+	SchemaVersion int
+
+	// Don't log success messages (e.g. for error reports)
+	Quiet bool
+
+	// Statistics are cumulative values instead of a diff, see state.DiffState (the snapshot's
+	// CollectedIntervalSecs is 0, since they don't cover a known interval)
+	Baseline bool
+
+	// Collector that produced the snapshot, see config.ServerConfig.GetCollectorInstance
+	CollectorInstance string
+
+	// Set when collected from a standby with collect_from_standby, lists the statistics
+	// that only reflect the standby (see state.StandbyLocalStatistics)
+	StandbyLocal []string
+
+	// Only contains schema information and settings, no statistics (see --schema-only)
+	SchemaOnly bool
+}
+
+// Output - Destination that full snapshots get submitted to, selected by the output_type setting
+type Output interface {
+	Submit(ctx context.Context, snapshot Snapshot) error
+}
+
+// NewOutput - Returns the configured Output for the server (by default the pganalyze service)
+func NewOutput(server state.Server, collectionOpts state.CollectionOpts, logger *util.Logger) (Output, error) {
+	switch server.Config.OutputType {
+	case "", "pganalyze":
+		return pganalyzeOutput{server: server, collectionOpts: collectionOpts, logger: logger}, nil
+	case "http":
+		if server.Config.OutputURL == "" {
+			return nil, fmt.Errorf("output_type \"http\" requires output_url to be set")
+		}
+		return httpOutput{url: server.Config.OutputURL, client: server.Config.HTTPClient(), logger: logger}, nil
+	case "file":
+		if server.Config.OutputDirectory == "" {
+			return nil, fmt.Errorf("output_type \"file\" requires output_directory to be set")
+		}
+		return fileOutput{directory: server.Config.OutputDirectory, server: server, logger: logger}, nil
+	}
+
+	return nil, fmt.Errorf("Unknown output_type \"%s\" (supported: pganalyze, http, file)", server.Config.OutputType)
+}
+
+// RequiresGrant - Whether the configured output needs a snapshot grant from the pganalyze API
+func RequiresGrant(config config.ServerConfig) bool {
+	return config.OutputType == "" || config.OutputType == "pganalyze"
+}
+
+// pganalyzeOutput - Uploads to S3 using the grant, and then notifies the pganalyze API
+type pganalyzeOutput struct {
+	server         state.Server
+	collectionOpts state.CollectionOpts
+	logger         *util.Logger
+}
+
+func (o pganalyzeOutput) Submit(ctx context.Context, snapshot Snapshot) error {
+	s3Location, err := uploadSnapshot(o.server.Config.HTTPClient(), o.server.Grant, o.logger, snapshot.Data, snapshot.UUID, o.server.Config.S3MultipartThresholdBytes())
+	if err != nil {
+		o.logger.PrintError("Error uploading to S3: %s", err)
+		return err
+	}
+
+	return submitSnapshot(o.server, o.collectionOpts, o.logger, s3Location, snapshot)
+}
+
+// httpOutput - POSTs the snapshot to an arbitrary HTTP endpoint (e.g. an internal aggregator)
+type httpOutput struct {
+	url    string
+	client *http.Client
+	logger *util.Logger
+}
+
+func (o httpOutput) Submit(ctx context.Context, snapshot Snapshot) error {
+	resp, err := util.DoWithRetry(o.client, util.DefaultHTTPRetryPolicy, o.logger, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", o.url, bytes.NewReader(snapshot.Data.Bytes()))
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("User-Agent", util.CollectorNameAndVersion)
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		req.Header.Set("Content-Encoding", "deflate")
+		req.Header.Set("Pganalyze-Snapshot-Uuid", snapshot.UUID)
+		req.Header.Set("Pganalyze-Snapshot-Schema-Version", fmt.Sprintf("%d", snapshot.SchemaVersion))
+		req.Header.Set("Pganalyze-Collected-At", fmt.Sprintf("%d", snapshot.CollectedAt.Unix()))
+		if snapshot.Baseline {
+			req.Header.Set("Pganalyze-Snapshot-Baseline", "true")
+		}
+		if len(snapshot.StandbyLocal) > 0 {
+			req.Header.Set("Pganalyze-Standby-Local", strings.Join(snapshot.StandbyLocal, ","))
+		}
+		if snapshot.SchemaOnly {
+			req.Header.Set("Pganalyze-Snapshot-Schema-Only", "true")
+		}
+		if snapshot.CollectorInstance != "" {
+			req.Header.Set("Pganalyze-Collector-Instance", snapshot.CollectorInstance)
+		}
+
+		return req.WithContext(ctx), nil
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Error when submitting to %s: %s: %s", o.url, resp.Status, body)
+	}
+
+	if !snapshot.Quiet {
+		o.logger.PrintInfo("Submitted snapshot successfully to %s", o.url)
+	}
+
+	return nil
+}
+
+// fileOutput - Writes the snapshot into a local directory, named by its UUID, together with
+// the metadata needed to upload it later (see spool.go)
+type fileOutput struct {
+	directory string
+	server    state.Server
+	logger    *util.Logger
+}
+
+func (o fileOutput) Submit(ctx context.Context, snapshot Snapshot) error {
+	location, err := writeSpooledSnapshot(o.directory, o.server.Config.APIKey, o.server.Config.SectionName, o.server.Config.SystemID, snapshot)
+	if err != nil {
+		return err
+	}
+
+	if !snapshot.Quiet {
+		o.logger.PrintInfo("Wrote snapshot to %s", location)
+	}
+
+	return nil
+}
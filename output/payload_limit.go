@@ -0,0 +1,139 @@
+package output
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	snapshot "github.com/pganalyze/collector/output/pganalyze_collector"
+	"github.com/pganalyze/collector/util"
+)
+
+// Number of statements (by total time) that are kept when statements get dropped to fit the payload limit
+const payloadLimitTopStatements = 100
+
+// Parts of a full snapshot that can be dropped when it exceeds max_payload_size_mb, in the order
+// they get dropped - each returns a description of what it dropped, or "" if there was nothing to drop
+var fullSnapshotReductions = []func(s *snapshot.FullSnapshot) string{
+	dropHistoricQueryStatistics,
+	dropFunctionStatistics,
+	dropNonTopStatements,
+	dropIndexStatistics,
+}
+
+func dropHistoricQueryStatistics(s *snapshot.FullSnapshot) string {
+	if len(s.HistoricQueryStatistics) == 0 {
+		return ""
+	}
+	dropped := fmt.Sprintf("query statistics of %d earlier snapshots", len(s.HistoricQueryStatistics))
+	s.HistoricQueryStatistics = nil
+	dropUnusedQueryInformations(s)
+	return dropped
+}
+
+func dropFunctionStatistics(s *snapshot.FullSnapshot) string {
+	if len(s.FunctionInformations) == 0 && len(s.FunctionStatistics) == 0 {
+		return ""
+	}
+	dropped := fmt.Sprintf("information and statistics of %d functions", len(s.FunctionInformations))
+	s.FunctionInformations = nil
+	s.FunctionStatistics = nil
+	return dropped
+}
+
+func dropNonTopStatements(s *snapshot.FullSnapshot) string {
+	if len(s.QueryStatistics) <= payloadLimitTopStatements {
+		return ""
+	}
+	dropped := fmt.Sprintf("statistics of %d statements outside the top %d (by total time)", len(s.QueryStatistics)-payloadLimitTopStatements, payloadLimitTopStatements)
+	sort.SliceStable(s.QueryStatistics, func(i, j int) bool {
+		return s.QueryStatistics[i].TotalTime > s.QueryStatistics[j].TotalTime
+	})
+	s.QueryStatistics = s.QueryStatistics[:payloadLimitTopStatements]
+	dropUnusedQueryInformations(s)
+	return dropped
+}
+
+func dropIndexStatistics(s *snapshot.FullSnapshot) string {
+	if len(s.IndexStatistics) == 0 {
+		return ""
+	}
+	dropped := fmt.Sprintf("statistics of %d indices", len(s.IndexStatistics))
+	s.IndexStatistics = nil
+	return dropped
+}
+
+// dropUnusedQueryInformations - Removes the query texts of statements that have no statistics left
+func dropUnusedQueryInformations(s *snapshot.FullSnapshot) {
+	used := make(map[int32]bool)
+	for _, stat := range s.QueryStatistics {
+		used[stat.QueryIdx] = true
+	}
+	for _, h := range s.HistoricQueryStatistics {
+		for _, stat := range h.Statistics {
+			used[stat.QueryIdx] = true
+		}
+	}
+
+	var infos []*snapshot.QueryInformation
+	for _, info := range s.QueryInformations {
+		if used[info.QueryIdx] {
+			infos = append(infos, info)
+		}
+	}
+	s.QueryInformations = infos
+}
+
+func compressFullSnapshot(s *snapshot.FullSnapshot) (bytes.Buffer, error) {
+	var compressedData bytes.Buffer
+
+	data, err := proto.Marshal(s)
+	if err != nil {
+		return compressedData, err
+	}
+
+	w := zlib.NewWriter(&compressedData)
+	w.Write(data)
+	w.Close()
+
+	return compressedData, nil
+}
+
+// compressFullSnapshotWithLimit - Serializes and compresses the snapshot, dropping its lowest
+// priority parts until it fits into maxBytes (0 for no limit)
+//
+// What got dropped is logged, and recorded in the collector errors of the snapshot. If the snapshot
+// still doesn't fit once there is nothing left to drop, it gets submitted regardless.
+func compressFullSnapshotWithLimit(s *snapshot.FullSnapshot, maxBytes int, logger *util.Logger) (bytes.Buffer, error) {
+	compressedData, err := compressFullSnapshot(s)
+	if err != nil || maxBytes <= 0 {
+		return compressedData, err
+	}
+
+	for _, reduce := range fullSnapshotReductions {
+		if compressedData.Len() <= maxBytes {
+			return compressedData, nil
+		}
+		size := compressedData.Len()
+		dropped := reduce(s)
+		if dropped == "" {
+			continue
+		}
+		message := fmt.Sprintf("Snapshot size of %d bytes exceeds max_payload_size_mb, dropped %s", size, dropped)
+		logger.PrintWarning("%s", message)
+		s.CollectorErrors = append(s.CollectorErrors, message)
+
+		compressedData, err = compressFullSnapshot(s)
+		if err != nil {
+			return compressedData, err
+		}
+	}
+
+	if compressedData.Len() > maxBytes {
+		logger.PrintWarning("Snapshot size of %d bytes still exceeds max_payload_size_mb after dropping everything that can be dropped, submitting it regardless", compressedData.Len())
+	}
+
+	return compressedData, nil
+}
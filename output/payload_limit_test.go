@@ -0,0 +1,50 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"testing"
+
+	snapshot "github.com/pganalyze/collector/output/pganalyze_collector"
+	"github.com/pganalyze/collector/util"
+)
+
+func TestCompressFullSnapshotWithLimit(t *testing.T) {
+	logger := &util.Logger{Destination: log.New(&bytes.Buffer{}, "", 0)}
+
+	var s snapshot.FullSnapshot
+	for i := 0; i < 500; i++ {
+		s.QueryInformations = append(s.QueryInformations, &snapshot.QueryInformation{QueryIdx: int32(i), NormalizedQuery: fmt.Sprintf("SELECT * FROM table_%d WHERE id = $1", i)})
+		s.QueryStatistics = append(s.QueryStatistics, &snapshot.QueryStatistic{QueryIdx: int32(i), TotalTime: float64(i)})
+	}
+	s.HistoricQueryStatistics = []*snapshot.HistoricQueryStatistics{{Statistics: []*snapshot.QueryStatistic{{QueryIdx: 1}}}}
+
+	unlimited, err := compressFullSnapshotWithLimit(&s, 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.QueryStatistics) != 500 || len(s.HistoricQueryStatistics) != 1 {
+		t.Fatalf("Expected no changes without a limit")
+	}
+
+	limited, err := compressFullSnapshotWithLimit(&s, unlimited.Len()/2, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limited.Len() > unlimited.Len()/2 {
+		t.Errorf("Expected snapshot to fit into %d bytes, got %d", unlimited.Len()/2, limited.Len())
+	}
+	if s.HistoricQueryStatistics != nil {
+		t.Errorf("Expected historic statistics to be dropped first")
+	}
+	if len(s.QueryStatistics) != payloadLimitTopStatements || s.QueryStatistics[0].TotalTime != 499 {
+		t.Errorf("Expected the top %d statements by total time to be kept, got %d", payloadLimitTopStatements, len(s.QueryStatistics))
+	}
+	if len(s.QueryInformations) != payloadLimitTopStatements {
+		t.Errorf("Expected query texts of dropped statements to be removed, got %d", len(s.QueryInformations))
+	}
+	if len(s.CollectorErrors) != 2 {
+		t.Errorf("Expected dropped parts to be recorded in the snapshot, got %v", s.CollectorErrors)
+	}
+}
@@ -71,6 +71,9 @@ type FullSnapshot struct {
 	QueryInformations       []*QueryInformation        `protobuf:"bytes,210,rep,name=query_informations,json=queryInformations" json:"query_informations,omitempty"`
 	QueryStatistics         []*QueryStatistic          `protobuf:"bytes,211,rep,name=query_statistics,json=queryStatistics" json:"query_statistics,omitempty"`
 	HistoricQueryStatistics []*HistoricQueryStatistics `protobuf:"bytes,213,rep,name=historic_query_statistics,json=historicQueryStatistics" json:"historic_query_statistics,omitempty"`
+	// pg_stat_statements_info (Postgres 14+)
+	QueryStatisticsDeallocCount int64                      `protobuf:"varint,214,opt,name=query_statistics_dealloc_count,json=queryStatisticsDeallocCount" json:"query_statistics_dealloc_count,omitempty"`
+	QueryStatisticsResetAt      *google_protobuf.Timestamp `protobuf:"bytes,215,opt,name=query_statistics_reset_at,json=queryStatisticsResetAt" json:"query_statistics_reset_at,omitempty"`
 	RelationInformations    []*RelationInformation     `protobuf:"bytes,220,rep,name=relation_informations,json=relationInformations" json:"relation_informations,omitempty"`
 	RelationStatistics      []*RelationStatistic       `protobuf:"bytes,221,rep,name=relation_statistics,json=relationStatistics" json:"relation_statistics,omitempty"`
 	RelationEvents          []*RelationEvent           `protobuf:"bytes,223,rep,name=relation_events,json=relationEvents" json:"relation_events,omitempty"`
@@ -267,6 +270,20 @@ func (m *FullSnapshot) GetHistoricQueryStatistics() []*HistoricQueryStatistics {
 	return nil
 }
 
+func (m *FullSnapshot) GetQueryStatisticsDeallocCount() int64 {
+	if m != nil {
+		return m.QueryStatisticsDeallocCount
+	}
+	return 0
+}
+
+func (m *FullSnapshot) GetQueryStatisticsResetAt() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.QueryStatisticsResetAt
+	}
+	return nil
+}
+
 func (m *FullSnapshot) GetRelationInformations() []*RelationInformation {
 	if m != nil {
 		return m.RelationInformations
@@ -31,7 +31,7 @@ func submitReportRun(server state.Server, report reports.Report, logger *util.Lo
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Add("Accept", "application/json,text/plain")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := server.Config.HTTPClient().Do(req)
 	// TODO: We could consider re-running on error (e.g. if it was a temporary server issue)
 	if err != nil {
 		return err
@@ -42,6 +42,7 @@ func submitReportRun(server state.Server, report reports.Report, logger *util.Lo
 	if err != nil {
 		return err
 	}
+	body = []byte(util.RedactAPIKey(string(body), server.Config.APIKey))
 
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("Error when submitting: %s\n", body)
@@ -73,7 +74,7 @@ func SubmitReport(server state.Server, grant state.Grant, report reports.Report,
 	w.Write(data)
 	w.Close()
 
-	s3Location, err := uploadSnapshot(grant, logger, compressedData, report.RunID())
+	s3Location, err := uploadSnapshot(server.Config.HTTPClient(), grant, logger, compressedData, report.RunID(), server.Config.S3MultipartThresholdBytes())
 	if err != nil {
 		logger.PrintError("Error uploading to S3: %s", err)
 		return err
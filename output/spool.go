@@ -0,0 +1,238 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pganalyze/collector/state"
+	"github.com/pganalyze/collector/util"
+)
+
+// On-disk format of snapshots written by output_type = file, so they can be carried across an
+// air gap and uploaded later using --upload-snapshots:
+//
+//	<output_directory>/<uuid>       zlib-compressed protocol buffers (same as uploaded to S3)
+//	<output_directory>/<uuid>.json  spoolMetadata, written last (a snapshot without it is incomplete)
+//
+// The metadata is signed with an HMAC-SHA256 keyed by the server's API key, so the uploading side
+// only submits snapshots that were written for the same server and haven't been modified. The
+// signature covers all metadata fields (including ones this version doesn't know about), since
+// they are forwarded to the pganalyze service alongside the data.
+
+// SpoolFormatVersion - Version of the on-disk snapshot format, stored in the metadata
+//
+// Adding metadata fields doesn't require a new version, since unknown fields are ignored when
+// reading, and missing ones are zero (make sure that is a sensible default for new fields).
+const SpoolFormatVersion = 1
+
+// Snapshots spooled before the schema version was recorded were all written in this version
+const spoolDefaultSchemaVersion = 1
+
+const spoolMetadataSuffix = ".json"
+
+type spoolMetadata struct {
+	FormatVersion     int      `json:"format_version"`
+	UUID              string   `json:"uuid"`
+	SchemaVersion     int      `json:"schema_version,omitempty"`
+	CollectedAt       int64    `json:"collected_at"`
+	Baseline          bool     `json:"baseline"`
+	SectionName       string   `json:"section_name"`
+	SystemID          string   `json:"system_id,omitempty"`
+	CollectorInstance string   `json:"collector_instance,omitempty"`
+	StandbyLocal      []string `json:"standby_local,omitempty"`
+	SchemaOnly        bool     `json:"schema_only,omitempty"`
+	CollectorVersion  string   `json:"collector_version"`
+	DataSHA256        string   `json:"data_sha256"`
+	Signature         string   `json:"signature"`
+}
+
+// spoolSignature - Signs the metadata JSON object without its signature field
+//
+// The fields are re-encoded with sorted keys and without whitespace, so the signature doesn't
+// depend on how the metadata file was formatted.
+func spoolSignature(apiKey string, metadataJSON []byte) (string, error) {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(metadataJSON, &fields)
+	if err != nil {
+		return "", err
+	}
+	delete(fields, "signature")
+
+	signedJSON, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, []byte(apiKey))
+	mac.Write(signedJSON)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// SpooledSnapshot - Snapshot found in a spool directory that hasn't been uploaded yet
+type SpooledSnapshot struct {
+	Path        string // Location of the snapshot data, the metadata is at Path + ".json"
+	SectionName string
+	CollectedAt time.Time
+
+	metadata     spoolMetadata
+	metadataJSON []byte // As read from disk, for verifying the signature
+}
+
+func writeSpooledSnapshot(directory string, apiKey string, sectionName string, systemID string, snapshot Snapshot) (string, error) {
+	err := os.MkdirAll(directory, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	location := filepath.Join(directory, snapshot.UUID)
+	err = ioutil.WriteFile(location, snapshot.Data.Bytes(), 0644)
+	if err != nil {
+		return "", err
+	}
+
+	checksum := sha256.Sum256(snapshot.Data.Bytes())
+	metadata := spoolMetadata{
+		FormatVersion:     SpoolFormatVersion,
+		UUID:              snapshot.UUID,
+		SchemaVersion:     snapshot.SchemaVersion,
+		CollectedAt:       snapshot.CollectedAt.Unix(),
+		Baseline:          snapshot.Baseline,
+		SectionName:       sectionName,
+		SystemID:          systemID,
+		CollectorInstance: snapshot.CollectorInstance,
+		StandbyLocal:      snapshot.StandbyLocal,
+		SchemaOnly:        snapshot.SchemaOnly,
+		CollectorVersion:  util.CollectorVersion,
+		DataSHA256:        hex.EncodeToString(checksum[:]),
+	}
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return "", err
+	}
+	metadata.Signature, err = spoolSignature(apiKey, metadataJSON)
+	if err != nil {
+		return "", err
+	}
+	metadataJSON, err = json.Marshal(metadata)
+	if err != nil {
+		return "", err
+	}
+
+	// Write and rename, so an uploader never sees partially written metadata
+	tmpFile := location + spoolMetadataSuffix + ".tmp"
+	err = ioutil.WriteFile(tmpFile, metadataJSON, 0644)
+	if err != nil {
+		return "", err
+	}
+	err = os.Rename(tmpFile, location+spoolMetadataSuffix)
+	if err != nil {
+		return "", err
+	}
+
+	return location, nil
+}
+
+// ReadSpoolDirectory - Returns all complete snapshots in the directory, oldest first
+//
+// Snapshots whose metadata can't be read are skipped with a warning, so they don't hold up the
+// upload of the others.
+func ReadSpoolDirectory(directory string, logger *util.Logger) ([]SpooledSnapshot, error) {
+	files, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []SpooledSnapshot
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), spoolMetadataSuffix) {
+			continue
+		}
+
+		metadataFile := filepath.Join(directory, f.Name())
+		metadataJSON, err := ioutil.ReadFile(metadataFile)
+		if err != nil {
+			logger.PrintWarning("Skipping snapshot: could not read metadata: %s", err)
+			continue
+		}
+
+		var metadata spoolMetadata
+		err = json.Unmarshal(metadataJSON, &metadata)
+		if err != nil {
+			logger.PrintWarning("Skipping snapshot: invalid metadata in %s: %s", metadataFile, err)
+			continue
+		}
+		if metadata.FormatVersion != SpoolFormatVersion {
+			logger.PrintWarning("Skipping snapshot: unsupported format version %d in %s", metadata.FormatVersion, metadataFile)
+			continue
+		}
+		if metadata.SchemaVersion == 0 {
+			metadata.SchemaVersion = spoolDefaultSchemaVersion
+		}
+
+		snapshots = append(snapshots, SpooledSnapshot{
+			Path:         strings.TrimSuffix(metadataFile, spoolMetadataSuffix),
+			SectionName:  metadata.SectionName,
+			CollectedAt:  time.Unix(metadata.CollectedAt, 0),
+			metadata:     metadata,
+			metadataJSON: metadataJSON,
+		})
+	}
+
+	// Snapshots are diffs against the previous one, so keep them in the order they were taken
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].CollectedAt.Before(snapshots[j].CollectedAt)
+	})
+
+	return snapshots, nil
+}
+
+// Load - Reads the snapshot data, and verifies it was written for the server with the given API key
+func (s SpooledSnapshot) Load(apiKey string) (Snapshot, error) {
+	signature, err := spoolSignature(apiKey, s.metadataJSON)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if !hmac.Equal([]byte(s.metadata.Signature), []byte(signature)) {
+		return Snapshot{}, fmt.Errorf("Signature mismatch for %s (was it written with a different API key?)", s.Path)
+	}
+
+	data, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	checksum := sha256.Sum256(data)
+	if hex.EncodeToString(checksum[:]) != s.metadata.DataSHA256 {
+		return Snapshot{}, fmt.Errorf("Checksum mismatch for %s (file is corrupted or incomplete)", s.Path)
+	}
+
+	// The data is submitted as-is, so it keeps the schema version it was written with, even if
+	// this collector was upgraded (or downgraded) in the meantime
+	return Snapshot{UUID: s.metadata.UUID, SchemaVersion: s.metadata.SchemaVersion, CollectedAt: s.CollectedAt, Data: *bytes.NewBuffer(data), Baseline: s.metadata.Baseline, CollectorInstance: s.metadata.CollectorInstance, StandbyLocal: s.metadata.StandbyLocal, SchemaOnly: s.metadata.SchemaOnly}, nil
+}
+
+// Remove - Deletes the snapshot from the spool directory, once it has been uploaded
+func (s SpooledSnapshot) Remove() error {
+	err := os.Remove(s.Path + spoolMetadataSuffix)
+	if err != nil {
+		return err
+	}
+	return os.Remove(s.Path)
+}
+
+// SubmitSpooledSnapshot - Uploads a previously spooled snapshot to the pganalyze service, regardless
+// of the output_type configured for the server
+func SubmitSpooledSnapshot(server state.Server, collectionOpts state.CollectionOpts, logger *util.Logger, snapshot Snapshot) error {
+	return pganalyzeOutput{server: server, collectionOpts: collectionOpts, logger: logger}.Submit(context.Background(), snapshot)
+}
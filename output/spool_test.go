@@ -0,0 +1,196 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pganalyze/collector/util"
+)
+
+var spoolTestLogger = &util.Logger{Destination: log.New(ioutil.Discard, "", 0)}
+
+// rewriteSpoolMetadata - Changes metadata fields of a spooled snapshot (nil values remove the
+// field), and signs it again with apiKey unless that's empty
+func rewriteSpoolMetadata(t *testing.T, location string, apiKey string, fields map[string]interface{}) {
+	var metadata map[string]interface{}
+	metadataJSON, err := ioutil.ReadFile(location + spoolMetadataSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.Unmarshal(metadataJSON, &metadata)
+	for k, v := range fields {
+		if v == nil {
+			delete(metadata, k)
+		} else {
+			metadata[k] = v
+		}
+	}
+	if apiKey != "" {
+		metadataJSON, _ = json.Marshal(metadata)
+		metadata["signature"], err = spoolSignature(apiKey, metadataJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	metadataJSON, _ = json.Marshal(metadata)
+	ioutil.WriteFile(location+spoolMetadataSuffix, metadataJSON, 0644)
+}
+
+func TestSpooledSnapshotRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pganalyze-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	older := Snapshot{UUID: "b-older", CollectedAt: time.Unix(1500000000, 0), Data: *bytes.NewBufferString("first")}
+	newer := Snapshot{UUID: "a-newer", CollectedAt: time.Unix(1500000600, 0), Data: *bytes.NewBufferString("second"), Baseline: true}
+	for _, s := range []Snapshot{newer, older} {
+		if _, err = writeSpooledSnapshot(dir, "secret", "server1", "", s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A snapshot without metadata is still being written, and must be ignored
+	ioutil.WriteFile(filepath.Join(dir, "incomplete"), []byte("partial"), 0644)
+
+	spooled, err := ReadSpoolDirectory(dir, spoolTestLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spooled) != 2 || spooled[0].Path != filepath.Join(dir, "b-older") || spooled[1].Path != filepath.Join(dir, "a-newer") {
+		t.Fatalf("Expected both snapshots ordered by collection time, got %+v", spooled)
+	}
+
+	loaded, err := spooled[1].Load("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.UUID != "a-newer" || !loaded.CollectedAt.Equal(newer.CollectedAt) || !loaded.Baseline || loaded.Data.String() != "second" {
+		t.Errorf("Loaded snapshot doesn't match the written one: %+v", loaded)
+	}
+
+	if _, err = spooled[0].Load("other-key"); err == nil {
+		t.Errorf("Expected signature mismatch when loading with a different API key")
+	}
+
+	ioutil.WriteFile(spooled[0].Path, []byte("tampered"), 0644)
+	if _, err = spooled[0].Load("secret"); err == nil {
+		t.Errorf("Expected checksum mismatch for modified snapshot data")
+	}
+
+	if err = spooled[1].Remove(); err != nil {
+		t.Fatal(err)
+	}
+	spooled, _ = ReadSpoolDirectory(dir, spoolTestLogger)
+	if len(spooled) != 1 {
+		t.Errorf("Expected one remaining snapshot after removal, got %d", len(spooled))
+	}
+}
+
+var spoolSchemaVersionTests = []struct {
+	extraFields map[string]interface{}
+	expected    int
+}{
+	// Written before the schema version was recorded
+	{map[string]interface{}{"schema_version": nil}, 1},
+	// Written by a newer collector, with metadata fields this version doesn't know about
+	{map[string]interface{}{"schema_version": 2, "future_field": "value"}, 2},
+}
+
+func TestSpooledSnapshotSchemaVersion(t *testing.T) {
+	for _, test := range spoolSchemaVersionTests {
+		dir, err := ioutil.TempDir("", "pganalyze-spool")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		location, err := writeSpooledSnapshot(dir, "secret", "server1", "", Snapshot{UUID: "uuid", CollectedAt: time.Unix(1500000000, 0), Data: *bytes.NewBufferString("data")})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rewriteSpoolMetadata(t, location, "secret", test.extraFields)
+
+		spooled, err := ReadSpoolDirectory(dir, spoolTestLogger)
+		if err != nil {
+			t.Errorf("\nMetadata: %v\nUnexpected error: %s", test.extraFields, err)
+			continue
+		}
+		loaded, err := spooled[0].Load("secret")
+		if err != nil {
+			t.Errorf("\nMetadata: %v\nUnexpected error: %s", test.extraFields, err)
+			continue
+		}
+		if loaded.SchemaVersion != test.expected {
+			t.Errorf("\nMetadata: %v\nExpected: %d\n actual: %d", test.extraFields, test.expected, loaded.SchemaVersion)
+		}
+	}
+}
+
+var spoolTamperedMetadataTests = []map[string]interface{}{
+	// The API interprets the data based on this, so it must not be changed after the fact
+	{"schema_version": 2},
+	{"schema_only": true},
+	{"standby_local": []string{"other"}},
+	{"collector_instance": "other"},
+	{"system_id": "other"},
+	{"section_name": "other"},
+	{"future_field": "value"},
+}
+
+func TestSpooledSnapshotTamperedMetadata(t *testing.T) {
+	for _, fields := range spoolTamperedMetadataTests {
+		dir, err := ioutil.TempDir("", "pganalyze-spool")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		location, err := writeSpooledSnapshot(dir, "secret", "server1", "system", Snapshot{UUID: "uuid", SchemaVersion: 1, CollectedAt: time.Unix(1500000000, 0), Data: *bytes.NewBufferString("data"), StandbyLocal: []string{"local"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rewriteSpoolMetadata(t, location, "", fields)
+
+		spooled, err := ReadSpoolDirectory(dir, spoolTestLogger)
+		if err != nil || len(spooled) != 1 {
+			t.Fatalf("\nMetadata: %v\nExpected one snapshot, got %d (error: %v)", fields, len(spooled), err)
+		}
+		if _, err = spooled[0].Load("secret"); err == nil {
+			t.Errorf("\nMetadata: %v\nExpected signature mismatch for modified metadata", fields)
+		}
+	}
+}
+
+func TestReadSpoolDirectorySkipsInvalidMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pganalyze-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, uuid := range []string{"a-valid", "b-invalid", "c-unsupported"} {
+		_, err = writeSpooledSnapshot(dir, "secret", "server1", "", Snapshot{UUID: uuid, CollectedAt: time.Unix(1500000000, 0), Data: *bytes.NewBufferString("data")})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	ioutil.WriteFile(filepath.Join(dir, "b-invalid"+spoolMetadataSuffix), []byte("{"), 0644)
+	rewriteSpoolMetadata(t, filepath.Join(dir, "c-unsupported"), "secret", map[string]interface{}{"format_version": SpoolFormatVersion + 1})
+
+	spooled, err := ReadSpoolDirectory(dir, spoolTestLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spooled) != 1 || spooled[0].Path != filepath.Join(dir, "a-valid") {
+		t.Errorf("Expected only the valid snapshot, got %+v", spooled)
+	}
+}
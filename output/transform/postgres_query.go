@@ -13,6 +13,9 @@ type statementKey struct {
 	databaseOid state.Oid
 	userOid     state.Oid
 	fingerprint [21]byte
+
+	// Set for the state.OtherStatementsKey entry, which doesn't belong to a single database and role
+	other bool
 }
 
 type statementValue struct {
@@ -22,9 +25,24 @@ type statementValue struct {
 }
 
 func upsertQueryReferenceAndInformation(s *snapshot.FullSnapshot, roleOidToIdx OidToIdx, databaseOidToIdx OidToIdx, key statementKey, value statementValue) int32 {
+	var roleIdx, databaseIdx int32
+	if key.other {
+		roleIdx, databaseIdx = otherStatementsReferences(s)
+	} else {
+		var ok bool
+		roleIdx, ok = roleOidToIdx[key.userOid]
+		if !ok && key.userOid != 0 {
+			// The role was dropped, report it separately instead of attributing to an unrelated role
+			roleIdx = int32(len(s.RoleReferences))
+			s.RoleReferences = append(s.RoleReferences, &snapshot.RoleReference{Name: state.DroppedRoleName(key.userOid)})
+			roleOidToIdx[key.userOid] = roleIdx
+		}
+		databaseIdx = databaseOidToIdx[key.databaseOid]
+	}
+
 	newRef := snapshot.QueryReference{
-		DatabaseIdx: databaseOidToIdx[key.databaseOid],
-		RoleIdx:     roleOidToIdx[key.userOid],
+		DatabaseIdx: databaseIdx,
+		RoleIdx:     roleIdx,
 		Fingerprint: key.fingerprint[:],
 	}
 
@@ -49,6 +67,34 @@ func upsertQueryReferenceAndInformation(s *snapshot.FullSnapshot, roleOidToIdx O
 	return idx
 }
 
+// otherStatementsReferences - Returns the placeholder role and database references for the
+// state.OtherStatementsKey entry, adding them on first use
+func otherStatementsReferences(s *snapshot.FullSnapshot) (roleIdx int32, databaseIdx int32) {
+	roleIdx = -1
+	for idx, ref := range s.RoleReferences {
+		if ref.Name == state.OtherStatementsRoleName {
+			roleIdx = int32(idx)
+		}
+	}
+	if roleIdx == -1 {
+		roleIdx = int32(len(s.RoleReferences))
+		s.RoleReferences = append(s.RoleReferences, &snapshot.RoleReference{Name: state.OtherStatementsRoleName})
+	}
+
+	databaseIdx = -1
+	for idx, ref := range s.DatabaseReferences {
+		if ref.Name == state.OtherStatementsDatabaseName {
+			databaseIdx = int32(idx)
+		}
+	}
+	if databaseIdx == -1 {
+		databaseIdx = int32(len(s.DatabaseReferences))
+		s.DatabaseReferences = append(s.DatabaseReferences, &snapshot.DatabaseReference{Name: state.OtherStatementsDatabaseName})
+	}
+
+	return
+}
+
 func upsertQueryReferenceAndInformationSimple(refs []*snapshot.QueryReference, infos []*snapshot.QueryInformation, roleIdx int32, databaseIdx int32, originalQuery string) (int32, []*snapshot.QueryReference, []*snapshot.QueryInformation) {
 	fingerprint := util.FingerprintQuery(originalQuery)
 
@@ -22,6 +22,7 @@ func groupStatements(statements state.PostgresStatementMap, statsMap state.Diffe
 			databaseOid: sKey.DatabaseOid,
 			userOid:     sKey.UserOid,
 			fingerprint: util.FingerprintQuery(statement.NormalizedQuery),
+			other:       sKey == state.OtherStatementsKey,
 		}
 
 		value, exist := groupedStatements[key]
@@ -90,5 +91,10 @@ func transformPostgresStatements(s snapshot.FullSnapshot, newState state.Persist
 		s.HistoricQueryStatistics = append(s.HistoricQueryStatistics, &h)
 	}
 
+	s.QueryStatisticsDeallocCount = newState.StatementInfo.Dealloc
+	if newState.StatementInfo.StatsReset.Valid {
+		s.QueryStatisticsResetAt, _ = ptypes.TimestampProto(newState.StatementInfo.StatsReset.Time)
+	}
+
 	return s
 }
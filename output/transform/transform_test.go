@@ -3,7 +3,9 @@ package transform_test
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
+	"github.com/guregu/null"
 	"github.com/pganalyze/collector/output/pganalyze_collector"
 	"github.com/pganalyze/collector/output/transform"
 	"github.com/pganalyze/collector/state"
@@ -128,3 +130,71 @@ func TestStatements(t *testing.T) {
 		t.Errorf("\nExpected:%+v\n\tActual: %+v\n\n", string(expectedJSON), string(actualJSON))
 	}
 }
+
+func TestStatementsDroppedRole(t *testing.T) {
+	key := state.PostgresStatementKey{UserOid: 11, QueryID: 1}
+
+	newState := state.PersistedState{}
+	transientState := state.TransientState{
+		Roles:      []state.PostgresRole{{Oid: 10, Name: "app"}},
+		Statements: state.PostgresStatementMap{key: state.PostgresStatement{NormalizedQuery: "SELECT 1"}},
+	}
+	diffState := state.DiffState{StatementStats: state.DiffedPostgresStatementStatsMap{key: state.DiffedPostgresStatementStats{Calls: 1}}}
+
+	actual := transform.StateToSnapshot(newState, diffState, transientState)
+
+	if len(actual.RoleReferences) != 2 || actual.RoleReferences[1].Name != "<dropped role 11>" {
+		t.Fatalf("Expected a placeholder reference for the dropped role, got %v", actual.RoleReferences)
+	}
+	if len(actual.QueryReferences) != 1 || actual.QueryReferences[0].RoleIdx != 1 {
+		t.Errorf("Expected statement of the dropped role to reference the placeholder, got %v", actual.QueryReferences)
+	}
+}
+
+func TestStatementsOtherStatements(t *testing.T) {
+	key := state.PostgresStatementKey{DatabaseOid: 1, UserOid: 10, QueryID: 1}
+
+	newState := state.PersistedState{}
+	transientState := state.TransientState{
+		Roles:     []state.PostgresRole{{Oid: 10, Name: "app"}},
+		Databases: []state.PostgresDatabase{{Oid: 1, Name: "appdb"}},
+		Statements: state.PostgresStatementMap{
+			key:                      state.PostgresStatement{NormalizedQuery: "SELECT 1"},
+			state.OtherStatementsKey: state.PostgresStatement{NormalizedQuery: state.OtherStatementsQuery},
+		},
+	}
+	diffState := state.DiffState{StatementStats: state.DiffedPostgresStatementStatsMap{
+		key:                      state.DiffedPostgresStatementStats{Calls: 1},
+		state.OtherStatementsKey: state.DiffedPostgresStatementStats{Calls: 5},
+	}}
+
+	actual := transform.StateToSnapshot(newState, diffState, transientState)
+
+	if len(actual.RoleReferences) != 2 || actual.RoleReferences[1].Name != state.OtherStatementsRoleName {
+		t.Fatalf("Expected a placeholder role reference for other statements, got %v", actual.RoleReferences)
+	}
+	if len(actual.DatabaseReferences) != 2 || actual.DatabaseReferences[1].Name != state.OtherStatementsDatabaseName {
+		t.Fatalf("Expected a placeholder database reference for other statements, got %v", actual.DatabaseReferences)
+	}
+	for _, info := range actual.QueryInformations {
+		ref := actual.QueryReferences[info.QueryIdx]
+		isOther := info.NormalizedQuery == state.OtherStatementsQuery
+		if isOther != (ref.RoleIdx == 1 && ref.DatabaseIdx == 1) {
+			t.Errorf("Unexpected references for %q: role %d, database %d", info.NormalizedQuery, ref.RoleIdx, ref.DatabaseIdx)
+		}
+	}
+}
+
+func TestStatementInfo(t *testing.T) {
+	reset := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	newState := state.PersistedState{StatementInfo: state.PostgresStatementInfo{Dealloc: 42, StatsReset: null.TimeFrom(reset)}}
+
+	actual := transform.StateToSnapshot(newState, state.DiffState{}, state.TransientState{})
+
+	if actual.QueryStatisticsDeallocCount != 42 {
+		t.Errorf("Expected dealloc count 42, got %d", actual.QueryStatisticsDeallocCount)
+	}
+	if actual.QueryStatisticsResetAt == nil || actual.QueryStatisticsResetAt.Seconds != reset.Unix() {
+		t.Errorf("Expected stats reset at %s, got %v", reset, actual.QueryStatisticsResetAt)
+	}
+}
@@ -20,17 +20,17 @@ type s3UploadResponse struct {
 	Key      string
 }
 
-func uploadCompactSnapshot(s3 state.GrantS3, logger *util.Logger, data bytes.Buffer, filename string) (string, error) {
+func uploadCompactSnapshot(httpClient *http.Client, s3 state.GrantS3, logger *util.Logger, data bytes.Buffer, filename string) (string, error) {
 	if s3.S3URL == "" {
 		return "", fmt.Errorf("Error - can't upload without valid S3 URL")
 	}
 
 	logger.PrintVerbose("Successfully prepared S3 request - size of request body: %.4f MB", float64(data.Len())/1024.0/1024.0)
 
-	return uploadToS3(s3.S3URL, s3.S3Fields, logger, data.Bytes(), filename)
+	return uploadToS3(httpClient, s3.S3URL, s3.S3Fields, logger, data.Bytes(), filename)
 }
 
-func uploadSnapshot(grant state.Grant, logger *util.Logger, data bytes.Buffer, filename string) (string, error) {
+func uploadSnapshot(httpClient *http.Client, grant state.Grant, logger *util.Logger, data bytes.Buffer, filename string, multipartThresholdBytes int64) (string, error) {
 	var err error
 
 	if !grant.Valid {
@@ -55,10 +55,17 @@ func uploadSnapshot(grant state.Grant, logger *util.Logger, data bytes.Buffer, f
 
 	logger.PrintVerbose("Successfully prepared S3 request - size of request body: %.4f MB", float64(data.Len())/1024.0/1024.0)
 
-	return uploadToS3(grant.S3URL, grant.S3Fields, logger, data.Bytes(), filename)
+	if multipartThresholdBytes > 0 && int64(data.Len()) > multipartThresholdBytes {
+		if grant.S3Multipart != nil {
+			return uploadToS3Multipart(httpClient, *grant.S3Multipart, logger, data.Bytes())
+		}
+		logger.PrintVerbose("Snapshot is over the multipart upload threshold, but the grant doesn't support multipart uploads")
+	}
+
+	return uploadToS3(httpClient, grant.S3URL, grant.S3Fields, logger, data.Bytes(), filename)
 }
 
-func uploadToS3(S3URL string, S3Fields map[string]string, logger *util.Logger, data []byte, filename string) (string, error) {
+func uploadToS3(httpClient *http.Client, S3URL string, S3Fields map[string]string, logger *util.Logger, data []byte, filename string) (string, error) {
 	var err error
 	var formBytes bytes.Buffer
 
@@ -79,13 +86,14 @@ func uploadToS3(S3URL string, S3Fields map[string]string, logger *util.Logger, d
 
 	writer.Close()
 
-	req, err := http.NewRequest("POST", S3URL, &formBytes)
-	if err != nil {
-		return "", err
-	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := util.DoWithRetry(httpClient, util.DefaultHTTPRetryPolicy, logger, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", S3URL, bytes.NewReader(formBytes.Bytes()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req, nil
+	})
 	if err != nil {
 		return "", err
 	}
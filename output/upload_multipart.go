@@ -0,0 +1,167 @@
+package output
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pganalyze/collector/state"
+	"github.com/pganalyze/collector/util"
+)
+
+// S3 doesn't allow parts smaller than this (except for the last one)
+const s3MinimumPartSize = 5 * 1024 * 1024
+
+const s3DefaultPartSize = 64 * 1024 * 1024
+
+type s3CompletedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+type s3CompleteMultipartUpload struct {
+	XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+	Parts   []s3CompletedPart `xml:"Part"`
+}
+
+type s3CompleteMultipartUploadResponse struct {
+	XMLName  xml.Name
+	Location string
+	Bucket   string
+	Key      string
+	Code     string // Only set for errors, which S3 can return with a 200 status for this request
+	Message  string
+}
+
+// uploadToS3Multipart - Uploads data in parts using the presigned multipart upload URLs of the grant,
+// for snapshots that are too large to reliably upload in a single request
+//
+// On failure the multipart upload is aborted, so S3 doesn't keep the parts uploaded so far around.
+// The grant's URLs can't be used for another attempt either way.
+func uploadToS3Multipart(httpClient *http.Client, upload state.GrantS3Multipart, logger *util.Logger, data []byte) (string, error) {
+	key, err := uploadAndCompleteS3Multipart(httpClient, upload, logger, data)
+	if err != nil && upload.AbortURL != "" {
+		if abortErr := abortS3Multipart(httpClient, upload.AbortURL, logger); abortErr != nil {
+			logger.PrintVerbose("Could not abort S3 multipart upload: %s", abortErr)
+		}
+	}
+	return key, err
+}
+
+func uploadAndCompleteS3Multipart(httpClient *http.Client, upload state.GrantS3Multipart, logger *util.Logger, data []byte) (string, error) {
+	partSize := upload.PartSizeBytes
+	if partSize == 0 {
+		partSize = s3DefaultPartSize
+	} else if partSize < s3MinimumPartSize {
+		partSize = s3MinimumPartSize
+	}
+
+	partCount := int((int64(len(data)) + partSize - 1) / partSize)
+	if partCount > len(upload.PartURLs) {
+		return "", fmt.Errorf("Snapshot requires %d parts of %d bytes, but the grant only allows %d parts", partCount, partSize, len(upload.PartURLs))
+	}
+
+	var completed s3CompleteMultipartUpload
+	for idx := 0; idx < partCount; idx++ {
+		start := int64(idx) * partSize
+		end := start + partSize
+		if end > int64(len(data)) {
+			end = int64(len(data))
+		}
+
+		etag, err := uploadS3Part(httpClient, upload.PartURLs[idx], logger, data[start:end])
+		if err != nil {
+			return "", fmt.Errorf("Error uploading part %d of %d: %s", idx+1, partCount, err)
+		}
+		completed.Parts = append(completed.Parts, s3CompletedPart{PartNumber: idx + 1, ETag: etag})
+	}
+
+	logger.PrintVerbose("Uploaded %d parts to S3, completing multipart upload", partCount)
+
+	completeBody, err := xml.Marshal(completed)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := util.DoWithRetry(httpClient, util.DefaultHTTPRetryPolicy, logger, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", upload.CompleteURL, bytes.NewReader(completeBody))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/xml")
+		return req, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Bad S3 multipart completion return code %s (should be 200 OK), body: %s", resp.Status, body)
+	}
+
+	var s3Resp s3CompleteMultipartUploadResponse
+	err = xml.Unmarshal(body, &s3Resp)
+	if err != nil {
+		return "", err
+	}
+	if s3Resp.XMLName.Local == "Error" {
+		return "", fmt.Errorf("S3 multipart completion failed: %s: %s", s3Resp.Code, s3Resp.Message)
+	}
+
+	if s3Resp.Key == "" {
+		return upload.Key, nil
+	}
+	return s3Resp.Key, nil
+}
+
+func abortS3Multipart(httpClient *http.Client, abortURL string, logger *util.Logger) error {
+	resp, err := util.DoWithRetry(httpClient, util.DefaultHTTPRetryPolicy, logger, func() (*http.Request, error) {
+		return http.NewRequest("DELETE", abortURL, nil)
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Bad S3 multipart abort return code %s (should be 204 No Content), body: %s", resp.Status, body)
+	}
+
+	return nil
+}
+
+func uploadS3Part(httpClient *http.Client, partURL string, logger *util.Logger, data []byte) (string, error) {
+	resp, err := util.DoWithRetry(httpClient, util.DefaultHTTPRetryPolicy, logger, func() (*http.Request, error) {
+		req, err := http.NewRequest("PUT", partURL, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.ContentLength = int64(len(data))
+		return req, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return "", fmt.Errorf("Bad S3 part upload return code %s (should be 200 OK), body: %s", resp.Status, body)
+	}
+
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		return "", fmt.Errorf("S3 part upload response is missing the ETag header")
+	}
+
+	return etag, nil
+}
@@ -0,0 +1,96 @@
+package output
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pganalyze/collector/state"
+	"github.com/pganalyze/collector/util"
+)
+
+func TestUploadToS3Multipart(t *testing.T) {
+	var received [][]byte
+	var completed s3CompleteMultipartUpload
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if strings.HasPrefix(r.URL.Path, "/part") {
+			received = append(received, body)
+			w.Header().Set("ETag", fmt.Sprintf("\"etag%d\"", len(received)))
+			return
+		}
+		xml.Unmarshal(body, &completed)
+		fmt.Fprint(w, "<CompleteMultipartUploadResult><Key>snapshots/test</Key></CompleteMultipartUploadResult>")
+	}))
+	defer ts.Close()
+
+	data := bytes.Repeat([]byte("x"), s3MinimumPartSize*2+10)
+	upload := state.GrantS3Multipart{
+		Key:           "snapshots/test",
+		PartURLs:      []string{ts.URL + "/part1", ts.URL + "/part2", ts.URL + "/part3", ts.URL + "/part4"},
+		PartSizeBytes: s3MinimumPartSize,
+		CompleteURL:   ts.URL + "/complete",
+	}
+
+	key, err := uploadToS3Multipart(http.DefaultClient, upload, &util.Logger{}, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if key != "snapshots/test" {
+		t.Errorf("\nExpected key: snapshots/test\n actual: %s", key)
+	}
+	if len(received) != 3 || len(received[2]) != 10 || !bytes.Equal(bytes.Join(received, nil), data) {
+		t.Errorf("\nExpected 3 parts that add up to the data, got %d parts", len(received))
+	}
+	if len(completed.Parts) != 3 || completed.Parts[2].PartNumber != 3 || completed.Parts[2].ETag != "\"etag3\"" {
+		t.Errorf("\nUnexpected completion request: %+v", completed)
+	}
+}
+
+func TestUploadToS3MultipartTooFewParts(t *testing.T) {
+	upload := state.GrantS3Multipart{PartURLs: []string{"http://localhost/part1"}, PartSizeBytes: s3MinimumPartSize}
+
+	_, err := uploadToS3Multipart(http.DefaultClient, upload, &util.Logger{}, make([]byte, s3MinimumPartSize+1))
+	if err == nil {
+		t.Errorf("Expected error when the grant doesn't have enough parts")
+	}
+}
+
+func TestUploadToS3MultipartAbortOnFailure(t *testing.T) {
+	aborted := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/part2":
+			w.WriteHeader(http.StatusForbidden)
+		case strings.HasPrefix(r.URL.Path, "/part"):
+			w.Header().Set("ETag", "\"etag\"")
+		case r.URL.Path == "/abort" && r.Method == "DELETE":
+			aborted = true
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	upload := state.GrantS3Multipart{
+		PartURLs:      []string{ts.URL + "/part1", ts.URL + "/part2"},
+		PartSizeBytes: s3MinimumPartSize,
+		CompleteURL:   ts.URL + "/complete",
+		AbortURL:      ts.URL + "/abort",
+	}
+
+	_, err := uploadToS3Multipart(http.DefaultClient, upload, &util.Logger{}, make([]byte, s3MinimumPartSize+1))
+	if err == nil {
+		t.Errorf("Expected error when a part upload fails")
+	}
+	if !aborted {
+		t.Errorf("Expected the multipart upload to be aborted after the failure")
+	}
+}
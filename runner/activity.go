@@ -2,7 +2,6 @@ package runner
 
 import (
 	"database/sql"
-	"fmt"
 	"time"
 
 	"github.com/pganalyze/collector/grant"
@@ -35,8 +34,9 @@ func processActivityForServer(server state.Server, globalCollectionOpts state.Co
 		return false, errors.Wrap(err, "error collecting postgres version")
 	}
 
-	if activity.Version.Numeric < state.MinRequiredPostgresVersion {
-		return false, fmt.Errorf("Error: Your PostgreSQL server version (%s) is too old, 9.2 or newer is required", activity.Version.Short)
+	err = activity.Version.CheckSupported()
+	if err != nil {
+		return false, err
 	}
 
 	activity.Backends, err = postgres.GetBackends(logger, connection, activity.Version)
@@ -51,6 +51,17 @@ func processActivityForServer(server state.Server, globalCollectionOpts state.Co
 
 	activity.CollectedAt = time.Now()
 
+	activity.LongRunningQueries = state.LongRunningQueries(activity.Backends, activity.CollectedAt, time.Duration(server.Config.LongRunningQueryThresholdSecs)*time.Second)
+	for _, q := range activity.LongRunningQueries {
+		logger.PrintVerbose("Query running for %s (pid %d, database %s, application %s): %s", q.Duration/time.Second*time.Second, q.Pid, q.DatabaseName.String, q.ApplicationName.String, q.Query)
+	}
+
+	for _, v := range activity.Vacuums {
+		if v.IndexVacuumPass() > 1 {
+			logger.PrintVerbose("Vacuum of %s.%s (database %s) is on pass %d of its indexes, since only %d dead tuples fit into maintenance_work_mem per pass", v.SchemaName, v.RelationName, v.DatabaseName, v.IndexVacuumPass(), v.MaxDeadTuples)
+		}
+	}
+
 	err = output.SubmitCompactActivitySnapshot(server, grant, globalCollectionOpts, logger, activity)
 	if err != nil {
 		return false, errors.Wrap(err, "failed to upload/send activity snapshot")
@@ -66,7 +77,11 @@ func CollectActivityFromAllServers(servers []state.Server, globalCollectionOpts
 			continue
 		}
 
-		prefixedLogger := logger.WithPrefixAndRememberErrors(server.Config.SectionName)
+		prefixedLogger := server.Config.Logger(logger, true)
+
+		if !isActiveCollector(server, globalCollectionOpts, prefixedLogger, false) {
+			continue
+		}
 
 		success, err := processActivityForServer(server, globalCollectionOpts, prefixedLogger)
 		if err != nil {
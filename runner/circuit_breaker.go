@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pganalyze/collector/config"
+	"github.com/pganalyze/collector/input/postgres"
+	"github.com/pganalyze/collector/state"
+	"github.com/pganalyze/collector/util"
+)
+
+// Time until which the circuit breaker of a server is open, by server (kept across config reloads)
+var circuitBreakers = make(map[string]time.Time)
+var circuitBreakersMutex sync.Mutex
+
+func circuitBreakerEnabled(config config.ServerConfig) bool {
+	return config.CircuitBreakerActiveBackendsPercent > 0 || config.CircuitBreakerLatencyMs > 0
+}
+
+// overloadReason - Describes which circuit breaker threshold the load exceeds, if any
+//
+// Besides the current load, this considers the collectors that ran into a timeout (or the
+// collection deadline) during the previous run, since our own queries being slow is the most
+// direct sign that collecting everything puts too much strain on the server.
+func overloadReason(config config.ServerConfig, load state.PostgresDatabaseLoad, prevTimings state.CollectorTimings) string {
+	if config.CircuitBreakerActiveBackendsPercent > 0 && load.ActiveBackendsPercent() > float64(config.CircuitBreakerActiveBackendsPercent) {
+		return fmt.Sprintf("%d of %d connections are active (threshold %d%%)", load.ActiveBackends, load.MaxConnections, config.CircuitBreakerActiveBackendsPercent)
+	}
+	if config.CircuitBreakerLatencyMs > 0 && load.QueryLatency > time.Duration(config.CircuitBreakerLatencyMs)*time.Millisecond {
+		return fmt.Sprintf("a trivial query took %s (threshold %d ms)", load.QueryLatency/time.Millisecond*time.Millisecond, config.CircuitBreakerLatencyMs)
+	}
+	if skipped := prevTimings.Skipped(); len(skipped) > 0 {
+		return fmt.Sprintf("collection of %s timed out during the last run", strings.Join(skipped, ", "))
+	}
+	return ""
+}
+
+// checkCircuitBreaker - Whether to skip expensive collectors for the server, since it was
+// overloaded within the last circuit_breaker_cooldown_secs
+//
+// The load is re-checked once the cool-down period is over, which either closes the circuit
+// breaker again, or keeps it open for another cool-down period.
+func checkCircuitBreaker(server state.Server, connection *sql.DB, logger *util.Logger) bool {
+	if !circuitBreakerEnabled(server.Config) {
+		return false
+	}
+
+	circuitBreakersMutex.Lock()
+	defer circuitBreakersMutex.Unlock()
+
+	id := collectorLockID(server)
+	now := time.Now()
+	openUntil, wasOpen := circuitBreakers[id]
+	if wasOpen && now.Before(openUntil) {
+		logger.PrintVerbose("Circuit breaker is open until %s, skipping expensive collectors", openUntil.Format(time.RFC3339))
+		return true
+	}
+
+	load, err := postgres.GetDatabaseLoad(connection)
+	if err != nil {
+		logger.PrintWarning("Could not determine database load for the circuit breaker: %s", err)
+		return wasOpen
+	}
+
+	if reason := overloadReason(server.Config, load, server.PrevState.CollectorStats.Timings); reason != "" {
+		circuitBreakers[id] = now.Add(time.Duration(server.Config.CircuitBreakerCooldownSecs) * time.Second)
+		logger.PrintWarning("Server looks overloaded (%s), skipping expensive collectors for the next %d seconds", reason, server.Config.CircuitBreakerCooldownSecs)
+		return true
+	}
+
+	if wasOpen {
+		delete(circuitBreakers, id)
+		logger.PrintInfo("Server load is back to normal, resuming full collection")
+	}
+	return false
+}
+
+// circuitBreakerOpen - Whether the server's circuit breaker is currently open, without checking the load
+func circuitBreakerOpen(server state.Server) bool {
+	circuitBreakersMutex.Lock()
+	defer circuitBreakersMutex.Unlock()
+
+	openUntil, ok := circuitBreakers[collectorLockID(server)]
+	return ok && time.Now().Before(openUntil)
+}
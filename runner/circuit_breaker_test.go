@@ -0,0 +1,62 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pganalyze/collector/config"
+	"github.com/pganalyze/collector/state"
+)
+
+var overloadReasonTests = []struct {
+	config      config.ServerConfig
+	load        state.PostgresDatabaseLoad
+	prevTimings state.CollectorTimings
+	expected    string
+}{
+	{
+		config.ServerConfig{CircuitBreakerActiveBackendsPercent: 80},
+		state.PostgresDatabaseLoad{ActiveBackends: 50, MaxConnections: 100, QueryLatency: 5 * time.Second},
+		nil,
+		"",
+	},
+	{
+		config.ServerConfig{CircuitBreakerActiveBackendsPercent: 80},
+		state.PostgresDatabaseLoad{ActiveBackends: 90, MaxConnections: 100},
+		nil,
+		"90 of 100 connections are active (threshold 80%)",
+	},
+	{
+		config.ServerConfig{CircuitBreakerLatencyMs: 500},
+		state.PostgresDatabaseLoad{ActiveBackends: 90, MaxConnections: 100, QueryLatency: 100 * time.Millisecond},
+		nil,
+		"",
+	},
+	{
+		config.ServerConfig{CircuitBreakerLatencyMs: 500},
+		state.PostgresDatabaseLoad{QueryLatency: 1200*time.Millisecond + 300*time.Microsecond},
+		nil,
+		"a trivial query took 1.2s (threshold 500 ms)",
+	},
+	{
+		config.ServerConfig{CircuitBreakerLatencyMs: 500},
+		state.PostgresDatabaseLoad{QueryLatency: 100 * time.Millisecond},
+		state.CollectorTimings{"relations": {Duration: 2 * time.Second}, "pg_stat_statements": {Duration: 30 * time.Second, Skipped: true}, "relation stats": {Duration: 30 * time.Second, Skipped: true}},
+		"collection of pg_stat_statements, relation stats timed out during the last run",
+	},
+	{
+		config.ServerConfig{CircuitBreakerLatencyMs: 500},
+		state.PostgresDatabaseLoad{QueryLatency: 100 * time.Millisecond},
+		state.CollectorTimings{"relations": {Duration: 2 * time.Second}},
+		"",
+	},
+}
+
+func TestOverloadReason(t *testing.T) {
+	for _, test := range overloadReasonTests {
+		actual := overloadReason(test.config, test.load, test.prevTimings)
+		if actual != test.expected {
+			t.Errorf("overloadReason(%+v): expected %q, actual %q", test.load, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"sync"
+
+	"github.com/pganalyze/collector/input/postgres"
+	"github.com/pganalyze/collector/state"
+	"github.com/pganalyze/collector/util"
+)
+
+// Collector locks held by this process, by server (kept across config reloads)
+var collectorLocks = make(map[string]*postgres.CollectorLock)
+var collectorLocksMutex sync.Mutex
+
+func collectorLockID(server state.Server) string {
+	return server.Config.SectionName + "/" + server.Config.StateKey()
+}
+
+// isActiveCollector - Whether this collector should collect and submit data for the server, which
+// is always the case unless coordinate_collectors is enabled, in which case only the collector that
+// holds the server's advisory lock is active, and the others stand by
+//
+// Standby collectors only try to take over the lock when acquire is set (i.e. on full snapshots),
+// so that the more frequent activity and log runs don't each open a new connection.
+func isActiveCollector(server state.Server, globalCollectionOpts state.CollectionOpts, logger *util.Logger, acquire bool) bool {
+	if !server.Config.CoordinateCollectors || globalCollectionOpts.TestRun {
+		return true
+	}
+
+	collectorLocksMutex.Lock()
+	defer collectorLocksMutex.Unlock()
+
+	id := collectorLockID(server)
+	if lock, ok := collectorLocks[id]; ok {
+		if lock.Held() {
+			return true
+		}
+		logger.PrintWarning("Lost connection holding the collector lock, another collector may take over")
+		lock.Release()
+		delete(collectorLocks, id)
+	}
+
+	if !acquire {
+		return false
+	}
+
+	lock, err := postgres.TryAcquireCollectorLock(server, logger, globalCollectionOpts)
+	if err != nil {
+		logger.PrintWarning("Could not acquire collector lock: %s", err)
+		return false
+	}
+	if lock == nil {
+		logger.PrintVerbose("Another collector holds the collector lock for this server, standing by")
+		return false
+	}
+
+	logger.PrintInfo("Acquired collector lock, collector instance \"%s\" is now collecting for this server", server.Config.GetCollectorInstance())
+	collectorLocks[id] = lock
+	return true
+}
+
+// ReleaseCollectorLocks - Releases all collector locks, so that standby collectors can take over
+// right away (e.g. on shutdown)
+func ReleaseCollectorLocks() {
+	collectorLocksMutex.Lock()
+	defer collectorLocksMutex.Unlock()
+
+	for id, lock := range collectorLocks {
+		lock.Release()
+		delete(collectorLocks, id)
+	}
+}
@@ -1,18 +1,58 @@
 package runner
 
 import (
+	"github.com/guregu/null"
 	"github.com/pganalyze/collector/state"
 	"github.com/pganalyze/collector/util"
 )
 
+// needsBaseline - Whether we have no previous state to diff against (e.g. on the first run), in
+// which case we submit the cumulative values as a baseline snapshot instead of an empty diff
+//
+// Previous state from a long time ago (e.g. after an outage) is still diffed against, since the
+// counters are cumulative, and the diff is correct for the longer interval.
+func needsBaseline(prevState state.PersistedState) bool {
+	return prevState.CollectedAt.IsZero()
+}
+
+// baselineState - Returns a previous state with zero values for all statistics counters in newState,
+// so that diffing against it returns the cumulative values
+//
+// Its CollectedAt is left unset, since the cumulative values don't cover a known interval.
+func baselineState(newState state.PersistedState) (prevState state.PersistedState) {
+	prevState.StatementStats = make(state.PostgresStatementStatsMap)
+	for key := range newState.StatementStats {
+		prevState.StatementStats[key] = state.PostgresStatementStats{}
+	}
+	prevState.RelationStats = make(state.PostgresRelationStatsMap)
+	for key := range newState.RelationStats {
+		prevState.RelationStats[key] = state.PostgresRelationStats{}
+	}
+	prevState.IndexStats = make(state.PostgresIndexStatsMap)
+	for key := range newState.IndexStats {
+		prevState.IndexStats[key] = state.PostgresIndexStats{}
+	}
+	prevState.FunctionStats = make(state.PostgresFunctionStatsMap)
+	for key := range newState.FunctionStats {
+		prevState.FunctionStats[key] = state.PostgresFunctionStats{}
+	}
+
+	return
+}
+
 func diffState(logger *util.Logger, prevState state.PersistedState, newState state.PersistedState, collectedIntervalSecs uint32) (diffState state.DiffState) {
 	diffState.StatementStats = diffStatements(newState.StatementStats, prevState.StatementStats)
 	diffState.RelationStats = diffRelationStats(newState.RelationStats, prevState.RelationStats)
 	diffState.IndexStats = diffIndexStats(newState.IndexStats, prevState.IndexStats)
+	diffState.FunctionStats = diffFunctionStats(newState.FunctionStats, prevState.FunctionStats)
+	diffState.DatabaseSizes = diffDatabaseSizes(newState.DatabaseSizes, prevState.DatabaseSizes, collectedIntervalSecs)
+	diffState.WalRate = diffWalPosition(newState.WalPosition, prevState.WalPosition, collectedIntervalSecs)
+	diffState.ArchiverStats = diffArchiverStats(newState.ArchiverStats, prevState.ArchiverStats, collectedIntervalSecs)
 	diffState.SystemCPUStats = diffSystemCPUStats(newState.System.CPUStats, prevState.System.CPUStats)
 	diffState.SystemNetworkStats = diffSystemNetworkStats(newState.System.NetworkStats, prevState.System.NetworkStats, collectedIntervalSecs)
 	diffState.SystemDiskStats = diffSystemDiskStats(newState.System.DiskStats, prevState.System.DiskStats, collectedIntervalSecs)
 	diffState.CollectorStats = diffCollectorStats(newState.CollectorStats, prevState.CollectorStats)
+	diffState.WaitSamplingProfile = diffWaitSamplingProfile(newState.WaitSamplingProfile, prevState.WaitSamplingProfile)
 
 	return
 }
@@ -71,6 +111,63 @@ func diffIndexStats(new state.PostgresIndexStatsMap, prev state.PostgresIndexSta
 	return
 }
 
+func diffFunctionStats(new state.PostgresFunctionStatsMap, prev state.PostgresFunctionStatsMap) (diff state.DiffedPostgresFunctionStatsMap) {
+	followUpRun := len(prev) > 0
+
+	diff = make(state.DiffedPostgresFunctionStatsMap)
+	for key, stats := range new {
+		prevStats, exists := prev[key]
+		if exists {
+			diff[key] = stats.DiffSince(prevStats)
+		} else if followUpRun { // New since the last run
+			diff[key] = stats.DiffSince(state.PostgresFunctionStats{})
+		}
+	}
+
+	return
+}
+
+func diffDatabaseSizes(new state.PostgresDatabaseSizeMap, prev state.PostgresDatabaseSizeMap, collectedIntervalSecs uint32) (diff state.DiffedDatabaseSizeMap) {
+	diff = make(state.DiffedDatabaseSizeMap)
+	for oid, sizeBytes := range new {
+		diffed := state.DiffedDatabaseSize{SizeBytes: sizeBytes}
+		// Databases that are new since the last run have an unknown growth, instead of
+		// growing by their full size
+		prevSizeBytes, exists := prev[oid]
+		if exists {
+			diffed.GrowthBytesPerSecond = null.FloatFrom(float64(sizeBytes-prevSizeBytes) / float64(collectedIntervalSecs))
+		}
+		diff[oid] = diffed
+	}
+
+	return
+}
+
+func diffWalPosition(new state.PostgresWalPosition, prev state.PostgresWalPosition, collectedIntervalSecs uint32) (diff state.DiffedWalRate) {
+	diff.Replay = new.Replay
+	// The position can go backwards when the server was restored from a backup, or a
+	// standby got re-synced, in which case we don't know the rate
+	if new.Bytes.Valid && prev.Bytes.Valid && new.Bytes.Int64 >= prev.Bytes.Int64 && collectedIntervalSecs > 0 {
+		diff.BytesPerSecond = null.FloatFrom(float64(new.Bytes.Int64-prev.Bytes.Int64) / float64(collectedIntervalSecs))
+	}
+	return
+}
+
+func diffArchiverStats(new *state.PostgresArchiverStats, prev *state.PostgresArchiverStats, collectedIntervalSecs uint32) *state.DiffedArchiverStats {
+	if new == nil {
+		return nil
+	}
+
+	diff := state.DiffedArchiverStats{Failing: new.Failing()}
+	if prev != nil && prev.StatsReset.Valid == new.StatsReset.Valid && prev.StatsReset.Time.Equal(new.StatsReset.Time) &&
+		new.ArchivedCount >= prev.ArchivedCount && new.FailedCount >= prev.FailedCount && collectedIntervalSecs > 0 {
+		diff.ArchivedPerSecond = null.FloatFrom(float64(new.ArchivedCount-prev.ArchivedCount) / float64(collectedIntervalSecs))
+		diff.NewFailures = null.IntFrom(new.FailedCount - prev.FailedCount)
+	}
+
+	return &diff
+}
+
 func diffSystemCPUStats(new state.CPUStatisticMap, prev state.CPUStatisticMap) (diff state.DiffedSystemCPUStatsMap) {
 	diff = make(state.DiffedSystemCPUStatsMap)
 	for cpuID, stats := range new {
@@ -129,3 +226,29 @@ func diffCollectorStats(new state.CollectorStats, prev state.CollectorStats) (di
 	diff = new.DiffSince(prev)
 	return
 }
+
+func diffWaitSamplingProfile(new state.PostgresWaitSamplingProfile, prev state.PostgresWaitSamplingProfile) (diff state.PostgresWaitSamplingProfile) {
+	if new == nil {
+		return
+	}
+
+	followUpRun := len(prev) > 0
+
+	diff = make(state.PostgresWaitSamplingProfile)
+	for key, count := range new {
+		// A lower count than before means the profile was reset in the meantime, in
+		// which case the current count is all we've got since the last run
+		prevCount, exists := prev[key]
+		if exists && count >= prevCount {
+			count -= prevCount
+		} else if !followUpRun {
+			continue
+		}
+
+		if count > 0 {
+			diff[key] = count
+		}
+	}
+
+	return
+}
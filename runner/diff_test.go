@@ -0,0 +1,142 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+	"github.com/pganalyze/collector/state"
+)
+
+var diffDatabaseSizesTests = []struct {
+	new      state.PostgresDatabaseSizeMap
+	prev     state.PostgresDatabaseSizeMap
+	expected state.DiffedDatabaseSizeMap
+}{
+	// First run
+	{
+		state.PostgresDatabaseSizeMap{1: 1000},
+		nil,
+		state.DiffedDatabaseSizeMap{1: {SizeBytes: 1000}},
+	},
+	// Growth, a new database and a dropped database
+	{
+		state.PostgresDatabaseSizeMap{1: 7000, 2: 5000000},
+		state.PostgresDatabaseSizeMap{1: 1000, 3: 2000},
+		state.DiffedDatabaseSizeMap{
+			1: {SizeBytes: 7000, GrowthBytesPerSecond: null.FloatFrom(10)},
+			2: {SizeBytes: 5000000},
+		},
+	},
+	// Shrinking
+	{
+		state.PostgresDatabaseSizeMap{1: 1000},
+		state.PostgresDatabaseSizeMap{1: 7000},
+		state.DiffedDatabaseSizeMap{1: {SizeBytes: 1000, GrowthBytesPerSecond: null.FloatFrom(-10)}},
+	},
+}
+
+func TestDiffDatabaseSizes(t *testing.T) {
+	for i, test := range diffDatabaseSizesTests {
+		actual := diffDatabaseSizes(test.new, test.prev, 600)
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("Test %d:\n\texpected %+v\n\tactual %+v\n", i, test.expected, actual)
+		}
+	}
+}
+
+var diffWalPositionTests = []struct {
+	new      state.PostgresWalPosition
+	prev     state.PostgresWalPosition
+	expected state.DiffedWalRate
+}{
+	// First run
+	{
+		state.PostgresWalPosition{Bytes: null.IntFrom(16000000)},
+		state.PostgresWalPosition{},
+		state.DiffedWalRate{},
+	},
+	// Primary generating WAL
+	{
+		state.PostgresWalPosition{Bytes: null.IntFrom(16000000)},
+		state.PostgresWalPosition{Bytes: null.IntFrom(10000000)},
+		state.DiffedWalRate{BytesPerSecond: null.FloatFrom(10000)},
+	},
+	// Standby replaying WAL
+	{
+		state.PostgresWalPosition{Bytes: null.IntFrom(16000000), Replay: true},
+		state.PostgresWalPosition{Bytes: null.IntFrom(16000000), Replay: true},
+		state.DiffedWalRate{BytesPerSecond: null.FloatFrom(0), Replay: true},
+	},
+	// Position went backwards (e.g. restored from a backup)
+	{
+		state.PostgresWalPosition{Bytes: null.IntFrom(1000)},
+		state.PostgresWalPosition{Bytes: null.IntFrom(16000000)},
+		state.DiffedWalRate{},
+	},
+}
+
+func TestDiffWalPosition(t *testing.T) {
+	for i, test := range diffWalPositionTests {
+		actual := diffWalPosition(test.new, test.prev, 600)
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("Test %d:\n\texpected %+v\n\tactual %+v\n", i, test.expected, actual)
+		}
+	}
+}
+
+func TestDiffArchiverStats(t *testing.T) {
+	reset := null.TimeFrom(time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC))
+	prev := &state.PostgresArchiverStats{ArchivedCount: 100, FailedCount: 2, StatsReset: reset,
+		LastArchivedTime: null.TimeFrom(time.Date(2018, 3, 1, 11, 50, 0, 0, time.UTC))}
+	new := &state.PostgresArchiverStats{ArchivedCount: 160, FailedCount: 5, StatsReset: reset,
+		LastArchivedTime: null.TimeFrom(time.Date(2018, 3, 1, 11, 55, 0, 0, time.UTC)),
+		LastFailedTime:   null.TimeFrom(time.Date(2018, 3, 1, 11, 59, 0, 0, time.UTC))}
+
+	actual := diffArchiverStats(new, prev, 600)
+	expected := &state.DiffedArchiverStats{ArchivedPerSecond: null.FloatFrom(0.1), NewFailures: null.IntFrom(3), Failing: true}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, actual)
+	}
+
+	// After a statistics reset we can't tell the rate
+	new.StatsReset = null.TimeFrom(time.Date(2018, 3, 1, 11, 0, 0, 0, time.UTC))
+	actual = diffArchiverStats(new, prev, 600)
+	expected = &state.DiffedArchiverStats{Failing: true}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %+v after reset, got %+v", expected, actual)
+	}
+
+	if diffArchiverStats(nil, prev, 600) != nil {
+		t.Errorf("Expected no archiver stats when they are not collected")
+	}
+}
+
+func TestDiffStateBaseline(t *testing.T) {
+	collectedAt := time.Date(2018, 3, 1, 12, 0, 0, 0, time.UTC)
+	key := state.PostgresStatementKey{DatabaseOid: 1, UserOid: 10, QueryID: 42}
+	newState := state.PersistedState{
+		CollectedAt:    collectedAt,
+		StatementStats: state.PostgresStatementStatsMap{key: {Calls: 5, TotalTime: 12.5}},
+		RelationStats:  state.PostgresRelationStatsMap{100: {SeqScan: 3}},
+	}
+
+	if !needsBaseline(state.PersistedState{}) {
+		t.Errorf("Expected baseline without previous state")
+	}
+	if needsBaseline(state.PersistedState{CollectedAt: collectedAt.Add(-2 * time.Hour)}) {
+		t.Errorf("Expected no baseline with old, but diffable previous state")
+	}
+	if needsBaseline(state.PersistedState{CollectedAt: collectedAt.Add(-10 * time.Minute)}) {
+		t.Errorf("Expected no baseline with recent previous state")
+	}
+
+	diff := diffState(nil, baselineState(newState), newState, 0)
+	if diff.StatementStats[key].Calls != 5 || diff.StatementStats[key].TotalTime != 12.5 {
+		t.Errorf("Expected cumulative statement stats, got %+v", diff.StatementStats[key])
+	}
+	if diff.RelationStats[100].SeqScan != 3 {
+		t.Errorf("Expected cumulative relation stats, got %+v", diff.RelationStats[100])
+	}
+}
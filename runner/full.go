@@ -1,8 +1,9 @@
 package runner
 
 import (
+	"bufio"
+	"context"
 	"database/sql"
-	"encoding/gob"
 	"fmt"
 	"os"
 	"os/exec"
@@ -18,33 +19,140 @@ import (
 	"github.com/pganalyze/collector/util"
 )
 
-func collectDiffAndSubmit(server state.Server, globalCollectionOpts state.CollectionOpts, logger *util.Logger) (state.PersistedState, error) {
+// ConnectionError - The collector could not connect to the database server
+type ConnectionError struct {
+	Err error
+}
+
+func (e ConnectionError) Error() string {
+	return fmt.Sprintf("Failed to connect to database: %s", e.Err)
+}
+
+// SubmitError - The snapshot could not be submitted (including failures to acquire a snapshot grant)
+type SubmitError struct {
+	Err error
+}
+
+func (e SubmitError) Error() string {
+	return e.Err.Error()
+}
+
+func collectAndDiff(ctx context.Context, server state.Server, globalCollectionOpts state.CollectionOpts, logger *util.Logger) (state.PersistedState, state.TransientState, state.DiffState, uint32, error) {
 	var newState state.PersistedState
+	var transientState state.TransientState
+	var diffedState state.DiffState
 	var err error
 	var connection *sql.DB
 
+	if err = ctx.Err(); err != nil {
+		return newState, transientState, diffedState, 0, err
+	}
+
+	collectCtx := ctx
+	if server.Config.CollectionDeadlineSecs > 0 {
+		var cancel context.CancelFunc
+		collectCtx, cancel = context.WithTimeout(ctx, time.Duration(server.Config.CollectionDeadlineSecs)*time.Second)
+		defer cancel()
+	}
+
 	connection, err = postgres.EstablishConnection(server, logger, globalCollectionOpts, "")
 	if err != nil {
-		return newState, fmt.Errorf("Failed to connect to database: %s", err)
+		return newState, transientState, diffedState, 0, ConnectionError{err}
+	}
+
+	collectionOpts := globalCollectionOpts
+	if !collectionOpts.SchemaOnly {
+		collectionOpts.ReducedCollection = checkCircuitBreaker(server, connection, logger)
 	}
 
-	newState, transientState, err := input.CollectFull(server, connection, globalCollectionOpts, logger)
+	// Cancelling ctx (e.g. on shutdown) cancels whichever query is running, whereas reaching the
+	// deadline of collectCtx only skips the slow collectors (see input.CollectFull)
+	err = postgres.RunWithDeadline(ctx, server, logger, collectionOpts, connection, func() (err error) {
+		newState, transientState, err = input.CollectFull(collectCtx, server, connection, collectionOpts, logger)
+		return
+	})
 	if err != nil {
-		connection.Close()
-		return newState, err
+		postgres.CloseConnection(server, connection)
+		return newState, transientState, diffedState, 0, err
 	}
 
 	// This is the easiest way to avoid opening multiple connections to different databases on the same instance
-	connection.Close()
+	postgres.CloseConnection(server, connection)
+
+	if err = ctx.Err(); err != nil {
+		return newState, transientState, diffedState, 0, err
+	}
+
+	// Schema-only snapshots have no statistics, so there is nothing to diff
+	if collectionOpts.SchemaOnly {
+		return newState, transientState, diffedState, 0, nil
+	}
+
+	prevState := server.PrevState
+	baseline := needsBaseline(prevState)
 
-	collectedIntervalSecs := uint32(newState.CollectedAt.Sub(server.PrevState.CollectedAt) / time.Second)
-	if collectedIntervalSecs == 0 {
-		collectedIntervalSecs = 1 // Avoid divide by zero errors for fast consecutive runs
+	// Baseline snapshots are submitted with an interval of 0, since their cumulative values (since
+	// the last stats reset) must not be interpreted as the activity of a single interval
+	var collectedIntervalSecs uint32
+	if baseline {
+		logger.PrintVerbose("No previous state to diff against, submitting cumulative statistics as a baseline snapshot")
+		prevState = baselineState(newState)
+	} else {
+		collectedIntervalSecs = uint32(newState.CollectedAt.Sub(prevState.CollectedAt) / time.Second)
+		if collectedIntervalSecs == 0 {
+			collectedIntervalSecs = 1 // Avoid divide by zero errors for fast consecutive runs
+		}
+	}
+
+	diffedState = diffState(logger, prevState, newState, collectedIntervalSecs)
+	diffedState.Baseline = baseline
+
+	collectorStats := diffedState.CollectorStats
+	logger.PrintVerbose("Collector memory usage: %.1f MB RSS, %.1f MB heap, %d goroutines, %d GC cycles (%.1f ms paused) since last run",
+		float64(collectorStats.MemoryRssBytes)/1024/1024, float64(collectorStats.MemoryHeapAllocatedBytes)/1024/1024,
+		collectorStats.ActiveGoroutines, collectorStats.GcCount, float64(collectorStats.GcPauseTotalNs)/1000/1000)
+	if len(collectorStats.Timings) > 0 {
+		logger.PrintVerbose("Collector timings: %s", collectorStats.Timings)
 	}
 
-	diffState := diffState(logger, server.PrevState, newState, collectedIntervalSecs)
+	if newState.WaitSamplingProfile != nil {
+		// pg_wait_sampling samples at a much higher frequency than we do, so prefer its data
+		newState.WaitEventHistogram = make(state.PostgresWaitEventHistogram)
+		for key, count := range diffedState.WaitSamplingProfile {
+			newState.WaitEventHistogram[state.PostgresWaitEventKey{WaitEventType: key.WaitEventType, WaitEvent: key.WaitEvent}] += count
+			newState.WaitEventSampleCount += count
+		}
+		if server.WaitEventSampler != nil {
+			server.WaitEventSampler.Drain()
+		}
+	} else if server.WaitEventSampler != nil {
+		newState.WaitEventHistogram, newState.ActiveSessionHistogram, newState.WaitEventSampleCount = server.WaitEventSampler.Drain()
+		if newState.WaitEventSampleCount > 0 {
+			logger.PrintVerbose("Wait event sampling: %d samples with %d distinct session states since the last snapshot", newState.WaitEventSampleCount, len(newState.ActiveSessionHistogram))
+		}
+	}
+
+	if server.LogFilePositions != nil {
+		newState.LogFilePositions = server.LogFilePositions.Get()
+	}
+
+	// Rank before limiting, so I/O heavy statements are kept even if they don't take much time
+	diffedState.StatementIORankings = rankStatementsByIO(diffedState.StatementStats)
+
+	if server.Config.MaxStatements > 0 {
+		diffedState.StatementStats = limitStatements(diffedState.StatementStats, server.Config.MaxStatements, server.Config.MaxStatementsRankBy, diffedState.StatementIORankings.Keys())
+		if _, exists := diffedState.StatementStats[state.OtherStatementsKey]; exists {
+			if transientState.Statements == nil {
+				transientState.Statements = make(state.PostgresStatementMap)
+			}
+			transientState.Statements[state.OtherStatementsKey] = state.PostgresStatement{NormalizedQuery: state.OtherStatementsQuery}
+		}
+	}
 
 	if transientState.HasStatementText {
+		if server.StatementTexts != nil {
+			server.StatementTexts.Update(transientState.Statements)
+		}
 		transientState.HistoricStatementStats = server.PrevState.UnidentifiedStatementStats
 	} else {
 		timeKey := state.PostgresStatementStatsTimeKey{CollectedAt: newState.CollectedAt, CollectedIntervalSecs: collectedIntervalSecs}
@@ -52,15 +160,24 @@ func collectDiffAndSubmit(server state.Server, globalCollectionOpts state.Collec
 		if newState.UnidentifiedStatementStats == nil {
 			newState.UnidentifiedStatementStats = make(state.HistoricStatementStatsMap)
 		}
-		newState.UnidentifiedStatementStats[timeKey] = diffState.StatementStats
-		diffState.StatementStats = make(state.DiffedPostgresStatementStatsMap)
+		newState.UnidentifiedStatementStats[timeKey] = diffedState.StatementStats
+		diffedState.StatementStats = make(state.DiffedPostgresStatementStatsMap)
 	}
 
-	err = output.SendFull(server, globalCollectionOpts, logger, newState, diffState, transientState, collectedIntervalSecs)
+	return newState, transientState, diffedState, collectedIntervalSecs, nil
+}
+
+func collectDiffAndSubmit(ctx context.Context, server state.Server, globalCollectionOpts state.CollectionOpts, logger *util.Logger) (state.PersistedState, error) {
+	newState, transientState, diffState, collectedIntervalSecs, err := collectAndDiff(ctx, server, globalCollectionOpts, logger)
 	if err != nil {
 		return newState, err
 	}
 
+	err = output.SendFull(server, globalCollectionOpts, logger, newState, diffState, transientState, collectedIntervalSecs)
+	if err != nil {
+		return newState, SubmitError{err}
+	}
+
 	// After we've done all processing, and in case we did a reset, make sure the
 	// next snapshot has an empty reference point
 	if transientState.ResetStatementStats != nil {
@@ -70,6 +187,45 @@ func collectDiffAndSubmit(server state.Server, globalCollectionOpts state.Collec
 	return newState, nil
 }
 
+// RunCollection - Runs a single full collection for the given server and returns the
+// collected state, as well as the diff against server.PrevState, without writing the
+// state file or submitting anything to the pganalyze service
+//
+// Pass the returned PersistedState as PrevState on the next call to get a diff across
+// runs. This is safe to call concurrently for different servers.
+func RunCollection(ctx context.Context, server state.Server, globalCollectionOpts state.CollectionOpts, logger *util.Logger) (newState state.PersistedState, diffState state.DiffState, err error) {
+	var transientState state.TransientState
+
+	panicErr, stackTrace := capturePanic(func() {
+		newState, transientState, diffState, _, err = collectAndDiff(ctx, server, globalCollectionOpts.ForServer(server.Config), logger)
+	})
+	if panicErr != nil {
+		logger.PrintVerbose("Panic: %s\n%s", panicErr, stackTrace)
+		return newState, diffState, fmt.Errorf("%s", panicErr)
+	}
+	if err != nil {
+		return
+	}
+
+	// See collectDiffAndSubmit - the next run needs to diff against the post-reset values
+	if transientState.ResetStatementStats != nil {
+		newState.StatementStats = transientState.ResetStatementStats
+	}
+
+	return
+}
+
+// RunCollectionWithConnection - Like RunCollection, but uses the given connection instead of
+// connecting based on server.Config, e.g. for tests using sqlmock, or when embedding the collector
+// in an application that manages its own connections
+//
+// The statement_timeout and lock_timeout are applied per session (like SET), so the connection should be
+// limited to a single open connection. The connection is not closed when the collection is done.
+func RunCollectionWithConnection(ctx context.Context, server state.Server, connection *sql.DB, globalCollectionOpts state.CollectionOpts, logger *util.Logger) (newState state.PersistedState, diffState state.DiffState, err error) {
+	server.Connection = connection
+	return RunCollection(ctx, server, globalCollectionOpts, logger)
+}
+
 func capturePanic(f func()) (err interface{}, stackTrace []byte) {
 	defer func() {
 		if err = recover(); err != nil {
@@ -82,22 +238,28 @@ func capturePanic(f func()) (err interface{}, stackTrace []byte) {
 	return
 }
 
-func processDatabase(server state.Server, globalCollectionOpts state.CollectionOpts, logger *util.Logger) (state.PersistedState, state.Grant, error) {
+func processDatabase(ctx context.Context, server state.Server, globalCollectionOpts state.CollectionOpts, logger *util.Logger) (state.PersistedState, state.Grant, error) {
 	var newGrant state.Grant
 	var newState state.PersistedState
 	var err error
 
-	if !globalCollectionOpts.ForceEmptyGrant {
+	collectionOpts := globalCollectionOpts.ForServer(server.Config)
+
+	if !collectionOpts.ForceEmptyGrant && output.RequiresGrant(server.Config) {
 		// Note: In case of server errors, we should reuse the old grant if its still recent (i.e. less than 50 minutes ago)
-		newGrant, err = grant.GetDefaultGrant(server, globalCollectionOpts, logger)
+		newGrant, err = grant.GetDefaultGrant(server, collectionOpts, logger)
 		if err != nil {
 			if server.Grant.Valid {
-				logger.PrintVerbose("Could not acquire snapshot grant, reusing previous grant: %s", err)
+				// The multipart upload of the previous grant has been used (or aborted) already, and
+				// can't be reused, so large snapshots fall back to a single upload request
+				logger.PrintVerbose("Could not acquire snapshot grant, reusing previous grant without its multipart upload: %s", err)
+				server.Grant.S3Multipart = nil
 			} else {
-				return state.PersistedState{}, state.Grant{}, err
+				return state.PersistedState{}, state.Grant{}, SubmitError{err}
 			}
 		} else {
 			server.Grant = newGrant
+			checkCollectorVersion(server, newGrant, logger)
 		}
 	}
 
@@ -112,7 +274,7 @@ func processDatabase(server state.Server, globalCollectionOpts state.CollectionO
 	}
 
 	runFunc := func() {
-		newState, err = collectDiffAndSubmit(server, globalCollectionOpts, logger)
+		newState, err = collectDiffAndSubmit(ctx, server, collectionOpts, logger)
 	}
 
 	var panicErr interface{}
@@ -132,50 +294,78 @@ func processDatabase(server state.Server, globalCollectionOpts state.CollectionO
 	return newState, newGrant, err
 }
 
-func writeStateFile(servers []state.Server, globalCollectionOpts state.CollectionOpts, logger *util.Logger) {
-	stateOnDisk := state.StateOnDisk{PrevStateByAPIKey: make(map[string]state.PersistedState), FormatVersion: state.StateOnDiskFormatVersion}
+// WriteStateFile - Writes the prevState structs of all servers to the state file
+//
+// The state is written to a temporary file first and then renamed, so that a collector that
+// gets killed while writing doesn't leave a truncated state file behind.
+func WriteStateFile(servers []state.Server, globalCollectionOpts state.CollectionOpts, logger *util.Logger) {
+	prevStateByKey := make(map[string]state.PersistedState)
 
 	for _, server := range servers {
-		stateOnDisk.PrevStateByAPIKey[server.Config.APIKey] = server.PrevState
+		prevStateByKey[server.Config.StateKey()] = server.PrevState
 	}
 
-	file, err := os.Create(globalCollectionOpts.StateFilename)
+	tmpFilename := globalCollectionOpts.StateFilename + ".tmp"
+	file, err := os.Create(tmpFilename)
 	if err != nil {
 		logger.PrintWarning("Could not write out state file to %s because of error: %s", globalCollectionOpts.StateFilename, err)
 		return
 	}
-	defer file.Close()
 
-	encoder := gob.NewEncoder(file)
-	encoder.Encode(stateOnDisk)
+	writer := bufio.NewWriter(file)
+	err = writeStateStream(writer, prevStateByKey)
+	if err == nil {
+		err = writer.Flush()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpFilename, globalCollectionOpts.StateFilename)
+	}
+	if err != nil {
+		logger.PrintWarning("Could not write out state file to %s because of error: %s", globalCollectionOpts.StateFilename, err)
+		os.Remove(tmpFilename)
+	}
 }
 
 // ReadStateFile - This reads in the prevState structs from the state file - only run this on initial bootup and SIGHUP!
+//
+// Only the states of the given servers are decoded, states of other keys in the file are skipped.
 func ReadStateFile(servers []state.Server, globalCollectionOpts state.CollectionOpts, logger *util.Logger) {
-	var stateOnDisk state.StateOnDisk
-
 	file, err := os.Open(globalCollectionOpts.StateFilename)
 	if err != nil {
 		logger.PrintVerbose("Did not open state file: %s", err)
 		return
 	}
-	decoder := gob.NewDecoder(file)
-	err = decoder.Decode(&stateOnDisk)
-	if err != nil {
-		logger.PrintVerbose("Could not decode state file: %s", err)
-		return
-	}
 	defer file.Close()
 
-	if stateOnDisk.FormatVersion < state.StateOnDiskFormatVersion {
+	wantedKeys := make(map[string]bool)
+	for _, server := range servers {
+		wantedKeys[server.Config.StateKey()] = true
+		if server.Config.CollectorInstance != "" {
+			wantedKeys[server.Config.APIKey] = true
+		}
+	}
+
+	prevStateByKey, err := readStateStream(file, wantedKeys)
+	if err == errStateFormatChanged {
 		logger.PrintVerbose("Ignoring state file since the on-disk format has changed")
 		return
+	} else if err != nil {
+		logger.PrintVerbose("Could not decode state file: %s", err)
+		return
 	}
 
 	for idx, server := range servers {
-		prevState, exist := stateOnDisk.PrevStateByAPIKey[server.Config.APIKey]
+		prevState, exist := prevStateByKey[server.Config.StateKey()]
+		if !exist && server.Config.CollectorInstance != "" {
+			// State written before collector_instance was set
+			prevState, exist = prevStateByKey[server.Config.APIKey]
+		}
 		if exist {
-			prefixedLogger := logger.WithPrefix(server.Config.SectionName)
+			prefixedLogger := server.Config.Logger(logger, false)
 			prefixedLogger.PrintVerbose("Successfully recovered state from on-disk file")
 			servers[idx].PrevState = prevState
 		}
@@ -197,18 +387,27 @@ func runCompletionCallback(callbackType string, callbackCmd string, sectionName
 }
 
 // CollectAllServers - Collects statistics from all servers and sends them as full snapshots to the pganalyze service
-func CollectAllServers(servers []state.Server, globalCollectionOpts state.CollectionOpts, logger *util.Logger) {
+//
+// Cancelling ctx (e.g. when shutting down) stops the in-progress collection without submitting it, and
+// keeps the previous state of that server, so the next run diffs against the last submitted snapshot.
+func CollectAllServers(ctx context.Context, servers []state.Server, globalCollectionOpts state.CollectionOpts, logger *util.Logger) {
 	for idx, server := range servers {
 		var err error
 
-		prefixedLogger := logger.WithPrefixAndRememberErrors(server.Config.SectionName)
+		prefixedLogger := server.Config.Logger(logger, true)
 
-		newState, grant, err := processDatabase(server, globalCollectionOpts, prefixedLogger)
-		if err != nil {
+		if !isActiveCollector(server, globalCollectionOpts, prefixedLogger, true) {
+			continue
+		}
+
+		newState, grant, err := processDatabase(ctx, server, globalCollectionOpts, prefixedLogger)
+		if err != nil && ctx.Err() != nil {
+			prefixedLogger.PrintInfo("Stopped collection before it completed: %s", ctx.Err())
+		} else if err != nil {
 			prefixedLogger.PrintError("Could not process database: %s", err)
 			if grant.Valid && !globalCollectionOpts.TestRun && globalCollectionOpts.SubmitCollectedData {
 				server.Grant = grant
-				err = output.SendFailedFull(server, globalCollectionOpts, prefixedLogger)
+				err = output.SendFailedFull(server, globalCollectionOpts.ForServer(server.Config), prefixedLogger)
 				if err != nil {
 					prefixedLogger.PrintWarning("Could not send error information to remote server: %s", err)
 				}
@@ -226,6 +425,6 @@ func CollectAllServers(servers []state.Server, globalCollectionOpts state.Collec
 	}
 
 	if globalCollectionOpts.WriteStateUpdate {
-		writeStateFile(servers, globalCollectionOpts, logger)
+		WriteStateFile(servers, globalCollectionOpts, logger)
 	}
 }
@@ -1,6 +1,8 @@
 package runner
 
 import (
+	"time"
+
 	"github.com/pganalyze/collector/grant"
 	"github.com/pganalyze/collector/input"
 	"github.com/pganalyze/collector/output"
@@ -21,17 +23,38 @@ func processLogsForServer(server state.Server, globalCollectionOpts state.Collec
 	}
 
 	// TODO: We'll need to pass a connection here for EXPLAINs to run (or hand them over to the next full snapshot run)
+	start := time.Now()
 	logState, err := input.CollectLogs(server, nil, globalCollectionOpts, logger)
 	defer logState.Cleanup()
 	if err != nil {
 		return false, errors.Wrap(err, "could not collect logs")
 	}
+	logLineCount := 0
+	for _, logFile := range logState.LogFiles {
+		logLineCount += len(logFile.LogLines)
+	}
+	logger.PrintVerbose("Collected %d log lines from %d log files in %.1f ms", logLineCount, len(logState.LogFiles), float64(time.Since(start))/float64(time.Millisecond))
+	for key, stats := range logState.TempFileStats {
+		logger.PrintVerbose("Temp files: %d created (%.1f MB total, %.1f MB largest) in database %s by %s: %s", stats.Count,
+			float64(stats.TotalBytes)/1024/1024, float64(stats.MaxBytes)/1024/1024, key.Database, key.Username, stats.NormalizedQuery)
+	}
+	for key, stats := range logState.CanceledStatements {
+		logger.PrintVerbose("Canceled statements: %d due to %s in database %s by %s: %s", stats.Count, key.Reason, key.Database, key.Username, stats.NormalizedQuery)
+	}
+	for key, failures := range logState.AuthFailures {
+		logger.PrintVerbose("Authentication failures: %d for user %s from %s (%s) between %s and %s", failures.Count, key.Username, key.ClientAddr, key.Reason,
+			failures.FirstOccurredAt.Format(time.RFC3339), failures.LastOccurredAt.Format(time.RFC3339))
+	}
 
 	err = output.UploadAndSendLogs(server, grant, globalCollectionOpts, logger, logState)
 	if err != nil {
 		return false, errors.Wrap(err, "failed to upload/send logs")
 	}
 
+	if server.LogFilePositions != nil {
+		server.LogFilePositions.Set(logState.LogFilePositions)
+	}
+
 	return true, nil
 }
 
@@ -42,13 +65,17 @@ func CollectLogsFromAllServers(servers []state.Server, globalCollectionOpts stat
 	}
 
 	for _, server := range servers {
-		if !server.Config.EnableLogs {
+		if !server.Config.EnableLogs && server.Config.LogLocation == "" {
 			continue
 		}
 
-		prefixedLogger := logger.WithPrefixAndRememberErrors(server.Config.SectionName)
+		prefixedLogger := server.Config.Logger(logger, true)
+
+		if !isActiveCollector(server, globalCollectionOpts, prefixedLogger, false) {
+			continue
+		}
 
-		success, err := processLogsForServer(server, globalCollectionOpts, prefixedLogger)
+		success, err := processLogsForServer(server, globalCollectionOpts.ForServer(server.Config), prefixedLogger)
 		if err != nil {
 			prefixedLogger.PrintError("Could not collect logs for server: %s", err)
 			if server.Config.ErrorCallback != "" {
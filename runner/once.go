@@ -0,0 +1,131 @@
+package runner
+
+import (
+	"context"
+
+	"github.com/pganalyze/collector/output"
+	"github.com/pganalyze/collector/state"
+	"github.com/pganalyze/collector/util"
+)
+
+// OnceStatus - Outcome of a single full snapshot for one server, ordered by severity
+type OnceStatus int
+
+const (
+	// OnceSucceeded - Snapshot was collected completely and submitted
+	OnceSucceeded OnceStatus = iota
+
+	// OncePartial - Snapshot was submitted, but errors or warnings were logged during
+	// collection (e.g. parts of the snapshot were skipped due to a statement timeout)
+	OncePartial
+
+	// OnceSubmitFailed - Snapshot was collected, but could not be submitted
+	OnceSubmitFailed
+
+	// OnceFailed - Snapshot could not be collected for other reasons (e.g. missing permissions)
+	OnceFailed
+
+	// OnceConnectionFailed - Could not connect to the database server
+	OnceConnectionFailed
+)
+
+// Process exit codes for --once, see OnceExitCode
+const (
+	ExitCodeSuccess           = 0
+	ExitCodeError             = 1 // Configuration errors and other failures
+	ExitCodeConnectionFailed  = 2
+	ExitCodePartialCollection = 3
+	ExitCodeSubmitFailed      = 4
+)
+
+// OnceResult - Outcome of CollectAllServersOnce for one server
+type OnceResult struct {
+	SectionName string
+	Status      OnceStatus
+	Err         error
+
+	// Errors and warnings logged during collection (set for OncePartial)
+	ErrorMessages   []string
+	WarningMessages []string
+}
+
+// ExitCode - Process exit code that corresponds to the status
+func (s OnceStatus) ExitCode() int {
+	switch s {
+	case OnceSucceeded:
+		return ExitCodeSuccess
+	case OncePartial:
+		return ExitCodePartialCollection
+	case OnceSubmitFailed:
+		return ExitCodeSubmitFailed
+	case OnceConnectionFailed:
+		return ExitCodeConnectionFailed
+	}
+	return ExitCodeError
+}
+
+// OnceExitCode - Process exit code for the most severe outcome across all servers
+func OnceExitCode(results []OnceResult) int {
+	worst := OnceSucceeded
+	for _, result := range results {
+		if result.Status > worst {
+			worst = result.Status
+		}
+	}
+	return worst.ExitCode()
+}
+
+// CollectAllServersOnce - Collects and submits a single full snapshot for each server (like one
+// scheduled run of CollectAllServers), e.g. for cron or CI usage, and returns the outcome per server
+func CollectAllServersOnce(servers []state.Server, globalCollectionOpts state.CollectionOpts, logger *util.Logger) []OnceResult {
+	var results []OnceResult
+
+	for idx, server := range servers {
+		prefixedLogger := server.Config.Logger(logger, true)
+		result := OnceResult{SectionName: server.Config.SectionName}
+
+		newState, grant, err := processDatabase(context.Background(), server, globalCollectionOpts, prefixedLogger)
+		if err != nil {
+			prefixedLogger.PrintError("Could not process database: %s", err)
+			switch err.(type) {
+			case ConnectionError:
+				result.Status = OnceConnectionFailed
+			case SubmitError:
+				result.Status = OnceSubmitFailed
+			default:
+				result.Status = OnceFailed
+			}
+			result.Err = err
+
+			if result.Status != OnceSubmitFailed && grant.Valid && globalCollectionOpts.SubmitCollectedData {
+				server.Grant = grant
+				sendErr := output.SendFailedFull(server, globalCollectionOpts.ForServer(server.Config), prefixedLogger)
+				if sendErr != nil {
+					prefixedLogger.PrintWarning("Could not send error information to remote server: %s", sendErr)
+				}
+			}
+			if server.Config.ErrorCallback != "" {
+				runCompletionCallback("error", server.Config.ErrorCallback, server.Config.SectionName, "full", err, prefixedLogger)
+			}
+		} else {
+			if len(prefixedLogger.ErrorMessages) > 0 || len(prefixedLogger.WarningMessages) > 0 {
+				result.Status = OncePartial
+				result.ErrorMessages = prefixedLogger.ErrorMessages
+				result.WarningMessages = prefixedLogger.WarningMessages
+			}
+			if server.Config.SuccessCallback != "" {
+				runCompletionCallback("success", server.Config.SuccessCallback, server.Config.SectionName, "full", nil, prefixedLogger)
+			}
+			servers[idx].Grant = grant
+			servers[idx].PrevState = newState
+		}
+
+		results = append(results, result)
+	}
+
+	if globalCollectionOpts.WriteStateUpdate {
+		WriteStateFile(servers, globalCollectionOpts, logger)
+	}
+
+	return results
+}
@@ -0,0 +1,29 @@
+package runner
+
+import "testing"
+
+var onceExitCodeTests = []struct {
+	statuses []OnceStatus
+	expected int
+}{
+	{[]OnceStatus{}, ExitCodeSuccess},
+	{[]OnceStatus{OnceSucceeded, OnceSucceeded}, ExitCodeSuccess},
+	{[]OnceStatus{OnceSucceeded, OncePartial}, ExitCodePartialCollection},
+	{[]OnceStatus{OncePartial, OnceSubmitFailed}, ExitCodeSubmitFailed},
+	{[]OnceStatus{OnceFailed, OnceSubmitFailed}, ExitCodeError},
+	{[]OnceStatus{OnceConnectionFailed, OnceFailed, OnceSucceeded}, ExitCodeConnectionFailed},
+}
+
+func TestOnceExitCode(t *testing.T) {
+	for _, test := range onceExitCodeTests {
+		var results []OnceResult
+		for _, status := range test.statuses {
+			results = append(results, OnceResult{Status: status})
+		}
+
+		actual := OnceExitCode(results)
+		if actual != test.expected {
+			t.Errorf("\nStatuses: %v\nExpected: %d\n actual: %d", test.statuses, test.expected, actual)
+		}
+	}
+}
@@ -23,7 +23,7 @@ func runReport(reportType string, server state.Server, globalCollectionOpts stat
 	var err error
 	var connection *sql.DB
 
-	prefixedLogger := logger.WithPrefix(server.Config.SectionName)
+	prefixedLogger := server.Config.Logger(logger, false)
 
 	connection, err = postgres.EstablishConnection(server, logger, globalCollectionOpts, "")
 	if err != nil {
@@ -76,7 +76,7 @@ func getRequestedReports(server state.Server, globalCollectionOpts state.Collect
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Add("Accept", "application/json,text/plain")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := server.Config.HTTPClient().Do(req)
 	if err != nil {
 		return
 	}
@@ -86,6 +86,7 @@ func getRequestedReports(server state.Server, globalCollectionOpts state.Collect
 	if err != nil {
 		return
 	}
+	body = []byte(util.RedactAPIKey(string(body), server.Config.APIKey))
 
 	if resp.StatusCode != http.StatusOK || len(body) == 0 {
 		err = fmt.Errorf("Error when getting requested reports: %s\n", body)
@@ -143,7 +144,11 @@ func RunRequestedReports(servers []state.Server, globalCollectionOpts state.Coll
 			continue
 		}
 
-		prefixedLogger := logger.WithPrefix(server.Config.SectionName)
+		prefixedLogger := server.Config.Logger(logger, false)
+
+		if !isActiveCollector(server, globalCollectionOpts, prefixedLogger, false) {
+			continue
+		}
 
 		reports, grant, err := getRequestedReports(server, globalCollectionOpts, prefixedLogger)
 		if err != nil {
@@ -155,6 +160,11 @@ func RunRequestedReports(servers []state.Server, globalCollectionOpts state.Coll
 			continue
 		}
 
+		if circuitBreakerOpen(server) {
+			prefixedLogger.PrintWarning("Skipping %d requested reports since the server looks overloaded (circuit breaker is open)", len(reports))
+			continue
+		}
+
 		connection, err := postgres.EstablishConnection(server, prefixedLogger, globalCollectionOpts, "")
 		if err != nil {
 			prefixedLogger.PrintError("Error: Failed to connect to database: %s", err)
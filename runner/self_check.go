@@ -0,0 +1,55 @@
+package runner
+
+import (
+	"fmt"
+
+	"github.com/pganalyze/collector/input/postgres"
+	"github.com/pganalyze/collector/state"
+	"github.com/pganalyze/collector/util"
+)
+
+// RunSelfTest - Connects to all servers and outputs a checklist of whether the prerequisites for collecting data are met
+func RunSelfTest(servers []state.Server, globalCollectionOpts state.CollectionOpts, logger *util.Logger) (allPassed bool) {
+	allPassed = true
+
+	for _, server := range servers {
+		prefixedLogger := server.Config.Logger(logger, false)
+
+		fmt.Printf("Server [%s]:\n", server.Config.SectionName)
+
+		connection, err := postgres.EstablishConnection(server, prefixedLogger, globalCollectionOpts, "")
+		if err != nil {
+			printSelfTestCheck(postgres.SelfTestCheck{Name: "Connection", Detail: err.Error()})
+			allPassed = false
+			continue
+		}
+		printSelfTestCheck(postgres.SelfTestCheck{Name: "Connection", Passed: true, Detail: "connected"})
+
+		version, err := postgres.GetPostgresVersion(prefixedLogger, connection)
+		if err != nil {
+			printSelfTestCheck(postgres.SelfTestCheck{Name: "Postgres version", Detail: err.Error()})
+			allPassed = false
+			connection.Close()
+			continue
+		}
+
+		for _, check := range postgres.RunSelfTestChecks(connection, server.Config, version) {
+			printSelfTestCheck(check)
+			if !check.Passed {
+				allPassed = false
+			}
+		}
+
+		connection.Close()
+	}
+
+	return
+}
+
+func printSelfTestCheck(check postgres.SelfTestCheck) {
+	status := "PASS"
+	if !check.Passed {
+		status = "FAIL"
+	}
+	fmt.Printf("  [%s] %s: %s\n", status, check.Name, check.Detail)
+}
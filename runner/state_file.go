@@ -0,0 +1,134 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/pganalyze/collector/state"
+)
+
+// The streamed state file starts with this magic and the format version, followed by one
+// entry per state key: the key length (uint32), the key, the data length (uint64) and the
+// gob-encoded PersistedState. This allows skipping the states of servers we don't monitor
+// (anymore) without decoding them, and only holding one encoded state in memory at a time.
+var stateFileMagic = []byte("PGASTATE")
+
+// Upper bound for state keys, to avoid allocating based on a corrupted length
+const stateFileMaxKeyLength = 4096
+
+func writeStateStream(w io.Writer, prevStateByKey map[string]state.PersistedState) error {
+	if _, err := w.Write(stateFileMagic); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint32(state.StateOnDiskFormatVersion)); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for key, prevState := range prevStateByKey {
+		buf.Reset()
+		if err := gob.NewEncoder(&buf).Encode(prevState); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.BigEndian, uint32(len(key))); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, key); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.BigEndian, uint64(buf.Len())); err != nil {
+			return err
+		}
+		if _, err := buf.WriteTo(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readStateStream - Reads the states for the given keys from a state file, skipping all others
+//
+// Files in the legacy format (a single gob-encoded state.StateOnDisk) are decoded as a whole,
+// they get replaced by the streamed format on the next write.
+func readStateStream(r io.Reader, wantedKeys map[string]bool) (map[string]state.PersistedState, error) {
+	prevStateByKey := make(map[string]state.PersistedState)
+	reader := bufio.NewReader(r)
+
+	magic, err := reader.Peek(len(stateFileMagic))
+	if err != nil || !bytes.Equal(magic, stateFileMagic) {
+		return readLegacyState(reader, wantedKeys)
+	}
+	reader.Discard(len(stateFileMagic))
+
+	var formatVersion uint32
+	if err = binary.Read(reader, binary.BigEndian, &formatVersion); err != nil {
+		return nil, err
+	}
+	if formatVersion != state.StateOnDiskFormatVersion {
+		return nil, errStateFormatChanged
+	}
+
+	for {
+		var keyLength uint32
+		err = binary.Read(reader, binary.BigEndian, &keyLength)
+		if err == io.EOF {
+			return prevStateByKey, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if keyLength > stateFileMaxKeyLength {
+			return nil, fmt.Errorf("invalid key length %d", keyLength)
+		}
+		key := make([]byte, keyLength)
+		if _, err = io.ReadFull(reader, key); err != nil {
+			return nil, err
+		}
+		var dataLength uint64
+		if err = binary.Read(reader, binary.BigEndian, &dataLength); err != nil {
+			return nil, err
+		}
+
+		data := io.LimitReader(reader, int64(dataLength))
+		if wantedKeys[string(key)] {
+			var prevState state.PersistedState
+			if err = gob.NewDecoder(data).Decode(&prevState); err != nil {
+				return nil, err
+			}
+			prevStateByKey[string(key)] = prevState
+		}
+		// Skip over the remaining data, this is all of it for states we didn't decode
+		skipped, err := io.Copy(ioutil.Discard, data)
+		if err != nil {
+			return nil, err
+		}
+		if !wantedKeys[string(key)] && uint64(skipped) != dataLength {
+			return nil, io.ErrUnexpectedEOF
+		}
+	}
+}
+
+func readLegacyState(r io.Reader, wantedKeys map[string]bool) (map[string]state.PersistedState, error) {
+	var stateOnDisk state.StateOnDisk
+	if err := gob.NewDecoder(r).Decode(&stateOnDisk); err != nil {
+		return nil, err
+	}
+	if stateOnDisk.FormatVersion < state.StateOnDiskLegacyFormatVersion {
+		return nil, errStateFormatChanged
+	}
+
+	prevStateByKey := make(map[string]state.PersistedState)
+	for key, prevState := range stateOnDisk.PrevStateByAPIKey {
+		if wantedKeys[key] {
+			prevStateByKey[key] = prevState
+		}
+	}
+	return prevStateByKey, nil
+}
+
+var errStateFormatChanged = fmt.Errorf("on-disk format has changed")
@@ -0,0 +1,75 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+	"time"
+
+	"github.com/pganalyze/collector/state"
+)
+
+var stateFileStates = map[string]state.PersistedState{
+	"key1":          {CollectedAt: time.Unix(1000, 0).UTC()},
+	"key2":          {CollectedAt: time.Unix(2000, 0).UTC()},
+	"key2@instance": {CollectedAt: time.Unix(3000, 0).UTC()},
+}
+
+var readStateStreamTests = []struct {
+	wantedKeys []string
+	expected   []string
+}{
+	{[]string{}, []string{}},
+	{[]string{"key1"}, []string{"key1"}},
+	{[]string{"key2@instance", "missing"}, []string{"key2@instance"}},
+	{[]string{"key1", "key2", "key2@instance"}, []string{"key1", "key2", "key2@instance"}},
+}
+
+func TestReadStateStream(t *testing.T) {
+	var streamed bytes.Buffer
+	if err := writeStateStream(&streamed, stateFileStates); err != nil {
+		t.Fatalf("Could not write state stream: %s", err)
+	}
+
+	var legacy bytes.Buffer
+	stateOnDisk := state.StateOnDisk{FormatVersion: state.StateOnDiskLegacyFormatVersion, PrevStateByAPIKey: stateFileStates}
+	if err := gob.NewEncoder(&legacy).Encode(stateOnDisk); err != nil {
+		t.Fatalf("Could not write legacy state: %s", err)
+	}
+
+	for _, data := range [][]byte{streamed.Bytes(), legacy.Bytes()} {
+		for _, test := range readStateStreamTests {
+			wantedKeys := make(map[string]bool)
+			for _, key := range test.wantedKeys {
+				wantedKeys[key] = true
+			}
+
+			actual, err := readStateStream(bytes.NewReader(data), wantedKeys)
+			if err != nil {
+				t.Errorf("\nWanted: %v\nUnexpected error: %s", test.wantedKeys, err)
+				continue
+			}
+			if len(actual) != len(test.expected) {
+				t.Errorf("\nWanted: %v\nExpected: %v\n actual: %v", test.wantedKeys, test.expected, actual)
+			}
+			for _, key := range test.expected {
+				if !actual[key].CollectedAt.Equal(stateFileStates[key].CollectedAt) {
+					t.Errorf("\nKey: %s\nExpected: %v\n actual: %v", key, stateFileStates[key].CollectedAt, actual[key].CollectedAt)
+				}
+			}
+		}
+	}
+}
+
+func TestReadStateStreamTruncated(t *testing.T) {
+	var streamed bytes.Buffer
+	if err := writeStateStream(&streamed, stateFileStates); err != nil {
+		t.Fatalf("Could not write state stream: %s", err)
+	}
+
+	data := streamed.Bytes()
+	_, err := readStateStream(bytes.NewReader(data[:len(data)-10]), map[string]bool{})
+	if err == nil {
+		t.Errorf("Expected an error for a truncated state file")
+	}
+}
@@ -0,0 +1,63 @@
+package runner
+
+import (
+	"sort"
+
+	"github.com/pganalyze/collector/state"
+)
+
+// limitStatements - Keeps the top statements ranked by total time (or calls, if rankBy is "calls"),
+// and sums up the remaining statements into a single state.OtherStatementsKey entry, so totals stay accurate
+// (the entry is only added if at least one statement was folded into it)
+//
+// Statements in keep are retained in addition to the top statements (e.g. those in other rankings).
+func limitStatements(stats state.DiffedPostgresStatementStatsMap, limit int, rankBy string, keep state.PostgresStatementKeySet) state.DiffedPostgresStatementStatsMap {
+	if limit <= 0 || len(stats) <= limit {
+		return stats
+	}
+
+	keys := make([]state.PostgresStatementKey, 0, len(stats))
+	for key := range stats {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a := stats[keys[i]]
+		b := stats[keys[j]]
+		if rankBy == "calls" && a.Calls != b.Calls {
+			return a.Calls > b.Calls
+		}
+		if rankBy != "calls" && a.TotalTime != b.TotalTime {
+			return a.TotalTime > b.TotalTime
+		}
+		return statementKeyLess(keys[i], keys[j])
+	})
+
+	limited := make(state.DiffedPostgresStatementStatsMap, limit+len(keep)+1)
+	var other state.DiffedPostgresStatementStats
+	folded := 0
+	for idx, key := range keys {
+		if (idx < limit || keep[key]) && key != state.OtherStatementsKey {
+			limited[key] = stats[key]
+		} else {
+			other = other.Add(stats[key])
+			folded++
+		}
+	}
+	if folded > 0 {
+		limited[state.OtherStatementsKey] = other
+	}
+
+	return limited
+}
+
+// statementKeyLess - Orders statement keys, to make selections deterministic for equally ranked statements
+func statementKeyLess(a state.PostgresStatementKey, b state.PostgresStatementKey) bool {
+	if a.DatabaseOid != b.DatabaseOid {
+		return a.DatabaseOid < b.DatabaseOid
+	}
+	if a.UserOid != b.UserOid {
+		return a.UserOid < b.UserOid
+	}
+	return a.QueryID < b.QueryID
+}
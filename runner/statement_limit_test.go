@@ -0,0 +1,79 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/pganalyze/collector/state"
+)
+
+var limitStatementsTests = []struct {
+	rankBy        string
+	expectedKept  []int64
+	expectedCalls int64
+}{
+	{"total_time", []int64{1, 2}, 23},
+	{"calls", []int64{3, 4}, 3},
+}
+
+func TestLimitStatements(t *testing.T) {
+	for _, test := range limitStatementsTests {
+		stats := state.DiffedPostgresStatementStatsMap{
+			state.PostgresStatementKey{DatabaseOid: 1, UserOid: 1, QueryID: 1}: {Calls: 1, TotalTime: 100},
+			state.PostgresStatementKey{DatabaseOid: 1, UserOid: 1, QueryID: 2}: {Calls: 2, TotalTime: 50},
+			state.PostgresStatementKey{DatabaseOid: 1, UserOid: 1, QueryID: 3}: {Calls: 20, TotalTime: 10},
+			state.PostgresStatementKey{DatabaseOid: 1, UserOid: 1, QueryID: 4}: {Calls: 3, TotalTime: 1},
+		}
+
+		actual := limitStatements(stats, 2, test.rankBy, nil)
+
+		if len(actual) != 3 {
+			t.Errorf("limitStatements(%s): expected 3 entries, got %d", test.rankBy, len(actual))
+		}
+		for _, queryID := range test.expectedKept {
+			if _, exists := actual[state.PostgresStatementKey{DatabaseOid: 1, UserOid: 1, QueryID: queryID}]; !exists {
+				t.Errorf("limitStatements(%s): expected queryid %d to be kept", test.rankBy, queryID)
+			}
+		}
+		if other := actual[state.OtherStatementsKey]; other.Calls != test.expectedCalls {
+			t.Errorf("limitStatements(%s): expected other statements to have %d calls, got %d", test.rankBy, test.expectedCalls, other.Calls)
+		}
+	}
+}
+
+func TestLimitStatementsNothingFolded(t *testing.T) {
+	stats := state.DiffedPostgresStatementStatsMap{
+		state.PostgresStatementKey{DatabaseOid: 1, UserOid: 1, QueryID: 1}: {Calls: 1, TotalTime: 100},
+		state.PostgresStatementKey{DatabaseOid: 1, UserOid: 1, QueryID: 2}: {Calls: 2, TotalTime: 50},
+		state.PostgresStatementKey{DatabaseOid: 1, UserOid: 1, QueryID: 3}: {Calls: 3, TotalTime: 10},
+	}
+
+	// All statements beyond the limit are kept because of another ranking
+	keep := state.PostgresStatementKeySet{state.PostgresStatementKey{DatabaseOid: 1, UserOid: 1, QueryID: 3}: true}
+	actual := limitStatements(stats, 2, "total_time", keep)
+	if _, exists := actual[state.OtherStatementsKey]; exists || len(actual) != 3 {
+		t.Errorf("Expected no other statements entry when nothing was folded into it, got %v", actual)
+	}
+}
+
+func TestRankStatementsByIO(t *testing.T) {
+	stats := state.DiffedPostgresStatementStatsMap{
+		state.PostgresStatementKey{DatabaseOid: 1, UserOid: 1, QueryID: 1}: {TotalTime: 100, SharedBlksRead: 10},
+		state.PostgresStatementKey{DatabaseOid: 1, UserOid: 1, QueryID: 2}: {TotalTime: 50, SharedBlksRead: 5000, TempBlksWritten: 20},
+		state.PostgresStatementKey{DatabaseOid: 1, UserOid: 1, QueryID: 3}: {TotalTime: 1, TempBlksRead: 400, TempBlksWritten: 400},
+		state.OtherStatementsKey: {SharedBlksRead: 100000},
+	}
+
+	rankings := rankStatementsByIO(stats)
+	if len(rankings.SharedBlksRead) != 2 || rankings.SharedBlksRead[0].Key.QueryID != 2 || rankings.SharedBlksRead[1].Key.QueryID != 1 {
+		t.Errorf("Unexpected shared blocks read ranking: %+v", rankings.SharedBlksRead)
+	}
+	if len(rankings.TempBlks) != 2 || rankings.TempBlks[0].Key.QueryID != 3 || rankings.TempBlks[0].Value != 800 {
+		t.Errorf("Unexpected temp blocks ranking: %+v", rankings.TempBlks)
+	}
+
+	// Statements in a ranking are kept when limiting, even if they rank low by time
+	limited := limitStatements(stats, 1, "total_time", rankings.Keys())
+	if _, ok := limited[state.PostgresStatementKey{DatabaseOid: 1, UserOid: 1, QueryID: 3}]; !ok {
+		t.Errorf("Expected statement in temp blocks ranking to be kept")
+	}
+}
@@ -0,0 +1,46 @@
+package runner
+
+import (
+	"sort"
+
+	"github.com/pganalyze/collector/state"
+)
+
+// Number of statements in each of the I/O rankings
+const statementIORankingSize = 10
+
+func rankStatementsByIO(stats state.DiffedPostgresStatementStatsMap) state.StatementIORankings {
+	return state.StatementIORankings{
+		SharedBlksRead: rankStatements(stats, statementIORankingSize, func(s state.DiffedPostgresStatementStats) int64 {
+			return s.SharedBlksRead
+		}),
+		TempBlks: rankStatements(stats, statementIORankingSize, func(s state.DiffedPostgresStatementStats) int64 {
+			return s.TempBlksRead + s.TempBlksWritten
+		}),
+	}
+}
+
+// rankStatements - Returns the n statements with the highest value, leaving out statements without any
+func rankStatements(stats state.DiffedPostgresStatementStatsMap, n int, value func(state.DiffedPostgresStatementStats) int64) (ranking state.StatementRanking) {
+	for key, s := range stats {
+		if key == state.OtherStatementsKey {
+			continue
+		}
+		if v := value(s); v > 0 {
+			ranking = append(ranking, state.RankedStatement{Key: key, Value: v})
+		}
+	}
+
+	sort.Slice(ranking, func(i, j int) bool {
+		if ranking[i].Value != ranking[j].Value {
+			return ranking[i].Value > ranking[j].Value
+		}
+		return statementKeyLess(ranking[i].Key, ranking[j].Key)
+	})
+
+	if len(ranking) > n {
+		ranking = ranking[:n]
+	}
+
+	return
+}
@@ -0,0 +1,71 @@
+package runner
+
+import (
+	"github.com/pganalyze/collector/grant"
+	"github.com/pganalyze/collector/output"
+	"github.com/pganalyze/collector/state"
+	"github.com/pganalyze/collector/util"
+)
+
+// UploadSpooledSnapshots - Submits snapshots that were written to a directory by a collector
+// using output_type = file (e.g. on the other side of an air gap), and removes them once uploaded
+//
+// Snapshots are matched to servers by their config section name, and only uploaded when they
+// were signed with the same API key. Returns whether all snapshots were uploaded successfully.
+func UploadSpooledSnapshots(servers []state.Server, directory string, globalCollectionOpts state.CollectionOpts, logger *util.Logger) bool {
+	snapshots, err := output.ReadSpoolDirectory(directory, logger)
+	if err != nil {
+		logger.PrintError("Could not read snapshots from %s: %s", directory, err)
+		return false
+	}
+
+	serversBySection := make(map[string]state.Server)
+	for _, server := range servers {
+		serversBySection[server.Config.SectionName] = server
+	}
+
+	success := true
+	uploaded := 0
+	for _, spooled := range snapshots {
+		server, ok := serversBySection[spooled.SectionName]
+		if !ok {
+			logger.PrintWarning("Skipping snapshot %s: no server configured with section name \"%s\"", spooled.Path, spooled.SectionName)
+			success = false
+			continue
+		}
+
+		prefixedLogger := logger.WithPrefix(server.Config.SectionName)
+		collectionOpts := globalCollectionOpts.ForServer(server.Config)
+
+		snapshot, err := spooled.Load(server.Config.APIKey)
+		if err != nil {
+			prefixedLogger.PrintError("Skipping snapshot: %s", err)
+			success = false
+			continue
+		}
+
+		server.Grant, err = grant.GetDefaultGrant(server, collectionOpts, prefixedLogger)
+		if err != nil {
+			prefixedLogger.PrintError("Could not acquire snapshot grant: %s", err)
+			success = false
+			continue
+		}
+
+		err = output.SubmitSpooledSnapshot(server, collectionOpts, prefixedLogger, snapshot)
+		if err != nil {
+			prefixedLogger.PrintError("Could not upload snapshot %s (collected at %s): %s", spooled.Path, spooled.CollectedAt, err)
+			success = false
+			continue
+		}
+
+		err = spooled.Remove()
+		if err != nil {
+			prefixedLogger.PrintWarning("Uploaded snapshot, but could not remove it from %s: %s", directory, err)
+		}
+		uploaded++
+	}
+
+	logger.PrintInfo("Uploaded %d of %d snapshots from %s", uploaded, len(snapshots), directory)
+
+	return success
+}
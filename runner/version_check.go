@@ -0,0 +1,41 @@
+package runner
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pganalyze/collector/state"
+	"github.com/pganalyze/collector/util"
+)
+
+// Only remind once a day about a newer collector, instead of on every snapshot
+const versionNotificationInterval = 24 * time.Hour
+
+var lastVersionNotification time.Time
+var lastVersionNotificationMutex sync.Mutex
+
+// checkCollectorVersion - Logs a notice when the pganalyze API reports a newer collector version
+// in the snapshot grant (unless disable_version_check is set) - this never updates the collector
+func checkCollectorVersion(server state.Server, grant state.Grant, logger *util.Logger) {
+	if server.Config.DisableVersionCheck || !grant.Valid {
+		return
+	}
+
+	latestVersion := grant.Config.LatestCollectorVersion
+	if !grant.Config.CollectorUpgradeRecommended && (latestVersion == "" || util.CompareVersions(util.CollectorVersion, latestVersion) >= 0) {
+		return
+	}
+
+	lastVersionNotificationMutex.Lock()
+	defer lastVersionNotificationMutex.Unlock()
+	if time.Since(lastVersionNotification) < versionNotificationInterval {
+		return
+	}
+	lastVersionNotification = time.Now()
+
+	if latestVersion != "" {
+		logger.PrintWarning("A newer collector is available: you are running version %s, the latest version is %s - please upgrade (see https://github.com/pganalyze/collector/releases)", util.CollectorVersion, latestVersion)
+	} else {
+		logger.PrintWarning("A newer collector is available: version %s is outdated, the pganalyze service recommends to upgrade (see https://github.com/pganalyze/collector/releases)", util.CollectorVersion)
+	}
+}
@@ -0,0 +1,109 @@
+package runner
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/pganalyze/collector/input/postgres"
+	"github.com/pganalyze/collector/state"
+	"github.com/pganalyze/collector/util"
+)
+
+// SetupWaitEventSampling - Starts sampling wait events for all servers that have it enabled
+//
+// The samples are aggregated on the server's WaitEventSampler, and added to the next full
+// snapshot. Sampling stops once ctx is cancelled - wait on wg to make sure all sampling
+// goroutines have exited (and closed their connections).
+func SetupWaitEventSampling(ctx context.Context, wg *sync.WaitGroup, servers []state.Server, globalCollectionOpts state.CollectionOpts, logger *util.Logger) {
+	for idx, server := range servers {
+		if server.Config.WaitEventSampleInterval <= 0 {
+			continue
+		}
+
+		servers[idx].WaitEventSampler = &state.WaitEventSampler{}
+
+		prefixedLogger := server.Config.Logger(logger, false)
+		interval := time.Duration(server.Config.WaitEventSampleInterval) * time.Second
+
+		wg.Add(1)
+		go func(server state.Server) {
+			defer wg.Done()
+			sampleWaitEvents(ctx, server, interval, globalCollectionOpts, prefixedLogger)
+		}(servers[idx])
+	}
+}
+
+func sampleWaitEvents(ctx context.Context, server state.Server, interval time.Duration, globalCollectionOpts state.CollectionOpts, logger *util.Logger) {
+	var connection *sql.DB
+	var postgresVersion state.PostgresVersion
+	var useWaitSampling bool
+	var err error
+
+	defer func() {
+		if connection != nil {
+			connection.Close()
+		}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// We keep the connection open across samples, and only reconnect in case of errors
+		if connection == nil {
+			connection, err = postgres.EstablishConnection(server, logger, globalCollectionOpts, "")
+			if err != nil {
+				logger.PrintVerbose("Wait event sampling: Failed to connect to database: %s", err)
+				connection = nil
+				continue
+			}
+
+			postgresVersion, err = postgres.GetPostgresVersion(logger, connection)
+			if err != nil {
+				logger.PrintVerbose("Wait event sampling: Error collecting Postgres version: %s", err)
+				connection.Close()
+				connection = nil
+				continue
+			}
+
+			err = postgresVersion.CheckSupported()
+			if err != nil {
+				logger.PrintWarning("Wait event sampling: %s, stopping", err)
+				return
+			}
+
+			useWaitSampling = postgres.WaitSamplingAvailable(connection)
+			if useWaitSampling {
+				logger.PrintVerbose("Wait event sampling: Found pg_wait_sampling extension, using its profile instead")
+			}
+		}
+
+		if useWaitSampling {
+			continue
+		}
+
+		// Each sample must finish before the next one is due, so a slow query never piles up
+		sampleCtx, cancel := context.WithTimeout(ctx, interval)
+		sample, err := postgres.GetActiveSessionCounts(sampleCtx, connection, postgresVersion)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logger.PrintVerbose("Wait event sampling: Error collecting pg_stat_activity: %s", err)
+			connection.Close()
+			connection = nil
+			continue
+		}
+
+		server.WaitEventSampler.Add(sample)
+	}
+}
@@ -9,13 +9,26 @@ import (
 
 type Group struct {
 	interval *cronexpr.Expression
+	splay    time.Duration
+}
+
+// WithSplay - Returns a copy of the group with all runs shifted by the given offset
+func (group Group) WithSplay(splay time.Duration) Group {
+	group.splay = splay
+	return group
+}
+
+// next - Time of the next run after the given time, taking the splay into account
+func (group Group) next(t time.Time) time.Time {
+	return group.interval.Next(t.Add(-group.splay)).Add(group.splay)
 }
 
 func (group Group) Schedule(runner func(), logger *util.Logger, logName string) chan bool {
 	stop := make(chan bool)
 	go func() {
 		for {
-			delay := group.interval.Next(time.Now()).Sub(time.Now())
+			now := time.Now()
+			delay := group.next(now).Sub(now)
 
 			logger.PrintVerbose("Scheduled next run for %s in %+v", logName, delay)
 
@@ -19,3 +19,40 @@ func TestScheduler(t *testing.T) {
 		t.Errorf("\nNext run:\n\texpected %s\n\tactual %s\n\n", expectedNextRun, actualNextRun)
 	}
 }
+
+var splayTests = []struct {
+	group    string
+	splay    time.Duration
+	from     time.Time
+	expected time.Time
+}{
+	{"stats", 0, time.Date(2013, 1, 1, 0, 5, 0, 0, time.UTC), time.Date(2013, 1, 1, 0, 10, 0, 0, time.UTC)},
+	{"stats", 90 * time.Second, time.Date(2013, 1, 1, 0, 5, 0, 0, time.UTC), time.Date(2013, 1, 1, 0, 11, 30, 0, time.UTC)},
+	{"stats", 90 * time.Second, time.Date(2013, 1, 1, 0, 1, 0, 0, time.UTC), time.Date(2013, 1, 1, 0, 1, 30, 0, time.UTC)},
+	// Splays longer than the interval wrap around
+	{"activity", 73 * time.Second, time.Date(2013, 1, 1, 0, 5, 0, 0, time.UTC), time.Date(2013, 1, 1, 0, 5, 3, 0, time.UTC)},
+	{"reports", 1500 * time.Millisecond, time.Date(2013, 1, 1, 0, 5, 1, 0, time.UTC), time.Date(2013, 1, 1, 0, 5, 1, 500000000, time.UTC)},
+}
+
+func TestSchedulerSplay(t *testing.T) {
+	groups, err := GetSchedulerGroups()
+	if err != nil {
+		t.Fatalf("Error: %v\n", err)
+	}
+
+	for _, test := range splayTests {
+		actual := groups[test.group].WithSplay(test.splay).next(test.from)
+		if actual != test.expected {
+			t.Errorf("%s with splay %s from %s:\n\texpected %s\n\tactual %s\n", test.group, test.splay, test.from, test.expected, actual)
+		}
+	}
+}
+
+func TestSplayFromHash(t *testing.T) {
+	for _, hash := range []uint64{0, 1, 12345678901234567890, ^uint64(0)} {
+		splay := splayFromHash(hash, time.Minute)
+		if splay < 0 || splay >= time.Minute {
+			t.Errorf("splayFromHash(%d): %s outside of [0, 1m)", hash, splay)
+		}
+	}
+}
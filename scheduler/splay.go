@@ -0,0 +1,34 @@
+package scheduler
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/pganalyze/collector/config"
+)
+
+// GetSplay - Determines a stable offset for the collection schedule, between zero and the
+// largest schedule_splay_secs setting of the given servers
+//
+// The offset is derived from a hash of the server identities, so a given collector keeps
+// the same phase across restarts, while different collectors get spread out.
+func GetSplay(servers []config.ServerConfig) time.Duration {
+	maxSplaySecs := 0
+	h := fnv.New64a()
+	for _, server := range servers {
+		if server.ScheduleSplaySecs > maxSplaySecs {
+			maxSplaySecs = server.ScheduleSplaySecs
+		}
+		fmt.Fprintf(h, "%s/%s/%s:%d/%s\n", server.APIKeyFingerprint(), server.SystemID, server.GetDbHost(), server.GetDbPort(), server.GetDbName())
+	}
+	if maxSplaySecs <= 0 {
+		return 0
+	}
+
+	return splayFromHash(h.Sum64(), time.Duration(maxSplaySecs)*time.Second)
+}
+
+func splayFromHash(hash uint64, max time.Duration) time.Duration {
+	return time.Duration(hash%uint64(max/time.Millisecond)) * time.Millisecond
+}
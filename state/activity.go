@@ -8,5 +8,8 @@ type ActivityState struct {
 	Version  PostgresVersion
 	Backends []PostgresBackend
 
+	// Active queries running longer than long_running_query_threshold_secs, derived from Backends
+	LongRunningQueries []PostgresLongRunningQuery
+
 	Vacuums []PostgresVacuumProgress
 }
@@ -0,0 +1,20 @@
+package state
+
+import "time"
+
+// AuthFailureKey - Who failed to connect, and why
+type AuthFailureKey struct {
+	Username   string // User the client tried to connect as (empty if not logged)
+	ClientAddr string // Empty if not logged (see LogLine.ClientAddr), or for Unix socket connections
+	Reason     string // e.g. "password authentication failed" or "no pg_hba.conf entry"
+}
+
+// AuthFailures - Connection attempts that failed authentication, as logged
+type AuthFailures struct {
+	Count           int64
+	FirstOccurredAt time.Time
+	LastOccurredAt  time.Time
+}
+
+// AuthFailuresMap - Authentication failures by user, client address and reason, for the log lines in a LogState
+type AuthFailuresMap map[AuthFailureKey]AuthFailures
@@ -0,0 +1,34 @@
+package state
+
+import "time"
+
+// CanceledStatementsKey - Statement that got cancelled, identified like statements in the snapshot
+// (database, role and query fingerprint), together with why it was cancelled
+type CanceledStatementsKey struct {
+	Database    string
+	Username    string
+	Fingerprint [21]byte
+	Reason      string // "statement timeout" or "user request"
+}
+
+// CanceledStatementSample - Single cancellation of a statement, as logged
+type CanceledStatementSample struct {
+	OccurredAt time.Time
+	BackendPid int32
+}
+
+// CanceledStatements - Cancellations logged for a statement, due to statement_timeout or a
+// cancel request (e.g. pg_cancel_backend, or the client giving up on the query)
+type CanceledStatements struct {
+	NormalizedQuery string // Empty if the log didn't include the statement
+	QueryID         int64  // Only known for jsonlog on Postgres 15+
+
+	Count   int64
+	Samples []CanceledStatementSample // The first few cancellations, up to MaxCanceledStatementSamples
+}
+
+// MaxCanceledStatementSamples - Number of cancellations per statement that are kept as samples
+const MaxCanceledStatementSamples = 5
+
+// CanceledStatementsMap - Cancellations per statement, for the log lines in a LogState
+type CanceledStatementsMap map[CanceledStatementsKey]CanceledStatements
@@ -1,8 +1,18 @@
 package state
 
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
 type CollectorStats struct {
 	GoVersion string
 
+	// Collector that produced the snapshot (collector_instance setting, or the hostname)
+	CollectorInstance string
+
 	MemoryHeapAllocatedBytes uint64 // Bytes allocated and not yet freed
 	MemoryHeapObjects        uint64 // Total number of allocated objects
 	MemorySystemBytes        uint64 // Bytes obtained from system (sum of heap and fixed-size structures)
@@ -11,11 +21,84 @@ type CollectorStats struct {
 	ActiveGoroutines int32
 
 	CgoCalls int64
+
+	GcCount        uint32 // Number of completed GC cycles
+	GcPauseTotalNs uint64 // Cumulative time spent in GC stop-the-world pauses
+
+	// Wall-clock time spent in each collector during the full snapshot
+	Timings CollectorTimings
+}
+
+// CollectorTiming - Time spent in one collector, and the number of rows/objects it returned
+type CollectorTiming struct {
+	Duration    time.Duration
+	ObjectCount int
+	Skipped     bool // Hit a timeout or the collection deadline (in at least one database)
+}
+
+// CollectorTimings - Timings of all collectors that ran, by collector name
+type CollectorTimings map[string]CollectorTiming
+
+// Add - Records a collector run that started at the given time - collectors that
+// run once per database are summed up across all databases
+func (t CollectorTimings) Add(name string, start time.Time, objectCount int) {
+	if t == nil {
+		return
+	}
+	timing := t[name]
+	timing.Duration += time.Since(start)
+	timing.ObjectCount += objectCount
+	t[name] = timing
+}
+
+// Skip - Records that a collector was skipped since it hit a timeout or the collection deadline
+func (t CollectorTimings) Skip(name string) {
+	if t == nil {
+		return
+	}
+	timing := t[name]
+	timing.Skipped = true
+	t[name] = timing
+}
+
+// Skipped - Names of all collectors that were skipped, in alphabetical order
+func (t CollectorTimings) Skipped() []string {
+	var names []string
+	for name, timing := range t {
+		if timing.Skipped {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// String - Summary of all timings, slowest collectors first
+func (t CollectorTimings) String() string {
+	var names []string
+	for name := range t {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return t[names[i]].Duration > t[names[j]].Duration
+	})
+
+	var parts []string
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s: %.1f ms (%d objects)", name, float64(t[name].Duration)/float64(time.Millisecond), t[name].ObjectCount))
+	}
+	return strings.Join(parts, ", ")
 }
 
 type DiffedCollectorStats CollectorStats
 
 func (curr CollectorStats) DiffSince(prev CollectorStats) DiffedCollectorStats {
+	// GC counters start over when the collector restarts
+	if curr.GcCount < prev.GcCount || curr.GcPauseTotalNs < prev.GcPauseTotalNs {
+		prev.GcCount = 0
+		prev.GcPauseTotalNs = 0
+	}
+
 	return DiffedCollectorStats{
 		GoVersion:                curr.GoVersion,
 		MemoryHeapAllocatedBytes: curr.MemoryHeapAllocatedBytes,
@@ -24,5 +107,8 @@ func (curr CollectorStats) DiffSince(prev CollectorStats) DiffedCollectorStats {
 		MemoryRssBytes:           curr.MemoryRssBytes,
 		ActiveGoroutines:         curr.ActiveGoroutines,
 		CgoCalls:                 curr.CgoCalls - prev.CgoCalls,
+		GcCount:                  curr.GcCount - prev.GcCount,
+		GcPauseTotalNs:           curr.GcPauseTotalNs - prev.GcPauseTotalNs,
+		Timings:                  curr.Timings,
 	}
 }
@@ -0,0 +1,54 @@
+package state
+
+import "sync"
+
+// LogFilePosition - Position up to which a self-hosted log file has been read
+//
+// The inode is remembered so we can detect log rotation, where a new file takes
+// over the path, and still read the remainder of the old file.
+type LogFilePosition struct {
+	Inode  uint64
+	Offset int64
+}
+
+// LogFilePositionMap - Positions of all log files we've read, keyed by their path
+type LogFilePositionMap map[string]LogFilePosition
+
+// LogFilePositionTracker - Keeps track of log file positions in between runs
+//
+// This is shared between the log snapshot runs (which advance the positions) and
+// the full snapshot runs (which persist them to the state file), and is therefore
+// safe for concurrent use.
+type LogFilePositionTracker struct {
+	mutex     sync.Mutex
+	positions LogFilePositionMap
+}
+
+// NewLogFilePositionTracker - Starts tracking from the positions persisted by a previous run
+func NewLogFilePositionTracker(positions LogFilePositionMap) *LogFilePositionTracker {
+	return &LogFilePositionTracker{positions: positions}
+}
+
+// Get - Returns a copy of the current positions
+func (t *LogFilePositionTracker) Get() LogFilePositionMap {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.positions == nil {
+		return nil
+	}
+	positions := make(LogFilePositionMap, len(t.positions))
+	for path, position := range t.positions {
+		positions[path] = position
+	}
+	return positions
+}
+
+// Set - Replaces the current positions, to be called once the log data up to
+// these positions has been submitted
+func (t *LogFilePositionTracker) Set(positions LogFilePositionMap) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.positions = positions
+}
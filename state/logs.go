@@ -26,6 +26,19 @@ type LogState struct {
 
 	LogFiles     []LogFile
 	QuerySamples []PostgresQuerySample
+
+	// Derived from the temp file log lines in LogFiles
+	TempFileStats TempFileStatsMap
+
+	// Derived from the statement cancellation log lines in LogFiles
+	CanceledStatements CanceledStatementsMap
+
+	// Derived from the connection log lines in LogFiles
+	AuthFailures AuthFailuresMap
+
+	// Positions up to which self-hosted log files have been read - only to be
+	// remembered once the log files were submitted successfully
+	LogFilePositions LogFilePositionMap
 }
 
 // LogFile - Log file that we are uploading for reference in log line metadata
@@ -57,6 +70,14 @@ type LogLine struct {
 	Database    string
 	Query       string
 	Application string
+	ClientAddr  string // Host of the client without its port (from %r in the log_line_prefix, or csvlog/jsonlog)
+
+	// Only set for logs in the csvlog/jsonlog format, where these are reliably known
+	SQLState    string
+	SessionID   string
+	CommandTag  string
+	BackendType string // jsonlog only
+	QueryID     int64  // jsonlog only, matches pg_stat_statements.queryid
 
 	// Only used for collector-internal bookkeeping to determine how long to wait
 	// for associating related loglines with each other
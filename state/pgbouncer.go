@@ -0,0 +1,70 @@
+package state
+
+// PgbouncerState - Pool statistics from the pgbouncer admin console
+type PgbouncerState struct {
+	Stats     []PgbouncerStats
+	Pools     []PgbouncerPool
+	Clients   []PgbouncerClient
+	Databases []PgbouncerDatabase
+}
+
+// PgbouncerStats - Cumulative statistics per database, from SHOW STATS
+//
+// Times are in microseconds. Counters not reported by older pgbouncer versions are zero.
+type PgbouncerStats struct {
+	Database        string
+	TotalXactCount  int64
+	TotalQueryCount int64
+	TotalReceived   int64
+	TotalSent       int64
+	TotalXactTime   int64
+	TotalQueryTime  int64
+	TotalWaitTime   int64
+}
+
+// PgbouncerPool - Current state of a pool (database + user), from SHOW POOLS
+type PgbouncerPool struct {
+	Database string
+	User     string
+	PoolMode string
+
+	ClActive  int64
+	ClWaiting int64
+	SvActive  int64
+	SvIdle    int64
+	SvUsed    int64
+	SvTested  int64
+	SvLogin   int64
+
+	// How long the oldest waiting client has been waiting (in microseconds)
+	MaxWaitUs int64
+}
+
+// PgbouncerClient - Client connection to pgbouncer, from SHOW CLIENTS
+type PgbouncerClient struct {
+	User        string
+	Database    string
+	State       string
+	Addr        string
+	Port        int64
+	ConnectTime string
+	RequestTime string
+
+	// How long the client has been waiting for a server connection (in microseconds)
+	WaitUs int64
+}
+
+// PgbouncerDatabase - Configured database, from SHOW DATABASES
+type PgbouncerDatabase struct {
+	Name               string
+	Host               string
+	Port               int64
+	Database           string
+	PoolSize           int64
+	ReservePool        int64
+	PoolMode           string
+	MaxConnections     int64
+	CurrentConnections int64
+	Paused             bool
+	Disabled           bool
+}
@@ -0,0 +1,48 @@
+package state
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/guregu/null"
+)
+
+// PostgresAdvisoryLockKey - Identifies a granted advisory lock of a specific backend (the backend
+// start time distinguishes backends that reused the same pid)
+type PostgresAdvisoryLockKey struct {
+	Pid          int32
+	BackendStart int64 // Unix timestamp
+	DatabaseOid  Oid
+	ClassID      Oid
+	ObjID        Oid
+	ObjSubID     int32 // 1 for locks taken with a single bigint key, 2 for locks taken with two int4 keys
+}
+
+// LockKey - Returns the key as passed to pg_advisory_lock, i.e. either a bigint or two int4 values
+func (k PostgresAdvisoryLockKey) LockKey() string {
+	if k.ObjSubID == 1 {
+		return fmt.Sprintf("%d", int64(uint64(k.ClassID)<<32|uint64(k.ObjID)))
+	}
+	return fmt.Sprintf("%d, %d", int32(k.ClassID), int32(k.ObjID))
+}
+
+// PostgresAdvisoryLock - Advisory lock held by a backend
+type PostgresAdvisoryLock struct {
+	Key             PostgresAdvisoryLockKey
+	Mode            string // ExclusiveLock or ShareLock
+	DatabaseName    null.String
+	RoleName        null.String
+	ApplicationName null.String
+	State           null.String
+	Query           string // Current or last query of the backend (InsufficientPrivilegeQueryText if we can't see it)
+	WaitingPids     []int32
+
+	// Postgres doesn't track when a lock was granted, so this is when we first saw the lock, or when
+	// the backend went idle if it already held the lock at that point (the lock can be older)
+	HeldSince time.Time
+}
+
+// HeldDuration - How long the lock has been held at least, at the given time
+func (l PostgresAdvisoryLock) HeldDuration(now time.Time) time.Duration {
+	return now.Sub(l.HeldSince)
+}
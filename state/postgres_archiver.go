@@ -0,0 +1,31 @@
+package state
+
+import "github.com/guregu/null"
+
+// PostgresArchiverStats - Status of the WAL archiver process
+//
+// See https://www.postgresql.org/docs/10/static/monitoring-stats.html#PG-STAT-ARCHIVER-VIEW
+type PostgresArchiverStats struct {
+	ArchivedCount    int64
+	LastArchivedWal  null.String
+	LastArchivedTime null.Time
+	FailedCount      int64
+	LastFailedWal    null.String
+	LastFailedTime   null.Time
+	StatsReset       null.Time
+}
+
+// Failing - Whether the most recent archiving attempt failed, i.e. archiving is currently broken
+// and WAL is accumulating on the server
+func (s PostgresArchiverStats) Failing() bool {
+	return s.LastFailedTime.Valid && (!s.LastArchivedTime.Valid || s.LastFailedTime.Time.After(s.LastArchivedTime.Time))
+}
+
+// DiffedArchiverStats - WAL archiving activity since the last run
+type DiffedArchiverStats struct {
+	// Null if there are no previous statistics to compare to (or they were reset)
+	ArchivedPerSecond null.Float
+	NewFailures       null.Int
+
+	Failing bool
+}
@@ -1,6 +1,10 @@
 package state
 
-import "github.com/guregu/null"
+import (
+	"time"
+
+	"github.com/guregu/null"
+)
 
 // PostgresBackend - PostgreSQL server backend thats currently working, waiting
 // or idling (also known as an open connection)
@@ -27,7 +31,7 @@ type PostgresBackend struct {
 	WaitEventType null.String // 9.6+ The type of event for which the backend is waiting, if any; otherwise NULL
 	WaitEvent     null.String // 9.6+ Wait event name if backend is currently waiting, otherwise NULL
 
-	BackendType null.String // 10+ The process type of this backend
+	BackendType null.String // The process type of this backend (10+, "client backend" on older versions)
 
 	Query null.String // Text of this backend's most recent query
 
@@ -40,3 +44,89 @@ type PostgresBackend struct {
 	// - disabled: This state is reported if track_activities is disabled in this backend.
 	State null.String
 }
+
+// PostgresBackendTypeCounts - Number of current backends by their type (Key = backend_type)
+type PostgresBackendTypeCounts map[string]int64
+
+// InsufficientPrivilegeQueryText - Placeholder for query texts we are not allowed to see,
+// matching what Postgres itself shows to non-superusers
+const InsufficientPrivilegeQueryText = "<insufficient privilege>"
+
+// PostgresLongRunningQuery - Query that has been running longer than the configured threshold
+type PostgresLongRunningQuery struct {
+	Pid             int32
+	DatabaseName    null.String
+	RoleName        null.String
+	ApplicationName null.String
+	ClientAddr      null.String
+	QueryStart      time.Time
+	Duration        time.Duration
+	State           string
+	WaitEventType   null.String
+	WaitEvent       null.String
+
+	Query        string // Query text as reported by Postgres (cut off at track_activity_query_size)
+	QueryVisible bool   // False if we lack the privileges to see the query text, and Query is a placeholder
+}
+
+// LongRunningQueries - Returns all active queries that started at least minDuration before collectedAt
+func LongRunningQueries(backends []PostgresBackend, collectedAt time.Time, minDuration time.Duration) []PostgresLongRunningQuery {
+	var queries []PostgresLongRunningQuery
+
+	for _, b := range backends {
+		if !b.QueryStart.Valid || b.State.String != "active" {
+			continue
+		}
+		duration := collectedAt.Sub(b.QueryStart.Time)
+		if duration < minDuration {
+			continue
+		}
+
+		q := PostgresLongRunningQuery{
+			Pid:             b.Pid,
+			DatabaseName:    b.DatabaseName,
+			RoleName:        b.RoleName,
+			ApplicationName: b.ApplicationName,
+			ClientAddr:      b.ClientAddr,
+			QueryStart:      b.QueryStart.Time,
+			Duration:        duration,
+			State:           b.State.String,
+			WaitEventType:   b.WaitEventType,
+			WaitEvent:       b.WaitEvent,
+			Query:           b.Query.String,
+			QueryVisible:    true,
+		}
+		if !b.Query.Valid || b.Query.String == InsufficientPrivilegeQueryText {
+			q.Query = InsufficientPrivilegeQueryText
+			q.QueryVisible = false
+		}
+
+		queries = append(queries, q)
+	}
+
+	return queries
+}
+
+// PostgresIdleInTransactionBlocker - Session that is idle in transaction, while holding locks that
+// other backends are waiting for
+type PostgresIdleInTransactionBlocker struct {
+	Pid             int32
+	DatabaseName    null.String
+	RoleName        null.String
+	ApplicationName null.String
+	ClientAddr      null.String
+	XactStart       null.Time
+	StateChange     null.Time // When the session became idle
+	State           string
+	Query           string // Last query of the session (InsufficientPrivilegeQueryText if we can't see it)
+	HeldLocks       int64
+	BlockedPids     []int32
+}
+
+// IdleDuration - How long the session has been idle at the given time
+func (b PostgresIdleInTransactionBlocker) IdleDuration(now time.Time) time.Duration {
+	if !b.StateChange.Valid {
+		return 0
+	}
+	return now.Sub(b.StateChange.Time)
+}
@@ -0,0 +1,42 @@
+package state
+
+// CitusState - Cluster-wide information from a Citus coordinator
+type CitusState struct {
+	Nodes          []CitusNode
+	StatementStats CitusStatementStatsMap
+	TableSizes     map[Oid]CitusTableSize
+}
+
+// CitusNode - Member of the Citus cluster, from pg_dist_node
+type CitusNode struct {
+	NodeID           int64
+	GroupID          int64
+	Name             string
+	Port             int64
+	Role             string // primary, secondary or unavailable
+	Cluster          string
+	IsActive         bool
+	ShouldHaveShards bool
+}
+
+// CitusStatementKey - Identifies a distributed query, as tracked by citus_stat_statements
+type CitusStatementKey struct {
+	DatabaseOid  Oid
+	UserOid      Oid
+	QueryID      int64
+	Executor     string // e.g. adaptive or router
+	PartitionKey string // only set for queries routed to a single shard
+}
+
+// CitusStatementStats - Number of calls of a distributed query, by executor (see pg_stat_statements for timings)
+type CitusStatementStats struct {
+	Calls int64
+}
+
+type CitusStatementStatsMap map[CitusStatementKey]CitusStatementStats
+
+// CitusTableSize - Size of a distributed table, summed up across all shards on all workers
+type CitusTableSize struct {
+	SizeBytes      int64 // citus_relation_size, main fork only
+	TotalSizeBytes int64 // citus_total_relation_size, including indexes and TOAST
+}
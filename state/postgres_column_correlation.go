@@ -0,0 +1,73 @@
+package state
+
+import "math"
+
+// Thresholds for flagging indexes whose leading column is poorly correlated with the table order
+const (
+	LowCorrelationMinSizeBytes = 100 * 1024 * 1024
+	LowCorrelationThreshold    = 0.5
+)
+
+// PostgresColumnCorrelationMap - Correlation (-1 to 1) of indexed columns with the physical row
+// order, by table OID and column number
+type PostgresColumnCorrelationMap map[Oid]map[int32]float64
+
+// PostgresLowCorrelationIndex - B-tree index on a large table, whose leading column is stored in
+// mostly random order, so range scans on it have to read many different pages - the table is a
+// candidate for CLUSTER on this index (or pg_repack), if that's its most important access path
+type PostgresLowCorrelationIndex struct {
+	DatabaseOid    Oid
+	RelationOid    Oid
+	IndexOid       Oid
+	SchemaName     string
+	RelationName   string
+	IndexName      string
+	ColumnName     string
+	Correlation    float64
+	TableSizeBytes int64
+}
+
+// LowCorrelationIndices - Returns the valid B-tree indexes on tables of at least LowCorrelationMinSizeBytes,
+// whose leading column has an absolute correlation below LowCorrelationThreshold
+func LowCorrelationIndices(relations []PostgresRelation, relationStats PostgresRelationStatsMap, correlations PostgresColumnCorrelationMap) []PostgresLowCorrelationIndex {
+	var low []PostgresLowCorrelationIndex
+
+	for _, r := range relations {
+		sizeBytes := relationStats[r.Oid].SizeBytes
+		if sizeBytes < LowCorrelationMinSizeBytes || correlations[r.Oid] == nil {
+			continue
+		}
+
+		for _, i := range r.Indices {
+			// Expression indexes have a column number of zero, and no statistics on the table
+			if i.IndexType != "btree" || !i.IsValid || len(i.Columns) == 0 || i.Columns[0] <= 0 {
+				continue
+			}
+			correlation, exists := correlations[r.Oid][i.Columns[0]]
+			if !exists || math.Abs(correlation) >= LowCorrelationThreshold {
+				continue
+			}
+
+			var columnName string
+			for _, c := range r.Columns {
+				if c.Position == i.Columns[0] {
+					columnName = c.Name
+				}
+			}
+
+			low = append(low, PostgresLowCorrelationIndex{
+				DatabaseOid:    r.DatabaseOid,
+				RelationOid:    r.Oid,
+				IndexOid:       i.IndexOid,
+				SchemaName:     r.SchemaName,
+				RelationName:   r.RelationName,
+				IndexName:      i.Name,
+				ColumnName:     columnName,
+				Correlation:    correlation,
+				TableSizeBytes: sizeBytes,
+			})
+		}
+	}
+
+	return low
+}
@@ -1,5 +1,7 @@
 package state
 
+import "github.com/guregu/null"
+
 // PostgresDatabase - A database in the PostgreSQL system, with multiple schemas and tables contained in it
 type PostgresDatabase struct {
 	Oid              Oid    // ID of this database
@@ -21,4 +23,31 @@ type PostgresDatabase struct {
 	// This is used to track whether the database needs to be vacuumed in order to prevent multixact ID wraparound or to
 	// allow pg_multixact to be shrunk. It is the minimum of the per-table pg_class.relminmxid values.
 	MinimumMultixactXID Xid
+
+	SizeBytes null.Int // Total disk space used by this database (pg_database_size), null if we lack the CONNECT privilege
+}
+
+// PostgresDatabaseSizeMap - Size in bytes of each database, kept across runs to determine growth
+type PostgresDatabaseSizeMap map[Oid]int64
+
+// DiffedDatabaseSizeMap - Map of database sizes and their growth since the last run (Key = Database OID)
+type DiffedDatabaseSizeMap map[Oid]DiffedDatabaseSize
+
+// DiffedDatabaseSize - Current database size, and how fast it changed since the last run
+type DiffedDatabaseSize struct {
+	SizeBytes int64
+
+	// Null if the database didn't exist (or its size wasn't known) in the last run
+	GrowthBytesPerSecond null.Float
+}
+
+// DatabaseSizes - Returns the sizes of all databases we could determine a size for
+func DatabaseSizes(databases []PostgresDatabase) PostgresDatabaseSizeMap {
+	sizes := make(PostgresDatabaseSizeMap)
+	for _, d := range databases {
+		if d.SizeBytes.Valid {
+			sizes[d.Oid] = d.SizeBytes.Int64
+		}
+	}
+	return sizes
 }
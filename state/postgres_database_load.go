@@ -0,0 +1,19 @@
+package state
+
+import "time"
+
+// PostgresDatabaseLoad - Indicators of how busy the server is, used to back off collection
+// when it is overloaded
+type PostgresDatabaseLoad struct {
+	ActiveBackends int64
+	MaxConnections int64
+	QueryLatency   time.Duration // Round trip time of the (trivial) query that determined this
+}
+
+// ActiveBackendsPercent - Actively running backends as a percentage of max_connections
+func (l PostgresDatabaseLoad) ActiveBackendsPercent() float64 {
+	if l.MaxConnections == 0 {
+		return 0
+	}
+	return float64(l.ActiveBackends) / float64(l.MaxConnections) * 100
+}
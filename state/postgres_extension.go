@@ -0,0 +1,16 @@
+package state
+
+import "github.com/guregu/null"
+
+// PostgresExtension - Extension installed in a database, from pg_extension
+type PostgresExtension struct {
+	DatabaseOid   Oid
+	ExtensionName string
+	Version       string
+	SchemaName    string
+
+	// Version that ALTER EXTENSION ... UPDATE would update to (NULL if the extension's
+	// control file is no longer available on the server)
+	DefaultVersion  null.String
+	UpdateAvailable bool
+}
@@ -0,0 +1,36 @@
+package state
+
+import "github.com/guregu/null"
+
+// PostgresHbaRule - Client authentication rule from pg_hba.conf (Postgres 10+)
+//
+// See also https://www.postgresql.org/docs/10/static/view-pg-hba-file-rules.html
+type PostgresHbaRule struct {
+	LineNumber int32
+	Type       null.String // local, host, hostssl or hostnossl (NULL if the line can't be parsed)
+	Databases  []string
+	UserNames  []string
+	Address    null.String // Host name, IP address or one of all, samehost or samenet (NULL for local connections)
+	Netmask    null.String
+	AuthMethod null.String
+	Options    []string
+	Error      null.String // Reason the line can't be parsed (which means Postgres ignores it on a reload)
+}
+
+// Permissive - Whether the rule lets clients connect without a password (trust), or accepts
+// connections from any address
+func (r PostgresHbaRule) Permissive() bool {
+	if r.Error.Valid || !r.Type.Valid || r.Type.String == "local" {
+		return false
+	}
+	if r.AuthMethod.String == "trust" {
+		return true
+	}
+	switch r.Address.String {
+	case "all":
+		return true
+	case "0.0.0.0", "::":
+		return r.Netmask.String == "0.0.0.0" || r.Netmask.String == "::"
+	}
+	return false
+}
@@ -0,0 +1,21 @@
+package state
+
+// PostgresHypertable - TimescaleDB hypertable, with its chunks aggregated up
+//
+// The chunks themselves are regular child tables, and are also part of the relation
+// stats - use ChunkOids to associate them with their hypertable.
+type PostgresHypertable struct {
+	Oid                Oid
+	DatabaseOid        Oid
+	SchemaName         string
+	TableName          string
+	NumDimensions      int32
+	CompressionEnabled bool
+
+	ChunkOids        []Oid
+	NumChunks        int64
+	CompressedChunks int64
+
+	// Total size across all chunks (including indexes, TOAST and compressed data), from hypertable_size()
+	TotalSizeBytes int64
+}
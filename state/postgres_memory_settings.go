@@ -0,0 +1,112 @@
+package state
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Thresholds for flagging memory settings that obviously don't fit the system memory
+const (
+	// Beyond this, shared_buffers takes memory away from the OS page cache that Postgres also relies on
+	SharedBuffersMaxMemoryShare = 0.4
+
+	// Not every connection uses work_mem at the same time, but a single query may use it several
+	// times (once per sort or hash node), so only flag this when it's far off
+	WorkMemMaxMemoryFactor = 4.0
+)
+
+// PostgresMemorySettings - Memory-related settings of the server, together with the total system memory
+type PostgresMemorySettings struct {
+	SharedBuffersBytes      int64
+	EffectiveCacheSizeBytes int64
+	WorkMemBytes            int64
+	MaintenanceWorkMemBytes int64
+	MaxConnections          int64
+
+	TotalMemoryBytes int64
+}
+
+// MemorySettings - Summarizes the memory settings, returns false if any of them (or the system
+// memory) is unknown
+func MemorySettings(settings []PostgresSetting, memory Memory) (PostgresMemorySettings, bool) {
+	m := PostgresMemorySettings{TotalMemoryBytes: int64(memory.TotalBytes)}
+	found := 0
+
+	for _, s := range settings {
+		var value *int64
+		isBytes := true
+		switch s.Name {
+		case "shared_buffers":
+			value = &m.SharedBuffersBytes
+		case "effective_cache_size":
+			value = &m.EffectiveCacheSizeBytes
+		case "work_mem":
+			value = &m.WorkMemBytes
+		case "maintenance_work_mem":
+			value = &m.MaintenanceWorkMemBytes
+		case "max_connections":
+			value = &m.MaxConnections
+			isBytes = false
+		default:
+			continue
+		}
+
+		v, err := strconv.ParseInt(s.CurrentValue.String, 10, 64)
+		if err != nil {
+			return m, false
+		}
+		if isBytes {
+			unitBytes, ok := settingUnitBytes(s.Unit.String)
+			if !ok {
+				return m, false
+			}
+			v *= unitBytes
+		}
+		*value = v
+		found++
+	}
+
+	return m, found == 5 && m.TotalMemoryBytes > 0
+}
+
+// settingUnitBytes - Returns the size in bytes of a pg_settings memory unit, e.g. "8kB" or "MB"
+func settingUnitBytes(unit string) (int64, bool) {
+	multiplier := int64(1)
+	if i := strings.IndexFunc(unit, func(r rune) bool { return r < '0' || r > '9' }); i > 0 {
+		var err error
+		multiplier, err = strconv.ParseInt(unit[:i], 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		unit = unit[i:]
+	}
+
+	switch unit {
+	case "B":
+		return multiplier, true
+	case "kB":
+		return multiplier * 1024, true
+	case "MB":
+		return multiplier * 1024 * 1024, true
+	case "GB":
+		return multiplier * 1024 * 1024 * 1024, true
+	case "TB":
+		return multiplier * 1024 * 1024 * 1024 * 1024, true
+	}
+	return 0, false
+}
+
+// SharedBuffersMemoryShare - Share of system memory used for shared_buffers
+func (m PostgresMemorySettings) SharedBuffersMemoryShare() float64 {
+	return float64(m.SharedBuffersBytes) / float64(m.TotalMemoryBytes)
+}
+
+// WorkMemMemoryFactor - How many times the system memory all connections would use with one work_mem each
+func (m PostgresMemorySettings) WorkMemMemoryFactor() float64 {
+	return float64(m.WorkMemBytes) * float64(m.MaxConnections) / float64(m.TotalMemoryBytes)
+}
+
+// EffectiveCacheSizeExceedsMemory - Whether the planner assumes more cache than there is memory on the system
+func (m PostgresMemorySettings) EffectiveCacheSizeExceedsMemory() bool {
+	return m.EffectiveCacheSizeBytes > m.TotalMemoryBytes
+}
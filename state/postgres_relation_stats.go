@@ -12,6 +12,7 @@ type PostgresRelationStats struct {
 	NTupUpd          int64     // Number of rows updated
 	NTupDel          int64     // Number of rows deleted
 	NTupHotUpd       int64     // Number of rows HOT updated (i.e., with no separate index update required)
+	NTupNewpageUpd   int64     // Number of rows updated where the new row version went to a new heap page (16+)
 	NLiveTup         int64     // Estimated number of live rows
 	NDeadTup         int64     // Estimated number of dead rows
 	NModSinceAnalyze null.Int  // Estimated number of rows modified since this table was last analyzed
@@ -31,6 +32,15 @@ type PostgresRelationStats struct {
 	ToastBlksHit     int64     // Number of buffer hits in this table's TOAST table (if any)
 	TidxBlksRead     int64     // Number of disk blocks read from this table's TOAST table indexes (if any)
 	TidxBlksHit      int64     // Number of buffer hits in this table's TOAST table indexes (if any)
+
+	// Stats of this table's TOAST table (zero if there is none), note that SizeBytes already includes ToastSizeBytes
+	ToastSizeBytes      int64     // Size of the TOAST table (without its index)
+	ToastNLiveTup       int64     // Estimated number of live rows in the TOAST table
+	ToastNDeadTup       int64     // Estimated number of dead rows in the TOAST table
+	ToastLastAutovacuum null.Time // Last time at which the TOAST table was vacuumed by the autovacuum daemon
+
+	// Whether pg_statistic has rows for this table - unknown unless connected as superuser or using the stats helper
+	HasColumnStats null.Bool
 }
 
 type PostgresIndexStats struct {
@@ -61,6 +71,7 @@ func (curr PostgresRelationStats) DiffSince(prev PostgresRelationStats) DiffedPo
 		NTupUpd:          curr.NTupUpd - prev.NTupUpd,
 		NTupDel:          curr.NTupDel - prev.NTupDel,
 		NTupHotUpd:       curr.NTupHotUpd - prev.NTupHotUpd,
+		NTupNewpageUpd:   curr.NTupNewpageUpd - prev.NTupNewpageUpd,
 		NLiveTup:         curr.NLiveTup,
 		NDeadTup:         curr.NDeadTup,
 		NModSinceAnalyze: curr.NModSinceAnalyze,
@@ -80,6 +91,13 @@ func (curr PostgresRelationStats) DiffSince(prev PostgresRelationStats) DiffedPo
 		ToastBlksHit:     curr.ToastBlksHit - prev.ToastBlksHit,
 		TidxBlksRead:     curr.TidxBlksRead - prev.TidxBlksRead,
 		TidxBlksHit:      curr.TidxBlksHit - prev.TidxBlksHit,
+
+		ToastSizeBytes:      curr.ToastSizeBytes,
+		ToastNLiveTup:       curr.ToastNLiveTup,
+		ToastNDeadTup:       curr.ToastNDeadTup,
+		ToastLastAutovacuum: curr.ToastLastAutovacuum,
+
+		HasColumnStats: curr.HasColumnStats,
 	}
 }
 
@@ -93,3 +111,119 @@ func (curr PostgresIndexStats) DiffSince(prev PostgresIndexStats) DiffedPostgres
 		IdxBlksHit:  curr.IdxBlksHit - prev.IdxBlksHit,
 	}
 }
+
+// UnanalyzedRelationMinLiveTup - Tables smaller than this (the default autovacuum_analyze_threshold)
+// are not considered as missing statistics, since autovacuum won't analyze them yet either, and
+// the planner does fine for them without
+const UnanalyzedRelationMinLiveTup = 50
+
+// PostgresUnanalyzedRelation - Table that has never been analyzed, and thus gets planned without
+// statistics, e.g. after a bulk import
+type PostgresUnanalyzedRelation struct {
+	DatabaseOid  Oid
+	RelationOid  Oid
+	SchemaName   string
+	RelationName string
+	NLiveTup     int64
+}
+
+// UnanalyzedRelations - Returns all tables without planner statistics
+//
+// When we know whether pg_statistic has rows for a table we rely on that, otherwise we use the
+// absence of both last_analyze and last_autoanalyze. Partitioned tables are excluded, since they
+// legitimately have no statistics of their own.
+func UnanalyzedRelations(relations []PostgresRelation, relationStats PostgresRelationStatsMap) []PostgresUnanalyzedRelation {
+	var unanalyzed []PostgresUnanalyzedRelation
+
+	for _, r := range relations {
+		if r.RelationType != "r" {
+			continue
+		}
+		stats, exists := relationStats[r.Oid]
+		if !exists || stats.NLiveTup < UnanalyzedRelationMinLiveTup {
+			continue
+		}
+		if stats.HasColumnStats.Valid {
+			if stats.HasColumnStats.Bool {
+				continue
+			}
+		} else if stats.LastAnalyze.Valid || stats.LastAutoanalyze.Valid {
+			continue
+		}
+
+		unanalyzed = append(unanalyzed, PostgresUnanalyzedRelation{
+			DatabaseOid:  r.DatabaseOid,
+			RelationOid:  r.Oid,
+			SchemaName:   r.SchemaName,
+			RelationName: r.RelationName,
+			NLiveTup:     stats.NLiveTup,
+		})
+	}
+
+	return unanalyzed
+}
+
+// HotUpdateRatio - Returns the share of updates that were HOT updates (0 to 1), or -1 if there were no updates
+func (stats PostgresRelationStats) HotUpdateRatio() float64 {
+	if stats.NTupUpd <= 0 {
+		return -1
+	}
+	return float64(stats.NTupHotUpd) / float64(stats.NTupUpd)
+}
+
+// HotUpdateRatio - Returns the share of updates in the interval that were HOT updates (0 to 1), or -1 if there were no updates
+func (stats DiffedPostgresRelationStats) HotUpdateRatio() float64 {
+	return PostgresRelationStats(stats).HotUpdateRatio()
+}
+
+// LowHotUpdateMinUpdates - Tables with fewer updates than this are not flagged for a low HOT update ratio
+const LowHotUpdateMinUpdates = 10000
+
+// LowHotUpdateRatio - Heavily updated tables with a HOT update ratio below this are flagged
+const LowHotUpdateRatio = 0.5
+
+// PostgresLowHotUpdateRelation - Heavily updated table where most updates also had to update all
+// indexes, typically because an indexed column gets updated, or there is no free space on the
+// page (which a lower fillfactor can help with)
+type PostgresLowHotUpdateRelation struct {
+	DatabaseOid    Oid
+	RelationOid    Oid
+	SchemaName     string
+	RelationName   string
+	Fillfactor     int32
+	NTupUpd        int64
+	NTupHotUpd     int64
+	NTupNewpageUpd int64 // Zero before Postgres 16
+	HotUpdateRatio float64
+}
+
+// LowHotUpdateRelations - Returns all tables with at least LowHotUpdateMinUpdates updates, and a
+// HOT update ratio below LowHotUpdateRatio (based on the cumulative statistics)
+func LowHotUpdateRelations(relations []PostgresRelation, relationStats PostgresRelationStatsMap) []PostgresLowHotUpdateRelation {
+	var low []PostgresLowHotUpdateRelation
+
+	for _, r := range relations {
+		stats, exists := relationStats[r.Oid]
+		if !exists || stats.NTupUpd < LowHotUpdateMinUpdates {
+			continue
+		}
+		ratio := stats.HotUpdateRatio()
+		if ratio >= LowHotUpdateRatio {
+			continue
+		}
+
+		low = append(low, PostgresLowHotUpdateRelation{
+			DatabaseOid:    r.DatabaseOid,
+			RelationOid:    r.Oid,
+			SchemaName:     r.SchemaName,
+			RelationName:   r.RelationName,
+			Fillfactor:     r.Fillfactor(),
+			NTupUpd:        stats.NTupUpd,
+			NTupHotUpd:     stats.NTupHotUpd,
+			NTupNewpageUpd: stats.NTupNewpageUpd,
+			HotUpdateRatio: ratio,
+		})
+	}
+
+	return low
+}
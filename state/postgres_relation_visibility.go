@@ -0,0 +1,57 @@
+package state
+
+import "github.com/guregu/null"
+
+// Tables smaller than this (in pages, 1 GB) are not flagged as wraparound risks, since even a
+// full scan of them by an aggressive vacuum finishes quickly
+const VisibilityWraparoundRiskMinPages = 131072
+
+// PostgresRelationVisibility - Visibility map summary of a table, i.e. how much of it vacuum can skip
+type PostgresRelationVisibility struct {
+	RelPages      int64 // Size of the table in pages (estimate as of the last VACUUM/ANALYZE)
+	RelAllVisible int64 // Number of all-visible pages (estimate as of the last VACUUM/ANALYZE)
+
+	// Exact counts from pg_visibility_map_summary() - only set when the pg_visibility extension
+	// is installed in the database, and the collector is allowed to use it, for tables that
+	// meet the size and age criteria of WraparoundRisk
+	AllVisible null.Int
+	AllFrozen  null.Int
+
+	XidAge       int64 // Age of relfrozenxid
+	FreezeMaxAge int64 // autovacuum_freeze_max_age, beyond which autovacuum runs an aggressive (anti-wraparound) vacuum
+}
+
+// PostgresRelationVisibilityMap - Visibility of each table, by table OID
+type PostgresRelationVisibilityMap map[Oid]PostgresRelationVisibility
+
+// AllVisibleFraction - Share of pages that are all-visible (0 to 1), based on the exact count if we have it
+func (v PostgresRelationVisibility) AllVisibleFraction() float64 {
+	if v.RelPages <= 0 {
+		return 1
+	}
+	allVisible := v.RelAllVisible
+	if v.AllVisible.Valid {
+		allVisible = v.AllVisible.Int64
+	}
+	return float64(allVisible) / float64(v.RelPages)
+}
+
+// AllFrozenFraction - Share of pages that are all-frozen (0 to 1), i.e. that the next aggressive
+// vacuum can skip - returns false if unknown (without pg_visibility)
+func (v PostgresRelationVisibility) AllFrozenFraction() (float64, bool) {
+	if !v.AllFrozen.Valid {
+		return 0, false
+	}
+	if v.RelPages <= 0 {
+		return 1, true
+	}
+	return float64(v.AllFrozen.Int64) / float64(v.RelPages), true
+}
+
+// WraparoundRisk - Whether the table is a large one that is getting close to an aggressive
+// vacuum (at 75% of autovacuum_freeze_max_age), while most of its pages still need freezing
+func (v PostgresRelationVisibility) WraparoundRisk() bool {
+	frozen, known := v.AllFrozenFraction()
+	return known && frozen < 0.5 && v.RelPages >= VisibilityWraparoundRiskMinPages &&
+		v.FreezeMaxAge > 0 && v.XidAge >= v.FreezeMaxAge*3/4
+}
@@ -0,0 +1,49 @@
+package state_test
+
+import (
+	"testing"
+
+	"github.com/guregu/null"
+	"github.com/pganalyze/collector/state"
+)
+
+var wraparoundRiskTests = []struct {
+	name       string
+	visibility state.PostgresRelationVisibility
+	expected   bool
+}{
+	{
+		"large table close to an aggressive vacuum, mostly not frozen",
+		state.PostgresRelationVisibility{RelPages: 200000, AllFrozen: null.IntFrom(10000), XidAge: 160000000, FreezeMaxAge: 200000000},
+		true,
+	},
+	{
+		"mostly frozen",
+		state.PostgresRelationVisibility{RelPages: 200000, AllFrozen: null.IntFrom(150000), XidAge: 160000000, FreezeMaxAge: 200000000},
+		false,
+	},
+	{
+		"young relfrozenxid",
+		state.PostgresRelationVisibility{RelPages: 200000, AllFrozen: null.IntFrom(10000), XidAge: 100000000, FreezeMaxAge: 200000000},
+		false,
+	},
+	{
+		"small table",
+		state.PostgresRelationVisibility{RelPages: 1000, AllFrozen: null.IntFrom(0), XidAge: 160000000, FreezeMaxAge: 200000000},
+		false,
+	},
+	{
+		"all-frozen pages unknown (no pg_visibility)",
+		state.PostgresRelationVisibility{RelPages: 200000, RelAllVisible: 0, XidAge: 160000000, FreezeMaxAge: 200000000},
+		false,
+	},
+}
+
+func TestWraparoundRisk(t *testing.T) {
+	for _, test := range wraparoundRiskTests {
+		actual := test.visibility.WraparoundRisk()
+		if actual != test.expected {
+			t.Errorf("%s: expected %t, actual %t", test.name, test.expected, actual)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package state
 
 import (
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/guregu/null"
 )
@@ -24,6 +26,16 @@ type PostgresRelation struct {
 	FrozenXID              Xid
 	MinimumMultixactXID    Xid
 
+	// Only relevant for materialized views: False if it was created WITH NO DATA (or
+	// never refreshed since), in which case it can't be queried
+	IsPopulated bool
+
+	// Changes on every rewrite, e.g. REFRESH MATERIALIZED VIEW (see PostgresMatviewRefresh)
+	Relfilenode Oid
+
+	// Table access method, equivalent with pg_am.amname, e.g. "heap" (NULL for views, and before Postgres 12)
+	AccessMethod null.String
+
 	// True if another process is currently holding an AccessExclusiveLock on this
 	// relation, this also means we don't collect columns/index/constraints data
 	ExclusivelyLocked bool
@@ -36,17 +48,27 @@ type PostgresColumn struct {
 	DefaultValue null.String
 	NotNull      bool
 	Position     int32
+
+	StatisticsTarget           int32      // ALTER TABLE ... ALTER COLUMN ... SET STATISTICS, -1 if default_statistics_target is used
+	NDistinctOverride          null.Float // ALTER TABLE ... ALTER COLUMN ... SET (n_distinct = ...), if set
+	NDistinctInheritedOverride null.Float // ALTER TABLE ... ALTER COLUMN ... SET (n_distinct_inherited = ...), if set
+}
+
+// HasStatisticsOverride - Whether the planner statistics of this column have been adjusted manually
+func (c PostgresColumn) HasStatisticsOverride() bool {
+	return c.StatisticsTarget != -1 || c.NDistinctOverride.Valid || c.NDistinctInheritedOverride.Valid
 }
 
 type PostgresIndex struct {
 	RelationOid   Oid
 	IndexOid      Oid
-	IndexType     string // Equivalent with pg_am.amname, e.g. "btree", "gist", "gin", "brin"
+	IndexType     string // Index access method, equivalent with pg_am.amname, e.g. "btree", "gist", "gin", "brin", "hash"
 	Columns       []int32
 	Name          string
 	IsPrimary     bool
 	IsUnique      bool
 	IsValid       bool
+	IsReady       bool // False while CREATE INDEX CONCURRENTLY is still running (or after it failed early)
 	IndexDef      string
 	ConstraintDef null.String
 	Options       map[string]string
@@ -63,6 +85,199 @@ type PostgresConstraint struct {
 	ForeignUpdateType string  // Foreign key update action code: a = no action, r = restrict, c = cascade, n = set null, d = set default
 	ForeignDeleteType string  // Foreign key deletion action code: a = no action, r = restrict, c = cascade, n = set null, d = set default
 	ForeignMatchType  string  // Foreign key match type: f = full, p = partial, s = simple
+	IsValidated       bool    // False if added as NOT VALID, and not yet checked using ALTER TABLE ... VALIDATE CONSTRAINT
+	IsDeferrable      bool    // DEFERRABLE, i.e. the check can be deferred to the end of the transaction
+	IsDeferred        bool    // INITIALLY DEFERRED, i.e. the check is deferred by default
+}
+
+// PostgresBrokenSchemaObject - Index or constraint that needs to be cleaned up or validated, e.g. an
+// invalid index left behind by a failed CREATE INDEX CONCURRENTLY
+type PostgresBrokenSchemaObject struct {
+	DatabaseOid  Oid
+	RelationOid  Oid
+	SchemaName   string
+	RelationName string
+	ObjectType   string // "index" or "constraint"
+	Name         string
+	Reason       string // "invalid", "not ready" or "not validated"
+}
+
+// BrokenSchemaObjects - Returns all invalid/not ready indices and NOT VALID constraints of the given relations
+func BrokenSchemaObjects(relations []PostgresRelation) []PostgresBrokenSchemaObject {
+	var objects []PostgresBrokenSchemaObject
+
+	for _, r := range relations {
+		broken := PostgresBrokenSchemaObject{DatabaseOid: r.DatabaseOid, RelationOid: r.Oid, SchemaName: r.SchemaName, RelationName: r.RelationName}
+
+		for _, i := range r.Indices {
+			broken.ObjectType = "index"
+			broken.Name = i.Name
+			if !i.IsReady {
+				broken.Reason = "not ready"
+				objects = append(objects, broken)
+			} else if !i.IsValid {
+				broken.Reason = "invalid"
+				objects = append(objects, broken)
+			}
+		}
+
+		for _, c := range r.Constraints {
+			if !c.IsValidated {
+				broken.ObjectType = "constraint"
+				broken.Name = c.Name
+				broken.Reason = "not validated"
+				objects = append(objects, broken)
+			}
+		}
+	}
+
+	return objects
+}
+
+// PostgresUnindexedForeignKey - Foreign key whose columns are not covered by an index, which makes
+// deletes and key updates on the referenced table scan the whole referencing table
+type PostgresUnindexedForeignKey struct {
+	DatabaseOid    Oid
+	RelationOid    Oid
+	SchemaName     string
+	RelationName   string
+	ConstraintName string
+	Columns        []int32
+	ColumnNames    []string
+
+	// Referenced table (names are empty if it wasn't collected, e.g. due to a lock)
+	ForeignOid          Oid
+	ForeignSchemaName   string
+	ForeignRelationName string
+
+	// Index that would support the foreign key, e.g. CREATE INDEX ON public.orders (customer_id)
+	SuggestedIndexDef string
+}
+
+// UnindexedForeignKeys - Returns foreign keys without a valid, non-partial index that has the
+// foreign key columns as its leading columns (in any order)
+func UnindexedForeignKeys(relations []PostgresRelation) []PostgresUnindexedForeignKey {
+	var unindexed []PostgresUnindexedForeignKey
+
+	relationsByOid := make(map[Oid]PostgresRelation, len(relations))
+	for _, r := range relations {
+		relationsByOid[r.Oid] = r
+	}
+
+	for _, r := range relations {
+		for _, c := range r.Constraints {
+			if c.Type != "f" || len(c.Columns) == 0 || hasIndexOnLeadingColumns(r.Indices, c.Columns) {
+				continue
+			}
+			fk := PostgresUnindexedForeignKey{
+				DatabaseOid: r.DatabaseOid, RelationOid: r.Oid, SchemaName: r.SchemaName, RelationName: r.RelationName,
+				ConstraintName: c.Name, Columns: c.Columns, ForeignOid: c.ForeignOid,
+			}
+			for _, position := range c.Columns {
+				fk.ColumnNames = append(fk.ColumnNames, r.columnName(position))
+			}
+			if foreign, ok := relationsByOid[c.ForeignOid]; ok {
+				fk.ForeignSchemaName = foreign.SchemaName
+				fk.ForeignRelationName = foreign.RelationName
+			}
+			fk.SuggestedIndexDef = "CREATE INDEX ON " + quoteIdentifier(r.SchemaName) + "." + quoteIdentifier(r.RelationName) + " ("
+			for idx, name := range fk.ColumnNames {
+				if idx > 0 {
+					fk.SuggestedIndexDef += ", "
+				}
+				fk.SuggestedIndexDef += quoteIdentifier(name)
+			}
+			fk.SuggestedIndexDef += ")"
+			unindexed = append(unindexed, fk)
+		}
+	}
+
+	return unindexed
+}
+
+func (r PostgresRelation) columnName(position int32) string {
+	for _, column := range r.Columns {
+		if column.Position == position {
+			return column.Name
+		}
+	}
+	return strconv.Itoa(int(position))
+}
+
+// quoteIdentifier - Quotes the identifier if needed, like quote_ident() in Postgres (ignoring keywords)
+func quoteIdentifier(name string) string {
+	for idx, r := range name {
+		if !(r >= 'a' && r <= 'z' || r == '_' || (idx > 0 && r >= '0' && r <= '9')) {
+			return "\"" + strings.Replace(name, "\"", "\"\"", -1) + "\""
+		}
+	}
+	return name
+}
+
+func hasIndexOnLeadingColumns(indices []PostgresIndex, columns []int32) bool {
+	for _, i := range indices {
+		// Partial indices only cover some rows, so they can't be used for all foreign key checks
+		if !i.IsValid || len(i.Columns) < len(columns) || strings.Contains(i.IndexDef, " WHERE ") {
+			continue
+		}
+		leading := make(map[int32]bool, len(columns))
+		for _, column := range i.Columns[:len(columns)] {
+			leading[column] = true
+		}
+		covered := true
+		for _, column := range columns {
+			if !leading[column] {
+				covered = false
+				break
+			}
+		}
+		if covered {
+			return true
+		}
+	}
+	return false
+}
+
+// PostgresColumnStatisticsOverride - Column with a manually set statistics target or n_distinct,
+// which are a common reason for planner misestimates on large tables
+type PostgresColumnStatisticsOverride struct {
+	DatabaseOid                Oid
+	RelationOid                Oid
+	SchemaName                 string
+	RelationName               string
+	ColumnName                 string
+	StatisticsTarget           int32
+	NDistinctOverride          null.Float
+	NDistinctInheritedOverride null.Float
+}
+
+// ColumnStatisticsOverrides - Returns all columns with statistics overrides, for tables of at least the given size
+func ColumnStatisticsOverrides(relations []PostgresRelation, relationStats PostgresRelationStatsMap, minSizeBytes int64) []PostgresColumnStatisticsOverride {
+	var overrides []PostgresColumnStatisticsOverride
+
+	for _, r := range relations {
+		if relationStats[r.Oid].SizeBytes < minSizeBytes {
+			continue
+		}
+
+		for _, c := range r.Columns {
+			if !c.HasStatisticsOverride() {
+				continue
+			}
+			overrides = append(overrides, PostgresColumnStatisticsOverride{
+				DatabaseOid:                r.DatabaseOid,
+				RelationOid:                r.Oid,
+				SchemaName:                 r.SchemaName,
+				RelationName:               r.RelationName,
+				ColumnName:                 c.Name,
+				StatisticsTarget:           c.StatisticsTarget,
+				NDistinctOverride:          c.NDistinctOverride,
+				NDistinctInheritedOverride: c.NDistinctInheritedOverride,
+			})
+		}
+	}
+
+	return overrides
 }
 
 // Fillfactor - Returns the FILLFACTOR storage parameter set on the table, or the default (100)
@@ -87,3 +302,24 @@ func (i PostgresIndex) Fillfactor() int32 {
 	}
 	return -1
 }
+
+// PostgresMatviewRefresh - Tracks when a materialized view was last seen as refreshed
+//
+// Postgres does not record when a materialized view was refreshed, so we detect it across
+// snapshots instead: A regular REFRESH rewrites the relation (new relfilenode), and a
+// REFRESH ... CONCURRENTLY shows up as inserted/updated/deleted rows in the relation stats.
+type PostgresMatviewRefresh struct {
+	Relfilenode       Oid
+	ModificationCount int64 // n_tup_ins + n_tup_upd + n_tup_del
+
+	// Zero in case no refresh was observed since we started tracking this matview
+	LastRefreshDetectedAt time.Time
+}
+
+// PostgresMatviewRefreshKey - Identifies a materialized view, OIDs are only unique within a database
+type PostgresMatviewRefreshKey struct {
+	DatabaseOid Oid
+	RelationOid Oid
+}
+
+type PostgresMatviewRefreshMap map[PostgresMatviewRefreshKey]PostgresMatviewRefresh
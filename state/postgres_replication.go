@@ -6,10 +6,23 @@ import (
 	"github.com/guregu/null"
 )
 
+// StandbyLocalStatistics - Parts of a snapshot that only reflect the standby itself when collected
+// from a standby (e.g. queries run against it), as opposed to cluster-wide data that is replicated
+// from the primary (schema, table and index sizes, settings, sequences)
+var StandbyLocalStatistics = []string{
+	"statements",
+	"relation_stats",
+	"index_stats",
+	"function_stats",
+	"activity",
+	"wait_events",
+	"system",
+}
+
 type PostgresReplication struct {
 	InRecovery bool
 
-	// Data available on primary
+	// Data available on primary (on a standby CurrentXlogLocation is the last replayed location)
 	CurrentXlogLocation null.String
 	Standbys            []PostgresReplicationStandby
 
@@ -17,4 +17,13 @@ type PostgresRole struct {
 	PasswordValidUntil null.Time // Password expiry time (only used for password authentication); null if no expiration
 	Config             []string  // Role-specific defaults for run-time configuration variables
 	MemberOf           []Oid     // List of roles that this role is a member of (i.e. whose permissions it inherits)
+	ActiveConnections  int32     // Number of current connections of this role (from pg_stat_activity), counted against ConnectionLimit
+}
+
+// ConnectionLimitReached - Whether the role is using at least the given percentage of its connection limit
+func (r PostgresRole) ConnectionLimitReached(percent float64) bool {
+	if r.ConnectionLimit < 0 {
+		return false
+	}
+	return float64(r.ActiveConnections) >= float64(r.ConnectionLimit)*percent/100
 }
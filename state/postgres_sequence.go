@@ -0,0 +1,74 @@
+package state
+
+import (
+	"math"
+
+	"github.com/guregu/null"
+)
+
+// PostgresSequence - Sequence in a database, with its current value relative to the range it can use
+type PostgresSequence struct {
+	DatabaseOid  Oid
+	Oid          Oid
+	SchemaName   string
+	SequenceName string
+	DataType     string // smallint, integer or bigint (always bigint before Postgres 10)
+
+	// NULL if the sequence has not been used yet, or we don't have permission to read it
+	LastValue   null.Int
+	MinValue    int64
+	MaxValue    int64
+	IncrementBy int64
+	Cycle       bool
+
+	// Set for sequences that are owned by a table column (serial/identity columns)
+	OwnerRelationOid null.Int
+	OwnerColumnName  null.String
+	OwnerColumnType  null.String
+}
+
+// EffectiveMaxValue - The highest value the sequence can reach before inserts fail, which is limited
+// by both the sequence itself and the type of the owning column (e.g. an integer column using a bigint sequence)
+func (s PostgresSequence) EffectiveMaxValue() int64 {
+	max := s.MaxValue
+	if typeMax, ok := integerTypeMax[s.OwnerColumnType.String]; ok && s.OwnerColumnType.Valid && typeMax < max {
+		max = typeMax
+	}
+	return max
+}
+
+// EffectiveMinValue - Like EffectiveMaxValue, for descending sequences
+func (s PostgresSequence) EffectiveMinValue() int64 {
+	min := s.MinValue
+	if typeMax, ok := integerTypeMax[s.OwnerColumnType.String]; ok && s.OwnerColumnType.Valid && -typeMax-1 > min {
+		min = -typeMax - 1
+	}
+	return min
+}
+
+// PercentConsumed - How much of the sequence's usable range has been consumed (0-100)
+func (s PostgresSequence) PercentConsumed() float64 {
+	if !s.LastValue.Valid {
+		return 0
+	}
+
+	var used, total float64
+	if s.IncrementBy >= 0 {
+		used = float64(s.LastValue.Int64) - float64(s.MinValue)
+		total = float64(s.EffectiveMaxValue()) - float64(s.MinValue)
+	} else {
+		used = float64(s.MaxValue) - float64(s.LastValue.Int64)
+		total = float64(s.MaxValue) - float64(s.EffectiveMinValue())
+	}
+	if total <= 0 {
+		return 100
+	}
+
+	return math.Min(math.Max(used/total*100, 0), 100)
+}
+
+var integerTypeMax = map[string]int64{
+	"smallint": math.MaxInt16,
+	"integer":  math.MaxInt32,
+	"bigint":   math.MaxInt64,
+}
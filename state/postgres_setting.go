@@ -12,3 +12,46 @@ type PostgresSetting struct {
 	SourceFile   null.String `json:"sourcefile"`
 	SourceLine   null.String `json:"sourceline"`
 }
+
+// PostgresNonDefaultSetting - Setting that was changed from its built-in default on this server
+type PostgresNonDefaultSetting struct {
+	Name         string
+	CurrentValue null.String
+	BootValue    null.String // Built-in default
+	ResetValue   null.String // Value a RESET would return to (differs from CurrentValue if changed by ALTER ROLE/DATABASE)
+	Unit         null.String
+	Source       null.String // Where the current value came from, e.g. "configuration file" or "command line"
+	SourceFile   null.String
+	SourceLine   null.String
+}
+
+// NonDefaultSettings - Returns all settings whose value differs from the built-in default
+//
+// Settings that were only changed for our own connection ("client" and "session" sources), or that
+// are determined automatically ("default" and "override" sources) are not considered customized.
+func NonDefaultSettings(settings []PostgresSetting) []PostgresNonDefaultSetting {
+	var nonDefault []PostgresNonDefaultSetting
+
+	for _, s := range settings {
+		switch s.Source.String {
+		case "default", "override", "client", "session":
+			continue
+		}
+		if s.CurrentValue == s.BootValue && s.ResetValue == s.BootValue {
+			continue
+		}
+
+		nonDefault = append(nonDefault, PostgresNonDefaultSetting{
+			Name:         s.Name,
+			CurrentValue: s.CurrentValue,
+			BootValue:    s.BootValue,
+			ResetValue:   s.ResetValue,
+			Unit:         s.Unit,
+			Source:       s.Source,
+			SourceFile:   s.SourceFile,
+			SourceLine:   s.SourceLine,
+		})
+	}
+
+	return nonDefault
+}
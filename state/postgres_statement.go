@@ -36,8 +36,38 @@ type PostgresStatementStats struct {
 	// Postgres 9.5+
 	MinTime    null.Float // Minimum time spent in the statement, in milliseconds
 	MaxTime    null.Float // Maximum time spent in the statement, in milliseconds
-	MeanTime   null.Float // Mean time spent in the statement, in milliseconds
+	MeanTime   null.Float // Mean time spent in the statement, in milliseconds (derived from TotalTime and Calls on older versions)
 	StddevTime null.Float // Population standard deviation of time spent in the statement, in milliseconds
+
+	// Postgres 15+ (zero on older versions)
+	JitFunctions         int64   // Total number of functions JIT-compiled by the statement
+	JitGenerationTime    float64 // Total time spent by the statement on generating JIT code, in milliseconds
+	JitInliningCount     int64   // Number of times functions have been inlined
+	JitInliningTime      float64 // Total time spent by the statement on inlining functions, in milliseconds
+	JitOptimizationCount int64   // Number of times the statement has been optimized
+	JitOptimizationTime  float64 // Total time spent by the statement on optimizing, in milliseconds
+	JitEmissionCount     int64   // Number of times code has been emitted
+	JitEmissionTime      float64 // Total time spent by the statement on emitting code, in milliseconds
+	TempBlkReadTime      float64 // Total time the statement spent reading temporary file blocks, in milliseconds (if track_io_timing is enabled, otherwise zero)
+	TempBlkWriteTime     float64 // Total time the statement spent writing temporary file blocks, in milliseconds (if track_io_timing is enabled, otherwise zero)
+
+	// Postgres 17+ (zero on older versions)
+	JitDeformCount int64   // Total number of tuple deform functions JIT-compiled by the statement
+	JitDeformTime  float64 // Total time spent by the statement on JIT-compiling tuple deform functions, in milliseconds
+}
+
+// PostgresStatementSettings - Configuration of pg_stat_statements that determines which statements get tracked
+type PostgresStatementSettings struct {
+	Track null.String // Which statements are counted: "top" (top-level only), "all" (including nested statements) or "none"
+	Max   null.Int    // Maximum number of statements tracked, least-executed statements are deallocated beyond this
+}
+
+// PostgresStatementInfo - Statistics about pg_stat_statements itself (Postgres 14+)
+//
+// See also https://www.postgresql.org/docs/14/pgstatstatements.html#id-1.11.7.41.7
+type PostgresStatementInfo struct {
+	Dealloc    int64     // Total number of times entries were deallocated because more distinct statements than pg_stat_statements.max were observed
+	StatsReset null.Time // Time at which all statistics in the pg_stat_statements view were last reset
 }
 
 // PostgresStatementKey - Information that uniquely identifies a query
@@ -47,6 +77,18 @@ type PostgresStatementKey struct {
 	QueryID     int64 // Postgres 9.4+: Internal hash code, computed from the statement's parse tree
 }
 
+// OtherStatementsKey - Synthetic key that aggregates all statements beyond the configured
+// statement limit (real statements always have a database and user OID set)
+var OtherStatementsKey = PostgresStatementKey{}
+
+// OtherStatementsQuery - Query text used for the entry identified by OtherStatementsKey
+const OtherStatementsQuery = "<other statements>"
+
+// OtherStatementsDatabaseName / OtherStatementsRoleName - Placeholder references for the entry
+// identified by OtherStatementsKey, since it combines statements of all databases and roles
+const OtherStatementsDatabaseName = "<other statements>"
+const OtherStatementsRoleName = "<other statements>"
+
 type PostgresStatementStatsTimeKey struct {
 	CollectedAt           time.Time
 	CollectedIntervalSecs uint32
@@ -77,6 +119,19 @@ func (curr PostgresStatementStats) DiffSince(prev PostgresStatementStats) Diffed
 		TempBlksWritten:   curr.TempBlksWritten - prev.TempBlksWritten,
 		BlkReadTime:       curr.BlkReadTime - prev.BlkReadTime,
 		BlkWriteTime:      curr.BlkWriteTime - prev.BlkWriteTime,
+
+		JitFunctions:         curr.JitFunctions - prev.JitFunctions,
+		JitGenerationTime:    curr.JitGenerationTime - prev.JitGenerationTime,
+		JitInliningCount:     curr.JitInliningCount - prev.JitInliningCount,
+		JitInliningTime:      curr.JitInliningTime - prev.JitInliningTime,
+		JitOptimizationCount: curr.JitOptimizationCount - prev.JitOptimizationCount,
+		JitOptimizationTime:  curr.JitOptimizationTime - prev.JitOptimizationTime,
+		JitEmissionCount:     curr.JitEmissionCount - prev.JitEmissionCount,
+		JitEmissionTime:      curr.JitEmissionTime - prev.JitEmissionTime,
+		JitDeformCount:       curr.JitDeformCount - prev.JitDeformCount,
+		JitDeformTime:        curr.JitDeformTime - prev.JitDeformTime,
+		TempBlkReadTime:      curr.TempBlkReadTime - prev.TempBlkReadTime,
+		TempBlkWriteTime:     curr.TempBlkWriteTime - prev.TempBlkWriteTime,
 	}
 }
 
@@ -98,5 +153,18 @@ func (stmt DiffedPostgresStatementStats) Add(other DiffedPostgresStatementStats)
 		TempBlksWritten:   stmt.TempBlksWritten + other.TempBlksWritten,
 		BlkReadTime:       stmt.BlkReadTime + other.BlkReadTime,
 		BlkWriteTime:      stmt.BlkWriteTime + other.BlkWriteTime,
+
+		JitFunctions:         stmt.JitFunctions + other.JitFunctions,
+		JitGenerationTime:    stmt.JitGenerationTime + other.JitGenerationTime,
+		JitInliningCount:     stmt.JitInliningCount + other.JitInliningCount,
+		JitInliningTime:      stmt.JitInliningTime + other.JitInliningTime,
+		JitOptimizationCount: stmt.JitOptimizationCount + other.JitOptimizationCount,
+		JitOptimizationTime:  stmt.JitOptimizationTime + other.JitOptimizationTime,
+		JitEmissionCount:     stmt.JitEmissionCount + other.JitEmissionCount,
+		JitEmissionTime:      stmt.JitEmissionTime + other.JitEmissionTime,
+		JitDeformCount:       stmt.JitDeformCount + other.JitDeformCount,
+		JitDeformTime:        stmt.JitDeformTime + other.JitDeformTime,
+		TempBlkReadTime:      stmt.TempBlkReadTime + other.TempBlkReadTime,
+		TempBlkWriteTime:     stmt.TempBlkWriteTime + other.TempBlkWriteTime,
 	}
 }
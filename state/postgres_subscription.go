@@ -0,0 +1,75 @@
+package state
+
+import "github.com/guregu/null"
+
+// PostgresSubscriptionWorker - Logical replication worker for a subscription, from pg_stat_subscription
+//
+// There is one entry for the main apply worker of each subscription (with a NULL Pid in
+// case it is not running), plus one for each table synchronization worker (with RelationOid set).
+type PostgresSubscriptionWorker struct {
+	SubscriptionOid  Oid
+	SubscriptionName string
+	Pid              null.Int
+	RelationOid      null.Int
+
+	ReceivedLsn        null.String
+	LastMsgSendTime    null.Time
+	LastMsgReceiptTime null.Time
+	LatestEndLsn       null.String
+	LatestEndTime      null.Time
+}
+
+// PostgresSubscriptionStats - Error counters for a subscription, from pg_stat_subscription_stats (Postgres 15+)
+type PostgresSubscriptionStats struct {
+	SubscriptionName string
+	ApplyErrorCount  int64
+	SyncErrorCount   int64
+	StatsReset       null.Time
+}
+
+type PostgresSubscriptionStatsMap map[Oid]PostgresSubscriptionStats
+
+// PostgresPublication - Logical replication publication in a database, from pg_publication
+type PostgresPublication struct {
+	Oid         Oid
+	DatabaseOid Oid
+	Name        string
+	AllTables   bool
+	Insert      bool
+	Update      bool
+	Delete      bool
+	Truncate    bool // Postgres 11+
+
+	Tables []PostgresPublicationTable
+}
+
+// PostgresPublicationTable - Table that is part of a publication, from pg_publication_tables
+type PostgresPublicationTable struct {
+	SchemaName string
+	TableName  string
+}
+
+// PostgresSubscription - Logical replication subscription, from pg_subscription
+//
+// Note that this intentionally does not include the connection string (subconninfo),
+// since it may contain credentials for the publishing server.
+type PostgresSubscription struct {
+	Oid          Oid
+	DatabaseOid  Oid
+	Name         string
+	OwnerOid     Oid
+	Enabled      bool
+	SlotName     null.String
+	SyncCommit   string
+	Publications []string
+
+	Tables []PostgresSubscriptionTable
+}
+
+// PostgresSubscriptionTable - Table replicated by a subscription, from pg_subscription_rel
+//
+// The relation OID refers to the subscribing database (see PostgresSubscription.DatabaseOid).
+type PostgresSubscriptionTable struct {
+	RelationOid Oid
+	State       string // i = initialize, d = data is being copied, s = synchronized, r = ready
+}
@@ -0,0 +1,31 @@
+package state
+
+import "github.com/guregu/null"
+
+// PostgresTablespace - Tablespace on the database server, and the filesystem it lives on
+//
+// See also https://www.postgresql.org/docs/10/static/catalog-pg-tablespace.html
+type PostgresTablespace struct {
+	Oid   Oid
+	Name  string
+	Owner string
+
+	// Directory of the tablespace, empty for the built-in pg_default and pg_global tablespaces
+	// (which live in the data directory, see SystemState.DataDirectoryPartition)
+	Location string
+
+	// Total size of all objects in the tablespace (NULL if we lack permissions)
+	SizeBytes null.Int
+
+	// Filesystem usage of Location, only available when the collector runs on the database host
+	DiskTotalBytes     null.Int
+	DiskAvailableBytes null.Int
+}
+
+// DiskUsedPercent - How full the filesystem of the tablespace is, 0 if unknown
+func (t PostgresTablespace) DiskUsedPercent() float64 {
+	if !t.DiskTotalBytes.Valid || !t.DiskAvailableBytes.Valid || t.DiskTotalBytes.Int64 == 0 {
+		return 0
+	}
+	return 100 * float64(t.DiskTotalBytes.Int64-t.DiskAvailableBytes.Int64) / float64(t.DiskTotalBytes.Int64)
+}
@@ -23,3 +23,16 @@ type PostgresVacuumProgress struct {
 	MaxDeadTuples    int64
 	NumDeadTuples    int64
 }
+
+// IndexVacuumPass - Pass over the table's indexes that the vacuum is on (or finished last), zero
+// when it hasn't started vacuuming indexes yet
+//
+// Each pass happens once the dead tuple storage (max_dead_tuples, sized by maintenance_work_mem
+// or autovacuum_work_mem) is full, and has to scan every index of the table, so anything
+// beyond the first pass makes vacuums of heavily-indexed tables a lot slower.
+func (v PostgresVacuumProgress) IndexVacuumPass() int64 {
+	if v.Phase == "vacuuming indexes" {
+		return v.IndexVacuumCount + 1
+	}
+	return v.IndexVacuumCount
+}
@@ -1,5 +1,7 @@
 package state
 
+import "fmt"
+
 // Known PostgresVersion values - use these for checks in version-dependent code
 const (
 	PostgresVersion92 = 90200
@@ -8,14 +10,46 @@ const (
 	PostgresVersion95 = 90500
 	PostgresVersion96 = 90600
 	PostgresVersion10 = 100000
+	PostgresVersion11 = 110000
+	PostgresVersion12 = 120000
+	PostgresVersion13 = 130000
+	PostgresVersion14 = 140000
+	PostgresVersion15 = 150000
+	PostgresVersion16 = 160000
+	PostgresVersion17 = 170000
 
 	// MinRequiredPostgresVersion - We require PostgreSQL 9.2 or newer, since pg_stat_statements only started being usable then
 	MinRequiredPostgresVersion = PostgresVersion92
+
+	// MinRequiredPostgresVersionShort - MinRequiredPostgresVersion in the form used in messages
+	MinRequiredPostgresVersionShort = "9.2"
+)
+
+// Known PostgresVersion.Distribution values - use these to skip collectors that don't
+// work (or return meaningless data) on a managed service or fork
+const (
+	PostgresDistributionCommunity      = ""
+	PostgresDistributionAmazonRds      = "amazon_rds"
+	PostgresDistributionAmazonAurora   = "amazon_aurora"
+	PostgresDistributionGoogleCloudSQL = "google_cloudsql"
+	PostgresDistributionAzure          = "azure"
+	PostgresDistributionEnterpriseDB   = "enterprisedb"
 )
 
 // PostgresVersion - Identifying information about the PostgreSQL server version and build details
 type PostgresVersion struct {
-	Full    string `json:"full"`    // e.g. "PostgreSQL 9.5.1 on x86_64-pc-linux-gnu, compiled by gcc (Debian 4.9.2-10) 4.9.2, 64-bit"
-	Short   string `json:"short"`   // e.g. "9.5.1"
-	Numeric int    `json:"numeric"` // e.g. 90501
+	Full         string `json:"full"`         // e.g. "PostgreSQL 9.5.1 on x86_64-pc-linux-gnu, compiled by gcc (Debian 4.9.2-10) 4.9.2, 64-bit"
+	Short        string `json:"short"`        // e.g. "9.5.1"
+	Numeric      int    `json:"numeric"`      // e.g. 90501
+	Distribution string `json:"distribution"` // e.g. "amazon_aurora", see PostgresDistribution constants
+}
+
+// CheckSupported - Returns an error when the server is older than the minimum version
+// we support, since we'd otherwise fail later on with confusing errors about missing
+// catalog views or columns
+func (version PostgresVersion) CheckSupported() error {
+	if version.Numeric < MinRequiredPostgresVersion {
+		return fmt.Errorf("Postgres %s is not supported, minimum is %s", version.Short, MinRequiredPostgresVersionShort)
+	}
+	return nil
 }
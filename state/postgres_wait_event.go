@@ -0,0 +1,83 @@
+package state
+
+import "sync"
+
+// PostgresWaitEventKey - Identifies a wait event, with both fields empty for active
+// backends that are not waiting (i.e. running on CPU)
+type PostgresWaitEventKey struct {
+	WaitEventType string
+	WaitEvent     string
+}
+
+// PostgresWaitEventHistogram - Number of times active backends were seen in each wait event
+type PostgresWaitEventHistogram map[PostgresWaitEventKey]int64
+
+// PostgresWaitSamplingKey - Identifies a wait event for a specific query, as tracked by
+// the pg_wait_sampling extension (QueryID is 0 for backends not running a query)
+type PostgresWaitSamplingKey struct {
+	QueryID       int64
+	WaitEventType string
+	WaitEvent     string
+}
+
+// PostgresWaitSamplingProfile - Number of samples in each wait event and query, from pg_wait_sampling_profile
+//
+// In PersistedState these are the cumulative counters of the extension, in DiffState the
+// counts since the previous snapshot.
+type PostgresWaitSamplingProfile map[PostgresWaitSamplingKey]int64
+
+// PostgresActiveSessionKey - Identifies what a non-idle backend was doing when sampled (QueryID
+// is 0 before Postgres 14, when compute_query_id is off, or for backends not running a query)
+type PostgresActiveSessionKey struct {
+	State         string
+	QueryID       int64
+	WaitEventType string
+	WaitEvent     string
+}
+
+// PostgresActiveSessionHistogram - Number of times non-idle backends were seen with each state, query and wait event
+type PostgresActiveSessionHistogram map[PostgresActiveSessionKey]int64
+
+// WaitEventSampler - Accumulates wait event samples taken in between full snapshots
+//
+// This is shared between the sampling goroutine and the full snapshot runs, and is
+// therefore safe for concurrent use.
+type WaitEventSampler struct {
+	mutex       sync.Mutex
+	histogram   PostgresWaitEventHistogram
+	sessions    PostgresActiveSessionHistogram
+	sampleCount int64
+}
+
+// Add - Records one sample of pg_stat_activity
+func (s *WaitEventSampler) Add(sample PostgresActiveSessionHistogram) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.histogram == nil {
+		s.histogram = make(PostgresWaitEventHistogram)
+		s.sessions = make(PostgresActiveSessionHistogram)
+	}
+	for key, count := range sample {
+		s.sessions[key] += count
+		if key.State == "active" {
+			s.histogram[PostgresWaitEventKey{WaitEventType: key.WaitEventType, WaitEvent: key.WaitEvent}] += count
+		}
+	}
+	s.sampleCount++
+}
+
+// Drain - Returns all samples recorded since the last call, and starts over
+func (s *WaitEventSampler) Drain() (histogram PostgresWaitEventHistogram, sessions PostgresActiveSessionHistogram, sampleCount int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	histogram = s.histogram
+	sessions = s.sessions
+	sampleCount = s.sampleCount
+	s.histogram = nil
+	s.sessions = nil
+	s.sampleCount = 0
+
+	return
+}
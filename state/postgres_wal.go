@@ -0,0 +1,18 @@
+package state
+
+import "github.com/guregu/null"
+
+// PostgresWalPosition - Location in the WAL stream at the time of the snapshot
+type PostgresWalPosition struct {
+	// Byte offset of the location (i.e. the LSN as a number), NULL if unknown
+	Bytes null.Int
+
+	// On standbys this is the replay location, instead of the location WAL gets inserted at
+	Replay bool
+}
+
+// DiffedWalRate - How fast WAL was generated (or replayed, on standbys) since the last run
+type DiffedWalRate struct {
+	BytesPerSecond null.Float
+	Replay         bool
+}
@@ -0,0 +1,30 @@
+package state
+
+// PostgresWorkerUtilization - Background and parallel workers in use, compared to their limits (Postgres 10+)
+//
+// Parallel workers come out of both pools, so queries silently run without parallelism once
+// either max_parallel_workers or max_worker_processes is exhausted.
+type PostgresWorkerUtilization struct {
+	MaxWorkerProcesses          int64
+	MaxParallelWorkers          int64
+	MaxParallelWorkersPerGather int64
+
+	BackgroundWorkers int64 // All current background workers, including parallel workers
+	ParallelWorkers   int64
+}
+
+// WorkerProcessesUtilization - Share of max_worker_processes in use (0 to 1)
+func (u PostgresWorkerUtilization) WorkerProcessesUtilization() float64 {
+	if u.MaxWorkerProcesses <= 0 {
+		return 0
+	}
+	return float64(u.BackgroundWorkers) / float64(u.MaxWorkerProcesses)
+}
+
+// ParallelWorkersUtilization - Share of max_parallel_workers in use (0 to 1)
+func (u PostgresWorkerUtilization) ParallelWorkersUtilization() float64 {
+	if u.MaxParallelWorkers <= 0 {
+		return 0
+	}
+	return float64(u.ParallelWorkers) / float64(u.MaxParallelWorkers)
+}
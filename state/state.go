@@ -1,6 +1,7 @@
 package state
 
 import (
+	"database/sql"
 	"time"
 
 	raven "github.com/getsentry/raven-go"
@@ -16,9 +17,59 @@ type PersistedState struct {
 	IndexStats     PostgresIndexStatsMap
 	FunctionStats  PostgresFunctionStatsMap
 
+	StatementInfo PostgresStatementInfo
+
+	// Kept across runs so we can tell when statistics were tracked differently in the previous run
+	StatementSettings PostgresStatementSettings
+
+	SubscriptionWorkers []PostgresSubscriptionWorker
+	SubscriptionStats   PostgresSubscriptionStatsMap
+
+	// Only set when pgbouncer_url is configured
+	Pgbouncer PgbouncerState
+
+	// Only set when the citus extension is installed
+	Citus CitusState
+
 	Relations []PostgresRelation
 	Functions []PostgresFunction
 
+	// Extensions installed in each database we collected schema information for
+	Extensions []PostgresExtension
+
+	Sequences []PostgresSequence
+
+	// Invalid indices and NOT VALID constraints, derived from Relations
+	BrokenSchemaObjects []PostgresBrokenSchemaObject
+
+	// Foreign keys without an index on the referencing columns, derived from Relations
+	UnindexedForeignKeys []PostgresUnindexedForeignKey
+
+	// Columns with manual statistics settings on large tables, derived from Relations
+	ColumnStatisticsOverrides []PostgresColumnStatisticsOverride
+
+	// Tables without planner statistics, derived from Relations and RelationStats
+	UnanalyzedRelations []PostgresUnanalyzedRelation
+
+	// All-visible and all-frozen pages of each table (only collected together with RelationStats)
+	RelationVisibility PostgresRelationVisibilityMap
+
+	// Heavily updated tables with a low HOT update ratio, derived from Relations and RelationStats
+	LowHotUpdateRelations []PostgresLowHotUpdateRelation
+
+	// Correlation of indexed columns with the physical row order (only collected together with
+	// RelationStats, when we can read pg_stats)
+	ColumnCorrelations PostgresColumnCorrelationMap
+
+	// Indexes on large tables whose leading column has a low correlation, derived from Relations,
+	// RelationStats and ColumnCorrelations
+	LowCorrelationIndices []PostgresLowCorrelationIndex
+
+	// Only set for databases that have the timescaledb extension installed
+	Hypertables []PostgresHypertable
+
+	MatviewRefreshes PostgresMatviewRefreshMap
+
 	System         SystemState
 	CollectorStats CollectorStats
 
@@ -32,8 +83,51 @@ type PersistedState struct {
 	// reset afterwards.
 	StatementTextCounter int
 
+	// Statements matching the exclude_statements patterns, remembered for runs without statement text
+	ExcludedStatements PostgresStatementKeySet
+
 	// All statement stats that have not been identified (will be cleared by the next snapshot with statement text)
 	UnidentifiedStatementStats HistoricStatementStatsMap
+
+	// Wait events of active backends, sampled from pg_stat_activity since the previous
+	// snapshot (only set when wait event sampling is enabled), or from pg_wait_sampling_profile
+	WaitEventHistogram   PostgresWaitEventHistogram
+	WaitEventSampleCount int64
+
+	// Non-idle backends by state, query and wait event from the same samples as WaitEventHistogram
+	ActiveSessionHistogram PostgresActiveSessionHistogram
+
+	// Only set when the pg_wait_sampling extension is installed, in which case it
+	// replaces our own sampling of wait events (see WaitEventHistogram)
+	WaitSamplingProfile PostgresWaitSamplingProfile
+
+	// Number of current backends by type, e.g. to tell client connections and background workers apart
+	BackendTypeCounts PostgresBackendTypeCounts
+
+	// Background and parallel workers compared to their limits, nil if not collected
+	WorkerUtilization *PostgresWorkerUtilization
+
+	// Sessions idle in transaction that hold locks other backends are waiting on
+	IdleInTransactionBlockers []PostgresIdleInTransactionBlocker
+
+	// Granted advisory locks (kept in the state, so we know how long they have been held)
+	AdvisoryLocks []PostgresAdvisoryLock
+
+	// Sizes of all databases, derived from TransientState.Databases
+	DatabaseSizes PostgresDatabaseSizeMap
+
+	Tablespaces []PostgresTablespace
+
+	// Only set on Postgres 10 and newer, when the collector user may read pg_hba_file_rules
+	HbaRules []PostgresHbaRule
+
+	WalPosition PostgresWalPosition
+
+	// Only set on Postgres 9.4 and newer
+	ArchiverStats *PostgresArchiverStats
+
+	// Only set when log_location is configured, see LogFilePositionTracker
+	LogFilePositions LogFilePositionMap
 }
 
 // TransientState - State thats only used within a collector run (and not needed for diffs)
@@ -44,10 +138,21 @@ type TransientState struct {
 	Roles     []PostgresRole
 	Databases []PostgresDatabase
 
+	// Collectors that were skipped since they ran past the collection deadline
+	SkippedCollectors []string
+
+	// True if the server is a standby, in which case we avoid any write operations
+	// (e.g. pg_stat_statements_reset() or CREATE EXTENSION)
+	InRecovery bool
+
 	HasStatementText       bool
+	StatementRoleNames     StatementRoleNames
 	Statements             PostgresStatementMap
 	HistoricStatementStats HistoricStatementStatsMap
 
+	// Latency distribution of statements with enough calls, derived from StatementStats (Postgres 9.5+)
+	StatementLatencies PostgresStatementLatencyMap
+
 	// This is a new zero value that was recorded after a pg_stat_statements_reset(),
 	// in order to enable the next snapshot to be able to diff against something
 	ResetStatementStats PostgresStatementStatsMap
@@ -55,6 +160,17 @@ type TransientState struct {
 	Replication PostgresReplication
 	Settings    []PostgresSetting
 
+	// Settings changed from their built-in defaults, derived from Settings
+	NonDefaultSettings []PostgresNonDefaultSetting
+
+	// Memory settings compared to the system memory, derived from Settings and PersistedState.System
+	// (only set when both are known)
+	MemorySettings *PostgresMemorySettings
+
+	// Logical replication topology (publications are collected for each database we connect to)
+	Publications  []PostgresPublication
+	Subscriptions []PostgresSubscription
+
 	Version PostgresVersion
 
 	SentryClient *raven.Client
@@ -66,20 +182,39 @@ type DiffState struct {
 	RelationStats  DiffedPostgresRelationStatsMap
 	IndexStats     DiffedPostgresIndexStatsMap
 	FunctionStats  DiffedPostgresFunctionStatsMap
+	DatabaseSizes  DiffedDatabaseSizeMap
+	WalRate        DiffedWalRate
+	ArchiverStats  *DiffedArchiverStats
+
+	// Derived from StatementStats (including statements that get summed up by max_statements)
+	StatementIORankings StatementIORankings
 
 	SystemCPUStats     DiffedSystemCPUStatsMap
 	SystemNetworkStats DiffedNetworkStatsMap
 	SystemDiskStats    DiffedDiskStatsMap
 
 	CollectorStats DiffedCollectorStats
+
+	WaitSamplingProfile PostgresWaitSamplingProfile
+
+	// True if there was no recent previous state, and the statistics are cumulative values
+	// (since the last stats reset) instead of a diff
+	Baseline bool
 }
 
 // StateOnDiskFormatVersion - Increment this when an old state preserved to disk should be ignored
-const StateOnDiskFormatVersion = 1
+//
+// Version 2 is the streamed format that allows decoding only the states of the servers being monitored
+const StateOnDiskFormatVersion = 2
+
+// StateOnDiskLegacyFormatVersion - Version of state files that were a single gob-encoded StateOnDisk
+const StateOnDiskLegacyFormatVersion = 1
 
+// StateOnDisk - Legacy state file contents, still read so upgrading doesn't lose the previous state
 type StateOnDisk struct {
 	FormatVersion uint
 
+	// Keyed by the full API key, use util.MaskAPIKey when referring to a key in log output
 	PrevStateByAPIKey map[string]PersistedState
 }
 
@@ -102,28 +237,59 @@ type CollectionOpts struct {
 	SubmitCollectedData bool
 	TestRun             bool
 	TestReport          string
+	SelfTest            bool
 	TestRunLogs         bool
 	DebugLogs           bool
+	RunOnce             bool
+
+	// Only collect schema information and settings, without any statistics (and therefore without a diff
+	// against the previous state), for a cheap inventory snapshot
+	SchemaOnly bool
+
+	// Set while the server's circuit breaker is open, skips expensive collectors (see circuit_breaker_* settings)
+	ReducedCollection bool
+
+	// Upload snapshots spooled by output_type = file from this directory, instead of collecting
+	UploadSnapshotsDirectory string
 
 	StateFilename    string
 	WriteStateUpdate bool
 	ForceEmptyGrant  bool
 }
 
+// ForServer - Returns the effective collection options for a server, i.e. the global options
+// (from the command line) with anything that the server's config disables turned off
+func (opts CollectionOpts) ForServer(config config.ServerConfig) CollectionOpts {
+	opts.CollectPostgresRelations = opts.CollectPostgresRelations && !config.NoPostgresRelations
+	opts.CollectPostgresSettings = opts.CollectPostgresSettings && !config.NoPostgresSettings
+	opts.CollectPostgresLocks = opts.CollectPostgresLocks && !config.NoPostgresLocks
+	opts.CollectPostgresFunctions = opts.CollectPostgresFunctions && !config.NoPostgresFunctions
+	opts.CollectPostgresBloat = opts.CollectPostgresBloat && !config.NoPostgresBloat
+	opts.CollectPostgresViews = opts.CollectPostgresViews && !config.NoPostgresViews
+	opts.CollectExplain = opts.CollectExplain && !config.NoExplain
+	opts.CollectSystemInformation = opts.CollectSystemInformation && !config.NoSystemInformation
+	return opts
+}
+
 type GrantConfig struct {
 	ServerID  string `json:"server_id"`
 	SentryDsn string `json:"sentry_dsn"`
 
 	Features GrantFeatures `json:"features"`
+
+	// Newest released collector version, and whether the API recommends upgrading this collector
+	// (e.g. because of a known issue in this version)
+	LatestCollectorVersion      string `json:"latest_collector_version"`
+	CollectorUpgradeRecommended bool   `json:"collector_upgrade_recommended"`
 }
 
 type GrantFeatures struct {
 	Logs    bool `json:"logs"`
 	Explain bool `json:"explain"`
 
-	StatementTextFrequency  int   `json:"statement_text_frequency"`
-	StatementResetFrequency int   `json:"statement_reset_frequency"`
-	StatementTimeoutMs      int32 `json:"statement_timeout_ms"` // Statement timeout for all SQL statements sent to the database (defaults to 30s)
+	StatementTextFrequency  int    `json:"statement_text_frequency"`
+	StatementResetFrequency int    `json:"statement_reset_frequency"`
+	StatementTimeoutMs      *int32 `json:"statement_timeout_ms"` // Statement timeout for all SQL statements sent to the database (30 seconds when not set, 0 or less disables the timeout)
 }
 
 type Grant struct {
@@ -132,6 +298,19 @@ type Grant struct {
 	S3URL    string            `json:"s3_url"`
 	S3Fields map[string]string `json:"s3_fields"`
 	LocalDir string            `json:"local_dir"`
+
+	// Only set when the API supports multipart uploads (see GetDefaultGrant), used for
+	// snapshots over the s3_multipart_threshold_mb setting instead of S3URL/S3Fields
+	S3Multipart *GrantS3Multipart `json:"s3_multipart"`
+}
+
+// GrantS3Multipart - Presigned requests for an S3 multipart upload that the API has already created
+type GrantS3Multipart struct {
+	Key           string   `json:"key"`
+	PartURLs      []string `json:"part_urls"`       // Presigned UploadPart URLs for part 1, 2, ...
+	PartSizeBytes int64    `json:"part_size_bytes"` // Size of each part (except the last one)
+	CompleteURL   string   `json:"complete_url"`    // Presigned CompleteMultipartUpload URL
+	AbortURL      string   `json:"abort_url"`       // Presigned AbortMultipartUpload URL
 }
 
 func (g Grant) S3() GrantS3 {
@@ -148,4 +327,18 @@ type Server struct {
 	PrevState        PersistedState
 	RequestedSslMode string
 	Grant            Grant
+
+	// Set when wait event sampling is enabled for this server
+	WaitEventSampler *WaitEventSampler
+
+	// Set when log_location is configured for this server
+	LogFilePositions *LogFilePositionTracker
+
+	// Set when log collection is enabled for this server
+	StatementTexts *StatementTextCache
+
+	// Set when embedding the collector with an already established connection (see
+	// runner.RunCollectionWithConnection), which is then used instead of connecting based on
+	// Config - only the database of that connection gets collected, and it's never closed by us
+	Connection *sql.DB
 }
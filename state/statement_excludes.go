@@ -0,0 +1,80 @@
+package state
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PostgresStatementKeySet - Set of statements, e.g. those that are excluded from collection
+type PostgresStatementKeySet map[PostgresStatementKey]bool
+
+// StatementExcludes - Statements that should not be collected, based on the exclude_statements patterns
+//
+// The patterns match the query text, which we only get every few runs. In between we rely on
+// the keys of statements that matched when we last had the text.
+type StatementExcludes struct {
+	Patterns []*regexp.Regexp
+	Keys     PostgresStatementKeySet
+
+	// Databases that are not monitored (see db_deny_names), whose statements are excluded as well
+	DatabaseOids map[Oid]bool
+
+	// Roles whose statements are always excluded (see exclude_statements_users)
+	UserOids map[Oid]bool
+}
+
+// Excluded - Whether a statement should be excluded, the query text is only used when hasText is true
+func (e StatementExcludes) Excluded(key PostgresStatementKey, query string, hasText bool) bool {
+	if e.DatabaseOids[key.DatabaseOid] || e.UserOids[key.UserOid] {
+		return true
+	}
+	if len(e.Patterns) == 0 {
+		return false
+	}
+	if !hasText {
+		return e.Keys[key]
+	}
+	for _, re := range e.Patterns {
+		if re.MatchString(query) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextKeys - Returns the set to record this run's excluded statements in: with the query text
+// they get determined anew, without it the keys from the last run that had the text carry over
+func (e StatementExcludes) NextKeys(hasText bool) PostgresStatementKeySet {
+	if hasText || len(e.Patterns) == 0 || e.Keys == nil {
+		return make(PostgresStatementKeySet)
+	}
+	return e.Keys
+}
+
+// ExcludedUserOids - Resolves the exclude_statements_users entries (role names or OIDs) against
+// the roles, and returns the names that don't match any role
+func ExcludedUserOids(users []string, roles []PostgresRole) (oids map[Oid]bool, unknown []string) {
+	oids = make(map[Oid]bool)
+	for _, user := range users {
+		user = strings.TrimSpace(user)
+		if user == "" {
+			continue
+		}
+		if oid, err := strconv.ParseUint(user, 10, 32); err == nil {
+			oids[Oid(oid)] = true
+			continue
+		}
+		found := false
+		for _, role := range roles {
+			if role.Name == user {
+				oids[role.Oid] = true
+				found = true
+			}
+		}
+		if !found {
+			unknown = append(unknown, user)
+		}
+	}
+	return
+}
@@ -0,0 +1,93 @@
+package state_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/pganalyze/collector/state"
+)
+
+var healthCheckKey = state.PostgresStatementKey{DatabaseOid: 1, UserOid: 10, QueryID: 1}
+var appKey = state.PostgresStatementKey{DatabaseOid: 1, UserOid: 10, QueryID: 2}
+
+var excludedTests = []struct {
+	name     string
+	excludes state.StatementExcludes
+	key      state.PostgresStatementKey
+	query    string
+	hasText  bool
+	expected bool
+}{
+	{
+		"no patterns",
+		state.StatementExcludes{},
+		healthCheckKey, "SELECT 1", true, false,
+	},
+	{
+		"pattern matches query text",
+		state.StatementExcludes{Patterns: []*regexp.Regexp{regexp.MustCompile("^SELECT 1$")}},
+		healthCheckKey, "SELECT 1", true, true,
+	},
+	{
+		"pattern doesn't match query text",
+		state.StatementExcludes{Patterns: []*regexp.Regexp{regexp.MustCompile("^SELECT 1$")}},
+		appKey, "SELECT * FROM orders WHERE id = $1", true, false,
+	},
+	{
+		"without text the previously excluded keys are used",
+		state.StatementExcludes{Patterns: []*regexp.Regexp{regexp.MustCompile("^SELECT 1$")}, Keys: state.PostgresStatementKeySet{healthCheckKey: true}},
+		healthCheckKey, "", false, true,
+	},
+	{
+		"without text other keys are kept",
+		state.StatementExcludes{Patterns: []*regexp.Regexp{regexp.MustCompile("^SELECT 1$")}, Keys: state.PostgresStatementKeySet{healthCheckKey: true}},
+		appKey, "", false, false,
+	},
+	{
+		"excluded user",
+		state.StatementExcludes{UserOids: map[state.Oid]bool{10: true}},
+		appKey, "", false, true,
+	},
+	{
+		"excluded database",
+		state.StatementExcludes{DatabaseOids: map[state.Oid]bool{1: true}},
+		appKey, "SELECT * FROM orders WHERE id = $1", true, true,
+	},
+}
+
+func TestStatementExcludesExcluded(t *testing.T) {
+	for _, test := range excludedTests {
+		actual := test.excludes.Excluded(test.key, test.query, test.hasText)
+		if actual != test.expected {
+			t.Errorf("%s: expected %v, actual %v", test.name, test.expected, actual)
+		}
+	}
+}
+
+// Simulates the runs of GetStatements, which only gets the query text every few runs
+func TestStatementExcludesKeysCarryOver(t *testing.T) {
+	queries := map[state.PostgresStatementKey]string{healthCheckKey: "SELECT 1", appKey: "SELECT * FROM orders WHERE id = $1"}
+	excludes := state.StatementExcludes{Patterns: []*regexp.Regexp{regexp.MustCompile("^SELECT 1$")}}
+
+	for idx, hasText := range []bool{true, false, false, true, false} {
+		keys := excludes.NextKeys(hasText)
+		for key, query := range queries {
+			if !hasText {
+				query = ""
+			}
+			if excludes.Excluded(key, query, hasText) {
+				keys[key] = true
+			}
+		}
+		if len(keys) != 1 || !keys[healthCheckKey] {
+			t.Errorf("Run %d (text: %v): expected only the health check to be excluded, actual %v", idx, hasText, keys)
+		}
+		excludes.Keys = keys
+	}
+
+	// Once the pattern no longer matches, the next run with text stops excluding the statement
+	excludes.Patterns = []*regexp.Regexp{regexp.MustCompile("^SELECT 2$")}
+	if keys := excludes.NextKeys(true); len(keys) != 0 {
+		t.Errorf("Expected excluded keys to be determined anew with text, actual %v", keys)
+	}
+}
@@ -0,0 +1,56 @@
+package state
+
+// Statements need at least this many calls before their latency distribution is characterized,
+// otherwise a single slow first execution (e.g. with a cold cache) looks like an outlier
+const StatementLatencyMinCalls = 100
+
+// StatementLatencyOutlierFactor - Statements whose max_time is at least this many times their
+// mean_time are flagged as having occasional slow outliers
+const StatementLatencyOutlierFactor = 10
+
+// StatementLatencyOutlierMinMaxTime - Outliers below this (in milliseconds) are not flagged, a
+// fast query being occasionally slightly less fast isn't actionable
+const StatementLatencyOutlierMinMaxTime = 100.0
+
+// PostgresStatementLatency - Coarse characterization of a statement's latency distribution,
+// derived from the cumulative min/max/mean/stddev columns (Postgres 9.5+)
+type PostgresStatementLatency struct {
+	CoefficientOfVariation float64 // stddev_time / mean_time, above 1 typically means a skewed or bimodal distribution
+	MaxToMeanRatio         float64 // max_time / mean_time
+	Outlier                bool    // Usually fast, but with occasional executions that are much slower
+}
+
+// PostgresStatementLatencyMap - Latency characterization by statement, only for statements with enough calls
+type PostgresStatementLatencyMap map[PostgresStatementKey]PostgresStatementLatency
+
+// StatementLatencies - Characterizes the latency distribution of the statements that have the
+// native timing columns and at least StatementLatencyMinCalls calls
+func StatementLatencies(stats PostgresStatementStatsMap) PostgresStatementLatencyMap {
+	latencies := make(PostgresStatementLatencyMap)
+
+	for key, s := range stats {
+		if s.Calls < StatementLatencyMinCalls || !s.MaxTime.Valid || !s.StddevTime.Valid || !s.MeanTime.Valid || s.MeanTime.Float64 <= 0 {
+			continue
+		}
+
+		latency := PostgresStatementLatency{
+			CoefficientOfVariation: s.StddevTime.Float64 / s.MeanTime.Float64,
+			MaxToMeanRatio:         s.MaxTime.Float64 / s.MeanTime.Float64,
+		}
+		latency.Outlier = latency.MaxToMeanRatio >= StatementLatencyOutlierFactor && s.MaxTime.Float64 >= StatementLatencyOutlierMinMaxTime
+		latencies[key] = latency
+	}
+
+	return latencies
+}
+
+// Outliers - Returns the number of statements flagged as having slow outliers
+func (m PostgresStatementLatencyMap) Outliers() int {
+	count := 0
+	for _, latency := range m {
+		if latency.Outlier {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,31 @@
+package state
+
+// RankedStatement - Statement and its value for the metric a StatementRanking is ordered by
+type RankedStatement struct {
+	Key   PostgresStatementKey
+	Value int64
+}
+
+// StatementRanking - Statements with the highest values of one metric since the last run, highest first
+type StatementRanking []RankedStatement
+
+// StatementIORankings - Statements causing the most I/O since the last run, which are often not
+// the ones ranking highest by total time
+type StatementIORankings struct {
+	// Shared blocks read from outside of shared_buffers (OS page cache or disk)
+	SharedBlksRead StatementRanking
+
+	// Temp blocks read and written, i.e. sorts and hashes spilling to disk because of a too small work_mem
+	TempBlks StatementRanking
+}
+
+// Keys - All statements that are part of any of the rankings
+func (r StatementIORankings) Keys() PostgresStatementKeySet {
+	keys := make(PostgresStatementKeySet)
+	for _, ranking := range []StatementRanking{r.SharedBlksRead, r.TempBlks} {
+		for _, s := range ranking {
+			keys[s.Key] = true
+		}
+	}
+	return keys
+}
@@ -0,0 +1,49 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/guregu/null"
+)
+
+// StatementRoleNames - Names of the roles that ran the statements in pg_stat_statements, by OID
+//
+// pg_stat_statements keeps the entries of roles that got dropped (until they are deallocated,
+// or the statistics get reset), these have no name here.
+type StatementRoleNames map[Oid]null.String
+
+// ResolveStatementRoles - Looks up the role name for the user of each statement
+func ResolveStatementRoles(stats PostgresStatementStatsMap, roles []PostgresRole) StatementRoleNames {
+	roleNames := make(map[Oid]string, len(roles))
+	for _, role := range roles {
+		roleNames[role.Oid] = role.Name
+	}
+
+	names := make(StatementRoleNames)
+	for key := range stats {
+		if key.UserOid == 0 {
+			continue
+		}
+		if name, ok := roleNames[key.UserOid]; ok {
+			names[key.UserOid] = null.StringFrom(name)
+		} else {
+			names[key.UserOid] = null.String{}
+		}
+	}
+	return names
+}
+
+// DroppedRoles - OIDs of statement users that no longer exist
+func (n StatementRoleNames) DroppedRoles() (oids []Oid) {
+	for oid, name := range n {
+		if !name.Valid {
+			oids = append(oids, oid)
+		}
+	}
+	return
+}
+
+// DroppedRoleName - Placeholder name used for statements of a role that no longer exists
+func DroppedRoleName(oid Oid) string {
+	return fmt.Sprintf("<dropped role %d>", oid)
+}
@@ -0,0 +1,41 @@
+package state
+
+import "sync"
+
+// StatementTextsByQueryID - Normalized statement texts from pg_stat_statements, by queryid
+type StatementTextsByQueryID map[int64]string
+
+// StatementTextCache - Remembers the statement texts of the last full snapshot that
+// collected them, so log lines that carry a queryid (jsonlog on Postgres 15+) can
+// be associated with their pg_stat_statements entry
+//
+// This is shared between the full snapshot runs (which update it) and the log
+// snapshot runs (which read it), and is therefore safe for concurrent use.
+type StatementTextCache struct {
+	mutex sync.Mutex
+	texts StatementTextsByQueryID
+}
+
+// Update - Replaces the cached texts with those of the given statements
+func (c *StatementTextCache) Update(statements PostgresStatementMap) {
+	texts := make(StatementTextsByQueryID, len(statements))
+	for key, statement := range statements {
+		if key.QueryID != 0 && statement.NormalizedQuery != "" {
+			texts[key.QueryID] = statement.NormalizedQuery
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.texts = texts
+}
+
+// Lookup - Returns the normalized statement text for the given queryid, if known
+func (c *StatementTextCache) Lookup(queryID int64) (text string, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	text, ok = c.texts[queryID]
+	return
+}
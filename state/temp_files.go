@@ -0,0 +1,23 @@
+package state
+
+// TempFileStatsKey - Statement that created temp files, identified like statements in the snapshot
+// (database, role and query fingerprint), so it can be matched up with its temp block counters
+type TempFileStatsKey struct {
+	Database    string
+	Username    string
+	Fingerprint [21]byte
+}
+
+// TempFileStats - Temp files logged for a statement (requires log_temp_files), most often
+// caused by sorts or hashes that don't fit into work_mem
+type TempFileStats struct {
+	NormalizedQuery string // Empty if the log didn't include the statement
+	QueryID         int64  // Only known for jsonlog on Postgres 15+
+
+	Count      int64
+	TotalBytes int64
+	MaxBytes   int64
+}
+
+// TempFileStatsMap - Temp file usage per statement, for the log lines in a LogState
+type TempFileStatsMap map[TempFileStatsKey]TempFileStats
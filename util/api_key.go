@@ -0,0 +1,28 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// MaskAPIKey - Short, non-reversible fingerprint of an API key, to be used instead of the
+// key itself anywhere it would end up in log output or error messages
+func MaskAPIKey(apiKey string) string {
+	if apiKey == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// RedactAPIKey - Replaces all occurrences of the API key in text (e.g. an error response
+// from the pganalyze API) with its masked fingerprint
+func RedactAPIKey(text string, apiKey string) string {
+	if apiKey == "" {
+		return text
+	}
+
+	return strings.Replace(text, apiKey, "[api key "+MaskAPIKey(apiKey)+"]", -1)
+}
@@ -0,0 +1,52 @@
+package util_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pganalyze/collector/util"
+)
+
+var redactAPIKeyTests = []struct {
+	text     string
+	apiKey   string
+	expected string
+}{
+	{
+		"Invalid API key",
+		"SECRETKEY1234567",
+		"Invalid API key",
+	},
+	{
+		"Invalid API key SECRETKEY1234567 (expected SECRETKEY1234567)",
+		"SECRETKEY1234567",
+		"Invalid API key [api key " + util.MaskAPIKey("SECRETKEY1234567") + "] (expected [api key " + util.MaskAPIKey("SECRETKEY1234567") + "])",
+	},
+	{
+		"No API key configured",
+		"",
+		"No API key configured",
+	},
+}
+
+func TestRedactAPIKey(t *testing.T) {
+	for _, test := range redactAPIKeyTests {
+		actual := util.RedactAPIKey(test.text, test.apiKey)
+		if actual != test.expected {
+			t.Errorf("\nExpected: %s\n actual: %s", test.expected, actual)
+		}
+		if test.apiKey != "" && strings.Contains(actual, test.apiKey) {
+			t.Errorf("\nAPI key was not redacted: %s", actual)
+		}
+	}
+}
+
+func TestMaskAPIKey(t *testing.T) {
+	masked := util.MaskAPIKey("SECRETKEY1234567")
+	if len(masked) != 12 || strings.Contains("SECRETKEY1234567", masked) {
+		t.Errorf("\nUnexpected masked API key: %s", masked)
+	}
+	if util.MaskAPIKey("") != "" {
+		t.Errorf("\nExpected empty API key to stay empty")
+	}
+}
@@ -0,0 +1,50 @@
+package awsutil
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/pganalyze/collector/config"
+)
+
+// rdsAuthTokenLifetime - RDS refuses IAM auth tokens for new connections after 15 minutes
+const rdsAuthTokenLifetime = 15 * time.Minute
+
+// GenerateRdsAuthToken - Generates a short-lived token that can be used instead of a password
+// for connecting to RDS/Aurora with IAM database authentication
+//
+// This is a pre-signed "connect" request for the database endpoint and user, see
+// https://docs.aws.amazon.com/AmazonRDS/latest/UserGuide/UsingWithRDS.IAMDBAuth.html
+func GenerateRdsAuthToken(config config.ServerConfig) (string, error) {
+	dbHost := config.GetDbHost()
+	if dbHost == "" {
+		return "", fmt.Errorf("db_host is required for IAM authentication")
+	}
+	dbPort := config.GetDbPort()
+	if dbPort == 0 {
+		dbPort = 5432
+	}
+	dbUsername := config.GetDbUsername()
+	if dbUsername == "" {
+		return "", fmt.Errorf("db_username is required for IAM authentication")
+	}
+
+	endpoint := fmt.Sprintf("%s:%d", dbHost, dbPort)
+	req, err := http.NewRequest("GET", "https://"+endpoint+"/?Action=connect&DBUser="+url.QueryEscape(dbUsername), nil)
+	if err != nil {
+		return "", err
+	}
+
+	sess := GetAwsSession(config)
+	signer := v4.NewSigner(sess.Config.Credentials)
+	_, err = signer.Presign(req, nil, "rds-db", config.AwsRegion, rdsAuthTokenLifetime, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimPrefix(req.URL.String(), "https://"), nil
+}
@@ -0,0 +1,70 @@
+package util
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// HTTPRetryPolicy - Controls how often, and with which delay, HTTP requests are retried
+type HTTPRetryPolicy struct {
+	MaxAttempts int           // Including the initial request
+	BaseDelay   time.Duration // Delay before the first retry, doubled for every retry after that
+	MaxDelay    time.Duration
+}
+
+// DefaultHTTPRetryPolicy - Rides out brief API or S3 issues, without hammering the service
+var DefaultHTTPRetryPolicy = HTTPRetryPolicy{MaxAttempts: 4, BaseDelay: 1 * time.Second, MaxDelay: 30 * time.Second}
+
+// DoWithRetry - Runs an HTTP request, retrying with exponential backoff and jitter on network
+// errors (including timeouts), as well as 5xx and 429 responses
+//
+// All other responses (e.g. 4xx due to an invalid API key) are returned to the caller right
+// away, as is the response of the last attempt. newRequest is called for every attempt, since
+// a request body can only be read once.
+func DoWithRetry(client *http.Client, policy HTTPRetryPolicy, logger *Logger, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+		if err == nil && !isRetryableHTTPStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		if attempt >= policy.MaxAttempts {
+			return resp, err
+		}
+
+		delay := retryDelay(policy, attempt)
+		if err != nil {
+			logger.PrintVerbose("Request to %s failed (attempt %d of %d), retrying in %s: %s", req.URL.Host, attempt, policy.MaxAttempts, delay, err)
+		} else {
+			logger.PrintVerbose("Request to %s returned %s (attempt %d of %d), retrying in %s", req.URL.Host, resp.Status, attempt, policy.MaxAttempts, delay)
+			resp.Body.Close()
+		}
+
+		time.Sleep(delay)
+	}
+}
+
+func isRetryableHTTPStatus(statusCode int) bool {
+	return statusCode >= 500 || statusCode == http.StatusTooManyRequests
+}
+
+// Uses "equal jitter", i.e. the delay is randomly chosen between half and all of the backoff,
+// so that concurrent collectors don't retry in lockstep
+func retryDelay(policy HTTPRetryPolicy, attempt int) time.Duration {
+	backoff := policy.BaseDelay << uint(attempt-1)
+	if backoff > policy.MaxDelay || backoff <= 0 {
+		backoff = policy.MaxDelay
+	}
+
+	half := int64(backoff / 2)
+	if half <= 0 {
+		return backoff
+	}
+
+	return time.Duration(half + rand.Int63n(half+1))
+}
@@ -0,0 +1,71 @@
+package util_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pganalyze/collector/util"
+)
+
+var httpRetryTests = []struct {
+	statusCodes      []int
+	expectedStatus   int
+	expectedAttempts int
+}{
+	{
+		[]int{200},
+		200,
+		1,
+	},
+	{
+		[]int{503, 502, 200},
+		200,
+		3,
+	},
+	{
+		[]int{429, 200},
+		200,
+		2,
+	},
+	{
+		[]int{401},
+		401,
+		1,
+	},
+	{
+		[]int{500, 500, 500, 500, 500},
+		500,
+		3,
+	},
+}
+
+func TestDoWithRetry(t *testing.T) {
+	policy := util.HTTPRetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	logger := &util.Logger{}
+
+	for _, test := range httpRetryTests {
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.statusCodes[attempts])
+			attempts++
+		}))
+
+		resp, err := util.DoWithRetry(http.DefaultClient, policy, logger, func() (*http.Request, error) {
+			return http.NewRequest("GET", server.URL, nil)
+		})
+		server.Close()
+
+		if err != nil {
+			t.Errorf("DoWithRetry(%v): unexpected error %s", test.statusCodes, err)
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expectedStatus || attempts != test.expectedAttempts {
+			t.Errorf("DoWithRetry(%v): got status %d after %d attempts, expected status %d after %d attempts",
+				test.statusCodes, resp.StatusCode, attempts, test.expectedStatus, test.expectedAttempts)
+		}
+	}
+}
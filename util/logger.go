@@ -1,28 +1,107 @@
 package util
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 )
 
+// LogLevel - Minimum severity of messages that get printed (the zero value is info)
+type LogLevel int
+
+const (
+	LogLevelDebug LogLevel = iota - 1
+	LogLevelInfo
+	LogLevelWarning
+	LogLevelError
+)
+
+// ParseLogLevel - Parses a log level name (error, warn/warning, info, verbose/debug)
+func ParseLogLevel(name string) (LogLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "error":
+		return LogLevelError, nil
+	case "warn", "warning":
+		return LogLevelWarning, nil
+	case "info":
+		return LogLevelInfo, nil
+	case "verbose", "debug":
+		return LogLevelDebug, nil
+	}
+
+	return LogLevelInfo, fmt.Errorf("Unknown log level \"%s\", supported levels are error, warn, info and verbose/debug", name)
+}
+
 type Logger struct {
-	Verbose        bool
+	MinLevel       LogLevel
 	Quiet          bool
 	Prefix         *string
 	Destination    *log.Logger
 	RememberErrors bool
 	ErrorMessages  []string
+
+	// Also recorded when RememberErrors is set, e.g. to detect parts of a snapshot being skipped
+	WarningMessages []string
+
+	// Emit each log line as a JSON object instead of human-readable text
+	JSON bool
+
+	// Additional context included with each JSON log line (e.g. API key fingerprint)
+	Fields map[string]string
 }
 
 func (logger *Logger) WithPrefix(prefix string) *Logger {
-	return &Logger{Verbose: logger.Verbose, Quiet: logger.Quiet, Destination: logger.Destination, Prefix: &prefix}
+	return &Logger{MinLevel: logger.MinLevel, Quiet: logger.Quiet, Destination: logger.Destination, Prefix: &prefix, JSON: logger.JSON, Fields: logger.Fields}
 }
 
 func (logger *Logger) WithPrefixAndRememberErrors(prefix string) *Logger {
-	return &Logger{Verbose: logger.Verbose, Quiet: logger.Quiet, Destination: logger.Destination, Prefix: &prefix, RememberErrors: true}
+	return &Logger{MinLevel: logger.MinLevel, Quiet: logger.Quiet, Destination: logger.Destination, Prefix: &prefix, RememberErrors: true, JSON: logger.JSON, Fields: logger.Fields}
+}
+
+// WithField - Returns a copy of the logger that includes the given context field in JSON log lines
+func (logger *Logger) WithField(key string, value string) *Logger {
+	fields := make(map[string]string, len(logger.Fields)+1)
+	for k, v := range logger.Fields {
+		fields[k] = v
+	}
+	if value != "" {
+		fields[key] = value
+	}
+
+	newLogger := *logger
+	newLogger.Fields = fields
+	newLogger.ErrorMessages = nil
+	newLogger.WarningMessages = nil
+	return &newLogger
+}
+
+// WithLevel - Returns a copy of the logger using the named minimum log level, e.g. from a
+// per-server log_level setting (an empty or unknown name keeps the current level)
+func (logger *Logger) WithLevel(name string) *Logger {
+	newLogger := *logger
+	newLogger.ErrorMessages = nil
+	newLogger.WarningMessages = nil
+	if level, err := ParseLogLevel(name); err == nil {
+		newLogger.MinLevel = level
+	}
+	return &newLogger
+}
+
+var jsonLogLevels = map[string]string{
+	"V": "verbose",
+	"I": "info",
+	"W": "warning",
+	"E": "error",
 }
 
 func (logger *Logger) print(logLevel string, format string, args ...interface{}) {
+	if logger.JSON {
+		logger.printJSON(logLevel, fmt.Sprintf(format, args...))
+		return
+	}
+
 	if logger.Prefix != nil {
 		format = fmt.Sprintf("[%s] %s", *logger.Prefix, format)
 	}
@@ -32,8 +111,29 @@ func (logger *Logger) print(logLevel string, format string, args ...interface{})
 	logger.Destination.Printf(format, args...)
 }
 
+func (logger *Logger) printJSON(logLevel string, message string) {
+	line := make(map[string]string, len(logger.Fields)+4)
+	for k, v := range logger.Fields {
+		line[k] = v
+	}
+	line["level"] = jsonLogLevels[logLevel]
+	line["timestamp"] = time.Now().UTC().Format(time.RFC3339Nano)
+	line["message"] = message
+	if logger.Prefix != nil {
+		line["server"] = *logger.Prefix
+	}
+
+	lineJSON, err := json.Marshal(line)
+	if err != nil {
+		logger.Destination.Printf("%s %s", logLevel, message)
+		return
+	}
+
+	logger.Destination.Print(string(lineJSON))
+}
+
 func (logger *Logger) PrintVerbose(format string, args ...interface{}) {
-	if logger.Quiet || !logger.Verbose {
+	if logger.Quiet || logger.MinLevel > LogLevelDebug {
 		return
 	}
 
@@ -41,7 +141,7 @@ func (logger *Logger) PrintVerbose(format string, args ...interface{}) {
 }
 
 func (logger *Logger) PrintInfo(format string, args ...interface{}) {
-	if logger.Quiet {
+	if logger.Quiet || logger.MinLevel > LogLevelInfo {
 		return
 	}
 
@@ -49,6 +149,14 @@ func (logger *Logger) PrintInfo(format string, args ...interface{}) {
 }
 
 func (logger *Logger) PrintWarning(format string, args ...interface{}) {
+	if logger.RememberErrors {
+		logger.WarningMessages = append(logger.WarningMessages, fmt.Sprintf(format, args...))
+	}
+
+	if logger.MinLevel > LogLevelWarning {
+		return
+	}
+
 	logger.print("W", format, args...)
 }
 
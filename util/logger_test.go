@@ -0,0 +1,103 @@
+package util_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pganalyze/collector/util"
+)
+
+var jsonLoggerTests = []struct {
+	print    func(logger *util.Logger)
+	expected map[string]string
+}{
+	{
+		func(logger *util.Logger) { logger.PrintInfo("Submitted snapshot %d", 42) },
+		map[string]string{"level": "info", "message": "Submitted snapshot 42", "server": "default", "api_key_fingerprint": "abc123"},
+	},
+	{
+		func(logger *util.Logger) { logger.PrintError("Could not connect: %s", "timeout") },
+		map[string]string{"level": "error", "message": "Could not connect: timeout", "server": "default", "api_key_fingerprint": "abc123"},
+	},
+	{
+		func(logger *util.Logger) { logger.PrintVerbose("Not logged") },
+		nil,
+	},
+}
+
+func TestJSONLogger(t *testing.T) {
+	for _, test := range jsonLoggerTests {
+		var buf bytes.Buffer
+		logger := &util.Logger{Destination: log.New(&buf, "", 0), JSON: true}
+		prefixedLogger := logger.WithPrefix("default").WithField("api_key_fingerprint", "abc123")
+
+		test.print(prefixedLogger)
+
+		if test.expected == nil {
+			if buf.Len() != 0 {
+				t.Errorf("\nExpected no output\n actual: %s", buf.String())
+			}
+			continue
+		}
+
+		var actual map[string]string
+		err := json.Unmarshal(buf.Bytes(), &actual)
+		if err != nil {
+			t.Errorf("\nCould not parse output as JSON: %s\n output: %s", err, buf.String())
+			continue
+		}
+		if actual["timestamp"] == "" {
+			t.Errorf("\nExpected timestamp to be set\n actual: %s", buf.String())
+		}
+		delete(actual, "timestamp")
+
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("\nExpected: %v\n actual: %v", test.expected, actual)
+		}
+	}
+}
+
+var logLevelTests = []struct {
+	minLevel        string
+	expectedPrinted []string
+}{
+	{"error", []string{"E"}},
+	{"warn", []string{"W", "E"}},
+	{"info", []string{"I", "W", "E"}},
+	{"verbose", []string{"V", "I", "W", "E"}},
+	{"debug", []string{"V", "I", "W", "E"}},
+}
+
+func TestLogLevel(t *testing.T) {
+	for _, test := range logLevelTests {
+		var buf bytes.Buffer
+		logger := (&util.Logger{Destination: log.New(&buf, "", 0)}).WithLevel(test.minLevel)
+
+		logger.PrintVerbose("message")
+		logger.PrintInfo("message")
+		logger.PrintWarning("message")
+		logger.PrintError("message")
+
+		actual := []string{}
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line != "" {
+				actual = append(actual, strings.SplitN(line, " ", 2)[0])
+			}
+		}
+
+		if !reflect.DeepEqual(actual, test.expectedPrinted) {
+			t.Errorf("\nLevel: %s\nExpected: %v\n actual: %v", test.minLevel, test.expectedPrinted, actual)
+		}
+	}
+}
+
+func TestParseLogLevelInvalid(t *testing.T) {
+	_, err := util.ParseLogLevel("loud")
+	if err == nil {
+		t.Errorf("Expected error for unknown log level")
+	}
+}
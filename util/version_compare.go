@@ -0,0 +1,32 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CompareVersions - Compares two dotted version numbers (e.g. "0.12.0"), returning -1, 0 or 1
+//
+// Missing components count as zero, and anything after a "-" (pre-release suffixes) is ignored.
+func CompareVersions(a string, b string) int {
+	aParts := strings.Split(strings.SplitN(strings.TrimPrefix(a, "v"), "-", 2)[0], ".")
+	bParts := strings.Split(strings.SplitN(strings.TrimPrefix(b, "v"), "-", 2)[0], ".")
+
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aNum, bNum int
+		if i < len(aParts) {
+			aNum, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			bNum, _ = strconv.Atoi(bParts[i])
+		}
+		if aNum < bNum {
+			return -1
+		}
+		if aNum > bNum {
+			return 1
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,29 @@
+package util_test
+
+import (
+	"testing"
+
+	"github.com/pganalyze/collector/util"
+)
+
+var compareVersionsTests = []struct {
+	a        string
+	b        string
+	expected int
+}{
+	{"0.12.0", "0.12.0", 0},
+	{"0.12.0", "0.13.0", -1},
+	{"0.12.0", "0.9.1", 1},
+	{"0.12", "0.12.0", 0},
+	{"v0.12.1", "0.12.0", 1},
+	{"0.13.0-beta1", "0.13.0", 0},
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, test := range compareVersionsTests {
+		actual := util.CompareVersions(test.a, test.b)
+		if actual != test.expected {
+			t.Errorf("CompareVersions(%q, %q): expected %d, actual %d", test.a, test.b, test.expected, actual)
+		}
+	}
+}